@@ -0,0 +1,419 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Package aggregate calculates the standard aggregates of OPC-UA Part 13
+// from raw historical data.
+//
+// The server uses it to answer HistoryRead requests with
+// ReadProcessedDetails. Clients can use it to calculate processed values
+// locally from the raw values returned by HistoryReadRawModified if the
+// historian of a server does not support processed reads. The raw values
+// should be read with ReturnBounds set so that the values at the start and
+// the end of the processing intervals can be interpolated:
+//
+//	res, err := c.HistoryReadRawModified(ctx, nodes, &ua.ReadRawModifiedDetails{
+//		StartTime:    start,
+//		EndTime:      end,
+//		ReturnBounds: true,
+//	})
+//	...
+//	data := res.Results[0].HistoryData.Value.(*ua.HistoryData)
+//	avg, err := aggregate.Process(aggregate.Average, data.DataValues, start, end, time.Minute, aggregate.DefaultConfig())
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/
+package aggregate
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Node ids of the supported aggregate functions.
+var (
+	Interpolative               = ua.NewNumericNodeID(0, id.AggregateFunction_Interpolative)
+	Average                     = ua.NewNumericNodeID(0, id.AggregateFunction_Average)
+	TimeAverage                 = ua.NewNumericNodeID(0, id.AggregateFunction_TimeAverage)
+	TimeAverage2                = ua.NewNumericNodeID(0, id.AggregateFunction_TimeAverage2)
+	Total                       = ua.NewNumericNodeID(0, id.AggregateFunction_Total)
+	Minimum                     = ua.NewNumericNodeID(0, id.AggregateFunction_Minimum)
+	Maximum                     = ua.NewNumericNodeID(0, id.AggregateFunction_Maximum)
+	Range                       = ua.NewNumericNodeID(0, id.AggregateFunction_Range)
+	Count                       = ua.NewNumericNodeID(0, id.AggregateFunction_Count)
+	Start                       = ua.NewNumericNodeID(0, id.AggregateFunction_Start)
+	End                         = ua.NewNumericNodeID(0, id.AggregateFunction_End)
+	Delta                       = ua.NewNumericNodeID(0, id.AggregateFunction_Delta)
+	DurationGood                = ua.NewNumericNodeID(0, id.AggregateFunction_DurationGood)
+	DurationBad                 = ua.NewNumericNodeID(0, id.AggregateFunction_DurationBad)
+	PercentGood                 = ua.NewNumericNodeID(0, id.AggregateFunction_PercentGood)
+	PercentBad                  = ua.NewNumericNodeID(0, id.AggregateFunction_PercentBad)
+	StandardDeviationSample     = ua.NewNumericNodeID(0, id.AggregateFunction_StandardDeviationSample)
+	StandardDeviationPopulation = ua.NewNumericNodeID(0, id.AggregateFunction_StandardDeviationPopulation)
+	VarianceSample              = ua.NewNumericNodeID(0, id.AggregateFunction_VarianceSample)
+	VariancePopulation          = ua.NewNumericNodeID(0, id.AggregateFunction_VariancePopulation)
+)
+
+// Historian bits of the status code of a processed value. They are only
+// valid together with the DataValue info type bit which is part of the
+// constants.
+//
+// https://reference.opcfoundation.org/Core/Part11/v105/docs/6.3.2
+const (
+	HistorianCalculated   ua.StatusCode = 0x0401
+	HistorianInterpolated ua.StatusCode = 0x0402
+	HistorianPartial      ua.StatusCode = 0x0404
+)
+
+// Config controls how the aggregates are calculated.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/4.2.1.2
+type Config struct {
+	// TreatUncertainAsBad treats raw values with an uncertain status as bad.
+	TreatUncertainAsBad bool
+
+	// PercentDataBad is the minimum percentage of bad data in an
+	// interval which makes the status of the result bad.
+	PercentDataBad uint8
+
+	// PercentDataGood is the minimum percentage of good data in an
+	// interval which makes the status of the result good.
+	PercentDataGood uint8
+
+	// UseSlopedExtrapolation extrapolates the value after the last raw
+	// value from the slope of the last two values instead of holding
+	// the last value.
+	UseSlopedExtrapolation bool
+
+	// Stepped uses stepped interpolation between raw values instead of
+	// sloped (linear) interpolation. Values which are not numeric are
+	// always stepped.
+	Stepped bool
+}
+
+// DefaultConfig returns the default aggregate configuration.
+func DefaultConfig() Config {
+	return Config{
+		PercentDataBad:  100,
+		PercentDataGood: 100,
+	}
+}
+
+// ConfigFrom returns the configuration for the aggregate configuration of
+// a ReadProcessedDetails request.
+func ConfigFrom(c *ua.AggregateConfiguration, stepped bool) Config {
+	cfg := DefaultConfig()
+	if c != nil && !c.UseServerCapabilitiesDefaults {
+		cfg.TreatUncertainAsBad = c.TreatUncertainAsBad
+		cfg.PercentDataBad = c.PercentDataBad
+		cfg.PercentDataGood = c.PercentDataGood
+		cfg.UseSlopedExtrapolation = c.UseSlopedExtrapolation
+	}
+	cfg.Stepped = stepped
+	return cfg
+}
+
+// Supported returns true if the aggregate function is supported.
+func Supported(aggregateType *ua.NodeID) bool {
+	_, ok := lookup(aggregateType)
+	return ok
+}
+
+// Process calculates an aggregate for every processing interval between
+// start and end from the raw values of a variable. Values must be in
+// chronological order. If end is before start the results are returned in
+// reverse order. An interval of zero calculates a single value for the
+// whole time range.
+//
+// Values with the status StatusBadBoundNotFound or StatusBadNoData, as
+// returned by HistoryRead for missing bounds, are ignored.
+func Process(aggregateType *ua.NodeID, values []*ua.DataValue, start, end time.Time, interval time.Duration, cfg Config) ([]*ua.DataValue, error) {
+	fn, ok := lookup(aggregateType)
+	if !ok {
+		return nil, ua.StatusBadAggregateNotSupported
+	}
+	if start.IsZero() || end.IsZero() || start.Equal(end) {
+		return nil, ua.StatusBadInvalidTimestampArgument
+	}
+	if interval < 0 {
+		return nil, ua.StatusBadInvalidArgument
+	}
+	if cfg.PercentDataBad > 100 || cfg.PercentDataGood > 100 || int(cfg.PercentDataBad)+int(cfg.PercentDataGood) < 100 {
+		return nil, ua.StatusBadAggregateConfigurationRejected
+	}
+
+	lo, hi := start, end
+	reverse := end.Before(start)
+	if reverse {
+		lo, hi = end, start
+	}
+	if interval == 0 || interval > hi.Sub(lo) {
+		interval = hi.Sub(lo)
+	}
+
+	data := slices.DeleteFunc(slices.Clone(values), func(v *ua.DataValue) bool {
+		return v == nil || v.Status == ua.StatusBadBoundNotFound || v.Status == ua.StatusBadNoData
+	})
+
+	var results []*ua.DataValue
+	for s := lo; s.Before(hi); s = s.Add(interval) {
+		e := s.Add(interval)
+		partial := false
+		if e.After(hi) {
+			e, partial = hi, true
+		}
+		iv := &span{cfg: cfg, data: data, start: s, end: e}
+		iv.first = iv.search(s)
+		iv.last = iv.search(e)
+
+		v := fn(iv)
+		if partial && v.Status&HistorianCalculated == HistorianCalculated {
+			pv := *v
+			pv.Status |= HistorianPartial
+			v = &pv
+		}
+		results = append(results, v)
+	}
+	if reverse {
+		slices.Reverse(results)
+	}
+	return results, nil
+}
+
+// span is a single processing interval.
+type span struct {
+	cfg        Config
+	data       []*ua.DataValue
+	start, end time.Time
+
+	// data[first:last] are the raw values in [start, end)
+	first, last int
+}
+
+// raw returns the raw values of the interval.
+func (iv *span) raw() []*ua.DataValue {
+	return iv.data[iv.first:iv.last]
+}
+
+// search returns the index of the first value at or after t.
+func (iv *span) search(t time.Time) int {
+	return sort.Search(len(iv.data), func(i int) bool { return !ValueTime(iv.data[i]).Before(t) })
+}
+
+// good returns true if the value has a value and a status which is
+// considered good.
+func (iv *span) good(v *ua.DataValue) bool {
+	return v.Value != nil && v.Value.Value() != nil && iv.goodStatus(v.Status)
+}
+
+func (iv *span) goodStatus(s ua.StatusCode) bool {
+	switch {
+	case IsBad(s):
+		return false
+	case isUncertain(s):
+		return !iv.cfg.TreatUncertainAsBad
+	default:
+		return true
+	}
+}
+
+// number returns the numeric value of a good value.
+func (iv *span) number(v *ua.DataValue) (float64, bool) {
+	if !iv.good(v) {
+		return 0, false
+	}
+	return ToFloat(v.Value.Value())
+}
+
+// status calculates the status of a result from the fractions of good
+// and bad data in the interval.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.3.3
+func (iv *span) status(good, bad float64) ua.StatusCode {
+	switch {
+	case bad > 0 && bad*100 >= float64(iv.cfg.PercentDataBad):
+		return ua.StatusBad
+	case good*100 >= float64(iv.cfg.PercentDataGood):
+		return ua.StatusOK
+	default:
+		return ua.StatusUncertainDataSubNormal
+	}
+}
+
+// countStatus calculates the status of a result from the number of good
+// and bad raw values.
+func (iv *span) countStatus(good, bad int) ua.StatusCode {
+	n := float64(good + bad)
+	if n == 0 {
+		return ua.StatusOK
+	}
+	return iv.status(float64(good)/n, float64(bad)/n)
+}
+
+// durations returns how long the data in the interval was good and bad.
+// The status of a raw value lasts until the next raw value. The time
+// before the first raw value has the status of the value before the
+// interval or is bad if there is none.
+func (iv *span) durations() (good, bad time.Duration) {
+	t := iv.start
+	var prev *ua.DataValue
+	if iv.first > 0 {
+		prev = iv.data[iv.first-1]
+	}
+	add := func(to time.Time) {
+		if prev != nil && iv.goodStatus(prev.Status) {
+			good += to.Sub(t)
+		} else {
+			bad += to.Sub(t)
+		}
+	}
+	for _, v := range iv.raw() {
+		add(ValueTime(v))
+		t, prev = ValueTime(v), v
+	}
+	add(iv.end)
+	return good, bad
+}
+
+// timeStatus calculates the status of a result from the durations of good
+// and bad data in the interval.
+func (iv *span) timeStatus() ua.StatusCode {
+	good, bad := iv.durations()
+	total := float64(iv.end.Sub(iv.start))
+	return iv.status(float64(good)/total, float64(bad)/total)
+}
+
+// bound returns the interpolated bounding value at t. Bad values are
+// skipped and the bound is interpolated from the closest good values. The
+// status of the bound is uncertain if bad values were skipped or if the
+// value had to be extrapolated. It returns nil if there is no good value
+// at or before t.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/3.1.8
+func (iv *span) bound(t time.Time) *ua.DataValue {
+	i := iv.search(t)
+	exact := i < len(iv.data) && ValueTime(iv.data[i]).Equal(t)
+	if exact && iv.good(iv.data[i]) {
+		return iv.data[i]
+	}
+
+	// a bad value at t is replaced by the interpolated value
+	uncertain := exact
+	p := -1
+	for j := i - 1; j >= 0; j-- {
+		if iv.good(iv.data[j]) {
+			p = j
+			break
+		}
+		uncertain = true
+	}
+	if p < 0 {
+		return nil
+	}
+	q := -1
+	for j := i; j < len(iv.data); j++ {
+		if iv.good(iv.data[j]) {
+			q = j
+			break
+		}
+		if !iv.cfg.Stepped {
+			uncertain = true
+		}
+	}
+
+	prev := iv.data[p]
+	v := prev.Value
+	x0, num := ToFloat(prev.Value.Value())
+	switch {
+	case q < 0:
+		// extrapolate beyond the last good value.
+		uncertain = true
+		if !iv.cfg.Stepped && num && iv.cfg.UseSlopedExtrapolation {
+			for j := p - 1; j >= 0; j-- {
+				if x, ok := iv.number(iv.data[j]); ok {
+					v = slope(iv.data[j], x, prev, x0, t)
+					break
+				}
+			}
+		}
+	case !iv.cfg.Stepped && num:
+		if x1, ok := ToFloat(iv.data[q].Value.Value()); ok {
+			v = slope(prev, x0, iv.data[q], x1, t)
+		}
+	}
+
+	status := ua.StatusOK
+	if uncertain {
+		status = ua.StatusUncertainDataSubNormal
+	}
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+		Value:           v,
+		Status:          status | HistorianInterpolated,
+		SourceTimestamp: t,
+	}
+}
+
+// simpleBound returns the simple bounding value at t which is calculated
+// from the values right before and after t regardless of their status. The
+// status of the bound is the status of the value before t. It returns nil
+// if there is no value at or before t.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/3.1.9
+func (iv *span) simpleBound(t time.Time) *ua.DataValue {
+	i := iv.search(t)
+	if i < len(iv.data) && ValueTime(iv.data[i]).Equal(t) {
+		return iv.data[i]
+	}
+	if i == 0 {
+		return nil
+	}
+
+	prev := iv.data[i-1]
+	v := prev.Value
+	if i < len(iv.data) && !iv.cfg.Stepped && iv.good(prev) && iv.good(iv.data[i]) {
+		x0, ok0 := ToFloat(prev.Value.Value())
+		x1, ok1 := ToFloat(iv.data[i].Value.Value())
+		if ok0 && ok1 {
+			v = slope(prev, x0, iv.data[i], x1, t)
+		}
+	}
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+		Value:           v,
+		Status:          prev.Status,
+		SourceTimestamp: t,
+	}
+}
+
+// slope returns the value at t on the line through the two values.
+func slope(v0 *ua.DataValue, x0 float64, v1 *ua.DataValue, x1 float64, t time.Time) *ua.Variant {
+	t0, t1 := ValueTime(v0), ValueTime(v1)
+	if !t1.After(t0) {
+		return v0.Value
+	}
+	x := x0 + (x1-x0)*float64(t.Sub(t0))/float64(t1.Sub(t0))
+	return variant(FromFloat(x, v0.Value.Value()))
+}
+
+// ValueTime returns the timestamp by which a value is ordered in the
+// history: the source timestamp or the server timestamp if the value has no
+// source timestamp.
+func ValueTime(v *ua.DataValue) time.Time {
+	if v.SourceTimestamp.IsZero() {
+		return v.ServerTimestamp
+	}
+	return v.SourceTimestamp
+}
+
+// IsBad returns true if the severity of the status code is bad.
+func IsBad(s ua.StatusCode) bool {
+	return s&0x80000000 != 0
+}
+
+func isUncertain(s ua.StatusCode) bool {
+	return s&0xC0000000 == 0x40000000
+}
@@ -0,0 +1,301 @@
+package aggregate
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func raw(sec int, v any, status ua.StatusCode) *ua.DataValue {
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+		Value:           ua.MustVariant(v),
+		Status:          status,
+		SourceTimestamp: t0.Add(time.Duration(sec) * time.Second),
+	}
+}
+
+// testData has good values at 0s, 10s, 20s and 40s and a bad value at 30s.
+var testData = []*ua.DataValue{
+	raw(0, 10.0, ua.StatusOK),
+	raw(10, 20.0, ua.StatusOK),
+	raw(20, 30.0, ua.StatusOK),
+	raw(30, 0.0, ua.StatusBad),
+	raw(40, 50.0, ua.StatusOK),
+}
+
+type result struct {
+	v      any
+	status ua.StatusCode
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name     string
+		agg      *ua.NodeID
+		interval time.Duration
+		cfg      Config
+		want     []result
+	}{
+		{
+			name:     "interpolative",
+			agg:      Interpolative,
+			interval: 15 * time.Second,
+			want: []result{
+				{10.0, ua.StatusOK},
+				{25.0, ua.StatusOK | HistorianInterpolated},
+				{40.0, ua.StatusUncertainDataSubNormal | HistorianInterpolated},
+			},
+		},
+		{
+			name:     "interpolative stepped",
+			agg:      Interpolative,
+			interval: 15 * time.Second,
+			cfg:      Config{PercentDataBad: 100, PercentDataGood: 100, Stepped: true},
+			want: []result{
+				{10.0, ua.StatusOK},
+				{20.0, ua.StatusOK | HistorianInterpolated},
+				{30.0, ua.StatusUncertainDataSubNormal | HistorianInterpolated},
+			},
+		},
+		{
+			name:     "average",
+			agg:      Average,
+			interval: 20 * time.Second,
+			want: []result{
+				{15.0, ua.StatusOK | HistorianCalculated},
+				{30.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{50.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "average percent data good",
+			agg:      Average,
+			interval: 20 * time.Second,
+			cfg:      Config{PercentDataBad: 50, PercentDataGood: 50},
+			want: []result{
+				{15.0, ua.StatusOK | HistorianCalculated},
+				{nil, ua.StatusBad},
+				{50.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "time average",
+			agg:      TimeAverage,
+			interval: 20 * time.Second,
+			want: []result{
+				{20.0, ua.StatusOK | HistorianCalculated},
+				{40.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{50.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "time average 2",
+			agg:      TimeAverage2,
+			interval: 20 * time.Second,
+			want: []result{
+				{20.0, ua.StatusOK | HistorianCalculated},
+				{30.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{50.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "minimum",
+			agg:      Minimum,
+			interval: 20 * time.Second,
+			want: []result{
+				{10.0, ua.StatusOK | HistorianCalculated},
+				{30.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{50.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "maximum",
+			agg:      Maximum,
+			interval: 20 * time.Second,
+			want: []result{
+				{20.0, ua.StatusOK | HistorianCalculated},
+				{30.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{50.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "count",
+			agg:      Count,
+			interval: 20 * time.Second,
+			want: []result{
+				{int32(2), ua.StatusOK | HistorianCalculated},
+				{int32(1), ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{int32(1), ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "start",
+			agg:      Start,
+			interval: 20 * time.Second,
+			want: []result{
+				{10.0, ua.StatusOK},
+				{30.0, ua.StatusOK},
+				{50.0, ua.StatusOK},
+			},
+		},
+		{
+			name:     "end",
+			agg:      End,
+			interval: 20 * time.Second,
+			want: []result{
+				{20.0, ua.StatusOK},
+				{0.0, ua.StatusBad},
+				{50.0, ua.StatusOK},
+			},
+		},
+		{
+			name:     "delta",
+			agg:      Delta,
+			interval: 20 * time.Second,
+			want: []result{
+				{10.0, ua.StatusOK | HistorianCalculated},
+				{0.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{0.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "duration good",
+			agg:      DurationGood,
+			interval: 20 * time.Second,
+			want: []result{
+				{20000.0, ua.StatusOK | HistorianCalculated},
+				{10000.0, ua.StatusOK | HistorianCalculated},
+				{5000.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "duration bad",
+			agg:      DurationBad,
+			interval: 20 * time.Second,
+			want: []result{
+				{0.0, ua.StatusOK | HistorianCalculated},
+				{10000.0, ua.StatusOK | HistorianCalculated},
+				{0.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "percent good",
+			agg:      PercentGood,
+			interval: 20 * time.Second,
+			want: []result{
+				{100.0, ua.StatusOK | HistorianCalculated},
+				{50.0, ua.StatusOK | HistorianCalculated},
+				{100.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "standard deviation",
+			agg:      StandardDeviationPopulation,
+			interval: 20 * time.Second,
+			want: []result{
+				{5.0, ua.StatusOK | HistorianCalculated},
+				{0.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{0.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+		{
+			name:     "variance sample",
+			agg:      VarianceSample,
+			interval: 20 * time.Second,
+			want: []result{
+				{50.0, ua.StatusOK | HistorianCalculated},
+				{0.0, ua.StatusUncertainDataSubNormal | HistorianCalculated},
+				{0.0, ua.StatusOK | HistorianCalculated | HistorianPartial},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if cfg == (Config{}) {
+				cfg = DefaultConfig()
+			}
+			res, err := Process(tt.agg, testData, t0, t0.Add(45*time.Second), tt.interval, cfg)
+			require.NoError(t, err)
+
+			var got []result
+			for _, v := range res {
+				var x any
+				if v.Value != nil {
+					x = v.Value.Value()
+				}
+				got = append(got, result{x, v.Status})
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProcessReverse(t *testing.T) {
+	res, err := Process(Average, testData, t0.Add(40*time.Second), t0, 20*time.Second, DefaultConfig())
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, t0.Add(20*time.Second), res[0].SourceTimestamp)
+	require.Equal(t, t0, res[1].SourceTimestamp)
+	require.Equal(t, 15.0, res[1].Value.Value())
+}
+
+func TestProcessUnsigned(t *testing.T) {
+	values := []*ua.DataValue{
+		raw(0, uint32(30), ua.StatusOK),
+		raw(10, uint32(10), ua.StatusOK),
+	}
+	res, err := Process(Delta, values, t0, t0.Add(20*time.Second), 0, DefaultConfig())
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, int64(-20), res[0].Value.Value())
+
+	res, err = Process(Range, values, t0, t0.Add(20*time.Second), 0, DefaultConfig())
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, uint32(20), res[0].Value.Value())
+}
+
+func TestFromFloat(t *testing.T) {
+	require.Equal(t, uint8(0), FromFloat(-1, uint8(0)))
+	require.Equal(t, uint8(255), FromFloat(300, uint8(0)))
+	require.Equal(t, int16(-32768), FromFloat(-1e6, int16(0)))
+	require.Equal(t, uint64(0), FromFloat(-1, uint64(0)))
+	require.Equal(t, uint64(math.MaxUint64), FromFloat(1e20, uint64(0)))
+	require.Equal(t, int64(math.MaxInt64), FromFloat(1e19, int64(0)))
+	require.Equal(t, int32(3), FromFloat(2.6, int32(0)))
+}
+
+func TestProcessNoData(t *testing.T) {
+	res, err := Process(Average, testData, t0.Add(-time.Minute), t0, 0, DefaultConfig())
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, ua.StatusBadNoData, res[0].Status)
+}
+
+func TestProcessErrors(t *testing.T) {
+	_, err := Process(ua.NewNumericNodeID(0, 1), testData, t0, t0.Add(time.Minute), 0, DefaultConfig())
+	require.Equal(t, ua.StatusBadAggregateNotSupported, err)
+
+	_, err = Process(Average, testData, t0, t0, 0, DefaultConfig())
+	require.Equal(t, ua.StatusBadInvalidTimestampArgument, err)
+
+	_, err = Process(Average, testData, t0, t0.Add(time.Minute), 0, Config{PercentDataBad: 20, PercentDataGood: 20})
+	require.Equal(t, ua.StatusBadAggregateConfigurationRejected, err)
+}
+
+func TestConfigFrom(t *testing.T) {
+	require.Equal(t, DefaultConfig(), ConfigFrom(nil, false))
+	require.Equal(t, DefaultConfig(), ConfigFrom(&ua.AggregateConfiguration{UseServerCapabilitiesDefaults: true, PercentDataBad: 1}, false))
+	require.Equal(t,
+		Config{TreatUncertainAsBad: true, PercentDataBad: 80, PercentDataGood: 80, Stepped: true},
+		ConfigFrom(&ua.AggregateConfiguration{TreatUncertainAsBad: true, PercentDataBad: 80, PercentDataGood: 80}, true),
+	)
+}
@@ -0,0 +1,514 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package aggregate
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// aggregateFunc calculates the result of an aggregate for a single
+// processing interval.
+type aggregateFunc func(iv *span) *ua.DataValue
+
+var funcs = map[uint32]aggregateFunc{
+	id.AggregateFunction_Interpolative:               interpolative,
+	id.AggregateFunction_Average:                     average,
+	id.AggregateFunction_TimeAverage:                 timeAverage,
+	id.AggregateFunction_TimeAverage2:                timeAverage2,
+	id.AggregateFunction_Total:                       total,
+	id.AggregateFunction_Minimum:                     minimum,
+	id.AggregateFunction_Maximum:                     maximum,
+	id.AggregateFunction_Range:                       valueRange,
+	id.AggregateFunction_Count:                       count,
+	id.AggregateFunction_Start:                       start,
+	id.AggregateFunction_End:                         end,
+	id.AggregateFunction_Delta:                       delta,
+	id.AggregateFunction_DurationGood:                durationGood,
+	id.AggregateFunction_DurationBad:                 durationBad,
+	id.AggregateFunction_PercentGood:                 percentGood,
+	id.AggregateFunction_PercentBad:                  percentBad,
+	id.AggregateFunction_StandardDeviationSample:     deviation(true, true),
+	id.AggregateFunction_StandardDeviationPopulation: deviation(false, true),
+	id.AggregateFunction_VarianceSample:              deviation(true, false),
+	id.AggregateFunction_VariancePopulation:          deviation(false, false),
+}
+
+func lookup(aggregateType *ua.NodeID) (aggregateFunc, bool) {
+	if aggregateType == nil || aggregateType.Namespace() != 0 {
+		return nil, false
+	}
+	fn, ok := funcs[aggregateType.IntID()]
+	return fn, ok
+}
+
+// interpolative returns the interpolated value at the start of the interval.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.2
+func interpolative(iv *span) *ua.DataValue {
+	b := iv.bound(iv.start)
+	if b == nil {
+		return iv.noData()
+	}
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+		Value:           b.Value,
+		Status:          b.Status,
+		SourceTimestamp: iv.start,
+	}
+}
+
+// average returns the arithmetic mean of the good raw values.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.3
+func average(iv *span) *ua.DataValue {
+	xs, bad := iv.numbers()
+	if len(xs) == 0 {
+		return iv.noData()
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return iv.result(sum/float64(len(xs)), iv.countStatus(len(xs), bad))
+}
+
+// timeAverage returns the time weighted average of the data in the
+// interval using interpolated bounding values.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.4
+func timeAverage(iv *span) *ua.DataValue {
+	var points []*ua.DataValue
+	if b := iv.bound(iv.start); b != nil {
+		points = append(points, b)
+	}
+	for _, v := range iv.raw() {
+		if iv.good(v) && ValueTime(v).After(iv.start) {
+			points = append(points, v)
+		}
+	}
+	if len(points) == 0 {
+		return iv.noData()
+	}
+	// there is a good value before the end so the bound exists
+	points = append(points, iv.bound(iv.end))
+
+	sum, d := iv.area(points, func(*ua.DataValue) bool { return true })
+	if d == 0 {
+		return iv.noData()
+	}
+	return iv.result(sum/float64(d), iv.timeStatus())
+}
+
+// timeAverage2 returns the time weighted average of the good data in the
+// interval using simple bounding values.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.5
+func timeAverage2(iv *span) *ua.DataValue {
+	sum, d, ok := iv.area2()
+	if !ok {
+		return iv.noData()
+	}
+	return iv.result(sum/float64(d), iv.timeStatus())
+}
+
+// total returns the time integral of the good data in the interval in
+// value seconds.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.6
+func total(iv *span) *ua.DataValue {
+	sum, _, ok := iv.area2()
+	if !ok {
+		return iv.noData()
+	}
+	return iv.result(sum/float64(time.Second), iv.timeStatus())
+}
+
+// minimum returns the smallest good raw value with its timestamp.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.7
+func minimum(iv *span) *ua.DataValue {
+	return iv.extreme(func(x, y float64) bool { return x < y })
+}
+
+// maximum returns the largest good raw value with its timestamp.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.8
+func maximum(iv *span) *ua.DataValue {
+	return iv.extreme(func(x, y float64) bool { return x > y })
+}
+
+// valueRange returns the difference between the largest and the smallest
+// good raw value.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.13
+func valueRange(iv *span) *ua.DataValue {
+	lo, hi := minimum(iv), maximum(iv)
+	if IsBad(lo.Status) {
+		return lo
+	}
+	x, _ := ToFloat(lo.Value.Value())
+	y, _ := ToFloat(hi.Value.Value())
+	return iv.result(FromFloat(y-x, lo.Value.Value()), lo.Status)
+}
+
+// count returns the number of good raw values.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.20
+func count(iv *span) *ua.DataValue {
+	good, bad := 0, 0
+	for _, v := range iv.raw() {
+		if iv.good(v) {
+			good++
+		} else {
+			bad++
+		}
+	}
+	return iv.result(int32(good), iv.countStatus(good, bad))
+}
+
+// start returns the first raw value of the interval.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.26
+func start(iv *span) *ua.DataValue {
+	raw := iv.raw()
+	if len(raw) == 0 {
+		return iv.noData()
+	}
+	return raw[0]
+}
+
+// end returns the last raw value of the interval.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.27
+func end(iv *span) *ua.DataValue {
+	raw := iv.raw()
+	if len(raw) == 0 {
+		return iv.noData()
+	}
+	return raw[len(raw)-1]
+}
+
+// delta returns the difference between the last and the first good raw
+// value of the interval. The status is uncertain if there are bad values
+// before the first or after the last good value.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.30
+func delta(iv *span) *ua.DataValue {
+	raw := iv.raw()
+	first, last := -1, -1
+	for i, v := range raw {
+		if _, ok := iv.number(v); ok {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return iv.noData()
+	}
+	x, _ := iv.number(raw[first])
+	y, _ := iv.number(raw[last])
+	status := ua.StatusOK
+	if first > 0 || last < len(raw)-1 {
+		status = ua.StatusUncertainDataSubNormal
+	}
+	// the delta of unsigned values can be negative.
+	return iv.result(FromFloat(y-x, signed(raw[first].Value.Value())), status)
+}
+
+// durationGood returns the time in milliseconds during which the data was
+// good.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.22
+func durationGood(iv *span) *ua.DataValue {
+	good, _ := iv.durations()
+	return iv.result(ms(good), ua.StatusOK)
+}
+
+// durationBad returns the time in milliseconds during which the data was
+// bad.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.23
+func durationBad(iv *span) *ua.DataValue {
+	_, bad := iv.durations()
+	return iv.result(ms(bad), ua.StatusOK)
+}
+
+// percentGood returns the percentage of time during which the data was
+// good.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.24
+func percentGood(iv *span) *ua.DataValue {
+	good, _ := iv.durations()
+	return iv.result(100*float64(good)/float64(iv.end.Sub(iv.start)), ua.StatusOK)
+}
+
+// percentBad returns the percentage of time during which the data was
+// bad.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.25
+func percentBad(iv *span) *ua.DataValue {
+	_, bad := iv.durations()
+	return iv.result(100*float64(bad)/float64(iv.end.Sub(iv.start)), ua.StatusOK)
+}
+
+// deviation returns the standard deviation or the variance of the good raw
+// values. sample selects the sample over the population statistic.
+//
+// https://reference.opcfoundation.org/Core/Part13/v105/docs/5.4.3.37
+func deviation(sample, sqrt bool) aggregateFunc {
+	return func(iv *span) *ua.DataValue {
+		xs, bad := iv.numbers()
+		if len(xs) == 0 {
+			return iv.noData()
+		}
+		var mean float64
+		for _, x := range xs {
+			mean += x
+		}
+		mean /= float64(len(xs))
+
+		var sum float64
+		for _, x := range xs {
+			sum += (x - mean) * (x - mean)
+		}
+		n := float64(len(xs))
+		if sample {
+			n--
+		}
+		v := 0.0
+		if n > 0 {
+			v = sum / n
+		}
+		if sqrt {
+			v = math.Sqrt(v)
+		}
+		return iv.result(v, iv.countStatus(len(xs), bad))
+	}
+}
+
+// result returns a calculated value with the start of the interval as
+// timestamp. Values with a bad status are dropped.
+func (iv *span) result(v any, status ua.StatusCode) *ua.DataValue {
+	if IsBad(status) {
+		return &ua.DataValue{
+			EncodingMask:    ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+			Status:          status,
+			SourceTimestamp: iv.start,
+		}
+	}
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+		Value:           variant(v),
+		Status:          status | HistorianCalculated,
+		SourceTimestamp: iv.start,
+	}
+}
+
+// noData returns the result for an interval without usable data.
+func (iv *span) noData() *ua.DataValue {
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+		Status:          ua.StatusBadNoData,
+		SourceTimestamp: iv.start,
+	}
+}
+
+// numbers returns the good numeric raw values of the interval and the
+// number of the other raw values.
+func (iv *span) numbers() (xs []float64, bad int) {
+	for _, v := range iv.raw() {
+		if x, ok := iv.number(v); ok {
+			xs = append(xs, x)
+		} else {
+			bad++
+		}
+	}
+	return xs, bad
+}
+
+// extreme returns the good raw value for which less returns true compared
+// to all other good raw values.
+func (iv *span) extreme(less func(x, y float64) bool) *ua.DataValue {
+	var best *ua.DataValue
+	var bx float64
+	bad := 0
+	raw := iv.raw()
+	for _, v := range raw {
+		x, ok := iv.number(v)
+		switch {
+		case !ok:
+			bad++
+		case best == nil || less(x, bx):
+			best, bx = v, x
+		}
+	}
+	if best == nil {
+		return iv.noData()
+	}
+	status := iv.countStatus(len(raw)-bad, bad)
+	if IsBad(status) {
+		return iv.result(nil, status)
+	}
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp,
+		Value:           best.Value,
+		Status:          status | HistorianCalculated,
+		SourceTimestamp: ValueTime(best),
+	}
+}
+
+// area returns the time integral over the segments between consecutive
+// points which start with a point for which good returns true and the
+// total duration of these segments.
+func (iv *span) area(points []*ua.DataValue, good func(*ua.DataValue) bool) (sum float64, d time.Duration) {
+	for i := 0; i+1 < len(points); i++ {
+		p, q := points[i], points[i+1]
+		if !good(p) {
+			continue
+		}
+		x, ok := ToFloat(p.Value.Value())
+		if !ok {
+			continue
+		}
+		dt := ValueTime(q).Sub(ValueTime(p))
+		if y, ok := ToFloat(q.Value.Value()); ok && !iv.cfg.Stepped && good(q) {
+			sum += (x + y) / 2 * float64(dt)
+		} else {
+			sum += x * float64(dt)
+		}
+		d += dt
+	}
+	return sum, d
+}
+
+// area2 returns the time integral of the good data in the interval using
+// simple bounding values and the duration of the good data.
+func (iv *span) area2() (sum float64, d time.Duration, ok bool) {
+	var points []*ua.DataValue
+	if b := iv.simpleBound(iv.start); b != nil {
+		points = append(points, b)
+	}
+	for _, v := range iv.raw() {
+		if ValueTime(v).After(iv.start) {
+			points = append(points, v)
+		}
+	}
+	if len(points) == 0 {
+		return 0, 0, false
+	}
+	if b := iv.simpleBound(iv.end); b != nil {
+		points = append(points, b)
+	}
+	sum, d = iv.area(points, func(v *ua.DataValue) bool { _, ok := iv.number(v); return ok })
+	return sum, d, d > 0
+}
+
+// ms returns the duration in milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func variant(v any) *ua.Variant {
+	if v == nil {
+		return nil
+	}
+	return ua.MustVariant(v)
+}
+
+// ToFloat converts a numeric value to float64. It returns false if v is
+// not a number.
+func ToFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int8:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	default:
+		return 0, false
+	}
+}
+
+// FromFloat converts f to the numeric type of like. Values outside of the
+// range of an integer type are clamped to it since Go does not define the
+// conversion of such values.
+func FromFloat(f float64, like any) any {
+	switch like.(type) {
+	case int8:
+		return int8(clamp(f, math.MinInt8, math.MaxInt8))
+	case uint8:
+		return uint8(clamp(f, 0, math.MaxUint8))
+	case int16:
+		return int16(clamp(f, math.MinInt16, math.MaxInt16))
+	case uint16:
+		return uint16(clamp(f, 0, math.MaxUint16))
+	case int32:
+		return int32(clamp(f, math.MinInt32, math.MaxInt32))
+	case uint32:
+		return uint32(clamp(f, 0, math.MaxUint32))
+	case int64:
+		// the limits of the 64 bit types are not exact as float64.
+		switch f = math.Round(f); {
+		case f >= math.MaxInt64:
+			return int64(math.MaxInt64)
+		case f <= math.MinInt64:
+			return int64(math.MinInt64)
+		}
+		return int64(f)
+	case uint64:
+		switch f = math.Round(f); {
+		case f >= math.MaxUint64:
+			return uint64(math.MaxUint64)
+		case f <= 0:
+			return uint64(0)
+		}
+		return uint64(f)
+	case float32:
+		return float32(f)
+	default:
+		return f
+	}
+}
+
+// clamp rounds f to an integer in [lo, hi].
+func clamp(f, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, math.Round(f)))
+}
+
+// signed returns the zero value of a signed type which can hold the
+// differences of the values of the unsigned type of v or v itself.
+func signed(v any) any {
+	switch v.(type) {
+	case uint8:
+		return int16(0)
+	case uint16:
+		return int32(0)
+	case uint32, uint64:
+		return int64(0)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package opcua
+
+import (
+	"bytes"
+	"crypto/x509"
+	"net/url"
+	"sync"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
+	"github.com/gopcua/opcua/uapolicy"
+)
+
+// CertificateTrust is the decision about a server certificate which failed
+// the validation.
+type CertificateTrust int
+
+const (
+	// RejectCertificate rejects the certificate.
+	RejectCertificate CertificateTrust = iota
+
+	// TrustCertificateOnce accepts the certificate for this connection.
+	TrustCertificateOnce
+
+	// TrustCertificateAlways accepts the certificate for the lifetime of
+	// the client and adds it to the trust store if there is one.
+	TrustCertificateAlways
+)
+
+// CertificateTrustFunc decides about a server certificate which failed the
+// validation. err is the ua.StatusCode of the failure, e.g.
+// BadCertificateUntrusted or BadCertificateHostNameInvalid.
+type CertificateTrustFunc func(cert *x509.Certificate, err error) CertificateTrust
+
+// certVerifier validates the certificates of servers.
+type certVerifier struct {
+	store *uacert.Store
+	roots *x509.CertPool
+	trust CertificateTrustFunc
+
+	// mu protects trusted.
+	mu sync.Mutex
+
+	// trusted are the thumbprints of the certificates which the trust
+	// func accepted always.
+	trusted map[string]bool
+}
+
+// verifier returns the certificate verifier of the configuration and
+// creates it if necessary.
+func (cfg *Config) verifier() *certVerifier {
+	if cfg.certs == nil {
+		cfg.certs = &certVerifier{}
+	}
+	return cfg.certs
+}
+
+// verify validates the chain, the validity, the key usage and the host
+// name of the certificate of the server at the host.
+func (v *certVerifier) verify(cert []byte, host string) error {
+	leaf, err := uapolicy.ParseCertificate(cert)
+	if err != nil {
+		return ua.StatusBadCertificateInvalid
+	}
+	if v.isTrusted(leaf) {
+		return nil
+	}
+
+	err = v.verifyChain(cert)
+	if err == nil {
+		err = uacert.VerifyUsage(cert, x509.ExtKeyUsageServerAuth)
+	}
+	if err == nil {
+		err = uacert.VerifyHostname(cert, host)
+	}
+	return v.decide(leaf, err)
+}
+
+// verifyApplicationURI checks that the certificate of the server contains
+// the application uri of the server.
+func (v *certVerifier) verifyApplicationURI(cert []byte, uri string) error {
+	leaf, err := uapolicy.ParseCertificate(cert)
+	if err != nil {
+		return ua.StatusBadCertificateInvalid
+	}
+	if v.isTrusted(leaf) {
+		return nil
+	}
+	return v.decide(leaf, uacert.VerifyApplicationURI(cert, uri))
+}
+
+// verifyChain validates the certificate with the trusted certificates and
+// then with the trust store. Without both no certificate is trusted.
+func (v *certVerifier) verifyChain(cert []byte) error {
+	if v.roots != nil || v.store == nil {
+		err := uacert.VerifyChain(cert, v.roots)
+		if err == nil || v.store == nil {
+			return err
+		}
+	}
+	return v.store.Verify(cert)
+}
+
+// decide asks the trust func about a certificate which failed the
+// validation with err.
+func (v *certVerifier) decide(leaf *x509.Certificate, err error) error {
+	if err == nil || v.trust == nil {
+		return err
+	}
+	switch v.trust(leaf, err) {
+	case TrustCertificateOnce:
+		return nil
+	case TrustCertificateAlways:
+		v.mu.Lock()
+		if v.trusted == nil {
+			v.trusted = make(map[string]bool)
+		}
+		v.trusted[string(uapolicy.Thumbprint(leaf.Raw))] = true
+		v.mu.Unlock()
+		if v.store != nil {
+			return v.store.Trust(leaf.Raw)
+		}
+		return nil
+	default:
+		return err
+	}
+}
+
+// isTrusted returns true if the trust func accepted the certificate
+// always.
+func (v *certVerifier) isTrusted(leaf *x509.Certificate) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.trusted[string(uapolicy.Thumbprint(leaf.Raw))]
+}
+
+// verifyServerCertificate validates the certificate of the server before
+// the secure channel is opened if the client has a certificate verifier
+// and the security policy is not None.
+func (c *Client) verifyServerCertificate() error {
+	v := c.cfg.certs
+	if v == nil || c.cfg.sechan.SecurityPolicyURI == ua.SecurityPolicyURINone {
+		return nil
+	}
+	u, err := url.Parse(c.endpointURL)
+	if err != nil {
+		return err
+	}
+	return v.verify(c.cfg.sechan.RemoteCertificate, u.Hostname())
+}
+
+// verifySessionCertificate checks that the server certificate of a
+// CreateSession response is the validated certificate of the secure
+// channel and that it contains the application uri of the server.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.6.2
+func (c *Client) verifySessionCertificate(res *ua.CreateSessionResponse) error {
+	v := c.cfg.certs
+	if v == nil || c.cfg.sechan.SecurityPolicyURI == ua.SecurityPolicyURINone {
+		return nil
+	}
+	if !bytes.Equal(uapolicy.Thumbprint(res.ServerCertificate), uapolicy.Thumbprint(c.cfg.sechan.RemoteCertificate)) {
+		return ua.StatusBadCertificateInvalid
+	}
+	for _, ep := range res.ServerEndpoints {
+		if ep.Server != nil && ep.Server.ApplicationURI != "" {
+			return v.verifyApplicationURI(res.ServerCertificate, ep.Server.ApplicationURI)
+		}
+	}
+	return nil
+}
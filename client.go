@@ -24,6 +24,7 @@ import (
 	"github.com/gopcua/opcua/stats"
 	"github.com/gopcua/opcua/ua"
 	"github.com/gopcua/opcua/uacp"
+	"github.com/gopcua/opcua/uapolicy"
 	"github.com/gopcua/opcua/uasc"
 )
 
@@ -617,6 +618,10 @@ func (c *Client) Dial(ctx context.Context) error {
 		return errors.Errorf("secure channel already connected")
 	}
 
+	if err := c.verifyServerCertificate(); err != nil {
+		return err
+	}
+
 	var err error
 	c.conn, err = c.cfg.dialer.Dial(ctx, c.endpointURL)
 	if err != nil {
@@ -759,6 +764,11 @@ type Session struct {
 	// and User Authorization
 	serverNonce []byte
 
+	// serverEphemeralKey is the ephemeral key received from the server during
+	// Create and Activate Session response. Used to encrypt the secrets of the
+	// user identity tokens of the ECC security policies.
+	serverEphemeralKey *ua.EphemeralKeyType
+
 	// revisedTimeout is the actual maximum time that a Session shall remain open without activity.
 	revisedTimeout time.Duration
 }
@@ -803,6 +813,9 @@ func (c *Client) CreateSession(ctx context.Context, cfg *uasc.SessionConfig) (*S
 		ClientCertificate:       c.cfg.sechan.Certificate,
 		RequestedSessionTimeout: float64(cfg.SessionTimeout / time.Millisecond),
 	}
+	if uri := ecdhPolicyURI(cfg, sc); uri != "" {
+		req.RequestHeader = &ua.RequestHeader{AdditionalHeader: ua.NewAdditionalParameter(ua.ECDHPolicyURIParameter, ua.MustVariant(uri))}
+	}
 
 	var s *Session
 	// for the CreateSessionRequest the authToken is always nil.
@@ -819,6 +832,10 @@ func (c *Client) CreateSession(ctx context.Context, cfg *uasc.SessionConfig) (*S
 			return nil
 		}
 
+		if err := c.verifySessionCertificate(res); err != nil {
+			return err
+		}
+
 		// Ensure we have a valid identity token that the server will accept before trying to activate a session
 		if c.cfg.session.UserIdentityToken == nil {
 			opt := AuthAnonymous()
@@ -836,11 +853,12 @@ func (c *Client) CreateSession(ctx context.Context, cfg *uasc.SessionConfig) (*S
 		}
 
 		s = &Session{
-			cfg:               cfg,
-			resp:              res,
-			serverNonce:       res.ServerNonce,
-			serverCertificate: res.ServerCertificate,
-			revisedTimeout:    time.Duration(res.RevisedSessionTimeout) * time.Millisecond,
+			cfg:                cfg,
+			resp:               res,
+			serverNonce:        res.ServerNonce,
+			serverEphemeralKey: ecdhKey(res.ResponseHeader),
+			serverCertificate:  res.ServerCertificate,
+			revisedTimeout:     time.Duration(res.RevisedSessionTimeout) * time.Millisecond,
 		}
 
 		return nil
@@ -866,6 +884,41 @@ func anonymousPolicyID(endpoints []*ua.EndpointDescription) string {
 	return defaultAnonymousPolicyID
 }
 
+// ecdhPolicyURI returns the ECC security policy whose EccEncryptedSecret
+// encrypts the secret of the user identity token or an empty string if the
+// token has no secret or the policy does not use ECC.
+func ecdhPolicyURI(cfg *uasc.SessionConfig, sc *uasc.SecureChannel) string {
+	if _, ok := cfg.UserIdentityToken.(*ua.UserNameIdentityToken); !ok {
+		return ""
+	}
+	uri := cfg.AuthPolicyURI
+	if uri == "" {
+		uri = sc.SecurityPolicyURI()
+	}
+	if !uapolicy.IsECC(uri) {
+		return ""
+	}
+	return uri
+}
+
+// ecdhKey returns the ephemeral key of the server in the additional header
+// of a CreateSession or ActivateSession response or nil.
+func ecdhKey(h *ua.ResponseHeader) *ua.EphemeralKeyType {
+	if h == nil {
+		return nil
+	}
+	v := ua.AdditionalParameter(h.AdditionalHeader, ua.ECDHKeyParameter)
+	if v == nil {
+		return nil
+	}
+	eo, ok := v.Value().(*ua.ExtensionObject)
+	if !ok || eo == nil {
+		return nil
+	}
+	key, _ := eo.Value.(*ua.EphemeralKeyType)
+	return key
+}
+
 // ActivateSession activates the session and associates it with the client. If
 // the client already has a session it will be closed. To retain the current
 // session call DetachSession.
@@ -888,6 +941,17 @@ func (c *Client) ActivateSession(ctx context.Context, s *Session) error {
 		// nothing to do
 
 	case *ua.UserNameIdentityToken:
+		if uri := ecdhPolicyURI(s.cfg, sc); uri != "" {
+			pass, err := sc.EncryptUserSecret(uri, []byte(s.cfg.AuthPassword), s.serverCertificate, s.serverEphemeralKey, s.serverNonce)
+			if err != nil {
+				log.Printf("error encrypting user password: %s", err)
+				return err
+			}
+			// the EccEncryptedSecret identifies its algorithms itself.
+			tok.Password = pass
+			tok.EncryptionAlgorithm = ""
+			break
+		}
 		pass, passAlg, err := sc.EncryptUserPassword(s.cfg.AuthPolicyURI, s.cfg.AuthPassword, s.serverCertificate, s.serverNonce)
 		if err != nil {
 			log.Printf("error encrypting user password: %s", err)
@@ -921,14 +985,19 @@ func (c *Client) ActivateSession(ctx context.Context, s *Session) error {
 		UserIdentityToken:          ua.NewExtensionObject(s.cfg.UserIdentityToken),
 		UserTokenSignature:         s.cfg.UserTokenSignature,
 	}
+	// request a new ephemeral key for the next activation.
+	if uri := ecdhPolicyURI(s.cfg, sc); uri != "" {
+		req.RequestHeader = &ua.RequestHeader{AdditionalHeader: ua.NewAdditionalParameter(ua.ECDHPolicyURIParameter, ua.MustVariant(uri))}
+	}
 	return sc.SendRequest(ctx, req, s.resp.AuthenticationToken, func(v ua.Response) error {
 		var res *ua.ActivateSessionResponse
 		if err := safeAssign(v, &res); err != nil {
 			return err
 		}
 
-		// save the nonce for the next request
+		// save the nonce and the ephemeral key for the next request
 		s.serverNonce = res.ServerNonce
+		s.serverEphemeralKey = ecdhKey(res.ResponseHeader)
 
 		// close the previous session
 		//
@@ -1199,6 +1268,20 @@ func (c *Client) BrowseNext(ctx context.Context, req *ua.BrowseNextRequest) (*ua
 	return res, err
 }
 
+// TranslateBrowsePathsToNodeIDs resolves the node ids of browse paths.
+//
+// Part 4, Section 5.8.4
+func (c *Client) TranslateBrowsePathsToNodeIDs(ctx context.Context, req *ua.TranslateBrowsePathsToNodeIDsRequest) (*ua.TranslateBrowsePathsToNodeIDsResponse, error) {
+	stats.Client().Add("TranslateBrowsePathsToNodeIDs", 1)
+	stats.Client().Add("BrowsePathsToTranslate", int64(len(req.BrowsePaths)))
+
+	var res *ua.TranslateBrowsePathsToNodeIDsResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
 // RegisterNodes registers node ids for more efficient reads.
 //
 // Part 4, Section 5.8.5
@@ -1227,6 +1310,121 @@ func (c *Client) UnregisterNodes(ctx context.Context, req *ua.UnregisterNodesReq
 	return res, err
 }
 
+// QueryFirst searches the address space for the instances of node types
+// which match a content filter. The filter package helps to build the
+// content filter.
+//
+// Part 4, Section 5.9.3
+func (c *Client) QueryFirst(ctx context.Context, req *ua.QueryFirstRequest) (*ua.QueryFirstResponse, error) {
+	stats.Client().Add("QueryFirst", 1)
+	stats.Client().Add("NodeTypesToQuery", int64(len(req.NodeTypes)))
+
+	if req.View == nil {
+		req.View = &ua.ViewDescription{ViewID: ua.NewTwoByteNodeID(0)}
+	}
+	if req.Filter == nil {
+		req.Filter = &ua.ContentFilter{}
+	}
+
+	var res *ua.QueryFirstResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// QueryNext returns the next data sets of a query or releases its
+// continuation point.
+//
+// Part 4, Section 5.9.4
+func (c *Client) QueryNext(ctx context.Context, req *ua.QueryNextRequest) (*ua.QueryNextResponse, error) {
+	stats.Client().Add("QueryNext", 1)
+
+	var res *ua.QueryNextResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// AddNodes adds nodes to the address space of the server.
+//
+// Unset optional fields of the items, i.e. RequestedNewNodeID,
+// TypeDefinition and NodeAttributes, are set to null values.
+//
+// Part 4, Section 5.7.2
+func (c *Client) AddNodes(ctx context.Context, req *ua.AddNodesRequest) (*ua.AddNodesResponse, error) {
+	stats.Client().Add("AddNodes", 1)
+	stats.Client().Add("NodesToAdd", int64(len(req.NodesToAdd)))
+
+	// clone the request and the NodesToAdd to set defaults without
+	// manipulating them in-place.
+	items := make([]*ua.AddNodesItem, len(req.NodesToAdd))
+	for i, item := range req.NodesToAdd {
+		ic := &ua.AddNodesItem{}
+		*ic = *item
+		if ic.RequestedNewNodeID == nil {
+			ic.RequestedNewNodeID = ua.NewTwoByteExpandedNodeID(0)
+		}
+		if ic.TypeDefinition == nil {
+			ic.TypeDefinition = ua.NewTwoByteExpandedNodeID(0)
+		}
+		if ic.NodeAttributes == nil {
+			ic.NodeAttributes = ua.NewExtensionObject(nil)
+		}
+		items[i] = ic
+	}
+	req = &ua.AddNodesRequest{RequestHeader: req.RequestHeader, NodesToAdd: items}
+
+	var res *ua.AddNodesResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// AddReferences adds references to nodes of the server.
+//
+// Part 4, Section 5.7.3
+func (c *Client) AddReferences(ctx context.Context, req *ua.AddReferencesRequest) (*ua.AddReferencesResponse, error) {
+	stats.Client().Add("AddReferences", 1)
+	stats.Client().Add("ReferencesToAdd", int64(len(req.ReferencesToAdd)))
+
+	var res *ua.AddReferencesResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// DeleteNodes deletes nodes from the address space of the server.
+//
+// Part 4, Section 5.7.4
+func (c *Client) DeleteNodes(ctx context.Context, req *ua.DeleteNodesRequest) (*ua.DeleteNodesResponse, error) {
+	stats.Client().Add("DeleteNodes", 1)
+	stats.Client().Add("NodesToDelete", int64(len(req.NodesToDelete)))
+
+	var res *ua.DeleteNodesResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// DeleteReferences deletes references of nodes of the server.
+//
+// Part 4, Section 5.7.5
+func (c *Client) DeleteReferences(ctx context.Context, req *ua.DeleteReferencesRequest) (*ua.DeleteReferencesResponse, error) {
+	stats.Client().Add("DeleteReferences", 1)
+	stats.Client().Add("ReferencesToDelete", int64(len(req.ReferencesToDelete)))
+
+	var res *ua.DeleteReferencesResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
 func (c *Client) HistoryReadEvent(ctx context.Context, nodes []*ua.HistoryReadValueID, details *ua.ReadEventDetails) (*ua.HistoryReadResponse, error) {
 	stats.Client().Add("HistoryReadEvent", 1)
 	stats.Client().Add("HistoryReadValueID", int64(len(nodes)))
@@ -1319,6 +1517,106 @@ func (c *Client) HistoryReadAtTime(ctx context.Context, nodes []*ua.HistoryReadV
 	return res, err
 }
 
+// HistoryUpdate executes a synchronous history update request.
+func (c *Client) HistoryUpdate(ctx context.Context, req *ua.HistoryUpdateRequest) (*ua.HistoryUpdateResponse, error) {
+	stats.Client().Add("HistoryUpdate", 1)
+	stats.Client().Add("HistoryUpdateDetails", int64(len(req.HistoryUpdateDetails)))
+
+	var res *ua.HistoryUpdateResponse
+	err := c.Send(ctx, req, func(v ua.Response) error {
+		return safeAssign(v, &res)
+	})
+	return res, err
+}
+
+// HistoryUpdateData inserts, replaces or updates raw values in the history
+// of variables. The results contain a status code for every value.
+func (c *Client) HistoryUpdateData(ctx context.Context, details ...*ua.UpdateDataDetails) (*ua.HistoryUpdateResponse, error) {
+	stats.Client().Add("HistoryUpdateData", 1)
+
+	// Part 11, 6.9.2 UpdateDataDetails structure
+	req := &ua.HistoryUpdateRequest{}
+	for _, d := range details {
+		req.HistoryUpdateDetails = append(req.HistoryUpdateDetails, historyUpdateDetails(id.UpdateDataDetails_Encoding_DefaultBinary, d))
+	}
+	return c.HistoryUpdate(ctx, req)
+}
+
+// HistoryInsert inserts values into the history of a variable. Values for
+// which a value with the same timestamp already exists are rejected with
+// StatusBadEntryExists.
+func (c *Client) HistoryInsert(ctx context.Context, nodeID *ua.NodeID, values ...*ua.DataValue) (*ua.HistoryUpdateResponse, error) {
+	return c.HistoryUpdateData(ctx, &ua.UpdateDataDetails{
+		NodeID:               nodeID,
+		PerformInsertReplace: ua.PerformUpdateTypeInsert,
+		UpdateValues:         values,
+	})
+}
+
+// HistoryReplace replaces values in the history of a variable. Values for
+// which no value with the same timestamp exists are rejected with
+// StatusBadNoEntryExists.
+func (c *Client) HistoryReplace(ctx context.Context, nodeID *ua.NodeID, values ...*ua.DataValue) (*ua.HistoryUpdateResponse, error) {
+	return c.HistoryUpdateData(ctx, &ua.UpdateDataDetails{
+		NodeID:               nodeID,
+		PerformInsertReplace: ua.PerformUpdateTypeReplace,
+		UpdateValues:         values,
+	})
+}
+
+// HistoryDelete deletes the raw values of a variable between start and end.
+func (c *Client) HistoryDelete(ctx context.Context, nodeID *ua.NodeID, start, end time.Time) (*ua.HistoryUpdateResponse, error) {
+	stats.Client().Add("HistoryDelete", 1)
+
+	// Part 11, 6.9.5 DeleteRawModifiedDetails structure
+	return c.HistoryUpdate(ctx, &ua.HistoryUpdateRequest{
+		HistoryUpdateDetails: []*ua.ExtensionObject{
+			historyUpdateDetails(id.DeleteRawModifiedDetails_Encoding_DefaultBinary, &ua.DeleteRawModifiedDetails{
+				NodeID:    nodeID,
+				StartTime: start,
+				EndTime:   end,
+			}),
+		},
+	})
+}
+
+// HistoryDeleteAtTime deletes the values of a variable at the given times.
+// The results contain a status code for every time.
+func (c *Client) HistoryDeleteAtTime(ctx context.Context, nodeID *ua.NodeID, times ...time.Time) (*ua.HistoryUpdateResponse, error) {
+	stats.Client().Add("HistoryDeleteAtTime", 1)
+
+	// Part 11, 6.9.6 DeleteAtTimeDetails structure
+	return c.HistoryUpdate(ctx, &ua.HistoryUpdateRequest{
+		HistoryUpdateDetails: []*ua.ExtensionObject{
+			historyUpdateDetails(id.DeleteAtTimeDetails_Encoding_DefaultBinary, &ua.DeleteAtTimeDetails{
+				NodeID:   nodeID,
+				ReqTimes: times,
+			}),
+		},
+	})
+}
+
+// HistoryUpdateEvent inserts, replaces or updates events in the history of
+// event notifiers. The results contain a status code for every event.
+func (c *Client) HistoryUpdateEvent(ctx context.Context, details ...*ua.UpdateEventDetails) (*ua.HistoryUpdateResponse, error) {
+	stats.Client().Add("HistoryUpdateEvent", 1)
+
+	// Part 11, 6.9.4 UpdateEventDetails structure
+	req := &ua.HistoryUpdateRequest{}
+	for _, d := range details {
+		req.HistoryUpdateDetails = append(req.HistoryUpdateDetails, historyUpdateDetails(id.UpdateEventDetails_Encoding_DefaultBinary, d))
+	}
+	return c.HistoryUpdate(ctx, req)
+}
+
+func historyUpdateDetails(typeID uint32, details any) *ua.ExtensionObject {
+	return &ua.ExtensionObject{
+		TypeID:       ua.NewFourByteExpandedNodeID(0, uint16(typeID)),
+		EncodingMask: ua.ExtensionObjectBinary,
+		Value:        details,
+	}
+}
+
 // NamespaceArray returns the list of namespaces registered on the server.
 func (c *Client) NamespaceArray(ctx context.Context) ([]string, error) {
 	stats.Client().Add("NamespaceArray", 1)
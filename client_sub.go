@@ -59,6 +59,7 @@ func (c *Client) Subscribe(ctx context.Context, params *SubscriptionParameters,
 		items:                     make(map[uint32]*monitoredItem),
 		params:                    params,
 		nextSeq:                   1,
+		notifyRestored:            params.NotifyRestored,
 		c:                         c,
 	}
 
@@ -91,25 +92,39 @@ func (c *Client) SubscriptionIDs() []uint32 {
 // recreateSubscriptions creates new subscriptions
 // with the same parameters to replace the previous one
 func (c *Client) recreateSubscription(ctx context.Context, id uint32) error {
+	sub, err := c.recreateAndRegisterSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	sub.notifyRestoredSubscription(ctx)
+	return nil
+}
+
+// recreateAndRegisterSubscription replaces the subscription with a new one
+// and registers it under its new id.
+func (c *Client) recreateAndRegisterSubscription(ctx context.Context, id uint32) (*Subscription, error) {
 	c.subMux.Lock()
 	defer c.subMux.Unlock()
 
 	sub, ok := c.subs[id]
 	if !ok {
-		return ua.StatusBadSubscriptionIDInvalid
+		return nil, ua.StatusBadSubscriptionIDInvalid
 	}
 
 	sub.recreate_delete(ctx)
 	c.forgetSubscription_NeedsSubMuxLock(ctx, id)
 	if err := sub.recreate_create(ctx); err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := c.registerSubscription_NeedsSubMuxLock(sub); err != nil {
-		return err
+		return nil, err
 	}
 
-	return sub.recreate_monitoredItems(ctx)
+	if err := sub.recreate_monitoredItems(ctx); err != nil {
+		return nil, err
+	}
+	return sub, nil
 }
 
 // transferSubscriptions ask the server to transfer the given subscriptions
@@ -150,6 +165,7 @@ func (c *Client) republishSubscription(ctx context.Context, id uint32, available
 			return err
 		}
 	}
+	sub.notifyRestoredSubscription(ctx)
 	return nil
 }
 
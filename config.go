@@ -5,6 +5,7 @@
 package opcua
 
 import (
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/gopcua/opcua/errors"
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
 	"github.com/gopcua/opcua/uacp"
 	"github.com/gopcua/opcua/uapolicy"
 	"github.com/gopcua/opcua/uasc"
@@ -60,6 +62,7 @@ type Config struct {
 	dialer    *uacp.Dialer
 	sechan    *uasc.Config
 	session   *uasc.SessionConfig
+	certs     *certVerifier
 	stateCh   chan<- ConnState
 	stateFunc func(ConnState)
 }
@@ -198,6 +201,39 @@ func RemoteCertificateFile(filename string) Option {
 	}
 }
 
+// TrustStore validates the certificate of the server with the store if
+// the security mode is not None. The certificate must also allow its use
+// by a server and contain the host name of the endpoint url. The client
+// does not connect to servers whose certificate fails the validation
+// unless the func of CertificateTrustCallback accepts it.
+func TrustStore(store *uacert.Store) Option {
+	return func(cfg *Config) error {
+		cfg.verifier().store = store
+		return nil
+	}
+}
+
+// TrustedCertificates validates the certificate of the server with the
+// trusted certificates of the pool like TrustStore. Certificates which the
+// pool does not trust are validated with the trust store if there is one.
+func TrustedCertificates(pool *x509.CertPool) Option {
+	return func(cfg *Config) error {
+		cfg.verifier().roots = pool
+		return nil
+	}
+}
+
+// CertificateTrustCallback validates the certificate of the server like
+// TrustStore and calls f with the certificates which fail the validation,
+// e.g. to ask the user of an interactive tool. Without a trust store or
+// trusted certificates all certificates fail the validation.
+func CertificateTrustCallback(f CertificateTrustFunc) Option {
+	return func(cfg *Config) error {
+		cfg.verifier().trust = f
+		return nil
+	}
+}
+
 // SecurityMode sets the security mode for the secure channel.
 func SecurityMode(m ua.MessageSecurityMode) Option {
 	return func(cfg *Config) error {
@@ -385,6 +421,26 @@ func loadCertificate(filename string) ([]byte, error) {
 	return b, nil
 }
 
+// ApplicationCertificate sets the certificate and the private key of the
+// client in the secure channel configuration from an application instance
+// certificate, e.g. from uacert.LoadOrCreate. It also sets the
+// ApplicationURI from the URI within the certificate.
+//
+// Certificates with an ECDSA key are used with the ECC security policies.
+func ApplicationCertificate(c *uacert.Certificate) Option {
+	return func(cfg *Config) error {
+		switch key := c.PrivateKey.(type) {
+		case *rsa.PrivateKey:
+			cfg.sechan.LocalKey = key
+		case *ecdsa.PrivateKey:
+			cfg.sechan.LocalECKey = key
+		default:
+			return errors.Errorf("Private key is neither an RSA nor an ECDSA key")
+		}
+		return setCertificate(c.Certificate, cfg)
+	}
+}
+
 func setCertificate(cert []byte, cfg *Config) error {
 	cfg.sechan.Certificate = cert
 
@@ -1,6 +1,7 @@
 package opcua
 
 import (
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
 	"github.com/gopcua/opcua/uacp"
 	"github.com/gopcua/opcua/uapolicy"
 	"github.com/gopcua/opcua/uasc"
@@ -213,6 +215,13 @@ func TestOptions(t *testing.T) {
 	err = os.WriteFile(certPEMFile, certPEM, 0644)
 	require.NoError(t, err, "WriteFile(certPEMFile) failed")
 
+	store, err := uacert.NewStore(filepath.Join(d, "pki"))
+	require.NoError(t, err, "NewStore failed")
+	leaf, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err, "ParseCertificate failed")
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
 	err = os.WriteFile(keyDERFile, keyPKCS1DER, 0644)
 	require.NoError(t, err, "WriteFile(keyDERFile) failed")
 
@@ -228,6 +237,9 @@ func TestOptions(t *testing.T) {
 	err = os.WriteFile(keyPKCS8ECFile, keyPKCS8ECPem, 0644)
 	require.NoError(t, err, "WriteFile(keyPKCS8ECFile) failed")
 
+	ecCert, err := uacert.Generate(uacert.Options{ApplicationURI: "urn:client", Hosts: []string{"localhost"}, KeyType: uacert.NistP256})
+	require.NoError(t, err, "Generate failed")
+
 	connStateCh := make(chan ConnState)
 	connStateFunc := func(ConnState) {}
 
@@ -345,6 +357,35 @@ func TestOptions(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: `ApplicationCertificate`,
+			opt:  ApplicationCertificate(&uacert.Certificate{Certificate: certDER, PrivateKey: cert.PrivateKey.(*rsa.PrivateKey)}),
+			cfg: &Config{
+				sechan: func() *uasc.Config {
+					c := DefaultClientConfig()
+					c.Certificate = certDER
+					c.LocalKey = cert.PrivateKey.(*rsa.PrivateKey)
+					return c
+				}(),
+			},
+		},
+		{
+			name: `ApplicationCertificate() with ECDSA key`,
+			opt:  ApplicationCertificate(ecCert),
+			cfg: &Config{
+				sechan: func() *uasc.Config {
+					c := DefaultClientConfig()
+					c.Certificate = ecCert.Certificate
+					c.LocalECKey = ecCert.PrivateKey.(*ecdsa.PrivateKey)
+					return c
+				}(),
+				session: func() *uasc.SessionConfig {
+					sc := DefaultSessionConfig()
+					sc.ClientDescription.ApplicationURI = "urn:client"
+					return sc
+				}(),
+			},
+		},
 		{
 			name: `CertificateFile("cert.der")`,
 			opt:  CertificateFile(certDERFile),
@@ -898,6 +939,20 @@ func TestOptions(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: `TrustStore()`,
+			opt:  TrustStore(store),
+			cfg: &Config{
+				certs: &certVerifier{store: store},
+			},
+		},
+		{
+			name: `TrustedCertificates()`,
+			opt:  TrustedCertificates(pool),
+			cfg: &Config{
+				certs: &certVerifier{roots: pool},
+			},
+		},
 		{
 			name: `SendBufferSize()`,
 			opt:  SendBufferSize(5),
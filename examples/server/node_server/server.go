@@ -12,6 +12,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"time"
 
 	"github.com/gopcua/opcua/debug"
@@ -111,6 +112,12 @@ func main() {
 		server.SetLogger(logger),
 	)
 
+	// The history store records the values of historizing variables so that clients
+	// can read them with HistoryRead. This one keeps the last 1000 values per node in memory.
+	opts = append(opts,
+		server.SetHistoryStore(server.NewMemoryHistory(1000)),
+	)
+
 	// Here is an example of certificate generation.  This is not necessary if you already have a certificate.
 	if *gencert {
 		// it is important that the certificate is generated with the correct hostname/IP address URIs
@@ -186,12 +193,15 @@ func main() {
 	// be sure to add the reference to the node somewhere if desired, or clients won't be able to browse it.
 	var1 := nodeNS.AddNewVariableNode("TestVar1", float32(123.45))
 	nns_obj.AddRef(var1, id.HasComponent, true)
+	// every change of TestVar1 is recorded in the history store.
+	var1.SetHistorizing(true)
 
 	// This node will have a string node id (ns=<namespace id>,s=TestVar2)
 	// your variable node's value can also return a ua.Variant from a function if you want to update the value dynamically
 	// here we are just incrementing a counter every time the value is read.
-	var2Value := int32(0)
-	var2 := nodeNS.AddNewVariableStringNode("TestVar2", func() *ua.DataValue { var2Value++; return server.DataValueFromValue(var2Value) })
+	// The server reads the value and calls methods concurrently so the counter must be safe for concurrent use.
+	var var2Value atomic.Int32
+	var2 := nodeNS.AddNewVariableStringNode("TestVar2", func() *ua.DataValue { return server.DataValueFromValue(var2Value.Add(1)) })
 	nns_obj.AddRef(var2, id.HasComponent, true)
 
 	// Now we'll add a node from scratch.  This is a more manual way to add nodes to the server and gives you full
@@ -251,6 +261,18 @@ func main() {
 	nodeNS.AddNode(var6)
 	nns_obj.AddRef(var6, id.HasComponent, true)
 
+	// Methods are plain Go functions. The InputArguments and OutputArguments properties are
+	// generated from the function signature and the server validates the arguments before
+	// calling the function. Use server.SessionID(ctx) to find out who is calling.
+	reset, err := nodeNS.AddNewMethodNode("ResetTestVar2", func(ctx context.Context, v int32) (int32, error) {
+		log.Printf("session %s resets TestVar2 to %d", server.SessionID(ctx), v)
+		return var2Value.Swap(v), nil
+	}, server.InputArgumentNames("Value"), server.OutputArgumentNames("OldValue"))
+	if err != nil {
+		log.Fatalf("Error adding method: %s", err)
+	}
+	nns_obj.AddRef(reset, id.HasComponent, true)
+
 	// simulate a background process updating the data in the namespace.
 	go func() {
 		updates := 0
@@ -0,0 +1,237 @@
+// Copyright 2018-2020 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Package filter builds the ContentFilter expressions of OPC-UA Part 4 for
+// the Query services and event filters.
+//
+// An expression is a tree of operators and operands which is flattened into
+// the elements of a ContentFilter. The root of the expression becomes the
+// first element and the operands which are expressions themselves refer to
+// later elements:
+//
+//	machineType := ua.NewStringNodeID(2, "MachineType")
+//	f := filter.And(
+//		filter.OfType(machineType),
+//		filter.Like(filter.Value(machineType, "Vendor"), filter.Literal("Acme%")),
+//	).ContentFilter()
+//
+//	res, err := c.QueryFirst(ctx, &ua.QueryFirstRequest{
+//		NodeTypes: []*ua.NodeTypeDescription{{
+//			TypeDefinitionNode: ua.NewExpandedNodeID(machineType, "", 0),
+//			IncludeSubTypes:    true,
+//			DataToReturn:       []*ua.QueryDataDescription{},
+//		}},
+//		Filter: f,
+//	})
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.7
+package filter
+
+import (
+	"github.com/gopcua/opcua/ua"
+)
+
+// Operand is an operand of a filter expression.
+type Operand interface {
+	// filterOperand returns the operand of the content filter f. Operands
+	// which are expressions add their elements to f.
+	filterOperand(f *ua.ContentFilter) any
+}
+
+// Expr is an operator with its operands.
+type Expr struct {
+	op   ua.FilterOperator
+	args []Operand
+}
+
+// ContentFilter returns the content filter of the expression.
+func (e *Expr) ContentFilter() *ua.ContentFilter {
+	f := &ua.ContentFilter{}
+	e.add(f)
+	return f
+}
+
+// add appends the elements of the expression to f and returns the index of
+// its root element.
+func (e *Expr) add(f *ua.ContentFilter) uint32 {
+	i := len(f.Elements)
+	el := &ua.ContentFilterElement{FilterOperator: e.op}
+	f.Elements = append(f.Elements, el)
+
+	el.FilterOperands = make([]*ua.ExtensionObject, len(e.args))
+	for j, a := range e.args {
+		el.FilterOperands[j] = ua.NewExtensionObject(a.filterOperand(f))
+	}
+	return uint32(i)
+}
+
+func (e *Expr) filterOperand(f *ua.ContentFilter) any {
+	return &ua.ElementOperand{Index: e.add(f)}
+}
+
+type operand struct {
+	v any
+}
+
+func (o operand) filterOperand(*ua.ContentFilter) any {
+	return o.v
+}
+
+// Literal returns a literal value. It panics if v cannot be stored in a
+// variant.
+func Literal(v any) Operand {
+	return operand{&ua.LiteralOperand{Value: ua.MustVariant(v)}}
+}
+
+// Value returns the value of a node of the target which is reached by
+// following hierarchical references with the browse names in path. The
+// browse names are in namespace 0. The operand is null if the target is not
+// an instance of typeID or one of its subtypes.
+func Value(typeID *ua.NodeID, path ...string) Operand {
+	names := make([]*ua.QualifiedName, len(path))
+	for i, name := range path {
+		names[i] = &ua.QualifiedName{Name: name}
+	}
+	return SimpleAttribute(typeID, ua.AttributeIDValue, names...)
+}
+
+// SimpleAttribute returns an attribute of a node of the target which is
+// reached by following hierarchical references with the browse names in
+// path.
+func SimpleAttribute(typeID *ua.NodeID, attrID ua.AttributeID, path ...*ua.QualifiedName) Operand {
+	if typeID == nil {
+		typeID = ua.NewTwoByteNodeID(0)
+	}
+	if path == nil {
+		path = []*ua.QualifiedName{}
+	}
+	return operand{&ua.SimpleAttributeOperand{
+		TypeDefinitionID: typeID,
+		BrowsePath:       path,
+		AttributeID:      attrID,
+	}}
+}
+
+// Attribute returns an attribute of a node of the target which is reached
+// by following a relative path.
+func Attribute(typeID *ua.NodeID, attrID ua.AttributeID, path *ua.RelativePath) Operand {
+	if typeID == nil {
+		typeID = ua.NewTwoByteNodeID(0)
+	}
+	if path == nil {
+		path = &ua.RelativePath{Elements: []*ua.RelativePathElement{}}
+	}
+	return operand{&ua.AttributeOperand{
+		NodeID:      typeID,
+		BrowsePath:  path,
+		AttributeID: attrID,
+	}}
+}
+
+// Type returns an operand for the source or the target of RelatedTo which
+// matches the instances of typeID and its subtypes.
+func Type(typeID *ua.NodeID) Operand {
+	return Attribute(typeID, ua.AttributeIDNodeID, nil)
+}
+
+func expr(op ua.FilterOperator, args ...Operand) *Expr {
+	return &Expr{op: op, args: args}
+}
+
+// Equals is true if a and b are equal.
+func Equals(a, b Operand) *Expr {
+	return expr(ua.FilterOperatorEquals, a, b)
+}
+
+// IsNull is true if a is null.
+func IsNull(a Operand) *Expr {
+	return expr(ua.FilterOperatorIsNull, a)
+}
+
+// GreaterThan is true if a is greater than b.
+func GreaterThan(a, b Operand) *Expr {
+	return expr(ua.FilterOperatorGreaterThan, a, b)
+}
+
+// LessThan is true if a is less than b.
+func LessThan(a, b Operand) *Expr {
+	return expr(ua.FilterOperatorLessThan, a, b)
+}
+
+// GreaterThanOrEqual is true if a is greater than or equal to b.
+func GreaterThanOrEqual(a, b Operand) *Expr {
+	return expr(ua.FilterOperatorGreaterThanOrEqual, a, b)
+}
+
+// LessThanOrEqual is true if a is less than or equal to b.
+func LessThanOrEqual(a, b Operand) *Expr {
+	return expr(ua.FilterOperatorLessThanOrEqual, a, b)
+}
+
+// Like is true if a matches the pattern. The pattern uses % for any number
+// of characters, _ for a single character and [] for a set of characters.
+func Like(a, pattern Operand) *Expr {
+	return expr(ua.FilterOperatorLike, a, pattern)
+}
+
+// Not is true if a is false.
+func Not(a Operand) *Expr {
+	return expr(ua.FilterOperatorNot, a)
+}
+
+// Between is true if a is greater than or equal to min and less than or
+// equal to max.
+func Between(a, min, max Operand) *Expr {
+	return expr(ua.FilterOperatorBetween, a, min, max)
+}
+
+// InList is true if a is equal to one of the values.
+func InList(a Operand, values ...Operand) *Expr {
+	return expr(ua.FilterOperatorInList, append([]Operand{a}, values...)...)
+}
+
+// And is true if all operands are true. More than two operands are nested.
+func And(a, b Operand, more ...Operand) *Expr {
+	return chain(ua.FilterOperatorAnd, a, b, more)
+}
+
+// Or is true if any operand is true. More than two operands are nested.
+func Or(a, b Operand, more ...Operand) *Expr {
+	return chain(ua.FilterOperatorOr, a, b, more)
+}
+
+func chain(op ua.FilterOperator, a, b Operand, more []Operand) *Expr {
+	if len(more) > 0 {
+		b = chain(op, b, more[0], more[1:])
+	}
+	return expr(op, a, b)
+}
+
+// Cast converts a to the data type.
+func Cast(a Operand, dataType *ua.NodeID) *Expr {
+	return expr(ua.FilterOperatorCast, a, Literal(dataType))
+}
+
+// OfType is true if the target is an instance of typeID or one of its
+// subtypes.
+func OfType(typeID *ua.NodeID) *Expr {
+	return expr(ua.FilterOperatorOfType, Literal(typeID))
+}
+
+// RelatedTo is true if the source is related to the target with up to hops
+// references of refType or its subtypes. The source and the target are
+// either Type operands, literal node ids or expressions.
+func RelatedTo(source, target Operand, refType *ua.NodeID, hops uint32) *Expr {
+	return expr(ua.FilterOperatorRelatedTo, source, target, Literal(refType), Literal(hops))
+}
+
+// BitwiseAnd returns the bitwise and of a and b.
+func BitwiseAnd(a, b Operand) *Expr {
+	return expr(ua.FilterOperatorBitwiseAnd, a, b)
+}
+
+// BitwiseOr returns the bitwise or of a and b.
+func BitwiseOr(a, b Operand) *Expr {
+	return expr(ua.FilterOperatorBitwiseOr, a, b)
+}
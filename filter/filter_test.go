@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFilter(t *testing.T) {
+	typeID := ua.NewStringNodeID(2, "MachineType")
+
+	f := And(
+		OfType(typeID),
+		Or(
+			Equals(Value(typeID, "Vendor"), Literal("Acme")),
+			Between(Value(typeID, "Speed"), Literal(int32(1)), Literal(int32(10))),
+		),
+	).ContentFilter()
+
+	require.Len(t, f.Elements, 5)
+	ops := func(i int) []any {
+		var v []any
+		for _, eo := range f.Elements[i].FilterOperands {
+			v = append(v, eo.Value)
+		}
+		return v
+	}
+	value := func(name string) *ua.SimpleAttributeOperand {
+		return &ua.SimpleAttributeOperand{
+			TypeDefinitionID: typeID,
+			BrowsePath:       []*ua.QualifiedName{{Name: name}},
+			AttributeID:      ua.AttributeIDValue,
+		}
+	}
+
+	require.Equal(t, ua.FilterOperatorAnd, f.Elements[0].FilterOperator)
+	require.Equal(t, []any{&ua.ElementOperand{Index: 1}, &ua.ElementOperand{Index: 2}}, ops(0))
+
+	require.Equal(t, ua.FilterOperatorOfType, f.Elements[1].FilterOperator)
+	require.Equal(t, []any{&ua.LiteralOperand{Value: ua.MustVariant(typeID)}}, ops(1))
+
+	require.Equal(t, ua.FilterOperatorOr, f.Elements[2].FilterOperator)
+	require.Equal(t, []any{&ua.ElementOperand{Index: 3}, &ua.ElementOperand{Index: 4}}, ops(2))
+
+	require.Equal(t, ua.FilterOperatorEquals, f.Elements[3].FilterOperator)
+	require.Equal(t, []any{value("Vendor"), &ua.LiteralOperand{Value: ua.MustVariant("Acme")}}, ops(3))
+
+	require.Equal(t, ua.FilterOperatorBetween, f.Elements[4].FilterOperator)
+	require.Equal(t, []any{
+		value("Speed"),
+		&ua.LiteralOperand{Value: ua.MustVariant(int32(1))},
+		&ua.LiteralOperand{Value: ua.MustVariant(int32(10))},
+	}, ops(4))
+}
+
+func TestChain(t *testing.T) {
+	a, b, c := Literal(true), Literal(false), Literal(true)
+	f := Or(a, b, c).ContentFilter()
+
+	require.Len(t, f.Elements, 2)
+	require.Equal(t, ua.FilterOperatorOr, f.Elements[0].FilterOperator)
+	require.Equal(t, &ua.ElementOperand{Index: 1}, f.Elements[0].FilterOperands[1].Value)
+	require.Equal(t, ua.FilterOperatorOr, f.Elements[1].FilterOperator)
+	require.Len(t, f.Elements[1].FilterOperands, 2)
+}
+
+func TestEncode(t *testing.T) {
+	f := RelatedTo(Type(ua.NewNumericNodeID(0, 58)), InList(Literal(int32(1)), Literal(int32(2))), ua.NewNumericNodeID(0, 47), 2).ContentFilter()
+
+	b, err := ua.Encode(f)
+	require.NoError(t, err)
+
+	var got ua.ContentFilter
+	_, err = ua.Decode(b, &got)
+	require.NoError(t, err)
+	require.Len(t, got.Elements, 2)
+	require.Equal(t, ua.FilterOperatorRelatedTo, got.Elements[0].FilterOperator)
+	require.Len(t, got.Elements[0].FilterOperands, 4)
+	require.IsType(t, &ua.AttributeOperand{}, got.Elements[0].FilterOperands[0].Value)
+	require.Equal(t, uint32(1), got.Elements[0].FilterOperands[1].Value.(*ua.ElementOperand).Index)
+	require.Equal(t, ua.FilterOperatorInList, got.Elements[1].FilterOperator)
+}
@@ -0,0 +1,398 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/filter"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Condition is the state of a condition or of one of its branches as
+// reported by the last event of the condition.
+//
+// https://reference.opcfoundation.org/Core/Part9/v105/docs/5.5.2
+type Condition struct {
+	ConditionID   *ua.NodeID
+	BranchID      *ua.NodeID
+	EventID       []byte
+	EventType     *ua.NodeID
+	SourceNode    *ua.NodeID
+	SourceName    string
+	ConditionName string
+	Time          time.Time
+	Message       string
+	Severity      uint16
+	Retain        bool
+	Enabled       bool
+	Active        bool
+	Acked         bool
+	Confirmed     bool
+	Comment       string
+	ClientUserID  string
+	ShelvingState string
+	LimitState    string
+}
+
+// IsBranch reports whether the state is a branch of the condition.
+func (c *Condition) IsBranch() bool {
+	return c.BranchID != nil && !c.BranchID.Equal(ua.NewTwoByteNodeID(0))
+}
+
+func (c *Condition) key() string {
+	if c.IsBranch() {
+		return c.ConditionID.String() + "/" + c.BranchID.String()
+	}
+	return c.ConditionID.String()
+}
+
+// ConditionHandler is a function that is called for each condition event.
+type ConditionHandler func(*ConditionManager, *Condition)
+
+// conditionFields are the select clauses of the condition event filter in
+// the order of the Condition fields.
+var conditionFields = []struct {
+	typeID uint32
+	path   []string
+}{
+	{id.ConditionType, nil}, // ConditionId
+	{id.ConditionType, []string{"BranchId"}},
+	{id.BaseEventType, []string{"EventId"}},
+	{id.BaseEventType, []string{"EventType"}},
+	{id.BaseEventType, []string{"SourceNode"}},
+	{id.BaseEventType, []string{"SourceName"}},
+	{id.ConditionType, []string{"ConditionName"}},
+	{id.BaseEventType, []string{"Time"}},
+	{id.BaseEventType, []string{"Message"}},
+	{id.BaseEventType, []string{"Severity"}},
+	{id.ConditionType, []string{"Retain"}},
+	{id.ConditionType, []string{"EnabledState", "Id"}},
+	{id.AlarmConditionType, []string{"ActiveState", "Id"}},
+	{id.AcknowledgeableConditionType, []string{"AckedState", "Id"}},
+	{id.AcknowledgeableConditionType, []string{"ConfirmedState", "Id"}},
+	{id.ConditionType, []string{"Comment"}},
+	{id.ConditionType, []string{"ClientUserId"}},
+	{id.AlarmConditionType, []string{"ShelvingState", "CurrentState"}},
+	{id.ExclusiveLimitAlarmType, []string{"LimitState", "CurrentState"}},
+}
+
+// ConditionEventFilter returns the event filter for the condition events
+// and the refresh events of a condition refresh.
+func ConditionEventFilter() *ua.EventFilter {
+	selects := make([]*ua.SimpleAttributeOperand, len(conditionFields))
+	for i, f := range conditionFields {
+		op := &ua.SimpleAttributeOperand{
+			TypeDefinitionID: ua.NewNumericNodeID(0, f.typeID),
+			BrowsePath:       make([]*ua.QualifiedName, len(f.path)),
+			AttributeID:      ua.AttributeIDValue,
+		}
+		for j, name := range f.path {
+			op.BrowsePath[j] = &ua.QualifiedName{Name: name}
+		}
+		if len(f.path) == 0 {
+			op.AttributeID = ua.AttributeIDNodeID
+		}
+		selects[i] = op
+	}
+	where := filter.Or(
+		filter.OfType(ua.NewNumericNodeID(0, id.ConditionType)),
+		filter.OfType(ua.NewNumericNodeID(0, id.RefreshStartEventType)),
+		filter.OfType(ua.NewNumericNodeID(0, id.RefreshEndEventType)),
+	)
+	return &ua.EventFilter{SelectClauses: selects, WhereClause: where.ContentFilter()}
+}
+
+// ConditionManager keeps a table of the retained conditions which are
+// reported by an event notifier. The table is rebuilt with a
+// ConditionRefresh when the manager is created and after the client has
+// restored the subscription on a new session.
+type ConditionManager struct {
+	client    *opcua.Client
+	sub       *opcua.Subscription
+	notifyCh  chan *opcua.PublishNotificationData
+	closed    chan struct{}
+	closeOnce sync.Once
+	cb        ConditionHandler
+
+	mu           sync.RWMutex
+	conditions   map[string]*Condition
+	refreshing   bool
+	refreshed    map[string]bool
+	errHandlerCB func(error)
+}
+
+// NewConditionManager subscribes to the condition events of the event
+// notifier and refreshes the conditions. The Server object is used if the
+// notifier is nil. The handler is optional and is called for every
+// condition event after the table has been updated.
+// The caller must call Close to stop and clean up resources.
+func NewConditionManager(ctx context.Context, client *opcua.Client, params *opcua.SubscriptionParameters, notifier *ua.NodeID, cb ConditionHandler) (*ConditionManager, error) {
+	// the conditions are refreshed when the subscription is restored.
+	p := opcua.SubscriptionParameters{}
+	if params != nil {
+		p = *params
+	}
+	p.NotifyRestored = true
+	params = &p
+	if notifier == nil {
+		notifier = ua.NewNumericNodeID(0, id.Server)
+	}
+
+	m := &ConditionManager{
+		client:     client,
+		notifyCh:   make(chan *opcua.PublishNotificationData, DefaultCallbackBufferLen),
+		closed:     make(chan struct{}),
+		cb:         cb,
+		conditions: make(map[string]*Condition),
+	}
+
+	var err error
+	if m.sub, err = client.Subscribe(ctx, params, m.notifyCh); err != nil {
+		return nil, err
+	}
+
+	req := opcua.NewMonitoredItemCreateRequestWithDefaults(notifier, ua.AttributeIDEventNotifier, 1)
+	req.RequestedParameters.QueueSize = 1000
+	req.RequestedParameters.Filter = ua.NewExtensionObject(ConditionEventFilter())
+	res, err := m.sub.Monitor(ctx, ua.TimestampsToReturnBoth, req)
+	if err == nil && res.Results[0].StatusCode != ua.StatusOK {
+		err = &ItemError{NodeID: notifier, Status: res.Results[0].StatusCode}
+	}
+	if err != nil {
+		m.sub.Cancel(ctx)
+		return nil, err
+	}
+
+	go m.pump(ctx)
+
+	if err := m.Refresh(ctx); err != nil {
+		m.Close(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetErrorHandler sets an optional callback for async errors.
+func (m *ConditionManager) SetErrorHandler(cb func(error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errHandlerCB = cb
+}
+
+func (m *ConditionManager) sendError(err error) {
+	m.mu.RLock()
+	cb := m.errHandlerCB
+	m.mu.RUnlock()
+	if err != nil && cb != nil {
+		go cb(err)
+	}
+}
+
+// Close removes the subscription and stops the manager. Only the first
+// call has an effect.
+func (m *ConditionManager) Close(ctx context.Context) error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closed)
+		err = m.sub.Cancel(ctx)
+	})
+	return err
+}
+
+// Conditions returns the retained conditions and branches.
+func (m *ConditionManager) Conditions() []*Condition {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]*Condition, 0, len(m.conditions))
+	for _, c := range m.conditions {
+		cc := *c
+		list = append(list, &cc)
+	}
+	return list
+}
+
+// Condition returns the retained state of the condition with the given id
+// or nil if the condition is not retained. Branches are not returned.
+func (m *ConditionManager) Condition(conditionID *ua.NodeID) *Condition {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.conditions[conditionID.String()]
+	if !ok {
+		return nil
+	}
+	cc := *c
+	return &cc
+}
+
+// Refresh asks the server to report the retained conditions again. The
+// conditions which are not reported are removed from the table.
+func (m *ConditionManager) Refresh(ctx context.Context) error {
+	_, err := m.call(ctx, ua.NewNumericNodeID(0, id.ConditionType), id.ConditionType_ConditionRefresh, m.sub.SubscriptionID)
+	return err
+}
+
+// Acknowledge acknowledges the state of the condition with the event id
+// of the condition.
+func (m *ConditionManager) Acknowledge(ctx context.Context, c *Condition, comment string) error {
+	_, err := m.call(ctx, c.ConditionID, id.AcknowledgeableConditionType_Acknowledge, c.EventID, ua.NewLocalizedText(comment))
+	return err
+}
+
+// Confirm confirms the state of the condition with the event id of the
+// condition.
+func (m *ConditionManager) Confirm(ctx context.Context, c *Condition, comment string) error {
+	_, err := m.call(ctx, c.ConditionID, id.AcknowledgeableConditionType_Confirm, c.EventID, ua.NewLocalizedText(comment))
+	return err
+}
+
+// call calls a method of the condition types and returns the status of the
+// call as error.
+func (m *ConditionManager) call(ctx context.Context, objectID *ua.NodeID, methodID uint32, args ...any) (*ua.CallMethodResult, error) {
+	req := &ua.CallMethodRequest{
+		ObjectID:       objectID,
+		MethodID:       ua.NewNumericNodeID(0, methodID),
+		InputArguments: make([]*ua.Variant, len(args)),
+	}
+	for i, a := range args {
+		v, err := ua.NewVariant(a)
+		if err != nil {
+			return nil, err
+		}
+		req.InputArguments[i] = v
+	}
+	res, err := m.client.Call(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != ua.StatusOK {
+		return res, res.StatusCode
+	}
+	return res, nil
+}
+
+// internal func to read the notifications of the subscription and to update
+// the table of conditions.
+func (m *ConditionManager) pump(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.closed:
+			return
+		case msg := <-m.notifyCh:
+			if msg.Error != nil {
+				m.sendError(msg.Error)
+				continue
+			}
+
+			switch v := msg.Value.(type) {
+			case *ua.EventNotificationList:
+				for _, ev := range v.Events {
+					m.handleEvent(ev.EventFields)
+				}
+			case *opcua.SubscriptionRestored:
+				// conditions may have changed while the client was
+				// disconnected.
+				go func() {
+					if err := m.Refresh(ctx); err != nil {
+						m.sendError(err)
+					}
+				}()
+			case *ua.StatusChangeNotification:
+				m.sendError(errors.Errorf("subscription status changed: %s", v.Status))
+			default:
+				m.sendError(errors.Errorf("unknown message type: %T", msg.Value))
+			}
+		}
+	}
+}
+
+// handleEvent updates the table with a condition event or with the
+// refresh events which frame a condition refresh.
+func (m *ConditionManager) handleEvent(fields []*ua.Variant) {
+	if len(fields) != len(conditionFields) {
+		m.sendError(errors.Errorf("got %d event fields, want %d", len(fields), len(conditionFields)))
+		return
+	}
+	c := conditionFromFields(fields)
+
+	m.mu.Lock()
+	switch {
+	case c.EventType != nil && c.EventType.Equal(ua.NewNumericNodeID(0, id.RefreshStartEventType)):
+		m.refreshing = true
+		m.refreshed = make(map[string]bool)
+		m.mu.Unlock()
+		return
+
+	case c.EventType != nil && c.EventType.Equal(ua.NewNumericNodeID(0, id.RefreshEndEventType)):
+		// conditions which were not refreshed are no longer retained.
+		if m.refreshing {
+			for k := range m.conditions {
+				if !m.refreshed[k] {
+					delete(m.conditions, k)
+				}
+			}
+		}
+		m.refreshing, m.refreshed = false, nil
+		m.mu.Unlock()
+		return
+
+	case c.ConditionID == nil:
+		m.mu.Unlock()
+		return
+	}
+
+	if c.Retain {
+		m.conditions[c.key()] = c
+	} else {
+		delete(m.conditions, c.key())
+	}
+	if m.refreshing {
+		m.refreshed[c.key()] = true
+	}
+	m.mu.Unlock()
+
+	if m.cb != nil {
+		cc := *c
+		m.cb(m, &cc)
+	}
+}
+
+// conditionFromFields maps the fields of a condition event to a Condition.
+func conditionFromFields(f []*ua.Variant) *Condition {
+	c := &Condition{}
+	c.ConditionID, _ = f[0].Value().(*ua.NodeID)
+	c.BranchID, _ = f[1].Value().(*ua.NodeID)
+	c.EventID, _ = f[2].Value().([]byte)
+	c.EventType, _ = f[3].Value().(*ua.NodeID)
+	c.SourceNode, _ = f[4].Value().(*ua.NodeID)
+	c.SourceName, _ = f[5].Value().(string)
+	c.ConditionName, _ = f[6].Value().(string)
+	c.Time, _ = f[7].Value().(time.Time)
+	c.Message = text(f[8])
+	c.Severity, _ = f[9].Value().(uint16)
+	c.Retain, _ = f[10].Value().(bool)
+	c.Enabled, _ = f[11].Value().(bool)
+	c.Active, _ = f[12].Value().(bool)
+	c.Acked, _ = f[13].Value().(bool)
+	c.Confirmed, _ = f[14].Value().(bool)
+	c.Comment = text(f[15])
+	c.ClientUserID, _ = f[16].Value().(string)
+	c.ShelvingState = text(f[17])
+	c.LimitState = text(f[18])
+	return c
+}
+
+// text returns the text of a localized text.
+func text(v *ua.Variant) string {
+	if lt, ok := v.Value().(*ua.LocalizedText); ok && lt != nil {
+		return lt.Text
+	}
+	return ""
+}
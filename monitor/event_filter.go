@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"strings"
+
+	"github.com/gopcua/opcua/filter"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// EventFilter builds the EventFilter of an event request. The fields of the
+// events are selected by their browse paths relative to the event type, e.g.
+// "Severity", "/Message" or "EnabledState/Id". The browse names are in
+// namespace 0. The fields of an EventMessage are keyed by their path
+// without the leading slash.
+//
+//	f := monitor.NewEventFilter("EventType", "Message", "Severity").
+//		Where(filter.GreaterThanOrEqual(filter.Value(nil, "Severity"), filter.Literal(uint16(500))))
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.22.3
+type EventFilter struct {
+	names   []string
+	selects []*ua.SimpleAttributeOperand
+	where   *ua.ContentFilter
+}
+
+// NewEventFilter returns an event filter which selects the fields of the
+// BaseEventType with the given browse paths.
+func NewEventFilter(paths ...string) *EventFilter {
+	return (&EventFilter{}).Select(ua.NewNumericNodeID(0, id.BaseEventType), paths...)
+}
+
+// Select adds the fields of the event type with the given browse paths. The
+// fields are null for events which are not of the type. A nil type selects
+// the fields of all events.
+func (f *EventFilter) Select(typeID *ua.NodeID, paths ...string) *EventFilter {
+	if typeID == nil {
+		typeID = ua.NewTwoByteNodeID(0)
+	}
+	for _, p := range paths {
+		names := []*ua.QualifiedName{}
+		for _, name := range strings.Split(p, "/") {
+			if name != "" {
+				names = append(names, &ua.QualifiedName{Name: name})
+			}
+		}
+		f.names = append(f.names, strings.TrimPrefix(p, "/"))
+		f.selects = append(f.selects, &ua.SimpleAttributeOperand{
+			TypeDefinitionID: typeID,
+			BrowsePath:       names,
+			AttributeID:      ua.AttributeIDValue,
+		})
+	}
+	return f
+}
+
+// Where sets the expression which selects the events that are reported.
+func (f *EventFilter) Where(e *filter.Expr) *EventFilter {
+	f.where = e.ContentFilter()
+	return f
+}
+
+// Fields returns the names of the selected fields in the order of the
+// select clauses.
+func (f *EventFilter) Fields() []string {
+	return append([]string(nil), f.names...)
+}
+
+// EventFilter returns the EventFilter for the monitoring parameters.
+func (f *EventFilter) EventFilter() *ua.EventFilter {
+	where := f.where
+	if where == nil {
+		where = &ua.ContentFilter{}
+	}
+	return &ua.EventFilter{SelectClauses: f.selects, WhereClause: where}
+}
@@ -72,6 +72,18 @@ type DataChangeMessage struct {
 	NodeID *ua.NodeID
 }
 
+// EventHandler is a function that is called for each new event
+type EventHandler func(*Subscription, *EventMessage)
+
+// EventMessage represents an event from the server. The fields are keyed by the
+// names of the select clauses of the EventFilter of the request. It also includes
+// a reference to the event notifier and error (if any)
+type EventMessage struct {
+	Fields map[string]*ua.Variant
+	Error  error
+	NodeID *ua.NodeID
+}
+
 // NodeMonitor creates new subscriptions
 type NodeMonitor struct {
 	client           *opcua.Client
@@ -96,11 +108,14 @@ func (m *Item) NodeID() *ua.NodeID {
 	return m.nodeID
 }
 
-// Request is a struct to manage a request to monitor a node or modify a monitored node
+// Request is a struct to manage a request to monitor a node or modify a monitored node.
+// Requests with an EventFilter monitor the events of the node which must be an event
+// notifier.
 type Request struct {
 	NodeID               *ua.NodeID
 	MonitoringMode       ua.MonitoringMode
 	MonitoringParameters *ua.MonitoringParameters
+	EventFilter          *EventFilter
 	handle               uint32
 }
 
@@ -116,6 +131,9 @@ type Subscription struct {
 	mu               sync.RWMutex
 	handles          map[uint32]*ua.NodeID
 	itemLookup       map[uint32]Item
+	eventFields      map[uint32][]string
+	eventCh          chan<- *EventMessage
+	eventCB          EventHandler
 }
 
 // NewNodeMonitor creates a new NodeMonitor
@@ -139,6 +157,7 @@ func newSubscription(ctx context.Context, m *NodeMonitor, params *opcua.Subscrip
 		internalNotifyCh: make(chan *opcua.PublishNotificationData, notifyChanLength),
 		handles:          make(map[uint32]*ua.NodeID),
 		itemLookup:       make(map[uint32]Item),
+		eventFields:      make(map[uint32][]string),
 	}
 
 	var err error
@@ -188,6 +207,50 @@ func (m *NodeMonitor) ChanSubscribe(ctx context.Context, params *opcua.Subscript
 	return sub, nil
 }
 
+// SubscribeEvents creates a new callback-based subscription for the events of the
+// requests which must have an EventFilter.
+// The caller must call `Unsubscribe` to stop and clean up resources. Canceling the context
+// will also cause the subscription to stop, but `Unsubscribe` must still be called.
+func (m *NodeMonitor) SubscribeEvents(ctx context.Context, params *opcua.SubscriptionParameters, cb EventHandler, requests ...Request) (*Subscription, error) {
+	sub, err := newSubscription(ctx, m, params, DefaultCallbackBufferLen)
+	if err != nil {
+		return nil, err
+	}
+	sub.eventCB = cb
+
+	if _, err := sub.AddMonitorItems(ctx, requests...); err != nil {
+		sub.sub.Cancel(ctx)
+		return nil, err
+	}
+
+	go sub.pump(ctx, nil, nil)
+
+	return sub, nil
+}
+
+// ChanSubscribeEvents creates a new channel-based subscription for the events of the
+// requests which must have an EventFilter.
+// The channel should be deep enough to allow some buffering, otherwise `ErrSlowConsumer` is sent
+// via the monitor's `ErrHandler`.
+// The caller must call `Unsubscribe` to stop and clean up resources. Canceling the context
+// will also cause the subscription to stop, but `Unsubscribe` must still be called.
+func (m *NodeMonitor) ChanSubscribeEvents(ctx context.Context, params *opcua.SubscriptionParameters, ch chan<- *EventMessage, requests ...Request) (*Subscription, error) {
+	sub, err := newSubscription(ctx, m, params, 16)
+	if err != nil {
+		return nil, err
+	}
+	sub.eventCh = ch
+
+	if _, err := sub.AddMonitorItems(ctx, requests...); err != nil {
+		sub.sub.Cancel(ctx)
+		return nil, err
+	}
+
+	go sub.pump(ctx, nil, nil)
+
+	return sub, nil
+}
+
 func (s *Subscription) sendError(err error) {
 	if err != nil && s.monitor.errHandlerCB != nil {
 		go s.monitor.errHandlerCB(s.monitor.client, s, err)
@@ -216,7 +279,7 @@ func (s *Subscription) pump(ctx context.Context, notifyCh chan<- *DataChangeMess
 
 			// this is sort of a hack to emulate an `ErrSlowConsumer` error from the underlying subscription
 			// we check to see if the channel is "full" from the outside, and bail if it is.
-			if cb != nil && cap(s.internalNotifyCh) > 0 {
+			if (cb != nil || s.eventCB != nil) && cap(s.internalNotifyCh) > 0 {
 				if len(s.internalNotifyCh) == cap(s.internalNotifyCh) {
 					s.sendError(ErrSlowConsumer)
 					atomic.AddUint64(&s.dropped, 1)
@@ -253,9 +316,48 @@ func (s *Subscription) pump(ctx context.Context, notifyCh chan<- *DataChangeMess
 						cb(s, out)
 						atomic.AddUint64(&s.delivered, 1)
 					} else {
-						panic("notifyCh or cb must be set")
+						s.sendError(errors.Errorf("no data change handler for handle %d", item.ClientHandle))
 					}
 				}
+			case *ua.EventNotificationList:
+				for _, ev := range v.Events {
+					s.mu.RLock()
+					nid, ok := s.handles[ev.ClientHandle]
+					names := s.eventFields[ev.ClientHandle]
+					s.mu.RUnlock()
+
+					out := &EventMessage{}
+
+					if !ok {
+						out.Error = errors.Errorf("handle %d not found", ev.ClientHandle)
+					} else {
+						out.NodeID = nid
+						out.Fields = make(map[string]*ua.Variant, len(names))
+						for i, name := range names {
+							if i < len(ev.EventFields) {
+								out.Fields[name] = ev.EventFields[i]
+							}
+						}
+					}
+
+					if s.eventCh != nil {
+						select {
+						case s.eventCh <- out:
+							atomic.AddUint64(&s.delivered, 1)
+						default:
+							atomic.AddUint64(&s.dropped, 1)
+							s.sendError(ErrSlowConsumer)
+						}
+					} else if s.eventCB != nil {
+						s.eventCB(s, out)
+						atomic.AddUint64(&s.delivered, 1)
+					} else {
+						s.sendError(errors.Errorf("no event handler for handle %d", ev.ClientHandle))
+					}
+				}
+			case *opcua.SubscriptionRestored:
+				// sent with NotifyRestored. The monitored items deliver
+				// their values again.
 			default:
 				s.sendError(errors.Errorf("unknown message type: %T", msg.Value))
 			}
@@ -341,13 +443,21 @@ func (s *Subscription) AddMonitorItems(ctx context.Context, nodes ...Request) ([
 		s.handles[handle] = nodes[i].NodeID
 		nodes[i].handle = handle
 
-		request := opcua.NewMonitoredItemCreateRequestWithDefaults(node.NodeID, ua.AttributeIDValue, handle)
+		attrID := ua.AttributeIDValue
+		if node.EventFilter != nil {
+			attrID = ua.AttributeIDEventNotifier
+		}
+		request := opcua.NewMonitoredItemCreateRequestWithDefaults(node.NodeID, attrID, handle)
 		request.MonitoringMode = node.MonitoringMode
 
 		if node.MonitoringParameters != nil {
 			request.RequestedParameters = node.MonitoringParameters
 			request.RequestedParameters.ClientHandle = handle
 		}
+		if node.EventFilter != nil {
+			request.RequestedParameters.Filter = ua.NewExtensionObject(node.EventFilter.EventFilter())
+			s.eventFields[handle] = node.EventFilter.Fields()
+		}
 		toAdd = append(toAdd, request)
 	}
 	resp, err := s.sub.Monitor(ctx, ua.TimestampsToReturnBoth, toAdd...)
@@ -372,6 +482,7 @@ func (s *Subscription) AddMonitorItems(ctx context.Context, nodes ...Request) ([
 			})
 			// Clean up the handle for the failed item
 			delete(s.handles, nodes[i].handle)
+			delete(s.eventFields, nodes[i].handle)
 			continue
 		}
 		mn := Item{
@@ -438,6 +549,7 @@ func (s *Subscription) RemoveMonitorItems(ctx context.Context, items ...Item) er
 		}
 		delete(s.itemLookup, item.id)
 		delete(s.handles, item.handle)
+		delete(s.eventFields, item.handle)
 		toRemove = append(toRemove, item.id)
 	}
 
@@ -25,13 +25,16 @@ func (s *AttributeService) Read(sc *uasc.SecureChannel, r ua.Request, reqID uint
 		return nil, err
 	}
 
+	sess := s.srv.Session(req.RequestHeader)
+
 	results := make([]*ua.DataValue, len(req.NodesToRead))
 	for i, n := range req.NodesToRead {
 		if s.srv.cfg.logger != nil {
 			s.srv.cfg.logger.Debug("read: node=%s attr=%s", n.NodeID, n.AttributeID)
 		}
 
-		ns, err := s.srv.Namespace(int(n.NodeID.Namespace()))
+		nodeID := sess.resolveNodeID(n.NodeID)
+		ns, err := s.srv.Namespace(int(nodeID.Namespace()))
 		if err != nil {
 			results[i] = &ua.DataValue{
 				EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
@@ -40,8 +43,15 @@ func (s *AttributeService) Read(sc *uasc.SecureChannel, r ua.Request, reqID uint
 			}
 			continue
 		}
-		results[i] = ns.Attribute(n.NodeID, n.AttributeID)
-
+		if status := s.srv.allowed(sess, nodeID, readPermission(n.AttributeID)); status != ua.StatusOK {
+			results[i] = &ua.DataValue{
+				EncodingMask:    ua.DataValueServerTimestamp | ua.DataValueStatusCode,
+				ServerTimestamp: time.Now(),
+				Status:          status,
+			}
+			continue
+		}
+		results[i] = s.srv.userAttribute(sess, ns, nodeID, n.AttributeID, ns.Attribute(nodeID, n.AttributeID))
 	}
 
 	response := &ua.ReadResponse{
@@ -62,7 +72,46 @@ func (s *AttributeService) HistoryRead(sc *uasc.SecureChannel, r ua.Request, req
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if req.HistoryReadDetails == nil || req.HistoryReadDetails.Value == nil {
+		return &ua.HistoryReadResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadHistoryOperationInvalid),
+		}, nil
+	}
+
+	// Neither is only valid when reading events.
+	_, event := req.HistoryReadDetails.Value.(*ua.ReadEventDetails)
+	if req.TimestampsToReturn > ua.TimestampsToReturnNeither || (req.TimestampsToReturn == ua.TimestampsToReturnNeither && !event) {
+		return &ua.HistoryReadResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadTimestampsToReturnInvalid),
+		}, nil
+	}
+
+	// every node needs its own aggregate.
+	if d, ok := req.HistoryReadDetails.Value.(*ua.ReadProcessedDetails); ok && !req.ReleaseContinuationPoints && len(d.AggregateType) != len(req.NodesToRead) {
+		return &ua.HistoryReadResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadAggregateListMismatch),
+		}, nil
+	}
+
+	results := make([]*ua.HistoryReadResult, len(req.NodesToRead))
+	for i, n := range req.NodesToRead {
+		if s.srv.cfg.logger != nil {
+			s.srv.cfg.logger.Debug("history read: node=%s", n.NodeID)
+		}
+		results[i] = s.historyRead(sess, req, i)
+	}
+
+	return &ua.HistoryReadResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.10.4
@@ -73,6 +122,8 @@ func (s *AttributeService) Write(sc *uasc.SecureChannel, r ua.Request, reqID uin
 		return nil, err
 	}
 
+	sess := s.srv.Session(req.RequestHeader)
+
 	status := make([]ua.StatusCode, len(req.NodesToWrite))
 
 	for i := range req.NodesToWrite {
@@ -81,14 +132,22 @@ func (s *AttributeService) Write(sc *uasc.SecureChannel, r ua.Request, reqID uin
 			s.srv.cfg.logger.Debug("write: node=%s attr=%v", n.NodeID, n.AttributeID)
 		}
 
-		ns, err := s.srv.Namespace(int(n.NodeID.Namespace()))
+		nodeID := sess.resolveNodeID(n.NodeID)
+		ns, err := s.srv.Namespace(int(nodeID.Namespace()))
 		if err != nil {
 			status[i] = ua.StatusBadNodeIDUnknown
 			continue
 		}
 
-		status[i] = ns.SetAttribute(n.NodeID, n.AttributeID, n.Value)
-
+		if status[i] = s.srv.allowed(sess, nodeID, writePermission(n.AttributeID)); status[i] != ua.StatusOK {
+			continue
+		}
+		dv, code := s.srv.checkWrite(ns, nodeID, n)
+		if code != ua.StatusOK {
+			status[i] = code
+			continue
+		}
+		status[i] = ns.SetAttribute(nodeID, n.AttributeID, dv)
 	}
 	response := &ua.WriteResponse{
 		ResponseHeader: &ua.ResponseHeader{
@@ -117,5 +176,23 @@ func (s *AttributeService) HistoryUpdate(sc *uasc.SecureChannel, r ua.Request, r
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	results := make([]*ua.HistoryUpdateResult, len(req.HistoryUpdateDetails))
+	for i, d := range req.HistoryUpdateDetails {
+		if s.srv.cfg.logger != nil {
+			s.srv.cfg.logger.Debug("history update: details=%T", d.Value)
+		}
+		results[i] = s.historyUpdate(sess, d)
+	}
+
+	return &ua.HistoryUpdateResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
 }
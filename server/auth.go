@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uapolicy"
+	"github.com/gopcua/opcua/uasc"
+)
+
+// Identity is the user of a session. It is the result of the
+// authentication of the user identity token in ActivateSession.
+type Identity struct {
+	// TokenType is the type of the user identity token.
+	TokenType ua.UserTokenType
+
+	// UserName is the name of the user of a user name token.
+	UserName string
+
+	// Certificate is the DER encoded certificate of the user of an X509
+	// token.
+	Certificate []byte
+
+	// TokenData is the token of the user of an issued token.
+	TokenData []byte
+
+	// Groups are the groups and roles of the user which the authenticator
+	// granted, e.g. from the claims of an issued token. They are matched
+	// by the identity mapping rules with the GroupId and Role criteria.
+	Groups []string
+}
+
+// anonymousIdentity is the key of the identity of anonymous users.
+const anonymousIdentity = "anonymous"
+
+// newIdentity returns the identity of the user of a user identity token.
+func newIdentity(token any) *Identity {
+	switch tok := token.(type) {
+	case *ua.UserNameIdentityToken:
+		return &Identity{TokenType: ua.UserTokenTypeUserName, UserName: tok.UserName}
+	case *ua.X509IdentityToken:
+		return &Identity{TokenType: ua.UserTokenTypeCertificate, Certificate: tok.CertificateData}
+	case *ua.IssuedIdentityToken:
+		return &Identity{TokenType: ua.UserTokenTypeIssuedToken, TokenData: tok.TokenData}
+	default:
+		return &Identity{TokenType: ua.UserTokenTypeAnonymous}
+	}
+}
+
+// key returns a string which identifies the user. A nil identity is an
+// anonymous user.
+func (id *Identity) key() string {
+	if id == nil {
+		return anonymousIdentity
+	}
+	switch id.TokenType {
+	case ua.UserTokenTypeUserName:
+		return "username:" + id.UserName
+	case ua.UserTokenTypeCertificate:
+		return fmt.Sprintf("x509:%x", sha256.Sum256(id.Certificate))
+	case ua.UserTokenTypeIssuedToken:
+		return fmt.Sprintf("issued:%x", sha256.Sum256(id.TokenData))
+	default:
+		return anonymousIdentity
+	}
+}
+
+// Authenticator authenticates the users of sessions.
+type Authenticator interface {
+	// Authenticate is called with the decoded user identity token of an
+	// ActivateSession request and ctx carries the session. The token is
+	// one of *ua.AnonymousIdentityToken, *ua.UserNameIdentityToken,
+	// *ua.X509IdentityToken and *ua.IssuedIdentityToken. The password
+	// of a user name token and the data of an issued token are already
+	// decrypted and the signature of an X509 token is verified.
+	//
+	// Authenticate returns the identity of the user. If it returns a
+	// nil identity the identity is derived from the token. The session
+	// is rejected if it returns an error. If the error is a
+	// ua.StatusCode then it is returned to the client, otherwise the
+	// client gets BadUserAccessDenied.
+	Authenticate(ctx context.Context, token any) (*Identity, error)
+}
+
+// AuthenticatorFunc is a function which implements the Authenticator
+// interface.
+type AuthenticatorFunc func(ctx context.Context, token any) (*Identity, error)
+
+// Authenticate calls f(ctx, token).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, token any) (*Identity, error) {
+	return f(ctx, token)
+}
+
+// SessionIdentity returns the identity of the user of the session on
+// whose behalf the server is handling a request, e.g. in a method call.
+// It returns nil if ctx does not carry a session.
+func SessionIdentity(ctx context.Context) *Identity {
+	s := sessionFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.identity
+}
+
+// authenticate checks the user identity token of an ActivateSession
+// request for the session and returns the identity of the user. nonce is
+// the last nonce the server sent to the client.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.6.3
+func (s *Server) authenticate(sc *uasc.SecureChannel, sess *session, req *ua.ActivateSessionRequest, nonce []byte) (*Identity, error) {
+	// a null token is an anonymous user.
+	var token any = &ua.AnonymousIdentityToken{}
+	if req.UserIdentityToken != nil && req.UserIdentityToken.Value != nil {
+		token = req.UserIdentityToken.Value
+	}
+
+	switch tok := token.(type) {
+	case *ua.AnonymousIdentityToken:
+		// clients which do not know the policy id of the endpoint send
+		// a default one. Hence, only the auth mode is checked.
+		if !s.anonymousEnabled() {
+			return nil, ua.StatusBadIdentityTokenRejected
+		}
+
+	case *ua.UserNameIdentityToken:
+		p := s.userTokenPolicy(sc, tok.PolicyID, ua.UserTokenTypeUserName)
+		if p == nil {
+			return nil, ua.StatusBadIdentityTokenInvalid
+		}
+		pass, err := decryptUserSecret(sc, sess, p.SecurityPolicyURI, tok.EncryptionAlgorithm, tok.Password, nonce)
+		if err != nil {
+			if s.cfg.logger != nil {
+				s.cfg.logger.Warn("error decrypting user password: %s", err)
+			}
+			return nil, ua.StatusBadIdentityTokenInvalid
+		}
+		t := *tok
+		t.Password, t.EncryptionAlgorithm = pass, ""
+		token = &t
+
+	case *ua.X509IdentityToken:
+		p := s.userTokenPolicy(sc, tok.PolicyID, ua.UserTokenTypeCertificate)
+		if p == nil {
+			return nil, ua.StatusBadIdentityTokenInvalid
+		}
+		var sig []byte
+		if req.UserTokenSignature != nil {
+			sig = req.UserTokenSignature.Signature
+		}
+		if err := sc.VerifyUserTokenSignature(p.SecurityPolicyURI, tok.CertificateData, nonce, sig); err != nil {
+			if s.cfg.logger != nil {
+				s.cfg.logger.Warn("error verifying user token signature: %s", err)
+			}
+			return nil, ua.StatusBadUserSignatureInvalid
+		}
+
+	case *ua.IssuedIdentityToken:
+		p := s.userTokenPolicy(sc, tok.PolicyID, ua.UserTokenTypeIssuedToken)
+		if p == nil {
+			return nil, ua.StatusBadIdentityTokenInvalid
+		}
+		// issued tokens are encrypted like passwords.
+		data, err := decryptUserSecret(sc, sess, p.SecurityPolicyURI, tok.EncryptionAlgorithm, tok.TokenData, nonce)
+		if err != nil {
+			if s.cfg.logger != nil {
+				s.cfg.logger.Warn("error decrypting issued token: %s", err)
+			}
+			return nil, ua.StatusBadIdentityTokenInvalid
+		}
+		t := *tok
+		t.TokenData, t.EncryptionAlgorithm = data, ""
+		token = &t
+
+	default:
+		return nil, ua.StatusBadIdentityTokenInvalid
+	}
+
+	if s.cfg.authenticator == nil {
+		if _, ok := token.(*ua.AnonymousIdentityToken); !ok {
+			return nil, ua.StatusBadUserAccessDenied
+		}
+		return newIdentity(token), nil
+	}
+
+	id, err := s.cfg.authenticator.Authenticate(withSession(context.Background(), sess), token)
+	if err != nil {
+		if code, ok := err.(ua.StatusCode); ok {
+			return nil, code
+		}
+		if s.cfg.logger != nil {
+			s.cfg.logger.Warn("user access denied: %s", err)
+		}
+		return nil, ua.StatusBadUserAccessDenied
+	}
+	if id == nil {
+		id = newIdentity(token)
+	}
+	return id, nil
+}
+
+// decryptUserSecret decrypts the password of a user name token or the data
+// of an issued token. The ECC security policies encrypt it as an
+// EccEncryptedSecret with the ephemeral key of the session and the other
+// policies like a password.
+func decryptUserSecret(sc *uasc.SecureChannel, sess *session, policyURI, alg string, secret, nonce []byte) ([]byte, error) {
+	if policyURI == "" {
+		policyURI = sc.SecurityPolicyURI()
+	}
+	if uapolicy.IsECC(policyURI) {
+		return sc.DecryptUserSecret(policyURI, secret, sess.ephemeralKey, nonce)
+	}
+	return sc.DecryptUserPassword(policyURI, alg, secret, nonce)
+}
+
+// anonymousEnabled returns true if the server accepts anonymous users.
+// This is the case when anonymous users are enabled or when no auth mode
+// is enabled at all.
+func (s *Server) anonymousEnabled() bool {
+	if len(s.cfg.enabledAuth) == 0 {
+		return true
+	}
+	for _, a := range s.cfg.enabledAuth {
+		if a.tokenType == ua.UserTokenTypeAnonymous {
+			return true
+		}
+	}
+	return false
+}
+
+// userTokenPolicy returns the user token policy with the policy id and the
+// token type of the endpoints which match the security policy and mode of
+// the secure channel. It returns nil if there is none.
+func (s *Server) userTokenPolicy(sc *uasc.SecureChannel, policyID string, tokenType ua.UserTokenType) *ua.UserTokenPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ep := range s.endpoints {
+		if ep.SecurityPolicyURI != sc.SecurityPolicyURI() || ep.SecurityMode != sc.SecurityMode() {
+			continue
+		}
+		for _, p := range ep.UserIdentityTokens {
+			if p.PolicyID == policyID && p.TokenType == tokenType {
+				return p
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
+	"github.com/gopcua/opcua/uapolicy"
+	"github.com/gopcua/opcua/uasc"
+)
+
+// verifyCertificate validates the certificate of a client with the trust
+// store of the server. All certificates are valid without a trust store.
+func (s *Server) verifyCertificate(cert []byte) error {
+	if s.cfg.trustStore == nil {
+		return nil
+	}
+	if err := s.cfg.trustStore.Verify(cert); err != nil {
+		if s.cfg.logger != nil {
+			s.cfg.logger.Warn("rejected client certificate: %s", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// verifyClientCertificate checks that the client certificate of a
+// CreateSession request is the certificate of the secure channel,
+// validates it and checks that the application uri of the client matches
+// the uri in the certificate. The certificate is only checked if the
+// security policy of the secure channel is not None and only validated
+// with a trust store.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.6.2
+func (s *Server) verifyClientCertificate(sc *uasc.SecureChannel, req *ua.CreateSessionRequest) error {
+	if sc.SecurityPolicyURI() == ua.SecurityPolicyURINone {
+		return nil
+	}
+	if !sameLeaf(req.ClientCertificate, sc.RemoteCertificate()) {
+		if s.cfg.logger != nil {
+			s.cfg.logger.Warn("client certificate is not the certificate of the secure channel")
+		}
+		return ua.StatusBadCertificateInvalid
+	}
+	if s.cfg.trustStore == nil {
+		return nil
+	}
+	if err := s.verifyCertificate(req.ClientCertificate); err != nil {
+		return err
+	}
+	var appURI string
+	if req.ClientDescription != nil {
+		appURI = req.ClientDescription.ApplicationURI
+	}
+	if err := uacert.VerifyApplicationURI(req.ClientCertificate, appURI); err != nil {
+		if s.cfg.logger != nil {
+			s.cfg.logger.Warn("application uri %q does not match the client certificate", appURI)
+		}
+		return err
+	}
+	return nil
+}
+
+// sameLeaf returns true if both DER encoded chains start with the same
+// certificate.
+func sameLeaf(a, b []byte) bool {
+	ca, err := uapolicy.ParseCertificates(a)
+	if err != nil || len(ca) == 0 {
+		return false
+	}
+	cb, err := uapolicy.ParseCertificates(b)
+	if err != nil || len(cb) == 0 {
+		return false
+	}
+	return ca[0].Equal(cb[0])
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"io"
 	mrand "math/rand"
@@ -50,11 +52,19 @@ func newChannelBroker(logger Logger) *channelBroker {
 // RegisterConn connects a new UACP connection to the channel broker's list
 // of connections and starts waiting for data on it.  Data is pushed onto the broker's
 // Response channel
-// Blocks until the context is done, the connection closes, or a critical error
-func (c *channelBroker) RegisterConn(ctx context.Context, conn *uacp.Conn, localCert []byte, localKey *rsa.PrivateKey) error {
+// localKey is the *rsa.PrivateKey or the *ecdsa.PrivateKey of localCert.
+// Blocks until the context is done, the connection closes, or a critical error.
+// verify validates the certificate of the client in OpenSecureChannel.
+func (c *channelBroker) RegisterConn(ctx context.Context, conn *uacp.Conn, localCert []byte, localKey crypto.PrivateKey, verify func([]byte) error) error {
 	cfg := defaultChannelConfig()
 	cfg.Certificate = localCert
-	cfg.LocalKey = localKey
+	switch key := localKey.(type) {
+	case *rsa.PrivateKey:
+		cfg.LocalKey = key
+	case *ecdsa.PrivateKey:
+		cfg.LocalECKey = key
+	}
+	cfg.VerifyCertificate = verify
 
 	c.mu.Lock()
 	c.secureChannelID++
@@ -109,6 +119,8 @@ outer:
 				if c.logger != nil {
 					c.logger.Error("Secure Channel %d error: %s", secureChannelID, msg.Err)
 				}
+				// the connection cannot be used after an error.
+				conn.Close()
 				break outer
 			}
 			// todo(fs): honor ctx
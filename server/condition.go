@@ -0,0 +1,897 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gopcua/opcua/aggregate"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+var (
+	conditionType                = ua.NewNumericNodeID(0, id.ConditionType)
+	acknowledgeableConditionType = ua.NewNumericNodeID(0, id.AcknowledgeableConditionType)
+	alarmConditionType           = ua.NewNumericNodeID(0, id.AlarmConditionType)
+	limitAlarmType               = ua.NewNumericNodeID(0, id.LimitAlarmType)
+	exclusiveLimitAlarmType      = ua.NewNumericNodeID(0, id.ExclusiveLimitAlarmType)
+	nonExclusiveLimitAlarmType   = ua.NewNumericNodeID(0, id.NonExclusiveLimitAlarmType)
+)
+
+// Limits are the limits of a limit alarm. Limits which are nil are not
+// evaluated.
+type Limits struct {
+	HighHigh *float64
+	High     *float64
+	Low      *float64
+	LowLow   *float64
+}
+
+// limit is the state of a limit alarm.
+type limit int
+
+const (
+	limitNone limit = iota
+	limitHighHigh
+	limitHigh
+	limitLow
+	limitLowLow
+)
+
+// limitStates are the states of the ExclusiveLimitStateMachineType.
+var limitStates = map[limit]struct {
+	name string
+	id   uint32
+}{
+	limitHighHigh: {"HighHigh", id.ExclusiveLimitStateMachineType_HighHigh},
+	limitHigh:     {"High", id.ExclusiveLimitStateMachineType_High},
+	limitLow:      {"Low", id.ExclusiveLimitStateMachineType_Low},
+	limitLowLow:   {"LowLow", id.ExclusiveLimitStateMachineType_LowLow},
+}
+
+// shelving is the state of the ShelvingState of an alarm.
+type shelving int
+
+const (
+	unshelved shelving = iota
+	timedShelved
+	oneShotShelved
+)
+
+var shelvingStates = map[shelving]struct {
+	name string
+	id   uint32
+}{
+	unshelved:      {"Unshelved", id.ShelvedStateMachineType_Unshelved},
+	timedShelved:   {"Timed Shelved", id.ShelvedStateMachineType_TimedShelved},
+	oneShotShelved: {"One Shot Shelved", id.ShelvedStateMachineType_OneShotShelved},
+}
+
+// ConditionOption is an option function type to modify a Condition.
+type ConditionOption func(*Condition)
+
+// ConditionSeverity sets the severity of the events of the condition. The
+// default is 500.
+func ConditionSeverity(severity uint16) ConditionOption {
+	return func(c *Condition) {
+		c.severity = severity
+	}
+}
+
+// ConditionConfirm requires that acknowledged states of the condition are
+// also confirmed before they are no longer retained.
+func ConditionConfirm() ConditionOption {
+	return func(c *Condition) {
+		c.confirm = true
+	}
+}
+
+// ConditionBranches keeps previous states of the condition which still
+// have to be acknowledged or confirmed in branches when the condition
+// changes its state.
+func ConditionBranches() ConditionOption {
+	return func(c *Condition) {
+		c.branching = true
+	}
+}
+
+// ConditionLimits evaluates a limit alarm with the value of the input node
+// against the limits. The alarm is evaluated whenever ChangeNotification
+// is called for the input node.
+func ConditionLimits(input *ua.NodeID, limits Limits) ConditionOption {
+	return func(c *Condition) {
+		c.input = input
+		c.limits = limits
+	}
+}
+
+// Condition is an instance of the ConditionType or one of its subtypes
+// whose state is maintained by the server. Every change of the state is
+// reported with an event of the condition type by the source of the
+// condition.
+//
+// The condition node only has a HasTypeDefinition reference and the
+// ShelvingState object of alarms. Clients get the state of a condition from
+// its events and from ConditionRefresh.
+//
+// https://reference.opcfoundation.org/Core/Part9/v105/docs/5
+type Condition struct {
+	srv *Server
+
+	id         *ua.NodeID
+	source     *ua.NodeID
+	typeID     *ua.NodeID
+	shelvingID *ua.NodeID
+	name       string
+
+	severity  uint16
+	confirm   bool
+	branching bool
+	input     *ua.NodeID
+	limits    Limits
+
+	mu       sync.Mutex
+	enabled  bool
+	shelving shelving
+	unshelve time.Time
+	timer    *time.Timer
+	trunk    *conditionState
+	branches []*conditionState
+}
+
+// conditionState is the state of a condition or of one of its branches.
+type conditionState struct {
+	branchID *ua.NodeID
+
+	eventID []byte
+	time    time.Time
+
+	active    bool
+	acked     bool
+	confirmed bool
+	limit     limit
+
+	severity     uint16
+	lastSeverity uint16
+	message      *ua.LocalizedText
+	comment      *ua.LocalizedText
+	clientUserID string
+}
+
+// AddCondition creates a condition node with the given id and condition
+// type for the source node. The condition is created enabled, inactive and
+// acknowledged. Limit alarms are evaluated right away.
+func (s *Server) AddCondition(nodeID, source, typeID *ua.NodeID, name string, opts ...ConditionOption) (*Condition, error) {
+	src := s.nodeOrNil(source)
+	if src == nil {
+		return nil, ua.StatusBadNodeIDUnknown
+	}
+	if typeID == nil || !s.isSubtype(typeID, conditionType) {
+		return nil, ua.StatusBadTypeDefinitionInvalid
+	}
+	ns, err := s.Namespace(int(nodeID.Namespace()))
+	if err != nil {
+		return nil, ua.StatusBadNodeIDRejected
+	}
+	if s.Node(nodeID) != nil {
+		return nil, ua.StatusBadNodeIDExists
+	}
+
+	c := &Condition{
+		srv:      s,
+		id:       nodeID,
+		source:   source,
+		typeID:   typeID,
+		name:     name,
+		severity: 500,
+		enabled:  true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.trunk = &conditionState{
+		branchID:     ua.NewTwoByteNodeID(0),
+		acked:        true,
+		confirmed:    true,
+		severity:     c.severity,
+		lastSeverity: c.severity,
+		message:      ua.NewLocalizedText(name),
+		comment:      ua.NewLocalizedText(""),
+	}
+
+	n := ns.AddNode(newConditionNode(nodeID, name, typeID))
+	src.AddRef(n, id.HasCondition, true)
+	n.AddRef(src, id.HasCondition, false)
+
+	if c.alarm() {
+		// the shelving methods are called on the ShelvingState object.
+		c.shelvingID = ua.NewStringNodeID(nodeID.Namespace(), nodeIDName(nodeID)+".ShelvingState")
+		st := ns.AddNode(newConditionNode(c.shelvingID, "ShelvingState", ua.NewNumericNodeID(0, id.ShelvedStateMachineType)))
+		n.AddRef(st, id.HasComponent, true)
+	}
+
+	s.mu.Lock()
+	s.conditions[nodeID.String()] = c
+	if c.shelvingID != nil {
+		s.conditions[c.shelvingID.String()] = c
+	}
+	s.mu.Unlock()
+
+	if c.input != nil {
+		c.evaluate()
+	}
+	return c, nil
+}
+
+// newConditionNode creates an object node of the given object type.
+func newConditionNode(nodeID *ua.NodeID, name string, typeID *ua.NodeID) *Node {
+	return NewNode(
+		nodeID,
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDNodeClass:     DataValueFromValue(uint32(ua.NodeClassObject)),
+			ua.AttributeIDBrowseName:    DataValueFromValue(&ua.QualifiedName{NamespaceIndex: nodeID.Namespace(), Name: name}),
+			ua.AttributeIDDisplayName:   DataValueFromValue(ua.NewLocalizedText(name)),
+			ua.AttributeIDEventNotifier: DataValueFromValue(byte(ua.EventNotifierTypeNone)),
+		},
+		[]*ua.ReferenceDescription{{
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HasTypeDefinition),
+			IsForward:       true,
+			NodeID:          ua.NewExpandedNodeID(typeID, "", 0),
+			BrowseName:      &ua.QualifiedName{},
+			DisplayName:     &ua.LocalizedText{},
+			NodeClass:       ua.NodeClassObjectType,
+			TypeDefinition:  ua.NewTwoByteExpandedNodeID(0),
+		}},
+		nil,
+	)
+}
+
+// nodeIDName returns the identifier of a string node id or the string
+// form of other node ids.
+func nodeIDName(nodeID *ua.NodeID) string {
+	if nodeID.Type() == ua.NodeIDTypeString {
+		return nodeID.StringID()
+	}
+	return nodeID.String()
+}
+
+// Condition returns the condition with the given node id or nil if there
+// is no such condition.
+func (s *Server) Condition(nodeID *ua.NodeID) *Condition {
+	if nodeID == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conditions[nodeID.String()]
+}
+
+// allConditions returns the conditions of the server.
+func (s *Server) allConditions() []*Condition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*Condition
+	for k, c := range s.conditions {
+		// conditions are also indexed by their ShelvingState object.
+		if k == c.id.String() {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// ID returns the node id of the condition.
+func (c *Condition) ID() *ua.NodeID {
+	return c.id
+}
+
+// Enabled reports whether the condition is enabled.
+func (c *Condition) Enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enabled
+}
+
+// Active reports whether the alarm is active.
+func (c *Condition) Active() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.trunk.active
+}
+
+// Acked reports whether the current state of the condition is
+// acknowledged.
+func (c *Condition) Acked() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.trunk.acked
+}
+
+// Confirmed reports whether the current state of the condition is
+// confirmed.
+func (c *Condition) Confirmed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.trunk.confirmed
+}
+
+// Branches returns the number of branches of the condition.
+func (c *Condition) Branches() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.branches)
+}
+
+// SetActive changes the ActiveState of an alarm and reports the new state
+// with the message. It returns StatusBadTypeDefinitionInvalid if the
+// condition is not an alarm.
+func (c *Condition) SetActive(active bool, message string) error {
+	if !c.alarm() {
+		return ua.StatusBadTypeDefinitionInvalid
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setState(active, limitNone, message)
+	return nil
+}
+
+// evaluate sets the state of a limit alarm from the value of its input.
+func (c *Condition) evaluate() {
+	v, ok := aggregate.ToFloat(c.srv.attributeValue(c.input, ua.AttributeIDValue))
+	if !ok {
+		return
+	}
+	l := c.limits.state(v)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l == c.trunk.limit {
+		return
+	}
+	msg := "The value is within its limits"
+	if l != limitNone {
+		msg = "The value exceeds the " + limitStates[l].name + " limit"
+	}
+	c.setState(l != limitNone, l, msg)
+}
+
+// state returns the limit which is exceeded by the value.
+func (l Limits) state(v float64) limit {
+	switch {
+	case l.HighHigh != nil && v >= *l.HighHigh:
+		return limitHighHigh
+	case l.High != nil && v >= *l.High:
+		return limitHigh
+	case l.LowLow != nil && v <= *l.LowLow:
+		return limitLowLow
+	case l.Low != nil && v <= *l.Low:
+		return limitLow
+	default:
+		return limitNone
+	}
+}
+
+// setState changes the active and limit state of the trunk. A previous
+// state which still has to be acknowledged or confirmed is moved into a
+// branch if the condition supports branches. The caller must hold c.mu.
+func (c *Condition) setState(active bool, l limit, message string) {
+	t := c.trunk
+	if t.active == active && t.limit == l {
+		return
+	}
+
+	if c.branching && c.enabled && (!t.acked || !t.confirmed) {
+		b := *t
+		b.branchID = ua.NewGUIDNodeID(c.id.Namespace(), uuid.NewString())
+		c.branches = append(c.branches, &b)
+		c.fire(&b)
+		// the branch has to be acknowledged instead of the new state.
+		t.acked, t.confirmed = true, true
+	}
+
+	// an alarm becomes unacknowledged when it becomes active or changes
+	// its limit while it is active.
+	if active {
+		t.acked = false
+		t.confirmed = !c.confirm
+	}
+	if !active && c.shelving == oneShotShelved {
+		c.setShelving(unshelved, 0)
+	}
+	t.active, t.limit = active, l
+	t.message = ua.NewLocalizedText(message)
+	// disabled conditions report their state when they are enabled.
+	if c.enabled {
+		c.fire(t)
+	}
+}
+
+// setShelving changes the shelving state. Timed shelving ends after d.
+// The caller must hold c.mu.
+func (c *Condition) setShelving(s shelving, d time.Duration) {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.shelving, c.unshelve = s, time.Time{}
+	if s != timedShelved {
+		return
+	}
+	c.unshelve = time.Now().Add(d)
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.timer != timer {
+			return
+		}
+		c.timer = nil
+		c.shelving, c.unshelve = unshelved, time.Time{}
+		if c.enabled {
+			c.trunk.message = ua.NewLocalizedText("The alarm is unshelved")
+			c.fire(c.trunk)
+		}
+	})
+	c.timer = timer
+}
+
+// alarm reports whether the condition is an alarm.
+func (c *Condition) alarm() bool {
+	return c.srv.isSubtype(c.typeID, alarmConditionType)
+}
+
+// acknowledgeable reports whether the condition can be acknowledged.
+func (c *Condition) acknowledgeable() bool {
+	return c.srv.isSubtype(c.typeID, acknowledgeableConditionType)
+}
+
+// retain reports whether the state is of interest for clients which
+// refresh their conditions. Branches are previous states which are only
+// retained until they are acknowledged and confirmed. The caller must hold
+// c.mu.
+func (c *Condition) retain(st *conditionState) bool {
+	if !c.enabled {
+		return false
+	}
+	return (st == c.trunk && st.active) || !st.acked || !st.confirmed
+}
+
+// fire reports the state of the condition with a new event. Disabled
+// conditions only report that they are disabled. Branches which are no
+// longer retained are removed. The caller must hold c.mu.
+func (c *Condition) fire(st *conditionState) {
+	st.eventID = newEventID()
+	st.time = time.Now()
+	ev := c.event(st)
+
+	if st != c.trunk && !c.retain(st) {
+		for i, b := range c.branches {
+			if b == st {
+				c.branches = append(c.branches[:i], c.branches[i+1:]...)
+				break
+			}
+		}
+	}
+
+	for _, notifier := range c.srv.eventNotifiers(c.source) {
+		c.srv.reportEvent(notifier, ev)
+	}
+}
+
+// event returns the event for the state of the condition. The caller must
+// hold c.mu.
+func (c *Condition) event(st *conditionState) *Event {
+	f := map[string]any{
+		"EventId":     st.eventID,
+		"EventType":   c.typeID,
+		"SourceNode":  c.source,
+		"SourceName":  c.srv.Node(c.source).BrowseName().Name,
+		"Time":        st.time,
+		"ReceiveTime": st.time,
+		"Message":     st.message,
+		"Severity":    st.severity,
+
+		// the empty path selects the ConditionId.
+		"":                   c.id,
+		"ConditionClassId":   ua.NewNumericNodeID(0, id.BaseConditionClassType),
+		"ConditionClassName": ua.NewLocalizedText("BaseConditionClass"),
+		"ConditionName":      c.name,
+		"BranchId":           st.branchID,
+		"Retain":             c.retain(st),
+		"Quality":            ua.StatusOK,
+		"LastSeverity":       st.lastSeverity,
+		"Comment":            st.comment,
+		"ClientUserId":       st.clientUserID,
+	}
+	twoState(f, "EnabledState", c.enabled, "Enabled", "Disabled")
+
+	if c.acknowledgeable() {
+		twoState(f, "AckedState", st.acked, "Acknowledged", "Unacknowledged")
+		if c.confirm {
+			twoState(f, "ConfirmedState", st.confirmed, "Confirmed", "Unconfirmed")
+		}
+	}
+
+	if c.alarm() {
+		twoState(f, "ActiveState", st.active, "Active", "Inactive")
+		f["SuppressedOrShelved"] = c.shelving != unshelved
+		f["ShelvingState/CurrentState"] = ua.NewLocalizedText(shelvingStates[c.shelving].name)
+		f["ShelvingState/CurrentState/Id"] = ua.NewNumericNodeID(0, shelvingStates[c.shelving].id)
+		if c.shelving == timedShelved {
+			f["ShelvingState/UnshelveTime"] = float64(time.Until(c.unshelve) / time.Millisecond)
+		}
+		if c.input != nil {
+			f["InputNode"] = c.input
+		}
+	}
+
+	if c.srv.isSubtype(c.typeID, limitAlarmType) {
+		for name, v := range map[string]*float64{
+			"HighHighLimit": c.limits.HighHigh,
+			"HighLimit":     c.limits.High,
+			"LowLimit":      c.limits.Low,
+			"LowLowLimit":   c.limits.LowLow,
+		} {
+			if v != nil {
+				f[name] = *v
+			}
+		}
+	}
+
+	switch {
+	case c.srv.isSubtype(c.typeID, exclusiveLimitAlarmType):
+		if s, ok := limitStates[st.limit]; ok {
+			f["LimitState/CurrentState"] = ua.NewLocalizedText(s.name)
+			f["LimitState/CurrentState/Id"] = ua.NewNumericNodeID(0, s.id)
+		}
+	case c.srv.isSubtype(c.typeID, nonExclusiveLimitAlarmType):
+		// the high and low states are also active when the high high and
+		// low low limits are exceeded.
+		twoState(f, "HighHighState", st.limit == limitHighHigh, "HighHigh active", "HighHigh inactive")
+		twoState(f, "HighState", st.limit == limitHigh || st.limit == limitHighHigh, "High active", "High inactive")
+		twoState(f, "LowState", st.limit == limitLow || st.limit == limitLowLow, "Low active", "Low inactive")
+		twoState(f, "LowLowState", st.limit == limitLowLow, "LowLow active", "LowLow inactive")
+	}
+
+	ev := &Event{Fields: make(map[string]*ua.Variant, len(f))}
+	for k, v := range f {
+		ev.Fields[k] = ua.MustVariant(v)
+	}
+	return ev
+}
+
+// twoState sets the fields of a TwoStateVariableType.
+func twoState(f map[string]any, name string, v bool, trueState, falseState string) {
+	f[name+"/Id"] = v
+	if v {
+		f[name] = ua.NewLocalizedText(trueState)
+	} else {
+		f[name] = ua.NewLocalizedText(falseState)
+	}
+}
+
+// bindConditionMethods binds the methods of the condition types which are
+// executed on the conditions of the server.
+func (s *Server) bindConditionMethods() {
+	methods := []struct {
+		id    uint32
+		fn    any
+		names []string
+	}{
+		{id.ConditionType_Enable, s.enableCondition, nil},
+		{id.ConditionType_Disable, s.disableCondition, nil},
+		{id.ConditionType_AddComment, s.addConditionComment, []string{"EventId", "Comment"}},
+		{id.ConditionType_ConditionRefresh, s.conditionRefresh, []string{"SubscriptionId"}},
+		{id.ConditionType_ConditionRefresh2, s.conditionRefresh2, []string{"SubscriptionId", "MonitoredItemId"}},
+		{id.AcknowledgeableConditionType_Acknowledge, s.acknowledgeCondition, []string{"EventId", "Comment"}},
+		{id.AcknowledgeableConditionType_Confirm, s.confirmCondition, []string{"EventId", "Comment"}},
+		{id.ShelvedStateMachineType_TimedShelve, s.timedShelve, []string{"ShelvingTime"}},
+		{id.ShelvedStateMachineType_OneShotShelve, s.oneShotShelve, nil},
+		{id.ShelvedStateMachineType_Unshelve, s.unshelve, nil},
+	}
+	for _, m := range methods {
+		if err := s.BindMethod(ua.NewNumericNodeID(0, m.id), m.fn, InputArgumentNames(m.names...)); err != nil {
+			// the functions are known to be valid methods.
+			panic(err)
+		}
+	}
+}
+
+// callCondition returns the condition on which a method is called. It
+// returns StatusBadNodeIDUnknown if the object of the call is not a
+// condition of the server.
+func (s *Server) callCondition(ctx context.Context) (*Condition, error) {
+	c := s.Condition(MethodObjectID(ctx))
+	if c == nil {
+		return nil, ua.StatusBadNodeIDUnknown
+	}
+	return c, nil
+}
+
+func (s *Server) enableCondition(ctx context.Context) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.enabled {
+		return ua.StatusBadConditionAlreadyEnabled
+	}
+	c.enabled = true
+	c.trunk.message = ua.NewLocalizedText("The condition is enabled")
+	c.fire(c.trunk)
+	return nil
+}
+
+func (s *Server) disableCondition(ctx context.Context) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return ua.StatusBadConditionAlreadyDisabled
+	}
+	// the branches of a disabled condition are no longer of interest.
+	c.enabled = false
+	for len(c.branches) > 0 {
+		c.fire(c.branches[0])
+	}
+	c.trunk.message = ua.NewLocalizedText("The condition is disabled")
+	c.fire(c.trunk)
+	return nil
+}
+
+// branch returns the state of the condition or branch which was reported
+// with the event id. The caller must hold c.mu.
+func (c *Condition) branch(eventID []byte) (*conditionState, error) {
+	if !c.enabled {
+		return nil, ua.StatusBadConditionDisabled
+	}
+	for _, st := range append([]*conditionState{c.trunk}, c.branches...) {
+		if string(st.eventID) == string(eventID) {
+			return st, nil
+		}
+	}
+	return nil, ua.StatusBadEventIDUnknown
+}
+
+// setComment sets the comment of a state and the user of the session
+// which added it.
+func setComment(ctx context.Context, st *conditionState, comment *ua.LocalizedText) {
+	if comment == nil {
+		return
+	}
+	st.comment = comment
+	if sess := sessionFromContext(ctx); sess != nil {
+		sess.mu.Lock()
+		st.clientUserID = sess.identity.key()
+		sess.mu.Unlock()
+	}
+}
+
+func (s *Server) addConditionComment(ctx context.Context, eventID []byte, comment *ua.LocalizedText) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.branch(eventID)
+	if err != nil {
+		return err
+	}
+	setComment(ctx, st, comment)
+	st.message = ua.NewLocalizedText("A comment was added")
+	c.fire(st)
+	return nil
+}
+
+func (s *Server) acknowledgeCondition(ctx context.Context, eventID []byte, comment *ua.LocalizedText) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.branch(eventID)
+	if err != nil {
+		return err
+	}
+	if st.acked {
+		return ua.StatusBadConditionBranchAlreadyAcked
+	}
+	st.acked = true
+	setComment(ctx, st, comment)
+	st.message = ua.NewLocalizedText("The condition is acknowledged")
+	c.fire(st)
+	return nil
+}
+
+func (s *Server) confirmCondition(ctx context.Context, eventID []byte, comment *ua.LocalizedText) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	if !c.confirm {
+		return ua.StatusBadMethodInvalid
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, err := c.branch(eventID)
+	if err != nil {
+		return err
+	}
+	if st.confirmed {
+		return ua.StatusBadConditionBranchAlreadyConfirmed
+	}
+	st.confirmed = true
+	setComment(ctx, st, comment)
+	st.message = ua.NewLocalizedText("The condition is confirmed")
+	c.fire(st)
+	return nil
+}
+
+func (s *Server) timedShelve(ctx context.Context, shelvingTime float64) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	if shelvingTime <= 0 {
+		return ua.StatusBadShelvingTimeOutOfRange
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// a one shot shelved alarm can be changed to timed shelving.
+	if c.shelving == timedShelved {
+		return ua.StatusBadConditionAlreadyShelved
+	}
+	c.setShelving(timedShelved, time.Duration(shelvingTime*float64(time.Millisecond)))
+	c.trunk.message = ua.NewLocalizedText("The alarm is shelved")
+	c.fire(c.trunk)
+	return nil
+}
+
+func (s *Server) oneShotShelve(ctx context.Context) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shelving == oneShotShelved {
+		return ua.StatusBadConditionAlreadyShelved
+	}
+	c.setShelving(oneShotShelved, 0)
+	c.trunk.message = ua.NewLocalizedText("The alarm is shelved")
+	c.fire(c.trunk)
+	return nil
+}
+
+func (s *Server) unshelve(ctx context.Context) error {
+	c, err := s.callCondition(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shelving == unshelved {
+		return ua.StatusBadConditionNotShelved
+	}
+	c.setShelving(unshelved, 0)
+	c.trunk.message = ua.NewLocalizedText("The alarm is unshelved")
+	c.fire(c.trunk)
+	return nil
+}
+
+// conditionRefresh reports the retained conditions to all event monitored
+// items of a subscription of the calling session.
+//
+// https://reference.opcfoundation.org/Core/Part9/v105/docs/5.5.7
+func (s *Server) conditionRefresh(ctx context.Context, subID uint32) error {
+	items, err := s.refreshItems(ctx, subID)
+	if err != nil {
+		return err
+	}
+	s.refreshConditions(items)
+	return nil
+}
+
+// conditionRefresh2 reports the retained conditions to a single event
+// monitored item of a subscription of the calling session.
+//
+// https://reference.opcfoundation.org/Core/Part9/v105/docs/5.5.8
+func (s *Server) conditionRefresh2(ctx context.Context, subID, itemID uint32) error {
+	items, err := s.refreshItems(ctx, subID)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.ID == itemID {
+			s.refreshConditions([]*MonitoredItem{item})
+			return nil
+		}
+	}
+	return ua.StatusBadMonitoredItemIDInvalid
+}
+
+// refreshItems returns the event monitored items of a subscription of the
+// calling session.
+func (s *Server) refreshItems(ctx context.Context, subID uint32) ([]*MonitoredItem, error) {
+	if s.SubscriptionService == nil || s.MonitoredItemService == nil {
+		return nil, ua.StatusBadSubscriptionIDInvalid
+	}
+	s.SubscriptionService.Mu.Lock()
+	sub := s.SubscriptionService.Subs[subID]
+	s.SubscriptionService.Mu.Unlock()
+	if sub == nil || sub.session() != sessionFromContext(ctx) {
+		return nil, ua.StatusBadSubscriptionIDInvalid
+	}
+
+	s.MonitoredItemService.Mu.Lock()
+	defer s.MonitoredItemService.Mu.Unlock()
+	var items []*MonitoredItem
+	for _, item := range s.MonitoredItemService.Subs[subID] {
+		if item != nil && item.eventItem() {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// refreshConditions reports the retained states of the conditions to the
+// monitored items which report the events of their sources. The states are
+// framed by a RefreshStartEvent and a RefreshEndEvent.
+func (s *Server) refreshConditions(items []*MonitoredItem) {
+	refreshEvent := func(typeID uint32, message string) *Event {
+		now := time.Now()
+		return &Event{Fields: map[string]*ua.Variant{
+			"EventId":     ua.MustVariant(newEventID()),
+			"EventType":   ua.MustVariant(ua.NewNumericNodeID(0, typeID)),
+			"SourceNode":  ua.MustVariant(ua.NewNumericNodeID(0, id.Server)),
+			"SourceName":  ua.MustVariant("Server"),
+			"Time":        ua.MustVariant(now),
+			"ReceiveTime": ua.MustVariant(now),
+			"Message":     ua.MustVariant(ua.NewLocalizedText(message)),
+			"Severity":    ua.MustVariant(uint16(1)),
+		}}
+	}
+
+	for _, item := range items {
+		item.reportEvent(refreshEvent(id.RefreshStartEventType, "Condition refresh started"))
+	}
+
+	for _, c := range s.allConditions() {
+		notifiers := map[string]bool{}
+		for _, n := range s.eventNotifiers(c.source) {
+			notifiers[n.String()] = true
+		}
+
+		c.mu.Lock()
+		var events []*Event
+		for _, st := range append([]*conditionState{c.trunk}, c.branches...) {
+			if c.retain(st) {
+				events = append(events, c.event(st))
+			}
+		}
+		c.mu.Unlock()
+
+		for _, item := range items {
+			if !notifiers[item.Req.ItemToMonitor.NodeID.String()] {
+				continue
+			}
+			for _, ev := range events {
+				item.reportEvent(ev)
+			}
+		}
+	}
+
+	for _, item := range items {
+		item.reportEvent(refreshEvent(id.RefreshEndEventType, "Condition refresh ended"))
+	}
+}
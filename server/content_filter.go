@@ -0,0 +1,674 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/aggregate"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// filterTarget is the node or the event for which a content filter is
+// evaluated.
+type filterTarget interface {
+	// attribute returns the value of an AttributeOperand or nil if the
+	// target has no such value.
+	attribute(op *ua.AttributeOperand) any
+
+	// simpleAttribute returns the value of a SimpleAttributeOperand or
+	// nil if the target has no such value.
+	simpleAttribute(op *ua.SimpleAttributeOperand) any
+
+	// ofType reports whether the target is an instance of the type or of
+	// one of its subtypes.
+	ofType(typeID *ua.NodeID) bool
+
+	// node returns the node of the target or nil if the target is not a
+	// node.
+	node() *Node
+}
+
+// contentFilter evaluates a ContentFilter as described in Part 4, Section
+// 7.7. The first element is the root of the filter expression.
+//
+// The result of an element is a Go value, e.g. a bool for the logical
+// operators. A nil result is the NULL value of the specification which
+// turns And, Or and Not into a three-valued logic.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.7
+type contentFilter struct {
+	srv   *Server
+	elems []*ua.ContentFilterElement
+}
+
+// newContentFilter validates the content filter f. It returns the results
+// of the validation and StatusBadContentFilterInvalid if any element is
+// invalid.
+func newContentFilter(srv *Server, f *ua.ContentFilter) (*contentFilter, *ua.ContentFilterResult, ua.StatusCode) {
+	res := &ua.ContentFilterResult{
+		ElementResults:         []*ua.ContentFilterElementResult{},
+		ElementDiagnosticInfos: []*ua.DiagnosticInfo{},
+	}
+	if f == nil || len(f.Elements) == 0 {
+		return &contentFilter{srv: srv}, res, ua.StatusOK
+	}
+
+	status := ua.StatusOK
+	results := make([]*ua.ContentFilterElementResult, len(f.Elements))
+	for i, e := range f.Elements {
+		results[i] = validateFilterElement(i, len(f.Elements), e)
+		if results[i].StatusCode != ua.StatusOK {
+			status = ua.StatusBadContentFilterInvalid
+		}
+	}
+	// the results are only returned if the filter has errors.
+	if status != ua.StatusOK {
+		res.ElementResults = results
+		return nil, res, status
+	}
+	return &contentFilter{srv: srv, elems: f.Elements}, res, ua.StatusOK
+}
+
+// validateFilterElement checks the operator and the operands of the i-th of
+// n filter elements.
+func validateFilterElement(i, n int, e *ua.ContentFilterElement) *ua.ContentFilterElementResult {
+	res := &ua.ContentFilterElementResult{
+		StatusCode:             ua.StatusOK,
+		OperandStatusCodes:     make([]ua.StatusCode, len(e.FilterOperands)),
+		OperandDiagnosticInfos: []*ua.DiagnosticInfo{},
+	}
+
+	min, max := filterOperandCount(e.FilterOperator)
+	switch {
+	case min < 0:
+		res.StatusCode = ua.StatusBadFilterOperatorInvalid
+		return res
+	case e.FilterOperator == ua.FilterOperatorInView:
+		res.StatusCode = ua.StatusBadFilterOperatorUnsupported
+		return res
+	case len(e.FilterOperands) < min || (max > 0 && len(e.FilterOperands) > max):
+		res.StatusCode = ua.StatusBadFilterOperandCountMismatch
+		return res
+	}
+
+	for j, eo := range e.FilterOperands {
+		if eo == nil {
+			res.OperandStatusCodes[j] = ua.StatusBadFilterOperandInvalid
+			continue
+		}
+		switch op := eo.Value.(type) {
+		case *ua.ElementOperand:
+			// elements may only refer to later elements to avoid loops.
+			if int(op.Index) <= i || int(op.Index) >= n {
+				res.OperandStatusCodes[j] = ua.StatusBadFilterOperandInvalid
+			}
+		case *ua.LiteralOperand:
+			if op.Value == nil {
+				res.OperandStatusCodes[j] = ua.StatusBadFilterLiteralInvalid
+			}
+		case *ua.AttributeOperand, *ua.SimpleAttributeOperand:
+		default:
+			res.OperandStatusCodes[j] = ua.StatusBadFilterOperandInvalid
+		}
+		if res.OperandStatusCodes[j] != ua.StatusOK {
+			res.StatusCode = ua.StatusBadFilterOperandInvalid
+		}
+	}
+	return res
+}
+
+// filterOperandCount returns the minimum and maximum number of operands of
+// an operator. A maximum of zero means no limit and a negative minimum an
+// unknown operator.
+func filterOperandCount(op ua.FilterOperator) (min, max int) {
+	switch op {
+	case ua.FilterOperatorIsNull, ua.FilterOperatorNot, ua.FilterOperatorInView, ua.FilterOperatorOfType:
+		return 1, 1
+	case ua.FilterOperatorEquals, ua.FilterOperatorGreaterThan, ua.FilterOperatorLessThan,
+		ua.FilterOperatorGreaterThanOrEqual, ua.FilterOperatorLessThanOrEqual, ua.FilterOperatorLike,
+		ua.FilterOperatorAnd, ua.FilterOperatorOr, ua.FilterOperatorCast,
+		ua.FilterOperatorBitwiseAnd, ua.FilterOperatorBitwiseOr:
+		return 2, 2
+	case ua.FilterOperatorBetween:
+		return 3, 3
+	case ua.FilterOperatorInList:
+		return 2, 0
+	case ua.FilterOperatorRelatedTo:
+		// source, target, reference type and the optional number of hops
+		// and IncludeRefTypeSubtypes flag.
+		return 3, 6
+	default:
+		return -1, -1
+	}
+}
+
+// match reports whether the filter evaluates to true for the target. An
+// empty filter matches all targets.
+func (f *contentFilter) match(t filterTarget) bool {
+	if len(f.elems) == 0 {
+		return true
+	}
+	b, ok := f.eval(0, t).(bool)
+	return ok && b
+}
+
+// eval returns the result of the i-th element for the target.
+func (f *contentFilter) eval(i int, t filterTarget) any {
+	e := f.elems[i]
+	arg := func(j int) any { return f.operand(e.FilterOperands[j], t) }
+
+	switch e.FilterOperator {
+	case ua.FilterOperatorEquals:
+		return filterEquals(arg(0), arg(1))
+
+	case ua.FilterOperatorIsNull:
+		return arg(0) == nil
+
+	case ua.FilterOperatorGreaterThan:
+		c, ok := filterCompare(arg(0), arg(1))
+		return ok && c > 0
+
+	case ua.FilterOperatorLessThan:
+		c, ok := filterCompare(arg(0), arg(1))
+		return ok && c < 0
+
+	case ua.FilterOperatorGreaterThanOrEqual:
+		c, ok := filterCompare(arg(0), arg(1))
+		return ok && c >= 0
+
+	case ua.FilterOperatorLessThanOrEqual:
+		c, ok := filterCompare(arg(0), arg(1))
+		return ok && c <= 0
+
+	case ua.FilterOperatorLike:
+		s, ok1 := filterString(arg(0))
+		p, ok2 := filterString(arg(1))
+		if !ok1 || !ok2 {
+			return false
+		}
+		re, err := likeRegexp(p)
+		return err == nil && re.MatchString(s)
+
+	case ua.FilterOperatorNot:
+		b, ok := arg(0).(bool)
+		if !ok {
+			return nil
+		}
+		return !b
+
+	case ua.FilterOperatorBetween:
+		v := arg(0)
+		lo, ok1 := filterCompare(v, arg(1))
+		hi, ok2 := filterCompare(v, arg(2))
+		return ok1 && ok2 && lo >= 0 && hi <= 0
+
+	case ua.FilterOperatorInList:
+		v := arg(0)
+		for j := 1; j < len(e.FilterOperands); j++ {
+			if filterEquals(v, arg(j)) {
+				return true
+			}
+		}
+		return false
+
+	case ua.FilterOperatorAnd:
+		a, b := arg(0), arg(1)
+		if a == false || b == false {
+			return false
+		}
+		if a == true && b == true {
+			return true
+		}
+		return nil
+
+	case ua.FilterOperatorOr:
+		a, b := arg(0), arg(1)
+		if a == true || b == true {
+			return true
+		}
+		if a == false && b == false {
+			return false
+		}
+		return nil
+
+	case ua.FilterOperatorCast:
+		dt, ok := arg(1).(*ua.NodeID)
+		if !ok {
+			return nil
+		}
+		return filterCast(arg(0), dt)
+
+	case ua.FilterOperatorOfType:
+		typeID, ok := arg(0).(*ua.NodeID)
+		return ok && t.ofType(typeID)
+
+	case ua.FilterOperatorRelatedTo:
+		return f.relatedTo(e, t)
+
+	case ua.FilterOperatorBitwiseAnd, ua.FilterOperatorBitwiseOr:
+		a, ok1 := filterInt(arg(0))
+		b, ok2 := filterInt(arg(1))
+		if !ok1 || !ok2 {
+			return nil
+		}
+		if e.FilterOperator == ua.FilterOperatorBitwiseAnd {
+			return a & b
+		}
+		return a | b
+
+	default:
+		return nil
+	}
+}
+
+// operand returns the value of an operand for the target.
+func (f *contentFilter) operand(eo *ua.ExtensionObject, t filterTarget) any {
+	switch op := eo.Value.(type) {
+	case *ua.ElementOperand:
+		return f.eval(int(op.Index), t)
+	case *ua.LiteralOperand:
+		return op.Value.Value()
+	case *ua.AttributeOperand:
+		return t.attribute(op)
+	case *ua.SimpleAttributeOperand:
+		return t.simpleAttribute(op)
+	default:
+		return nil
+	}
+}
+
+// relatedTo reports whether the target matches the source operand and has
+// references of the reference type to a node which matches the target
+// operand within the number of hops.
+func (f *contentFilter) relatedTo(e *ua.ContentFilterElement, t filterTarget) bool {
+	n := t.node()
+	if n == nil || !f.related(e.FilterOperands[0], t) {
+		return false
+	}
+	// the related nodes are evaluated for the same user.
+	var sess *session
+	if nt, ok := t.(*nodeTarget); ok {
+		sess = nt.sess
+	}
+	refType, ok := f.operand(e.FilterOperands[2], t).(*ua.NodeID)
+	if !ok {
+		return false
+	}
+	hops := int64(1)
+	if len(e.FilterOperands) > 3 {
+		if v, ok := filterInt(f.operand(e.FilterOperands[3], t)); ok && v > 0 {
+			hops = v
+		}
+	}
+	subtypes := true
+	if len(e.FilterOperands) > 4 {
+		if v, ok := f.operand(e.FilterOperands[4], t).(bool); ok {
+			subtypes = v
+		}
+	}
+
+	seen := map[string]bool{n.ID().String(): true}
+	nodes := []*Node{n}
+	for ; hops > 0 && len(nodes) > 0; hops-- {
+		var next []*Node
+		for _, n := range nodes {
+			for _, ref := range n.references() {
+				if !ref.IsForward || ref.NodeID == nil || ref.NodeID.ServerIndex != 0 || !suitableRefType(f.srv, refType, ref.ReferenceTypeID, subtypes) {
+					continue
+				}
+				m := f.srv.Node(ref.NodeID.NodeID)
+				if m == nil || seen[m.ID().String()] {
+					continue
+				}
+				seen[m.ID().String()] = true
+				if f.related(e.FilterOperands[1], &nodeTarget{srv: f.srv, sess: sess, n: m}) {
+					return true
+				}
+				next = append(next, m)
+			}
+		}
+		nodes = next
+	}
+	return false
+}
+
+// related reports whether the node of the target matches a source or
+// target operand of the RelatedTo operator. The operand is either an
+// AttributeOperand with the id of the type of the node, a literal node id
+// of the node or its type or another element which must be true for the
+// node.
+func (f *contentFilter) related(eo *ua.ExtensionObject, t filterTarget) bool {
+	switch op := eo.Value.(type) {
+	case *ua.AttributeOperand:
+		return isNullNodeID(op.NodeID) || t.ofType(op.NodeID)
+	case *ua.ElementOperand:
+		b, ok := f.eval(int(op.Index), t).(bool)
+		return ok && b
+	case *ua.LiteralOperand:
+		nid, ok := op.Value.Value().(*ua.NodeID)
+		return ok && (t.node().ID().Equal(nid) || t.ofType(nid))
+	default:
+		return false
+	}
+}
+
+// filterEquals reports whether two values are equal after an implicit
+// conversion.
+func filterEquals(a, b any) bool {
+	c, ok := filterCompare(a, b)
+	return ok && c == 0
+}
+
+// filterCompare compares two values. Numbers are compared by value and
+// strings, localized texts and qualified names by their text. It returns
+// false if the values cannot be compared.
+func filterCompare(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	if x, ok := aggregate.ToFloat(a); ok {
+		if y, ok := aggregate.ToFloat(b); ok {
+			return cmpFloat(x, y), true
+		}
+		// a number can be compared with a numeric string.
+		if s, ok := b.(string); ok {
+			if y, err := strconv.ParseFloat(s, 64); err == nil {
+				return cmpFloat(x, y), true
+			}
+		}
+		return 0, false
+	}
+
+	switch x := a.(type) {
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case y:
+			return -1, true
+		default:
+			return 1, true
+		}
+	case time.Time:
+		y, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return x.Compare(y), true
+	case []byte:
+		y, ok := b.([]byte)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(x, y), true
+	case *ua.NodeID, *ua.ExpandedNodeID, *ua.GUID, ua.StatusCode:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), fmt.Sprintf("%T", a) == fmt.Sprintf("%T", b)
+	}
+
+	x, ok := filterString(a)
+	if !ok {
+		return 0, false
+	}
+	// a numeric string can be compared with a number.
+	if y, ok := aggregate.ToFloat(b); ok {
+		f, err := strconv.ParseFloat(x, 64)
+		return cmpFloat(f, y), err == nil
+	}
+	y, ok := filterString(b)
+	if !ok {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+func cmpFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// filterInt converts an integer value to int64. It returns false for
+// UInt64 values which are larger than the largest int64.
+func filterInt(v any) (int64, bool) {
+	switch x := v.(type) {
+	case int8:
+		return int64(x), true
+	case uint8:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case uint16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case uint32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint64:
+		if x > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(x), true
+	default:
+		return 0, false
+	}
+}
+
+// filterString returns the text of strings, localized texts and qualified
+// names.
+func filterString(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case *ua.LocalizedText:
+		if x == nil {
+			return "", false
+		}
+		return x.Text, true
+	case *ua.QualifiedName:
+		if x == nil {
+			return "", false
+		}
+		return x.Name, true
+	default:
+		return "", false
+	}
+}
+
+// filterCast converts a value to the built-in data type with the given id.
+// It returns nil if the value cannot be converted.
+func filterCast(v any, dataType *ua.NodeID) any {
+	if v == nil || dataType.Namespace() != 0 {
+		return nil
+	}
+
+	dt := dataType.IntID()
+	if dt == id.String {
+		switch x := v.(type) {
+		case string:
+			return x
+		case *ua.LocalizedText, *ua.QualifiedName:
+			s, _ := filterString(x)
+			return s
+		case time.Time:
+			return x.Format(time.RFC3339Nano)
+		default:
+			return fmt.Sprint(x)
+		}
+	}
+
+	s, isString := filterString(v)
+	switch dt {
+	case id.Boolean:
+		if isString {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil
+			}
+			return b
+		}
+		if f, ok := aggregate.ToFloat(v); ok {
+			return f != 0
+		}
+		b, _ := v.(bool)
+		return b
+	case id.DateTime:
+		if t, ok := v.(time.Time); ok {
+			return t
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if !isString || err != nil {
+			return nil
+		}
+		return t
+	case id.NodeID:
+		if nid, ok := v.(*ua.NodeID); ok {
+			return nid
+		}
+		nid, err := ua.ParseNodeID(s)
+		if !isString || err != nil {
+			return nil
+		}
+		return nid
+	case id.LocalizedText:
+		if !isString {
+			return nil
+		}
+		return ua.NewLocalizedText(s)
+	case id.QualifiedName:
+		if !isString {
+			return nil
+		}
+		return &ua.QualifiedName{Name: s}
+	}
+
+	var f float64
+	switch x := v.(type) {
+	case bool:
+		if x {
+			f = 1
+		}
+	default:
+		var ok bool
+		if f, ok = aggregate.ToFloat(v); !ok {
+			if !isString {
+				return nil
+			}
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil
+			}
+		}
+	}
+
+	// integers are rounded and must be in the range of the type.
+	inRange := func(min, max float64) (float64, bool) {
+		r := math.Round(f)
+		return r, r >= min && r <= max
+	}
+	switch dt {
+	case id.SByte:
+		if r, ok := inRange(math.MinInt8, math.MaxInt8); ok {
+			return int8(r)
+		}
+	case id.Byte:
+		if r, ok := inRange(0, math.MaxUint8); ok {
+			return uint8(r)
+		}
+	case id.Int16:
+		if r, ok := inRange(math.MinInt16, math.MaxInt16); ok {
+			return int16(r)
+		}
+	case id.UInt16:
+		if r, ok := inRange(0, math.MaxUint16); ok {
+			return uint16(r)
+		}
+	case id.Int32:
+		if r, ok := inRange(math.MinInt32, math.MaxInt32); ok {
+			return int32(r)
+		}
+	case id.UInt32:
+		if r, ok := inRange(0, math.MaxUint32); ok {
+			return uint32(r)
+		}
+	case id.Int64:
+		if r, ok := inRange(math.MinInt64, math.MaxInt64); ok {
+			return int64(r)
+		}
+	case id.UInt64:
+		if r, ok := inRange(0, math.MaxUint64); ok {
+			return uint64(r)
+		}
+	case id.Float:
+		return float32(f)
+	case id.Double:
+		return f
+	}
+	return nil
+}
+
+// likeRegexp converts the pattern of the Like operator into a regular
+// expression. The pattern supports the wildcards %, _, [] and [^] and
+// the escape character \.
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	p := []rune(pattern)
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(p) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(p[i])))
+		case '[':
+			end := -1
+			for j := i + 1; j < len(p); j++ {
+				if p[j] == ']' {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			b.WriteString("[")
+			for j := i + 1; j < end; j++ {
+				switch {
+				case p[j] == '^' && j == i+1, p[j] == '-':
+					b.WriteRune(p[j])
+				default:
+					b.WriteString(regexp.QuoteMeta(string(p[j])))
+				}
+			}
+			b.WriteString("]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
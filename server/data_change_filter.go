@@ -0,0 +1,169 @@
+package server
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/gopcua/opcua/aggregate"
+	"github.com/gopcua/opcua/ua"
+)
+
+// dataChangeFilter decides which samples of a monitored item are reported.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.22.2
+type dataChangeFilter struct {
+	trigger ua.DataChangeTrigger
+
+	// deadband is the absolute deadband.
+	deadband float64
+
+	// percent is the percent deadband. It is converted with the current
+	// EURange of the node when a sample is evaluated since the EURange can
+	// change.
+	percent float64
+	srv     *Server
+	nodeID  *ua.NodeID
+}
+
+// defaultDataChangeFilter is used for monitored items without a filter.
+var defaultDataChangeFilter = &dataChangeFilter{trigger: ua.DataChangeTriggerStatusValue}
+
+// newDataChangeFilter returns the data change filter for the filter of a
+// monitored item or the status code why it cannot be used.
+func (s *Server) newDataChangeFilter(item *ua.ReadValueID, eo *ua.ExtensionObject) (*dataChangeFilter, ua.StatusCode) {
+	if eo == nil || eo.Value == nil {
+		return defaultDataChangeFilter, ua.StatusOK
+	}
+	f, ok := eo.Value.(*ua.DataChangeFilter)
+	if !ok {
+		return nil, ua.StatusBadMonitoredItemFilterUnsupported
+	}
+	if item.AttributeID != ua.AttributeIDValue {
+		return nil, ua.StatusBadFilterNotAllowed
+	}
+
+	switch f.Trigger {
+	case ua.DataChangeTriggerStatus, ua.DataChangeTriggerStatusValue, ua.DataChangeTriggerStatusValueTimestamp:
+	default:
+		return nil, ua.StatusBadMonitoredItemFilterInvalid
+	}
+
+	dcf := &dataChangeFilter{trigger: f.Trigger}
+	switch ua.DeadbandType(f.DeadbandType) {
+	case ua.DeadbandTypeNone:
+		return dcf, ua.StatusOK
+	case ua.DeadbandTypeAbsolute:
+		if f.DeadbandValue < 0 {
+			return nil, ua.StatusBadDeadbandFilterInvalid
+		}
+		dcf.deadband = f.DeadbandValue
+	case ua.DeadbandTypePercent:
+		if f.DeadbandValue < 0 || f.DeadbandValue > 100 {
+			return nil, ua.StatusBadDeadbandFilterInvalid
+		}
+		if s.euRange(item.NodeID) == nil {
+			// only analog items have an EURange.
+			return nil, ua.StatusBadMonitoredItemFilterUnsupported
+		}
+		dcf.percent, dcf.srv, dcf.nodeID = f.DeadbandValue, s, item.NodeID
+	default:
+		return nil, ua.StatusBadDeadbandFilterInvalid
+	}
+
+	// deadbands only work for numbers.
+	if v := s.attributeValue(item.NodeID, ua.AttributeIDValue); v != nil && !numeric(v) {
+		return nil, ua.StatusBadDeadbandFilterInvalid
+	}
+	return dcf, ua.StatusOK
+}
+
+// euRange returns the EURange property of a node or nil if it has none.
+func (s *Server) euRange(nodeID *ua.NodeID) *ua.Range {
+	prop := s.followPath(nodeID, simpleRelativePath([]*ua.QualifiedName{{Name: "EURange"}}))
+	if prop == nil {
+		return nil
+	}
+	switch v := s.attributeValue(prop, ua.AttributeIDValue).(type) {
+	case *ua.Range:
+		return v
+	case *ua.ExtensionObject:
+		r, _ := v.Value.(*ua.Range)
+		return r
+	default:
+		return nil
+	}
+}
+
+// changed reports whether a sample has to be reported after the last
+// reported sample.
+func (f *dataChangeFilter) changed(last, dv *ua.DataValue) bool {
+	if last == nil || last.Status != dv.Status {
+		return true
+	}
+	switch f.trigger {
+	case ua.DataChangeTriggerStatus:
+		return false
+	case ua.DataChangeTriggerStatusValueTimestamp:
+		if !last.SourceTimestamp.Equal(dv.SourceTimestamp) {
+			return true
+		}
+	}
+	if last.Value == nil || dv.Value == nil {
+		return last.Value != dv.Value
+	}
+	deadband := f.absDeadband()
+	if deadband == 0 {
+		return !reflect.DeepEqual(last.Value, dv.Value)
+	}
+	return exceedsDeadband(last.Value.Value(), dv.Value.Value(), deadband)
+}
+
+// absDeadband returns the absolute deadband of the filter. A percent
+// deadband is zero if the node no longer has an EURange.
+func (f *dataChangeFilter) absDeadband() float64 {
+	if f.percent == 0 {
+		return f.deadband
+	}
+	r := f.srv.euRange(f.nodeID)
+	if r == nil {
+		return 0
+	}
+	return f.percent / 100 * math.Abs(r.High-r.Low)
+}
+
+// exceedsDeadband reports whether two numbers differ by more than the
+// deadband. Arrays exceed the deadband if one of their elements does.
+func exceedsDeadband(a, b any, deadband float64) bool {
+	x, okx := aggregate.ToFloat(a)
+	y, oky := aggregate.ToFloat(b)
+	if okx && oky {
+		return math.Abs(x-y) > deadband
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() != reflect.Slice || vb.Kind() != reflect.Slice {
+		return !reflect.DeepEqual(a, b)
+	}
+	if va.Len() != vb.Len() {
+		return true
+	}
+	for i := 0; i < va.Len(); i++ {
+		if exceedsDeadband(va.Index(i).Interface(), vb.Index(i).Interface(), deadband) {
+			return true
+		}
+	}
+	return false
+}
+
+// numeric reports whether a value is a number or an array of numbers.
+func numeric(v any) bool {
+	if _, ok := aggregate.ToFloat(v); ok {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return false
+	}
+	_, ok := aggregate.ToFloat(reflect.Zero(rv.Type().Elem()).Interface())
+	return ok
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// Event is a single occurrence of an event.
+//
+// Fields contains the values of the event fields indexed by their browse
+// path relative to the event type. The elements of a path are separated
+// by a slash, e.g. "Message" or "EnabledState/Id". The standard fields of
+// the BaseEventType are EventId, EventType, SourceNode, SourceName, Time,
+// ReceiveTime, Message and Severity. Condition events have the ConditionId
+// in the field with the empty path.
+type Event struct {
+	Fields map[string]*ua.Variant
+}
+
+// Time returns the value of the Time field of the event.
+func (e *Event) Time() time.Time {
+	v := e.Field("Time")
+	t, _ := v.Value().(time.Time)
+	return t
+}
+
+// EventID returns the value of the EventId field of the event.
+func (e *Event) EventID() []byte {
+	id, _ := e.Field("EventId").Value().([]byte)
+	return id
+}
+
+// Field returns the value of the field with the given browse path or an
+// empty variant if the event does not have the field.
+func (e *Event) Field(path string) *ua.Variant {
+	if v := e.Fields[path]; v != nil {
+		return v
+	}
+	return &ua.Variant{}
+}
+
+// selectFields returns the values of the fields selected by the select
+// clauses of an event filter in the order of the clauses.
+func (e *Event) selectFields(clauses []*ua.SimpleAttributeOperand) []*ua.Variant {
+	fields := make([]*ua.Variant, len(clauses))
+	for i, op := range clauses {
+		fields[i] = e.Field(browsePath(op.BrowsePath))
+	}
+	return fields
+}
+
+// browsePath returns the browse path as a string of slash separated
+// browse names.
+func browsePath(path []*ua.QualifiedName) string {
+	names := make([]string, len(path))
+	for i, qn := range path {
+		names[i] = qn.Name
+	}
+	return strings.Join(names, "/")
+}
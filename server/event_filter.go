@@ -0,0 +1,137 @@
+package server
+
+import (
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+var baseEventType = ua.NewNumericNodeID(0, id.BaseEventType)
+
+// eventFilter selects the fields of the events which are reported by a
+// monitored item. The where clause decides which events are reported.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.22.3
+type eventFilter struct {
+	srv     *Server
+	selects []*ua.SimpleAttributeOperand
+	where   *contentFilter
+}
+
+// newEventFilter validates the event filter of a monitored item. Select
+// clauses which cannot be resolved against the event type hierarchy are
+// reported in the result and always select a null value.
+func (s *Server) newEventFilter(eo *ua.ExtensionObject) (*eventFilter, *ua.EventFilterResult, ua.StatusCode) {
+	res := &ua.EventFilterResult{
+		SelectClauseResults:         []ua.StatusCode{},
+		SelectClauseDiagnosticInfos: []*ua.DiagnosticInfo{},
+		WhereClauseResult: &ua.ContentFilterResult{
+			ElementResults:         []*ua.ContentFilterElementResult{},
+			ElementDiagnosticInfos: []*ua.DiagnosticInfo{},
+		},
+	}
+
+	var f *ua.EventFilter
+	if eo != nil {
+		f, _ = eo.Value.(*ua.EventFilter)
+	}
+	if f == nil || len(f.SelectClauses) == 0 {
+		return nil, res, ua.StatusBadEventFilterInvalid
+	}
+
+	res.SelectClauseResults = make([]ua.StatusCode, len(f.SelectClauses))
+	for i, op := range f.SelectClauses {
+		res.SelectClauseResults[i] = s.checkSelectClause(op)
+	}
+
+	where, whereResult, status := newContentFilter(s, f.WhereClause)
+	res.WhereClauseResult = whereResult
+	if status != ua.StatusOK {
+		return nil, res, ua.StatusBadEventFilterInvalid
+	}
+	return &eventFilter{srv: s, selects: f.SelectClauses, where: where}, res, ua.StatusOK
+}
+
+// checkSelectClause checks that the browse path of a select clause leads
+// to an instance declaration of the event type or one of its super types.
+func (s *Server) checkSelectClause(op *ua.SimpleAttributeOperand) ua.StatusCode {
+	typeID := op.TypeDefinitionID
+	if isNullNodeID(typeID) {
+		typeID = baseEventType
+	}
+	if !s.isSubtype(typeID, baseEventType) {
+		return ua.StatusBadTypeDefinitionInvalid
+	}
+	// the NodeId of the condition type selects the ConditionId.
+	if op.AttributeID == ua.AttributeIDNodeID && len(op.BrowsePath) == 0 && s.isSubtype(typeID, conditionType) {
+		return ua.StatusOK
+	}
+	if op.AttributeID != ua.AttributeIDValue {
+		return ua.StatusBadAttributeIDInvalid
+	}
+	if len(op.BrowsePath) == 0 {
+		return ua.StatusBadBrowseNameInvalid
+	}
+
+	path := simpleRelativePath(op.BrowsePath)
+	for t := typeID; t != nil; t = s.superType(t) {
+		if s.followPath(t, path) != nil {
+			return ua.StatusOK
+		}
+	}
+	return ua.StatusBadNodeIDUnknown
+}
+
+// match reports whether the where clause selects the event.
+func (f *eventFilter) match(ev *Event) bool {
+	return f.where.match(&eventTarget{srv: f.srv, ev: ev})
+}
+
+// fields returns the values of the select clauses for the event.
+func (f *eventFilter) fields(ev *Event) []*ua.Variant {
+	t := &eventTarget{srv: f.srv, ev: ev}
+	fields := make([]*ua.Variant, len(f.selects))
+	for i, op := range f.selects {
+		fields[i] = t.field(op)
+	}
+	return fields
+}
+
+// eventTarget evaluates a content filter for an event.
+type eventTarget struct {
+	srv *Server
+	ev  *Event
+}
+
+// field returns the value of the event field selected by the operand or an
+// empty variant if the event is not of the type of the operand or does not
+// have the field.
+func (t *eventTarget) field(op *ua.SimpleAttributeOperand) *ua.Variant {
+	if !isNullNodeID(op.TypeDefinitionID) && !t.ofType(op.TypeDefinitionID) {
+		return &ua.Variant{}
+	}
+	if op.AttributeID == ua.AttributeIDNodeID && len(op.BrowsePath) == 0 {
+		return t.ev.Field("")
+	}
+	if op.AttributeID != ua.AttributeIDValue {
+		return &ua.Variant{}
+	}
+	return t.ev.Field(browsePath(op.BrowsePath))
+}
+
+func (t *eventTarget) attribute(op *ua.AttributeOperand) any {
+	// events have no nodes to start a relative path from.
+	return nil
+}
+
+func (t *eventTarget) simpleAttribute(op *ua.SimpleAttributeOperand) any {
+	return t.field(op).Value()
+}
+
+func (t *eventTarget) ofType(typeID *ua.NodeID) bool {
+	eventType, _ := t.ev.Field("EventType").Value().(*ua.NodeID)
+	return eventType != nil && t.srv.isSubtype(eventType, typeID)
+}
+
+func (t *eventTarget) node() *Node {
+	return nil
+}
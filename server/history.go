@@ -0,0 +1,397 @@
+package server
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/aggregate"
+	"github.com/gopcua/opcua/ua"
+)
+
+// HistoryStore is the historian backend of the server which stores the
+// history of variables and event notifiers and answers HistoryRead
+// requests.
+//
+// The server takes care of continuation points, NumValuesPerNode,
+// ReturnBounds and TimestampsToReturn so that a store only has to return
+// the values for a time range. Values are ordered by their source
+// timestamp. If a value has no source timestamp its server timestamp is
+// used instead.
+//
+// Use the SetHistoryStore option to configure the store of a server.
+type HistoryStore interface {
+	// RecordValue adds a value of a variable to the history.
+	RecordValue(nodeID *ua.NodeID, v *ua.DataValue) error
+
+	// ReadValues returns the values of a variable with a timestamp in the
+	// half-open interval [start, end) in chronological order. A zero start
+	// or end time means that the interval is unbounded on that side.
+	//
+	// If bounds is true the last value before start and the first value
+	// at or after end are returned as well if they exist.
+	//
+	// The returned values must not be modified by the caller.
+	ReadValues(nodeID *ua.NodeID, start, end time.Time, bounds bool) ([]*ua.DataValue, error)
+
+	// RecordEvent adds an event to the history of an event notifier.
+	RecordEvent(nodeID *ua.NodeID, ev *Event) error
+
+	// ReadEvents returns the events of an event notifier with a Time in the
+	// half-open interval [start, end) in chronological order. A zero start
+	// or end time means that the interval is unbounded on that side.
+	//
+	// The returned events must not be modified by the caller.
+	ReadEvents(nodeID *ua.NodeID, start, end time.Time) ([]*Event, error)
+}
+
+// HistoryUpdater is implemented by history stores which support the
+// HistoryUpdate service. Values are identified by their timestamp and
+// events by their EventId field.
+//
+// The methods return a status code per value, time or event id which
+// become the OperationResults of the HistoryUpdate response.
+type HistoryUpdater interface {
+	// UpdateValues inserts, replaces or updates, i.e. inserts or replaces,
+	// values of a variable depending on mode. The result of a value which
+	// cannot be stored is a bad status code, e.g. BadOutOfRange.
+	UpdateValues(nodeID *ua.NodeID, mode ua.PerformUpdateType, values []*ua.DataValue) ([]ua.StatusCode, error)
+
+	// DeleteValues deletes the values of a variable with a timestamp in the
+	// half-open interval [start, end) and returns the number of deleted
+	// values.
+	DeleteValues(nodeID *ua.NodeID, start, end time.Time) (int, error)
+
+	// DeleteValuesAtTime deletes the values of a variable at the given times.
+	DeleteValuesAtTime(nodeID *ua.NodeID, times []time.Time) ([]ua.StatusCode, error)
+
+	// UpdateEvents inserts, replaces or updates events of an event notifier
+	// depending on mode.
+	UpdateEvents(nodeID *ua.NodeID, mode ua.PerformUpdateType, events []*Event) ([]ua.StatusCode, error)
+
+	// DeleteEvents deletes the events of an event notifier with the given ids.
+	DeleteEvents(nodeID *ua.NodeID, eventIDs [][]byte) ([]ua.StatusCode, error)
+}
+
+// MemoryHistory is a HistoryStore which keeps the most recent values and
+// events of every node in a fixed size ring buffer in memory. Inserting
+// a value or an event which is older than all entries of a full buffer
+// fails with BadOutOfRange.
+type MemoryHistory struct {
+	size int
+
+	mu     sync.RWMutex
+	values map[string]*ring[*ua.DataValue]
+	events map[string]*ring[*Event]
+}
+
+// NewMemoryHistory returns an in-memory history store which keeps up to
+// size values and size events per node. When a buffer is full the oldest
+// entry is dropped.
+func NewMemoryHistory(size int) *MemoryHistory {
+	if size <= 0 {
+		size = 1
+	}
+	return &MemoryHistory{
+		size:   size,
+		values: make(map[string]*ring[*ua.DataValue]),
+		events: make(map[string]*ring[*Event]),
+	}
+}
+
+// RecordValue implements HistoryStore.
+func (h *MemoryHistory) RecordValue(nodeID *ua.NodeID, v *ua.DataValue) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := nodeID.String()
+	r := h.values[k]
+	if r == nil {
+		r = newRing(h.size, aggregate.ValueTime)
+		h.values[k] = r
+	}
+	r.insert(v)
+	return nil
+}
+
+// ReadValues implements HistoryStore.
+func (h *MemoryHistory) ReadValues(nodeID *ua.NodeID, start, end time.Time, bounds bool) ([]*ua.DataValue, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r := h.values[nodeID.String()]
+	if r == nil {
+		return nil, nil
+	}
+	return r.between(start, end, bounds), nil
+}
+
+// RecordEvent implements HistoryStore.
+func (h *MemoryHistory) RecordEvent(nodeID *ua.NodeID, ev *Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := nodeID.String()
+	r := h.events[k]
+	if r == nil {
+		r = newRing(h.size, (*Event).Time)
+		h.events[k] = r
+	}
+	r.insert(ev)
+	return nil
+}
+
+// ReadEvents implements HistoryStore.
+func (h *MemoryHistory) ReadEvents(nodeID *ua.NodeID, start, end time.Time) ([]*Event, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r := h.events[nodeID.String()]
+	if r == nil {
+		return nil, nil
+	}
+	return r.between(start, end, false), nil
+}
+
+// UpdateValues implements HistoryUpdater.
+func (h *MemoryHistory) UpdateValues(nodeID *ua.NodeID, mode ua.PerformUpdateType, values []*ua.DataValue) ([]ua.StatusCode, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := nodeID.String()
+	r := h.values[k]
+	if r == nil {
+		r = newRing(h.size, aggregate.ValueTime)
+		h.values[k] = r
+	}
+
+	results := make([]ua.StatusCode, len(values))
+	for i, v := range values {
+		t := aggregate.ValueTime(v)
+		if t.IsZero() {
+			results[i] = ua.StatusBadInvalidTimestamp
+			continue
+		}
+		results[i] = update(r, r.index(t), mode, v)
+	}
+	return results, nil
+}
+
+// DeleteValues implements HistoryUpdater.
+func (h *MemoryHistory) DeleteValues(nodeID *ua.NodeID, start, end time.Time) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.values[nodeID.String()]
+	if r == nil {
+		return 0, nil
+	}
+	lo, hi := 0, r.n
+	if !start.IsZero() {
+		lo = r.search(start)
+	}
+	if !end.IsZero() {
+		hi = r.search(end)
+	}
+	if lo >= hi {
+		return 0, nil
+	}
+	r.remove(lo, hi)
+	return hi - lo, nil
+}
+
+// DeleteValuesAtTime implements HistoryUpdater.
+func (h *MemoryHistory) DeleteValuesAtTime(nodeID *ua.NodeID, times []time.Time) ([]ua.StatusCode, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.values[nodeID.String()]
+	results := make([]ua.StatusCode, len(times))
+	for i, t := range times {
+		idx := -1
+		if r != nil {
+			idx = r.index(t)
+		}
+		if idx < 0 {
+			results[i] = ua.StatusBadNoEntryExists
+			continue
+		}
+		r.remove(idx, idx+1)
+		results[i] = ua.StatusOK
+	}
+	return results, nil
+}
+
+// UpdateEvents implements HistoryUpdater.
+func (h *MemoryHistory) UpdateEvents(nodeID *ua.NodeID, mode ua.PerformUpdateType, events []*Event) ([]ua.StatusCode, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := nodeID.String()
+	r := h.events[k]
+	if r == nil {
+		r = newRing(h.size, (*Event).Time)
+		h.events[k] = r
+	}
+
+	results := make([]ua.StatusCode, len(events))
+	for i, ev := range events {
+		results[i] = update(r, eventIndex(r, ev.EventID()), mode, ev)
+	}
+	return results, nil
+}
+
+// DeleteEvents implements HistoryUpdater.
+func (h *MemoryHistory) DeleteEvents(nodeID *ua.NodeID, eventIDs [][]byte) ([]ua.StatusCode, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := h.events[nodeID.String()]
+	results := make([]ua.StatusCode, len(eventIDs))
+	for i, id := range eventIDs {
+		idx := -1
+		if r != nil {
+			idx = eventIndex(r, id)
+		}
+		if idx < 0 {
+			results[i] = ua.StatusBadNoEntryExists
+			continue
+		}
+		r.remove(idx, idx+1)
+		results[i] = ua.StatusOK
+	}
+	return results, nil
+}
+
+// update inserts or replaces v in r. idx is the index of the entry which
+// v replaces or -1 if there is none.
+func update[T any](r *ring[T], idx int, mode ua.PerformUpdateType, v T) ua.StatusCode {
+	switch {
+	case idx >= 0 && mode == ua.PerformUpdateTypeInsert:
+		return ua.StatusBadEntryExists
+	case idx < 0 && mode == ua.PerformUpdateTypeReplace:
+		return ua.StatusBadNoEntryExists
+	case idx >= 0:
+		// the time of the entry may change.
+		r.remove(idx, idx+1)
+		r.insert(v)
+		return ua.StatusGoodEntryReplaced
+	case !r.insert(v):
+		// v is older than all entries of the full buffer.
+		return ua.StatusBadOutOfRange
+	default:
+		return ua.StatusGoodEntryInserted
+	}
+}
+
+// eventIndex returns the index of the event with the given id or -1.
+func eventIndex(r *ring[*Event], id []byte) int {
+	if len(id) == 0 {
+		return -1
+	}
+	for i := 0; i < r.n; i++ {
+		if bytes.Equal(r.at(i).EventID(), id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ring is a fixed size ring buffer of entries ordered by time.
+type ring[T any] struct {
+	buf   []T
+	start int
+	n     int
+	ts    func(T) time.Time
+}
+
+func newRing[T any](size int, ts func(T) time.Time) *ring[T] {
+	return &ring[T]{buf: make([]T, size), ts: ts}
+}
+
+// at returns the i-th oldest entry.
+func (r *ring[T]) at(i int) T {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+func (r *ring[T]) set(i int, v T) {
+	r.buf[(r.start+i)%len(r.buf)] = v
+}
+
+// search returns the index of the first entry at or after t.
+func (r *ring[T]) search(t time.Time) int {
+	return sort.Search(r.n, func(i int) bool { return !r.ts(r.at(i)).Before(t) })
+}
+
+// index returns the index of the first entry at exactly t or -1.
+func (r *ring[T]) index(t time.Time) int {
+	i := r.search(t)
+	if i < r.n && r.ts(r.at(i)).Equal(t) {
+		return i
+	}
+	return -1
+}
+
+// remove deletes the entries with an index in [i, j).
+func (r *ring[T]) remove(i, j int) {
+	k := j - i
+	for x := i; x+k < r.n; x++ {
+		r.set(x, r.at(x+k))
+	}
+	var zero T
+	for x := r.n - k; x < r.n; x++ {
+		r.set(x, zero)
+	}
+	r.n -= k
+}
+
+// insert adds v after all entries with the same or an earlier time and
+// drops the oldest entry if the buffer is full. It returns false if v is
+// not added because it is older than all entries of the full buffer.
+func (r *ring[T]) insert(v T) bool {
+	t := r.ts(v)
+	i := sort.Search(r.n, func(i int) bool { return r.ts(r.at(i)).After(t) })
+	if r.n == len(r.buf) {
+		if i == 0 {
+			return false
+		}
+		r.start = (r.start + 1) % len(r.buf)
+		r.n--
+		i--
+	}
+	r.n++
+	for j := r.n - 1; j > i; j-- {
+		r.set(j, r.at(j-1))
+	}
+	r.set(i, v)
+	return true
+}
+
+// between returns the entries in [start, end) and optionally the entries
+// right before and after that interval.
+func (r *ring[T]) between(start, end time.Time, bounds bool) []T {
+	lo, hi := 0, r.n
+	if !start.IsZero() {
+		lo = r.search(start)
+	}
+	if !end.IsZero() {
+		hi = r.search(end)
+	}
+	if bounds {
+		if lo > 0 {
+			lo--
+		}
+		if !end.IsZero() && hi < r.n {
+			hi++
+		}
+	}
+	if lo >= hi {
+		return nil
+	}
+	res := make([]T, 0, hi-lo)
+	for i := lo; i < hi; i++ {
+		res = append(res, r.at(i))
+	}
+	return res
+}
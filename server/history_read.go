@@ -0,0 +1,461 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gopcua/opcua/aggregate"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// maxProcessedValues limits the number of processing intervals of a
+// single ReadProcessedDetails request.
+const maxProcessedValues = 100000
+
+// historyContinuation is the state of a HistoryRead which could not return
+// all values or events in a single response.
+type historyContinuation struct {
+	nodeID string
+	event  bool
+	values []*ua.DataValue
+	events []*ua.HistoryEventFieldList
+}
+
+// historyRead reads the history of the i-th node of the request.
+func (s *AttributeService) historyRead(sess *session, req *ua.HistoryReadRequest, i int) *ua.HistoryReadResult {
+	n := req.NodesToRead[i]
+	fail := func(status ua.StatusCode) *ua.HistoryReadResult {
+		return &ua.HistoryReadResult{StatusCode: status, HistoryData: ua.NewExtensionObject(nil)}
+	}
+
+	if req.ReleaseContinuationPoints {
+		sess.takeHistoryContinuation(n.ContinuationPoint)
+		return fail(ua.StatusOK)
+	}
+
+	if n.NodeID == nil {
+		return fail(ua.StatusBadNodeIDInvalid)
+	}
+
+	store := s.srv.cfg.history
+	if store == nil {
+		return fail(ua.StatusBadHistoryOperationUnsupported)
+	}
+
+	_, event := req.HistoryReadDetails.Value.(*ua.ReadEventDetails)
+
+	var c *historyContinuation
+	if len(n.ContinuationPoint) > 0 {
+		c = sess.takeHistoryContinuation(n.ContinuationPoint)
+		if c == nil || c.nodeID != n.NodeID.String() || c.event != event {
+			return fail(ua.StatusBadContinuationPointInvalid)
+		}
+	} else {
+		if status := historyAccess(s.srv, n.NodeID, ua.AccessLevelTypeHistoryRead); status != ua.StatusOK {
+			return fail(status)
+		}
+		if status := s.srv.allowed(sess, n.NodeID, ua.PermissionTypeReadHistory); status != ua.StatusOK {
+			return fail(status)
+		}
+		c = &historyContinuation{nodeID: n.NodeID.String(), event: event}
+	}
+
+	var num uint32
+	status := ua.StatusOK
+	switch d := req.HistoryReadDetails.Value.(type) {
+	case *ua.ReadRawModifiedDetails:
+		num = d.NumValuesPerNode
+		if len(n.ContinuationPoint) == 0 {
+			c.values, status = readRaw(store, n.NodeID, d)
+		}
+	case *ua.ReadAtTimeDetails:
+		if len(n.ContinuationPoint) == 0 {
+			c.values, status = readAtTime(store, n.NodeID, d)
+		}
+	case *ua.ReadProcessedDetails:
+		if len(n.ContinuationPoint) == 0 {
+			c.values, status = readProcessed(store, n.NodeID, d, d.AggregateType[i], s.srv.stepped(n.NodeID))
+		}
+	case *ua.ReadEventDetails:
+		num = d.NumValuesPerNode
+		if len(n.ContinuationPoint) == 0 {
+			c.events, status = readEvents(s.srv, store, n.NodeID, d)
+		}
+	default:
+		status = ua.StatusBadHistoryOperationUnsupported
+	}
+	if status != ua.StatusOK {
+		return fail(status)
+	}
+
+	res := &ua.HistoryReadResult{StatusCode: ua.StatusOK}
+	if num > 0 && len(c.values)+len(c.events) > int(num) {
+		next := &historyContinuation{nodeID: c.nodeID, event: c.event}
+		if c.event {
+			c.events, next.events = c.events[:num], c.events[num:]
+		} else {
+			c.values, next.values = c.values[:num], c.values[num:]
+		}
+		cp, err := sess.addHistoryContinuation(next, int(s.srv.cfg.cap.MaxHistoryContinuationPoints))
+		if err != nil {
+			return fail(ua.StatusBadNoContinuationPoints)
+		}
+		res.ContinuationPoint = cp
+	}
+
+	if event {
+		if len(c.events) == 0 {
+			res.StatusCode = ua.StatusGoodNoData
+		}
+		res.HistoryData = ua.NewExtensionObject(&ua.HistoryEvent{Events: c.events})
+		return res
+	}
+
+	if len(c.values) == 0 {
+		res.StatusCode = ua.StatusGoodNoData
+	}
+	values := make([]*ua.DataValue, len(c.values))
+	for i, v := range c.values {
+		values[i] = withTimestamps(v, req.TimestampsToReturn)
+	}
+	res.HistoryData = ua.NewExtensionObject(&ua.HistoryData{DataValues: values})
+	return res
+}
+
+// historyAccess checks that the node exists and that its access level
+// permits reading or writing its history.
+func historyAccess(srv *Server, nodeID *ua.NodeID, flag ua.AccessLevelType) ua.StatusCode {
+	ns, err := srv.Namespace(int(nodeID.Namespace()))
+	if err != nil {
+		return ua.StatusBadNodeIDUnknown
+	}
+	dv := ns.Attribute(nodeID, ua.AttributeIDAccessLevel)
+	switch dv.Status {
+	case ua.StatusBadNodeIDUnknown, ua.StatusBadUserAccessDenied:
+		return dv.Status
+	}
+	if dv.Value == nil {
+		return ua.StatusOK
+	}
+	if access, ok := dv.Value.Value().(uint8); ok && access&uint8(flag) == 0 {
+		if flag == ua.AccessLevelTypeHistoryWrite {
+			return ua.StatusBadNotWritable
+		}
+		return ua.StatusBadNotReadable
+	}
+	return ua.StatusOK
+}
+
+// readRaw returns the raw values for a ReadRawModifiedDetails request.
+//
+// https://reference.opcfoundation.org/Core/Part11/v105/docs/6.4.3
+func readRaw(store HistoryStore, nodeID *ua.NodeID, d *ua.ReadRawModifiedDetails) ([]*ua.DataValue, ua.StatusCode) {
+	if d.IsReadModified {
+		return nil, ua.StatusBadHistoryOperationUnsupported
+	}
+
+	start, end := d.StartTime, d.EndTime
+	switch {
+	case start.IsZero() && end.IsZero():
+		return nil, ua.StatusBadInvalidTimestampArgument
+	case (start.IsZero() || end.IsZero()) && d.NumValuesPerNode == 0:
+		return nil, ua.StatusBadInvalidTimestampArgument
+	}
+
+	lo, hi, reverse := readInterval(start, end)
+	vals, err := store.ReadValues(nodeID, lo, hi, d.ReturnBounds)
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	vals = slices.Clone(vals)
+
+	if d.ReturnBounds {
+		var before, after *ua.DataValue
+		if len(vals) > 0 && !lo.IsZero() && aggregate.ValueTime(vals[0]).Before(lo) {
+			before, vals = vals[0], vals[1:]
+		}
+		if len(vals) > 0 && !hi.IsZero() && !aggregate.ValueTime(vals[len(vals)-1]).Before(hi) {
+			after, vals = vals[len(vals)-1], vals[:len(vals)-1]
+		}
+		// a value at exactly the start time is its own bound.
+		if !lo.IsZero() && (len(vals) == 0 || !aggregate.ValueTime(vals[0]).Equal(lo)) {
+			if before == nil {
+				before = boundNotFound(lo)
+			}
+			vals = append([]*ua.DataValue{before}, vals...)
+		}
+		if !hi.IsZero() {
+			if after == nil {
+				after = boundNotFound(hi)
+			}
+			vals = append(vals, after)
+		}
+	}
+
+	if reverse {
+		slices.Reverse(vals)
+	}
+	return vals, ua.StatusOK
+}
+
+// readInterval returns the interval [lo, hi) of the history to read from
+// the start and the end time of a request, one of which may be zero. The
+// history is read in reverse order from the end time if only the end time
+// is given or if the end time is before the start time.
+func readInterval(start, end time.Time) (lo, hi time.Time, reverse bool) {
+	switch {
+	case start.IsZero():
+		return time.Time{}, end, true
+	case !end.IsZero() && end.Before(start):
+		return end, start, true
+	default:
+		return start, end, false
+	}
+}
+
+// readAtTime returns the values at the requested times for a
+// ReadAtTimeDetails request. Values which do not exist in the history are
+// interpolated from their neighbours.
+//
+// https://reference.opcfoundation.org/Core/Part11/v105/docs/6.4.5
+func readAtTime(store HistoryStore, nodeID *ua.NodeID, d *ua.ReadAtTimeDetails) ([]*ua.DataValue, ua.StatusCode) {
+	vals := make([]*ua.DataValue, len(d.ReqTimes))
+	for i, t := range d.ReqTimes {
+		vs, err := store.ReadValues(nodeID, t, t.Add(time.Nanosecond), true)
+		if err != nil {
+			return nil, storeStatus(err)
+		}
+
+		var before, at, after *ua.DataValue
+		for _, v := range vs {
+			switch vt := aggregate.ValueTime(v); {
+			case vt.Before(t):
+				before = v
+			case vt.Equal(t):
+				if at == nil {
+					at = v
+				}
+			default:
+				after = v
+			}
+		}
+		if at != nil {
+			vals[i] = at
+			continue
+		}
+
+		// without simple bounds the closest good values are used.
+		if !d.UseSimpleBounds && before != nil && aggregate.IsBad(before.Status) {
+			vs, err := store.ReadValues(nodeID, time.Time{}, t, false)
+			if err != nil {
+				return nil, storeStatus(err)
+			}
+			before = nil
+			for j := len(vs) - 1; j >= 0; j-- {
+				if !aggregate.IsBad(vs[j].Status) {
+					before = vs[j]
+					break
+				}
+			}
+		}
+		if !d.UseSimpleBounds && after != nil && aggregate.IsBad(after.Status) {
+			vs, err := store.ReadValues(nodeID, t.Add(time.Nanosecond), time.Time{}, false)
+			if err != nil {
+				return nil, storeStatus(err)
+			}
+			after = nil
+			for _, v := range vs {
+				if !aggregate.IsBad(v.Status) {
+					after = v
+					break
+				}
+			}
+		}
+		vals[i] = interpolate(t, before, after)
+	}
+	return vals, ua.StatusOK
+}
+
+// readProcessed calculates the aggregate values for a ReadProcessedDetails
+// request from the raw history. stepped is true if the values of the
+// variable are not interpolated between the raw values.
+//
+// https://reference.opcfoundation.org/Core/Part11/v105/docs/6.4.4
+func readProcessed(store HistoryStore, nodeID *ua.NodeID, d *ua.ReadProcessedDetails, aggregateType *ua.NodeID, stepped bool) ([]*ua.DataValue, ua.StatusCode) {
+	if !aggregate.Supported(aggregateType) {
+		return nil, ua.StatusBadAggregateNotSupported
+	}
+	if d.StartTime.IsZero() || d.EndTime.IsZero() || d.StartTime.Equal(d.EndTime) {
+		return nil, ua.StatusBadInvalidTimestampArgument
+	}
+	if d.ProcessingInterval < 0 {
+		return nil, ua.StatusBadInvalidArgument
+	}
+
+	lo, hi := d.StartTime, d.EndTime
+	if hi.Before(lo) {
+		lo, hi = hi, lo
+	}
+	interval := time.Duration(d.ProcessingInterval * float64(time.Millisecond))
+	if interval > 0 && hi.Sub(lo)/interval >= maxProcessedValues {
+		return nil, ua.StatusBadResponseTooLarge
+	}
+
+	vals, err := store.ReadValues(nodeID, lo, hi, true)
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+
+	cfg := aggregate.ConfigFrom(d.AggregateConfiguration, stepped)
+	res, err := aggregate.Process(aggregateType, vals, d.StartTime, d.EndTime, interval, cfg)
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	return res, ua.StatusOK
+}
+
+// stepped returns true if the aggregates treat the values of the variable
+// as stepped. The Stepped property of the historical configuration of the
+// variable decides if it exists. Otherwise only the values of analog items
+// are interpolated.
+//
+// https://reference.opcfoundation.org/Core/Part11/v105/docs/5.2.2
+func (s *Server) stepped(nodeID *ua.NodeID) bool {
+	path := &ua.RelativePath{Elements: []*ua.RelativePathElement{
+		{ReferenceTypeID: ua.NewNumericNodeID(0, id.HasHistoricalConfiguration), TargetName: &ua.QualifiedName{Name: "HA Configuration"}},
+		{ReferenceTypeID: ua.NewNumericNodeID(0, id.HasProperty), TargetName: &ua.QualifiedName{Name: "Stepped"}},
+	}}
+	if prop := s.nodeOrNil(s.followPath(nodeID, path)); prop != nil {
+		if v := prop.Value(); v != nil && v.Value != nil {
+			if b, ok := v.Value.Value().(bool); ok {
+				return b
+			}
+		}
+	}
+
+	n := s.Node(nodeID)
+	if n == nil {
+		return true
+	}
+	t := n.typeDefinition()
+	analog := t != nil && (s.isSubtype(t, ua.NewNumericNodeID(0, id.BaseAnalogType)) || s.isSubtype(t, ua.NewNumericNodeID(0, id.AnalogItemType)))
+	return !analog
+}
+
+// readEvents returns the selected fields of the events which match the
+// where clause of a ReadEventDetails request.
+//
+// https://reference.opcfoundation.org/Core/Part11/v105/docs/6.4.2
+func readEvents(srv *Server, store HistoryStore, nodeID *ua.NodeID, d *ua.ReadEventDetails) ([]*ua.HistoryEventFieldList, ua.StatusCode) {
+	start, end := d.StartTime, d.EndTime
+	switch {
+	case start.IsZero() && end.IsZero():
+		return nil, ua.StatusBadInvalidTimestampArgument
+	case (start.IsZero() || end.IsZero()) && d.NumValuesPerNode == 0:
+		return nil, ua.StatusBadInvalidTimestampArgument
+	case d.Filter == nil || len(d.Filter.SelectClauses) == 0:
+		return nil, ua.StatusBadEventFilterInvalid
+	}
+
+	where, _, status := newContentFilter(srv, d.Filter.WhereClause)
+	if status != ua.StatusOK {
+		return nil, ua.StatusBadEventFilterInvalid
+	}
+
+	lo, hi, reverse := readInterval(start, end)
+	evs, err := store.ReadEvents(nodeID, lo, hi)
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	events := make([]*ua.HistoryEventFieldList, 0, len(evs))
+	for _, ev := range evs {
+		if !where.match(&eventTarget{srv: srv, ev: ev}) {
+			continue
+		}
+		events = append(events, &ua.HistoryEventFieldList{EventFields: ev.selectFields(d.Filter.SelectClauses)})
+	}
+	if reverse {
+		slices.Reverse(events)
+	}
+	return events, ua.StatusOK
+}
+
+// interpolate returns the value at time t between the two bounding values.
+// Numeric values are interpolated linearly and all other values are
+// stepped.
+func interpolate(t time.Time, before, after *ua.DataValue) *ua.DataValue {
+	if before == nil {
+		return &ua.DataValue{
+			EncodingMask:    ua.DataValueStatusCode | ua.DataValueSourceTimestamp | ua.DataValueServerTimestamp,
+			Status:          ua.StatusBadNoData,
+			SourceTimestamp: t,
+			ServerTimestamp: t,
+		}
+	}
+
+	v := before.Value
+	status := ua.StatusOK
+	if aggregate.IsBad(before.Status) || (after != nil && aggregate.IsBad(after.Status)) {
+		status = ua.StatusUncertainDataSubNormal
+	}
+	if after != nil && before.Value != nil && after.Value != nil {
+		x0, ok0 := aggregate.ToFloat(before.Value.Value())
+		x1, ok1 := aggregate.ToFloat(after.Value.Value())
+		t0, t1 := aggregate.ValueTime(before), aggregate.ValueTime(after)
+		if ok0 && ok1 && t1.After(t0) {
+			x := x0 + (x1-x0)*float64(t.Sub(t0))/float64(t1.Sub(t0))
+			if iv, err := ua.NewVariant(aggregate.FromFloat(x, before.Value.Value())); err == nil {
+				v = iv
+			}
+		}
+	}
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueValue | ua.DataValueStatusCode | ua.DataValueSourceTimestamp | ua.DataValueServerTimestamp,
+		Value:           v,
+		Status:          status | aggregate.HistorianInterpolated,
+		SourceTimestamp: t,
+		ServerTimestamp: t,
+	}
+}
+
+// boundNotFound returns the bounding value for a bound without data.
+func boundNotFound(t time.Time) *ua.DataValue {
+	return &ua.DataValue{
+		EncodingMask:    ua.DataValueStatusCode | ua.DataValueSourceTimestamp | ua.DataValueServerTimestamp,
+		Status:          ua.StatusBadBoundNotFound,
+		SourceTimestamp: t,
+		ServerTimestamp: t,
+	}
+}
+
+// withTimestamps returns a copy of v which only contains the requested
+// timestamps.
+func withTimestamps(v *ua.DataValue, ts ua.TimestampsToReturn) *ua.DataValue {
+	dv := *v
+	dv.EncodingMask &^= ua.DataValueSourceTimestamp | ua.DataValueSourcePicoseconds | ua.DataValueServerTimestamp | ua.DataValueServerPicoseconds
+	if dv.Status != ua.StatusOK {
+		dv.EncodingMask |= ua.DataValueStatusCode
+	}
+	if (ts == ua.TimestampsToReturnSource || ts == ua.TimestampsToReturnBoth) && !dv.SourceTimestamp.IsZero() {
+		dv.EncodingMask |= ua.DataValueSourceTimestamp
+	} else {
+		dv.SourceTimestamp = time.Time{}
+	}
+	if (ts == ua.TimestampsToReturnServer || ts == ua.TimestampsToReturnBoth) && !dv.ServerTimestamp.IsZero() {
+		dv.EncodingMask |= ua.DataValueServerTimestamp
+	} else {
+		dv.ServerTimestamp = time.Time{}
+	}
+	return &dv
+}
+
+// storeStatus maps an error of a history store to a status code.
+func storeStatus(err error) ua.StatusCode {
+	var status ua.StatusCode
+	if errors.As(err, &status) {
+		return status
+	}
+	return ua.StatusBadInternalError
+}
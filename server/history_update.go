@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/rand"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// historyUpdate performs a single operation of a HistoryUpdate request.
+//
+// https://reference.opcfoundation.org/Core/Part11/v105/docs/6.8
+func (s *AttributeService) historyUpdate(sess *session, eo *ua.ExtensionObject) *ua.HistoryUpdateResult {
+	fail := func(status ua.StatusCode) *ua.HistoryUpdateResult {
+		return &ua.HistoryUpdateResult{
+			StatusCode:       status,
+			OperationResults: []ua.StatusCode{},
+			DiagnosticInfos:  []*ua.DiagnosticInfo{},
+		}
+	}
+
+	if eo == nil || eo.Value == nil {
+		return fail(ua.StatusBadHistoryOperationInvalid)
+	}
+	store, ok := s.srv.cfg.history.(HistoryUpdater)
+	if !ok {
+		return fail(ua.StatusBadHistoryOperationUnsupported)
+	}
+
+	var nodeID *ua.NodeID
+	var perms []ua.PermissionType
+	switch d := eo.Value.(type) {
+	case *ua.UpdateDataDetails:
+		nodeID, perms = d.NodeID, updatePermissions(d.PerformInsertReplace)
+	case *ua.UpdateEventDetails:
+		nodeID, perms = d.NodeID, updatePermissions(d.PerformInsertReplace)
+	case *ua.DeleteRawModifiedDetails:
+		nodeID, perms = d.NodeID, []ua.PermissionType{ua.PermissionTypeDeleteHistory}
+	case *ua.DeleteAtTimeDetails:
+		nodeID, perms = d.NodeID, []ua.PermissionType{ua.PermissionTypeDeleteHistory}
+	case *ua.DeleteEventDetails:
+		nodeID, perms = d.NodeID, []ua.PermissionType{ua.PermissionTypeDeleteHistory}
+	default:
+		return fail(ua.StatusBadHistoryOperationInvalid)
+	}
+	if nodeID == nil {
+		return fail(ua.StatusBadNodeIDInvalid)
+	}
+	if status := historyAccess(s.srv, nodeID, ua.AccessLevelTypeHistoryWrite); status != ua.StatusOK {
+		return fail(status)
+	}
+	for _, perm := range perms {
+		if status := s.srv.allowed(sess, nodeID, perm); status != ua.StatusOK {
+			return fail(status)
+		}
+	}
+
+	var results []ua.StatusCode
+	var err error
+	status := ua.StatusOK
+	switch d := eo.Value.(type) {
+	case *ua.UpdateDataDetails:
+		if !validUpdateType(d.PerformInsertReplace) {
+			return fail(ua.StatusBadHistoryOperationInvalid)
+		}
+		results, err = store.UpdateValues(d.NodeID, d.PerformInsertReplace, d.UpdateValues)
+
+	case *ua.UpdateEventDetails:
+		if !validUpdateType(d.PerformInsertReplace) {
+			return fail(ua.StatusBadHistoryOperationInvalid)
+		}
+		if d.Filter == nil || len(d.Filter.SelectClauses) == 0 {
+			return fail(ua.StatusBadEventFilterInvalid)
+		}
+		events := make([]*Event, len(d.EventData))
+		for i, data := range d.EventData {
+			events[i] = eventFromFields(d.Filter.SelectClauses, data.EventFields)
+			if len(events[i].EventID()) == 0 && d.PerformInsertReplace != ua.PerformUpdateTypeReplace {
+				events[i].Fields["EventId"] = ua.MustVariant(newEventID())
+			}
+		}
+		results, err = store.UpdateEvents(d.NodeID, d.PerformInsertReplace, events)
+
+	case *ua.DeleteRawModifiedDetails:
+		if d.IsDeleteModified {
+			return fail(ua.StatusBadHistoryOperationUnsupported)
+		}
+		if d.StartTime.IsZero() || d.EndTime.IsZero() {
+			return fail(ua.StatusBadInvalidTimestampArgument)
+		}
+		start, end := d.StartTime, d.EndTime
+		if end.Before(start) {
+			start, end = end, start
+		}
+		var n int
+		n, err = store.DeleteValues(d.NodeID, start, end)
+		if n == 0 {
+			status = ua.StatusGoodNoData
+		}
+
+	case *ua.DeleteAtTimeDetails:
+		results, err = store.DeleteValuesAtTime(d.NodeID, d.ReqTimes)
+
+	case *ua.DeleteEventDetails:
+		results, err = store.DeleteEvents(d.NodeID, d.EventIDs)
+	}
+	if err != nil {
+		return fail(storeStatus(err))
+	}
+	if results == nil {
+		results = []ua.StatusCode{}
+	}
+
+	return &ua.HistoryUpdateResult{
+		StatusCode:       status,
+		OperationResults: results,
+		DiagnosticInfos:  []*ua.DiagnosticInfo{},
+	}
+}
+
+// updatePermissions returns the permissions which are needed to insert or
+// replace values or events. Updates can do both.
+func updatePermissions(t ua.PerformUpdateType) []ua.PermissionType {
+	switch t {
+	case ua.PerformUpdateTypeInsert:
+		return []ua.PermissionType{ua.PermissionTypeInsertHistory}
+	case ua.PerformUpdateTypeReplace:
+		return []ua.PermissionType{ua.PermissionTypeModifyHistory}
+	default:
+		return []ua.PermissionType{ua.PermissionTypeInsertHistory, ua.PermissionTypeModifyHistory}
+	}
+}
+
+func validUpdateType(t ua.PerformUpdateType) bool {
+	switch t {
+	case ua.PerformUpdateTypeInsert, ua.PerformUpdateTypeReplace, ua.PerformUpdateTypeUpdate:
+		return true
+	default:
+		return false
+	}
+}
+
+// eventFromFields creates an event from the field values selected by the
+// select clauses of an event filter.
+func eventFromFields(clauses []*ua.SimpleAttributeOperand, fields []*ua.Variant) *Event {
+	ev := &Event{Fields: make(map[string]*ua.Variant)}
+	for i, op := range clauses {
+		if i >= len(fields) {
+			break
+		}
+		if fields[i] == nil || fields[i].Value() == nil {
+			continue
+		}
+		ev.Fields[browsePath(op.BrowsePath)] = fields[i]
+	}
+	return ev
+}
+
+// newEventID returns a new random event id.
+func newEventID() []byte {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return id
+}
@@ -0,0 +1,383 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Method is a Go function that is bound to a Method node and that
+// the server executes when a client calls the method.
+//
+// The function can have any of the forms
+//
+//	func(in1 T1, in2 T2, ...) (out1 O1, out2 O2, ...)
+//	func(ctx context.Context, in1 T1, ...) (out1 O1, ..., err error)
+//
+// where the input and output types are OPC UA built-in types (bool, int32,
+// string, time.Time, *ua.NodeID, ...), slices of them, *ua.Variant,
+// *ua.ExtensionObject or pointers to structs registered with
+// ua.RegisterExtensionObject. The context carries the calling session
+// and object which can be retrieved with SessionID and MethodObjectID.
+// If the last return value is an error and it is a ua.StatusCode then it is
+// returned to the client as the status of the call.
+type Method struct {
+	fn reflect.Value
+
+	hasCtx bool
+	hasErr bool
+
+	inTypes  []reflect.Type
+	outTypes []reflect.Type
+
+	// Inputs and Outputs describe the arguments of the method. They are
+	// exposed in the InputArguments and OutputArguments properties of the
+	// method node.
+	Inputs  []*ua.Argument
+	Outputs []*ua.Argument
+}
+
+// MethodOption is an option function type to modify a Method.
+type MethodOption func(*Method)
+
+// InputArgumentNames sets the names of the input arguments. By default the
+// arguments are named "Input1", "Input2", ...
+func InputArgumentNames(names ...string) MethodOption {
+	return func(m *Method) {
+		for i := range names {
+			if i < len(m.Inputs) {
+				m.Inputs[i].Name = names[i]
+			}
+		}
+	}
+}
+
+// OutputArgumentNames sets the names of the output arguments. By default the
+// arguments are named "Output1", "Output2", ...
+func OutputArgumentNames(names ...string) MethodOption {
+	return func(m *Method) {
+		for i := range names {
+			if i < len(m.Outputs) {
+				m.Outputs[i].Name = names[i]
+			}
+		}
+	}
+}
+
+// InputArgumentDescriptions sets the descriptions of the input arguments.
+func InputArgumentDescriptions(desc ...string) MethodOption {
+	return func(m *Method) {
+		for i := range desc {
+			if i < len(m.Inputs) {
+				m.Inputs[i].Description = ua.NewLocalizedText(desc[i])
+			}
+		}
+	}
+}
+
+// OutputArgumentDescriptions sets the descriptions of the output arguments.
+func OutputArgumentDescriptions(desc ...string) MethodOption {
+	return func(m *Method) {
+		for i := range desc {
+			if i < len(m.Outputs) {
+				m.Outputs[i].Description = ua.NewLocalizedText(desc[i])
+			}
+		}
+	}
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	variantType = reflect.TypeOf(new(ua.Variant))
+	extObjType  = reflect.TypeOf(new(ua.ExtensionObject))
+	byteStrType = reflect.TypeOf([]byte{})
+)
+
+// builtinTypes maps the Go types to the OPC UA built-in types which can
+// be used as method arguments. The ids of the built-in types are also
+// the node ids of their data types.
+var builtinTypes = map[reflect.Type]ua.TypeID{
+	reflect.TypeOf(false):                  ua.TypeIDBoolean,
+	reflect.TypeOf(int8(0)):                ua.TypeIDSByte,
+	reflect.TypeOf(uint8(0)):               ua.TypeIDByte,
+	reflect.TypeOf(int16(0)):               ua.TypeIDInt16,
+	reflect.TypeOf(uint16(0)):              ua.TypeIDUint16,
+	reflect.TypeOf(int32(0)):               ua.TypeIDInt32,
+	reflect.TypeOf(uint32(0)):              ua.TypeIDUint32,
+	reflect.TypeOf(int64(0)):               ua.TypeIDInt64,
+	reflect.TypeOf(uint64(0)):              ua.TypeIDUint64,
+	reflect.TypeOf(float32(0)):             ua.TypeIDFloat,
+	reflect.TypeOf(float64(0)):             ua.TypeIDDouble,
+	reflect.TypeOf(""):                     ua.TypeIDString,
+	reflect.TypeOf(time.Time{}):            ua.TypeIDDateTime,
+	reflect.TypeOf(new(ua.GUID)):           ua.TypeIDGUID,
+	byteStrType:                            ua.TypeIDByteString,
+	reflect.TypeOf(ua.XMLElement("")):      ua.TypeIDXMLElement,
+	reflect.TypeOf(new(ua.NodeID)):         ua.TypeIDNodeID,
+	reflect.TypeOf(new(ua.ExpandedNodeID)): ua.TypeIDExpandedNodeID,
+	reflect.TypeOf(ua.StatusCode(0)):       ua.TypeIDStatusCode,
+	reflect.TypeOf(new(ua.QualifiedName)):  ua.TypeIDQualifiedName,
+	reflect.TypeOf(new(ua.LocalizedText)):  ua.TypeIDLocalizedText,
+	reflect.TypeOf(new(ua.DataValue)):      ua.TypeIDDataValue,
+}
+
+// NewMethod creates a method from the Go function fn. It returns an error
+// if fn is not a function or if one of its argument types cannot be mapped
+// to an OPC UA data type.
+func NewMethod(fn any, opts ...MethodOption) (*Method, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("method must be a function, got %T", fn)
+	}
+	t := v.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("method must not be variadic")
+	}
+
+	m := &Method{fn: v}
+
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if i == 0 && in == contextType {
+			m.hasCtx = true
+			continue
+		}
+		arg, err := argument(in)
+		if err != nil {
+			return nil, fmt.Errorf("input argument %d: %w", len(m.Inputs)+1, err)
+		}
+		arg.Name = fmt.Sprintf("Input%d", len(m.Inputs)+1)
+		m.inTypes = append(m.inTypes, in)
+		m.Inputs = append(m.Inputs, arg)
+	}
+
+	for i := 0; i < t.NumOut(); i++ {
+		out := t.Out(i)
+		if i == t.NumOut()-1 && out == errorType {
+			m.hasErr = true
+			continue
+		}
+		arg, err := argument(out)
+		if err != nil {
+			return nil, fmt.Errorf("output argument %d: %w", len(m.Outputs)+1, err)
+		}
+		arg.Name = fmt.Sprintf("Output%d", len(m.Outputs)+1)
+		m.outTypes = append(m.outTypes, out)
+		m.Outputs = append(m.Outputs, arg)
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// argument returns the argument description for the Go type t.
+func argument(t reflect.Type) (*ua.Argument, error) {
+	arg := &ua.Argument{
+		ValueRank:   -1, // scalar
+		Description: ua.NewLocalizedText(""),
+	}
+	if t.Kind() == reflect.Slice && t != byteStrType {
+		arg.ValueRank = 1 // one dimension
+		arg.ArrayDimensions = []uint32{0}
+		t = t.Elem()
+	}
+	dt, err := argumentDataType(t)
+	if err != nil {
+		return nil, err
+	}
+	arg.DataType = dt
+	return arg, nil
+}
+
+func argumentDataType(t reflect.Type) (*ua.NodeID, error) {
+	if typeID, ok := builtinTypes[t]; ok {
+		return ua.NewNumericNodeID(0, uint32(typeID)), nil
+	}
+	switch {
+	case t == variantType:
+		return ua.NewNumericNodeID(0, id.BaseDataType), nil
+	case t == extObjType:
+		return ua.NewNumericNodeID(0, id.Structure), nil
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct:
+		// only registered extension objects can be encoded.
+		if typeID := ua.ExtensionObjectTypeID(reflect.Zero(t).Interface()); typeID.NodeID.IntID() == 0 {
+			return nil, fmt.Errorf("unregistered extension object %s", t)
+		}
+		return ua.NewNumericNodeID(0, id.Structure), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// call validates the input arguments and executes the method.
+func (m *Method) call(ctx context.Context, args []*ua.Variant) *ua.CallMethodResult {
+	res := &ua.CallMethodResult{
+		InputArgumentResults:         make([]ua.StatusCode, len(args)),
+		InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+		OutputArguments:              []*ua.Variant{},
+	}
+
+	switch {
+	case len(args) < len(m.inTypes):
+		res.StatusCode = ua.StatusBadArgumentsMissing
+		res.InputArgumentResults = []ua.StatusCode{}
+		return res
+	case len(args) > len(m.inTypes):
+		res.StatusCode = ua.StatusBadTooManyArguments
+		res.InputArgumentResults = []ua.StatusCode{}
+		return res
+	}
+
+	var in []reflect.Value
+	if m.hasCtx {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+
+	invalid := false
+	for i, arg := range args {
+		v, status := argumentValue(arg, m.inTypes[i])
+		res.InputArgumentResults[i] = status
+		if status != ua.StatusOK {
+			invalid = true
+			continue
+		}
+		in = append(in, v)
+	}
+	if invalid {
+		res.StatusCode = ua.StatusBadInvalidArgument
+		return res
+	}
+
+	out, ok := m.invoke(in)
+	if !ok {
+		res.StatusCode = ua.StatusBadInternalError
+		return res
+	}
+	if m.hasErr {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			if code, ok := err.(ua.StatusCode); ok {
+				res.StatusCode = code
+			} else {
+				res.StatusCode = ua.StatusBadUnexpectedError
+			}
+			return res
+		}
+		out = out[:len(out)-1]
+	}
+
+	for i := range out {
+		v, err := outputVariant(out[i])
+		if err != nil {
+			res.StatusCode = ua.StatusBadUnexpectedError
+			res.OutputArguments = []*ua.Variant{}
+			return res
+		}
+		res.OutputArguments = append(res.OutputArguments, v)
+	}
+	res.StatusCode = ua.StatusOK
+	return res
+}
+
+// invoke calls the handler of the method. It returns false if the handler
+// panics so that a faulty handler does not crash the server.
+func (m *Method) invoke(in []reflect.Value) (out []reflect.Value, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic in method handler %s: %v", m.fn.Type(), r)
+			out, ok = nil, false
+		}
+	}()
+	return m.fn.Call(in), true
+}
+
+// argumentValue converts the variant to a value of type t.
+// It returns StatusBadTypeMismatch if the types are not compatible.
+func argumentValue(arg *ua.Variant, t reflect.Type) (reflect.Value, ua.StatusCode) {
+	if t == variantType {
+		if arg == nil {
+			arg = &ua.Variant{}
+		}
+		return reflect.ValueOf(arg), ua.StatusOK
+	}
+
+	var val any
+	if arg != nil {
+		val = arg.Value()
+	}
+	if val == nil {
+		// only arrays and references may be null
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Ptr {
+			return reflect.Zero(t), ua.StatusOK
+		}
+		return reflect.Value{}, ua.StatusBadTypeMismatch
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Type() == t {
+		return v, ua.StatusOK
+	}
+
+	// unwrap extension objects into the registered struct type
+	if eo, ok := val.(*ua.ExtensionObject); ok && eo != nil && eo.Value != nil {
+		if ev := reflect.ValueOf(eo.Value); ev.Type() == t {
+			return ev, ua.StatusOK
+		}
+	}
+
+	// arrays of extension objects
+	if eos, ok := val.([]*ua.ExtensionObject); ok && t.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(t, len(eos), len(eos))
+		for i, eo := range eos {
+			if eo == nil || eo.Value == nil || reflect.TypeOf(eo.Value) != t.Elem() {
+				return reflect.Value{}, ua.StatusBadTypeMismatch
+			}
+			s.Index(i).Set(reflect.ValueOf(eo.Value))
+		}
+		return s, ua.StatusOK
+	}
+	return reflect.Value{}, ua.StatusBadTypeMismatch
+}
+
+// outputVariant wraps an output value of a method in a variant.
+func outputVariant(v reflect.Value) (*ua.Variant, error) {
+	// nil pointers, e.g. a nil *ua.NodeID, are empty values.
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return &ua.Variant{}, nil
+	}
+	if v.Type() == variantType {
+		return v.Interface().(*ua.Variant), nil
+	}
+	if _, ok := builtinTypes[v.Type()]; !ok && v.Type() != extObjType {
+		switch {
+		case v.Kind() == reflect.Ptr:
+			return ua.NewVariant(ua.NewExtensionObject(v.Interface()))
+		case v.Kind() == reflect.Slice && v.Type() != byteStrType:
+			if _, ok := builtinTypes[v.Type().Elem()]; !ok && v.Type().Elem() != extObjType {
+				eos := make([]*ua.ExtensionObject, v.Len())
+				for i := range eos {
+					eos[i] = ua.NewExtensionObject(v.Index(i).Interface())
+				}
+				return ua.NewVariant(eos)
+			}
+		}
+	}
+	return ua.NewVariant(v.Interface())
+}
+
+// argumentsValue returns the value for the InputArguments and
+// OutputArguments properties.
+func argumentsValue(args []*ua.Argument) *ua.DataValue {
+	eos := make([]*ua.ExtensionObject, len(args))
+	for i, a := range args {
+		eos[i] = ua.NewExtensionObject(a)
+	}
+	return DataValueFromValue(eos)
+}
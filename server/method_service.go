@@ -1,6 +1,9 @@
 package server
 
 import (
+	"context"
+
+	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 	"github.com/gopcua/opcua/uasc"
 )
@@ -22,5 +25,119 @@ func (s *MethodService) Call(sc *uasc.SecureChannel, r ua.Request, reqID uint32)
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+	ctx := withSession(context.Background(), sess)
+
+	results := make([]*ua.CallMethodResult, len(req.MethodsToCall))
+	for i, m := range req.MethodsToCall {
+		if s.srv.cfg.logger != nil {
+			s.srv.cfg.logger.Debug("call: object=%s method=%s", m.ObjectID, m.MethodID)
+		}
+		results[i] = s.call(ctx, m)
+	}
+
+	return &ua.CallResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+func (s *MethodService) call(ctx context.Context, req *ua.CallMethodRequest) *ua.CallMethodResult {
+	fail := func(status ua.StatusCode) *ua.CallMethodResult {
+		return &ua.CallMethodResult{
+			StatusCode:                   status,
+			InputArgumentResults:         []ua.StatusCode{},
+			InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+			OutputArguments:              []*ua.Variant{},
+		}
+	}
+
+	if req.ObjectID == nil || req.MethodID == nil {
+		return fail(ua.StatusBadNodeIDInvalid)
+	}
+	sess := sessionFromContext(ctx)
+	objectID, methodID := sess.resolveNodeID(req.ObjectID), sess.resolveNodeID(req.MethodID)
+
+	// the object must exist in one of the namespaces
+	ns, err := s.srv.Namespace(int(objectID.Namespace()))
+	if err != nil {
+		return fail(ua.StatusBadNodeIDUnknown)
+	}
+	if dv := ns.Attribute(objectID, ua.AttributeIDNodeClass); dv == nil || dv.Status != ua.StatusOK {
+		return fail(ua.StatusBadNodeIDUnknown)
+	}
+
+	m := s.srv.method(methodID)
+	if m == nil {
+		return fail(ua.StatusBadMethodInvalid)
+	}
+
+	if mn := s.srv.Node(methodID); mn != nil {
+		if !mn.Executable() {
+			return fail(ua.StatusBadNotExecutable)
+		}
+		if on := s.srv.Node(objectID); on != nil && !s.hasMethod(on, methodID) {
+			return fail(ua.StatusBadMethodInvalid)
+		}
+	}
+
+	// the user needs the permission to call the method on the object.
+	for _, nid := range []*ua.NodeID{methodID, objectID} {
+		if status := s.srv.allowed(sess, nid, ua.PermissionTypeCall); status != ua.StatusOK {
+			return fail(status)
+		}
+	}
+
+	ctx = context.WithValue(ctx, objectIDKey{}, objectID)
+	return m.call(ctx, req.InputArguments)
+}
+
+// hasMethod returns true if the method is a component of the object or of
+// one of the object types the object is an instance of.
+func (s *MethodService) hasMethod(obj *Node, methodID *ua.NodeID) bool {
+	seen := make(map[string]bool)
+	todo := []*Node{obj}
+	for len(todo) > 0 {
+		n := todo[0]
+		todo = todo[1:]
+		if n == nil || seen[n.ID().String()] {
+			continue
+		}
+		seen[n.ID().String()] = true
+
+		for _, r := range n.references() {
+			if r.ReferenceTypeID == nil || r.NodeID == nil || !r.IsForward {
+				continue
+			}
+			switch r.ReferenceTypeID.IntID() {
+			case id.HasComponent:
+				if r.NodeID.NodeID.Equal(methodID) {
+					return true
+				}
+			case id.HasTypeDefinition:
+				todo = append(todo, s.srv.Node(r.NodeID.NodeID))
+			}
+		}
+		// methods are inherited from the super types
+		for _, r := range n.references() {
+			if r.ReferenceTypeID != nil && r.NodeID != nil && !r.IsForward && r.ReferenceTypeID.IntID() == id.HasSubtype {
+				todo = append(todo, s.srv.Node(r.NodeID.NodeID))
+			}
+		}
+	}
+	return false
+}
+
+type objectIDKey struct{}
+
+// MethodObjectID returns the id of the object on which the method
+// executed with ctx was called.
+func MethodObjectID(ctx context.Context) *ua.NodeID {
+	nid, _ := ctx.Value(objectIDKey{}).(*ua.NodeID)
+	return nid
 }
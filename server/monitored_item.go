@@ -0,0 +1,334 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// statusOverflow are the InfoType and the Overflow bits of a status code
+// which signal that values were discarded from the queue of a monitored
+// item.
+const statusOverflow = 0x0400 | 0x0080
+
+// MonitoredItem samples an attribute of a node and queues the changed
+// values until the subscription publishes them. Items which monitor the
+// EventNotifier attribute queue the events of the node instead. Events
+// which do not fit into the queue are discarded.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.12.1
+type MonitoredItem struct {
+	ID  uint32
+	Sub *Subscription
+	Req *ua.MonitoredItemCreateRequest
+
+	// Mode is the monitoring mode of the item. Disabled items neither
+	// sample nor queue values. Sampling items queue values but do not
+	// report them. It is protected by mu.
+	Mode ua.MonitoringMode
+
+	srv *Server
+
+	// samplingInterval and queueSize are the revised parameters of the
+	// item. They and the filters can be modified and are protected by mu.
+	samplingInterval time.Duration
+	queueSize        int
+	discardOldest    bool
+	filter           *dataChangeFilter
+	events           *eventFilter
+
+	mu         sync.Mutex
+	queue      []*ua.MonitoredItemNotification
+	eventQueue []*ua.EventFieldList
+	last       *ua.DataValue
+	stop       chan struct{}
+}
+
+// newMonitoredItem returns a monitored item with the revised parameters of
+// the request. The item does not sample until start is called.
+func newMonitoredItem(srv *Server, id uint32, sub *Subscription, req *ua.MonitoredItemCreateRequest, filter *dataChangeFilter, events *eventFilter) *MonitoredItem {
+	m := &MonitoredItem{
+		ID:            id,
+		Sub:           sub,
+		Req:           req,
+		Mode:          req.MonitoringMode,
+		srv:           srv,
+		discardOldest: req.RequestedParameters.DiscardOldest,
+		filter:        filter,
+		events:        events,
+	}
+	m.samplingInterval = m.reviseSamplingInterval(req.RequestedParameters.SamplingInterval)
+	m.queueSize = int(m.reviseQueueSize(req.RequestedParameters.QueueSize))
+	return m
+}
+
+// reviseSamplingInterval returns the sampling interval for a requested
+// interval in milliseconds. A negative interval is the publishing interval
+// of the subscription and zero the fastest supported interval. Intervals
+// are never shorter than the MinimumSamplingInterval of the node. Events
+// are not sampled.
+func (m *MonitoredItem) reviseSamplingInterval(requested float64) time.Duration {
+	if m.eventItem() {
+		return 0
+	}
+	if requested < 0 && m.Sub != nil {
+		requested = m.Sub.RevisedPublishingInterval
+	}
+	if min := m.srv.cfg.cap.MinSupportedSampleRate; requested < min {
+		requested = min
+	}
+	if m.Req.ItemToMonitor.AttributeID == ua.AttributeIDValue {
+		dv := m.srv.attributeValue(m.Req.ItemToMonitor.NodeID, ua.AttributeIDMinimumSamplingInterval)
+		if min, ok := dv.(float64); ok && requested < min {
+			requested = min
+		}
+	}
+	d := time.Duration(requested * float64(time.Millisecond))
+	if max := m.srv.cfg.maxSamplingInterval; max > 0 && d > max {
+		d = max
+	}
+	if d <= 0 {
+		// the ticker of the sampler needs a positive interval.
+		d = time.Millisecond
+	}
+	return d
+}
+
+// reviseQueueSize returns the queue size for a requested queue size. Event
+// items get the largest queue unless they request a size.
+func (m *MonitoredItem) reviseQueueSize(requested uint32) uint32 {
+	max := m.srv.cfg.cap.MaxMonitoredItemsQueueSize
+	if requested == 0 && m.eventItem() && max > 0 {
+		requested = max
+	}
+	if requested == 0 {
+		requested = 1
+	}
+	if max > 0 && requested > max {
+		requested = max
+	}
+	return requested
+}
+
+// eventItem reports whether the item monitors the events of a node.
+func (m *MonitoredItem) eventItem() bool {
+	return m.Req.ItemToMonitor.AttributeID == ua.AttributeIDEventNotifier
+}
+
+// revised returns the sampling interval in milliseconds and the queue
+// size.
+func (m *MonitoredItem) revised() (float64, uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return float64(m.samplingInterval) / float64(time.Millisecond), uint32(m.queueSize)
+}
+
+// modify changes the parameters and the filter of the item. Values which
+// no longer fit into the queue are discarded and the sampler restarts if
+// the sampling interval has changed.
+func (m *MonitoredItem) modify(params *ua.MonitoringParameters, filter *dataChangeFilter, events *eventFilter) {
+	interval := m.reviseSamplingInterval(params.SamplingInterval)
+	queueSize := int(m.reviseQueueSize(params.QueueSize))
+
+	m.mu.Lock()
+	m.Req.RequestedParameters = params
+	m.discardOldest = params.DiscardOldest
+	m.filter = filter
+	m.events = events
+	m.queueSize = queueSize
+	if n := len(m.eventQueue) - queueSize; n > 0 {
+		if m.discardOldest {
+			m.eventQueue = m.eventQueue[n:]
+		} else {
+			m.eventQueue = m.eventQueue[:queueSize]
+		}
+	}
+	if n := len(m.queue) - queueSize; n > 0 {
+		if m.discardOldest {
+			m.queue = m.queue[n:]
+		} else {
+			m.queue = m.queue[:queueSize]
+		}
+		if queueSize > 1 {
+			i := 0
+			if !m.discardOldest {
+				i = queueSize - 1
+			}
+			m.queue[i] = withOverflow(m.queue[i])
+		}
+	}
+	restart := interval != m.samplingInterval
+	m.samplingInterval = interval
+	m.mu.Unlock()
+
+	if restart {
+		m.stopSampling()
+		m.start()
+	}
+}
+
+// start starts the sampler unless the item is disabled or monitors
+// events.
+func (m *MonitoredItem) start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Mode == ua.MonitoringModeDisabled || m.stop != nil || m.eventItem() {
+		return
+	}
+	m.stop = make(chan struct{})
+	go m.run(m.stop, m.samplingInterval)
+}
+
+// stopSampling stops the sampler.
+func (m *MonitoredItem) stopSampling() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// setMode changes the monitoring mode. Disabling an item discards its
+// queue and the next enabled sample is always reported.
+func (m *MonitoredItem) setMode(mode ua.MonitoringMode) {
+	m.mu.Lock()
+	m.Mode = mode
+	if mode == ua.MonitoringModeDisabled {
+		m.queue = nil
+		m.eventQueue = nil
+		m.last = nil
+	}
+	m.mu.Unlock()
+
+	if mode == ua.MonitoringModeDisabled {
+		m.stopSampling()
+		return
+	}
+	m.start()
+}
+
+// run samples the value until stop is closed. The first sample is always
+// queued.
+func (m *MonitoredItem) run(stop chan struct{}, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	m.report(m.read(), false)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			m.report(m.read(), false)
+		}
+	}
+}
+
+// read returns the current value of the monitored attribute.
+func (m *MonitoredItem) read() *ua.DataValue {
+	n := m.Req.ItemToMonitor.NodeID
+	ns, err := m.srv.Namespace(int(n.Namespace()))
+	if err != nil {
+		return &ua.DataValue{EncodingMask: ua.DataValueStatusCode, Status: ua.StatusBadNodeIDUnknown}
+	}
+	dv := ns.Attribute(n, m.Req.ItemToMonitor.AttributeID)
+	if dv == nil {
+		return &ua.DataValue{EncodingMask: ua.DataValueStatusCode, Status: ua.StatusBadAttributeIDInvalid}
+	}
+	return dv
+}
+
+// report queues a value if the filter of the item reports it as changed
+// from the last queued value. force queues the value in any case.
+func (m *MonitoredItem) report(dv *ua.DataValue, force bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Mode == ua.MonitoringModeDisabled || m.eventItem() {
+		return
+	}
+	if !force && !m.filter.changed(m.last, dv) {
+		return
+	}
+	m.last = dv
+	m.enqueue(dv)
+}
+
+// enqueue adds a value to the queue. If the queue is full either the oldest
+// or the newest value is discarded and the overflow bit is set on the value
+// next to the gap. Queues of size one never signal an overflow.
+func (m *MonitoredItem) enqueue(dv *ua.DataValue) {
+	n := &ua.MonitoredItemNotification{
+		ClientHandle: m.Req.RequestedParameters.ClientHandle,
+		Value:        dv,
+	}
+	switch {
+	case len(m.queue) < m.queueSize:
+		m.queue = append(m.queue, n)
+	case m.queueSize == 1:
+		m.queue[0] = n
+	case m.discardOldest:
+		m.queue = append(m.queue[1:], n)
+		m.queue[0] = withOverflow(m.queue[0])
+	default:
+		m.queue[len(m.queue)-1] = withOverflow(n)
+	}
+}
+
+// withOverflow returns a copy of the notification with the overflow bit set
+// in the status code of its value.
+func withOverflow(n *ua.MonitoredItemNotification) *ua.MonitoredItemNotification {
+	dv := *n.Value
+	dv.Status |= statusOverflow
+	dv.EncodingMask |= ua.DataValueStatusCode
+	return &ua.MonitoredItemNotification{ClientHandle: n.ClientHandle, Value: &dv}
+}
+
+// reportEvent queues the selected fields of an event if the where clause
+// of the filter matches the event.
+func (m *MonitoredItem) reportEvent(ev *Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Mode == ua.MonitoringModeDisabled || m.events == nil || !m.events.match(ev) {
+		return
+	}
+	n := &ua.EventFieldList{
+		ClientHandle: m.Req.RequestedParameters.ClientHandle,
+		EventFields:  m.events.fields(ev),
+	}
+	switch {
+	case len(m.eventQueue) < m.queueSize:
+		m.eventQueue = append(m.eventQueue, n)
+	case m.discardOldest:
+		m.eventQueue = append(m.eventQueue[1:], n)
+	default:
+		m.eventQueue[len(m.eventQueue)-1] = n
+	}
+}
+
+// notifications removes the queued values and events of a reporting item
+// and returns them.
+func (m *MonitoredItem) notifications() ([]*ua.MonitoredItemNotification, []*ua.EventFieldList) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Mode != ua.MonitoringModeReporting {
+		return nil, nil
+	}
+	q, eq := m.queue, m.eventQueue
+	m.queue, m.eventQueue = nil, nil
+	return q, eq
+}
+
+// hasNotifications reports whether a reporting item has queued values or
+// events.
+func (m *MonitoredItem) hasNotifications() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Mode == ua.MonitoringModeReporting && (len(m.queue) > 0 || len(m.eventQueue) > 0)
+}
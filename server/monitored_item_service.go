@@ -37,6 +37,9 @@ func (s *MonitoredItemService) DeleteMonitoredItem(id uint32) {
 		// id does not exist.
 		return
 	}
+	if item != nil {
+		item.stopSampling()
+	}
 
 	if item == nil || item.Req == nil || item.Req.ItemToMonitor == nil || item.Req.ItemToMonitor.NodeID == nil {
 		return
@@ -97,41 +100,99 @@ func (s *MonitoredItemService) DeleteSub(id uint32) {
 	}
 }
 
+// ChangeNotification samples the monitored items of a node right away so
+// that a written value is reported without waiting for the next sample.
 func (s *MonitoredItemService) ChangeNotification(n *ua.NodeID) {
-
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
-	items, ok := s.Nodes[n.String()]
 
-	if !ok {
-		// this node isn't monitored - don't have to do anything.
-		return
+	for _, item := range s.Nodes[n.String()] {
+		if item == nil {
+			continue
+		}
+		item.report(item.read(), false)
 	}
+}
 
-	ns, err := s.SubService.srv.Namespace(int(n.Namespace()))
+// InitialValues queues the current values of the monitored items of a
+// subscription, e.g. after the subscription has been transferred to
+// another session.
+func (s *MonitoredItemService) InitialValues(subID uint32) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
 
-	for i := range items {
-		item := items[i]
+	for _, item := range s.Subs[subID] {
 		if item == nil {
 			continue
 		}
-		val := new(ua.MonitoredItemNotification)
-		val.ClientHandle = item.Req.RequestedParameters.ClientHandle
-		if err != nil {
-			if s.SubService.srv.cfg.logger != nil {
-				s.SubService.srv.cfg.logger.Warn("error getting namespace %d: %v", n.Namespace(), err)
-			}
-			val.Value = &ua.DataValue{}
-			val.Value.Status = ua.StatusBad
-			val.Value.EncodingMask |= ua.DataValueStatusCode
-			item.Sub.NotifyChannel <- val
+		item.report(item.read(), true)
+	}
+}
+
+// reportEvent queues an event for the monitored items of an event
+// notifier.
+func (s *MonitoredItemService) reportEvent(notifier *ua.NodeID, ev *Event) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	for _, item := range s.Nodes[notifier.String()] {
+		if item == nil || !item.eventItem() {
+			continue
+		}
+		item.reportEvent(ev)
+	}
+}
+
+// notifications removes the queued values and events of the reporting
+// monitored items of a subscription and returns them.
+func (s *MonitoredItemService) notifications(subID uint32) ([]*ua.MonitoredItemNotification, []*ua.EventFieldList) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	var list []*ua.MonitoredItemNotification
+	var events []*ua.EventFieldList
+	for _, item := range s.Subs[subID] {
+		if item == nil {
 			continue
 		}
-		dv := ns.Attribute(n, item.Req.ItemToMonitor.AttributeID)
-		val.Value = dv
-		item.Sub.NotifyChannel <- val
+		q, eq := item.notifications()
+		list = append(list, q...)
+		events = append(events, eq...)
 	}
+	return list, events
+}
 
+// hasNotifications reports whether a monitored item of a subscription has
+// values to report.
+func (s *MonitoredItemService) hasNotifications(subID uint32) bool {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	for _, item := range s.Subs[subID] {
+		if item != nil && item.hasNotifications() {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the data change filter or the event filter for the
+// filter of a monitored item together with the filter result. Items which
+// monitor the EventNotifier attribute need an event filter.
+func (s *MonitoredItemService) filter(item *ua.ReadValueID, eo *ua.ExtensionObject) (*dataChangeFilter, *eventFilter, *ua.ExtensionObject, ua.StatusCode) {
+	srv := s.SubService.srv
+	if item.AttributeID != ua.AttributeIDEventNotifier {
+		filter, status := srv.newDataChangeFilter(item, eo)
+		return filter, nil, ua.NewExtensionObject(nil), status
+	}
+
+	// only event notifiers have events.
+	notifier, _ := filterInt(srv.attributeValue(item.NodeID, ua.AttributeIDEventNotifier))
+	if notifier&int64(ua.EventNotifierTypeSubscribeToEvents) == 0 {
+		return nil, nil, ua.NewExtensionObject(nil), ua.StatusBadNotSupported
+	}
+	events, res, status := srv.newEventFilter(eo)
+	return nil, events, ua.NewExtensionObject(res), status
 }
 
 func (s *MonitoredItemService) NextID() uint32 {
@@ -142,15 +203,6 @@ func (s *MonitoredItemService) NextID() uint32 {
 	return i
 }
 
-type MonitoredItem struct {
-	ID  uint32
-	Sub *Subscription
-	Req *ua.MonitoredItemCreateRequest
-
-	//TODO: use this
-	Mode ua.MonitoringMode
-}
-
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.12.2
 func (s *MonitoredItemService) CreateMonitoredItems(sc *uasc.SecureChannel, r ua.Request, reqID uint32) (ua.Response, error) {
 	if s.SubService.srv.cfg.logger != nil {
@@ -161,8 +213,6 @@ func (s *MonitoredItemService) CreateMonitoredItems(sc *uasc.SecureChannel, r ua
 	if err != nil {
 		return nil, err
 	}
-	s.Mu.Lock()
-	defer s.Mu.Unlock()
 
 	count := len(req.ItemsToCreate)
 
@@ -172,6 +222,8 @@ func (s *MonitoredItemService) CreateMonitoredItems(sc *uasc.SecureChannel, r ua
 	if s.SubService.srv.cfg.logger != nil {
 		s.SubService.srv.cfg.logger.Debug("Creating monitored items for sub #%d", subID)
 	}
+	// the subscription service locks the monitored item service when it
+	// deletes a subscription so the lock order must be the same here.
 	s.SubService.Mu.Lock()
 	sub, ok := s.SubService.Subs[subID]
 	s.SubService.Mu.Unlock()
@@ -179,33 +231,53 @@ func (s *MonitoredItemService) CreateMonitoredItems(sc *uasc.SecureChannel, r ua
 		return nil, errors.New("sub doesn't exist")
 	}
 
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
 	sess := s.SubService.srv.Session(req.RequestHeader)
-	if sub.Session.AuthTokenID.String() != sess.AuthTokenID.String() {
+	if sub.session().AuthTokenID.String() != sess.AuthTokenID.String() {
 		return nil, errors.New("not your subscription, bro")
 	}
 
 	for i := range req.ItemsToCreate {
 		itemreq := req.ItemsToCreate[i]
+		// monitor the registered node and not its alias.
+		itemreq.ItemToMonitor.NodeID = sess.resolveNodeID(itemreq.ItemToMonitor.NodeID)
 		nodeid := itemreq.ItemToMonitor.NodeID
-		item := MonitoredItem{
-			ID:  s.NextID(),
-			Sub: sub,
-			Req: itemreq,
+		filter, events, filterResult, status := s.filter(itemreq.ItemToMonitor, itemreq.RequestedParameters.Filter)
+		if status != ua.StatusOK {
+			res[i] = &ua.MonitoredItemCreateResult{
+				StatusCode:   status,
+				FilterResult: filterResult,
+			}
+			continue
+		}
+		perm := readPermission(itemreq.ItemToMonitor.AttributeID)
+		if events != nil {
+			perm = ua.PermissionTypeReceiveEvents
 		}
+		if status := s.SubService.srv.allowed(sess, nodeid, perm); status != ua.StatusOK {
+			res[i] = &ua.MonitoredItemCreateResult{
+				StatusCode:   status,
+				FilterResult: ua.NewExtensionObject(nil),
+			}
+			continue
+		}
+		item := newMonitoredItem(s.SubService.srv, s.NextID(), sub, itemreq, filter, events)
 
 		// book keeping of the new item
-		s.Items[item.ID] = &item
+		s.Items[item.ID] = item
 		list, ok := s.Nodes[item.Req.ItemToMonitor.NodeID.String()]
 		if !ok {
 			list = make([]*MonitoredItem, 0, 1)
 		}
-		s.Nodes[item.Req.ItemToMonitor.NodeID.String()] = append(list, &item)
+		s.Nodes[item.Req.ItemToMonitor.NodeID.String()] = append(list, item)
 
 		list, ok = s.Subs[item.Sub.ID]
 		if !ok {
 			list = make([]*MonitoredItem, 0, 1)
 		}
-		s.Subs[item.Sub.ID] = append(list, &item)
+		s.Subs[item.Sub.ID] = append(list, item)
 
 		if s.SubService.srv.cfg.logger != nil {
 			s.SubService.srv.cfg.logger.Debug("Adding monitored item '%s' to sub #%d as %d->%d",
@@ -214,18 +286,16 @@ func (s *MonitoredItemService) CreateMonitoredItems(sc *uasc.SecureChannel, r ua
 				item.ID,
 				itemreq.RequestedParameters.ClientHandle)
 		}
+		interval, queueSize := item.revised()
 		res[i] = &ua.MonitoredItemCreateResult{
 			StatusCode:              ua.StatusOK,
 			MonitoredItemID:         item.ID,
-			RevisedSamplingInterval: sub.RevisedPublishingInterval,
-			RevisedQueueSize:        1,
-			FilterResult:            ua.NewExtensionObject(nil),
+			RevisedSamplingInterval: interval,
+			RevisedQueueSize:        queueSize,
+			FilterResult:            filterResult,
 		}
-		// do an initial update for the nodeids in the background.
-		// These lock the mutex so we can't do them inline here.
-		// This will cause them to happen once we unlock.
-		go s.ChangeNotification(nodeid)
-
+		// the first sample is queued right away.
+		item.start()
 	}
 
 	resp := &ua.CreateMonitoredItemsResponse{
@@ -255,7 +325,60 @@ func (s *MonitoredItemService) ModifyMonitoredItems(sc *uasc.SecureChannel, r ua
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.SubService.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	fault := func(status ua.StatusCode) (ua.Response, error) {
+		return &ua.ModifyMonitoredItemsResponse{ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, status)}, nil
+	}
+	if req.TimestampsToReturn > ua.TimestampsToReturnNeither {
+		return fault(ua.StatusBadTimestampsToReturnInvalid)
+	}
+	if len(req.ItemsToModify) == 0 {
+		return fault(ua.StatusBadNothingToDo)
+	}
+
+	// the subscription service locks the monitored item service when it
+	// deletes a subscription so the lock order must be the same here.
+	s.SubService.Mu.Lock()
+	sub, ok := s.SubService.Subs[req.SubscriptionID]
+	s.SubService.Mu.Unlock()
+	if !ok || sub.session().AuthTokenID.String() != sess.AuthTokenID.String() {
+		return fault(ua.StatusBadSubscriptionIDInvalid)
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	results := make([]*ua.MonitoredItemModifyResult, len(req.ItemsToModify))
+	for i, itemreq := range req.ItemsToModify {
+		results[i] = &ua.MonitoredItemModifyResult{FilterResult: ua.NewExtensionObject(nil)}
+
+		item, ok := s.Items[itemreq.MonitoredItemID]
+		if !ok || item == nil || item.Sub.ID != req.SubscriptionID {
+			results[i].StatusCode = ua.StatusBadMonitoredItemIDInvalid
+			continue
+		}
+		filter, events, filterResult, status := s.filter(item.Req.ItemToMonitor, itemreq.RequestedParameters.Filter)
+		results[i].FilterResult = filterResult
+		if status != ua.StatusOK {
+			results[i].StatusCode = status
+			continue
+		}
+
+		item.modify(itemreq.RequestedParameters, filter, events)
+		results[i].StatusCode = ua.StatusOK
+		results[i].RevisedSamplingInterval, results[i].RevisedQueueSize = item.revised()
+	}
+
+	return &ua.ModifyMonitoredItemsResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.12.4
@@ -271,23 +394,30 @@ func (s *MonitoredItemService) SetMonitoringMode(sc *uasc.SecureChannel, r ua.Re
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
 
-	results := make([]ua.StatusCode, len(req.MonitoredItemIDs))
-
 	sess := s.SubService.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	switch req.MonitoringMode {
+	case ua.MonitoringModeDisabled, ua.MonitoringModeSampling, ua.MonitoringModeReporting:
+	default:
+		return &ua.SetMonitoringModeResponse{ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadMonitoringModeInvalid)}, nil
+	}
 
+	results := make([]ua.StatusCode, len(req.MonitoredItemIDs))
 	for i := range req.MonitoredItemIDs {
 		id := req.MonitoredItemIDs[i]
 		item, ok := s.Items[id]
-
-		if item.Sub.Session.AuthTokenID.String() != sess.AuthTokenID.String() {
-			results[i] = ua.StatusBadSessionIDInvalid
-		}
-
-		if !ok {
+		if !ok || item == nil || item.Sub.ID != req.SubscriptionID {
 			results[i] = ua.StatusBadMonitoredItemIDInvalid
 			continue
 		}
-		item.Mode = req.MonitoringMode
+		if item.Sub.session().AuthTokenID.String() != sess.AuthTokenID.String() {
+			results[i] = ua.StatusBadSessionIDInvalid
+			continue
+		}
+		item.setMode(req.MonitoringMode)
 		results[i] = ua.StatusOK
 	}
 
@@ -334,17 +464,21 @@ func (s *MonitoredItemService) DeleteMonitoredItems(sc *uasc.SecureChannel, r ua
 	defer s.Mu.Unlock()
 
 	sess := s.SubService.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
 
 	results := make([]ua.StatusCode, len(req.MonitoredItemIDs))
 	for i := range req.MonitoredItemIDs {
 		id := req.MonitoredItemIDs[i]
 		item, ok := s.Items[id]
-		if !ok {
+		if !ok || item == nil || item.Sub.ID != req.SubscriptionID {
 			results[i] = ua.StatusBadMonitoredItemIDInvalid
+			continue
 		}
-
-		if item.Sub.Session.AuthTokenID.String() != sess.AuthTokenID.String() {
+		if item.Sub.session().AuthTokenID.String() != sess.AuthTokenID.String() {
 			results[i] = ua.StatusBadSessionIDInvalid
+			continue
 		}
 
 		// this function gets the lock so we need to do it in the background so it can happen after our lock is released.
@@ -264,7 +264,7 @@ func (ns *MapNamespace) Attribute(n *ua.NodeID, a ua.AttributeID) *ua.DataValue
 		case int:
 			// we can't use an int because it is of unspecified length.  I'm going to use int64 so that we don't
 			// have to worry about cutting data off.
-			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 6))
+			dv.Value, err = ua.NewVariant(ua.NewNumericNodeID(0, 8))
 			if err != nil {
 				if ns.srv.cfg.logger != nil {
 					ns.srv.cfg.logger.Warn("problem creating variant: %v", err)
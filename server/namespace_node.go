@@ -1,6 +1,8 @@
 package server
 
 import (
+	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -79,6 +81,38 @@ func NewNameSpace(name string) *NodeNameSpace {
 	return &NodeNameSpace{name: name, m: map[string]*Node{}}
 }
 
+// DeleteNode removes the node with the given id from the namespace.
+func (as *NodeNameSpace) DeleteNode(id *ua.NodeID) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	k := id.String()
+	if _, ok := as.m[k]; !ok {
+		return
+	}
+	delete(as.m, k)
+	as.nodes = slices.DeleteFunc(as.nodes, func(n *Node) bool { return n.ID().String() == k })
+}
+
+// allNodes returns the nodes of the namespace in the order they were
+// added.
+func (as *NodeNameSpace) allNodes() []*Node {
+	as.mu.RLock()
+	defer as.mu.RUnlock()
+
+	nodes := make([]*Node, 0, len(as.m))
+	seen := make(map[string]bool, len(as.m))
+	for _, n := range as.nodes {
+		// replaced nodes are still in the list.
+		k := n.ID().String()
+		if as.m[k] == n && !seen[k] {
+			seen[k] = true
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
 func (as *NodeNameSpace) AddNode(n *Node) *Node {
 	as.mu.Lock()
 	defer as.mu.Unlock()
@@ -101,6 +135,54 @@ func (as *NodeNameSpace) AddNode(n *Node) *Node {
 	return n
 }
 
+// InsertNode implements NodeManager.
+func (as *NodeNameSpace) InsertNode(n *Node) ua.StatusCode {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	k := n.ID().String()
+	if _, ok := as.m[k]; ok {
+		return ua.StatusBadNodeIDExists
+	}
+	n.ns = as
+	as.nodes = append(as.nodes, n)
+	as.m[k] = n
+	return ua.StatusOK
+}
+
+// RemoveNode implements NodeManager.
+func (as *NodeNameSpace) RemoveNode(nodeID *ua.NodeID) ua.StatusCode {
+	if as.Node(nodeID) == nil {
+		return ua.StatusBadNodeIDUnknown
+	}
+	as.DeleteNode(nodeID)
+	return ua.StatusOK
+}
+
+// InsertReference implements NodeManager.
+func (as *NodeNameSpace) InsertReference(sourceID *ua.NodeID, ref *ua.ReferenceDescription) ua.StatusCode {
+	n := as.Node(sourceID)
+	if n == nil {
+		return ua.StatusBadSourceNodeIDInvalid
+	}
+	if !n.insertReference(ref) {
+		return ua.StatusBadDuplicateReferenceNotAllowed
+	}
+	return ua.StatusOK
+}
+
+// RemoveReference implements NodeManager.
+func (as *NodeNameSpace) RemoveReference(sourceID *ua.NodeID, ref *ua.ReferenceDescription) ua.StatusCode {
+	n := as.Node(sourceID)
+	if n == nil {
+		return ua.StatusBadSourceNodeIDInvalid
+	}
+	if !n.removeReference(ref) {
+		return ua.StatusBadNotFound
+	}
+	return ua.StatusOK
+}
+
 func (as *NodeNameSpace) AddNewVariableNode(name string, value any) *Node {
 	n := NewVariableNode(ua.NewNumericNodeID(as.id, as.GetNextNodeID()), name, value)
 	as.AddNode(n)
@@ -112,6 +194,37 @@ func (as *NodeNameSpace) AddNewVariableStringNode(name string, value any) *Node
 	return n
 }
 
+// AddNewMethodNode adds a method node with a numeric node id which executes
+// the Go function fn together with its InputArguments and OutputArguments
+// properties. See Method for the supported function signatures.
+//
+// Add a HasComponent reference from the object the method belongs to
+// or clients won't be able to find and call it.
+func (as *NodeNameSpace) AddNewMethodNode(name string, fn any, opts ...MethodOption) (*Node, error) {
+	return as.addMethodNode(ua.NewNumericNodeID(as.id, as.GetNextNodeID()), name, fn, opts...)
+}
+
+// AddNewMethodStringNode is like AddNewMethodNode but uses the name as
+// string node id of the method.
+func (as *NodeNameSpace) AddNewMethodStringNode(name string, fn any, opts ...MethodOption) (*Node, error) {
+	return as.addMethodNode(ua.NewStringNodeID(as.id, name), name, fn, opts...)
+}
+
+func (as *NodeNameSpace) addMethodNode(nid *ua.NodeID, name string, fn any, opts ...MethodOption) (*Node, error) {
+	// the server executes the methods but namespaces created with
+	// NewNameSpace have none.
+	if as.srv == nil {
+		return nil, fmt.Errorf("namespace %s has no server", as.name)
+	}
+	n := NewMethodNode(nid, name)
+	as.AddNode(n)
+	if err := as.srv.BindMethod(nid, fn, opts...); err != nil {
+		as.DeleteNode(nid)
+		return nil, err
+	}
+	return n, nil
+}
+
 func (as *NodeNameSpace) Attribute(id *ua.NodeID, attr ua.AttributeID) *ua.DataValue {
 	n := as.Node(id)
 	if n == nil {
@@ -137,10 +250,14 @@ func (as *NodeNameSpace) Attribute(id *ua.NodeID, attr ua.AttributeID) *ua.DataV
 	case ua.AttributeIDNodeID:
 		a = &AttrValue{Value: DataValueFromValue(id)}
 	case ua.AttributeIDEventNotifier:
-		// TODO: this is a hack to force the EventNotifier to false for everything.
-		// If at some point someone or something needs to use this, this will have to go away and be
-		// fixed properly.
-		a = &AttrValue{Value: DataValueFromValue(byte(0))}
+		// EventNotifier is a byte but some nodes store it as another
+		// integer type and nodes without event notifier have none.
+		var notifier byte
+		if v, err := n.Attribute(attr); err == nil && v.Value != nil && v.Value.Value != nil {
+			x, _ := filterInt(v.Value.Value.Value())
+			notifier = byte(x)
+		}
+		a = &AttrValue{Value: DataValueFromValue(notifier)}
 	case ua.AttributeIDNodeClass:
 		a, err = n.Attribute(attr)
 		if err != nil {
@@ -194,9 +311,6 @@ func (as *NodeNameSpace) Root() *Node {
 }
 
 func (ns *NodeNameSpace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
-	ns.mu.RLock()
-	defer ns.mu.RUnlock()
-
 	if ns.srv.cfg.logger != nil {
 		ns.srv.cfg.logger.Debug("BrowseRequest: id=%s mask=%08b\n", bd.NodeID, bd.ResultMask)
 	}
@@ -206,10 +320,10 @@ func (ns *NodeNameSpace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 		return &ua.BrowseResult{StatusCode: ua.StatusBadNodeIDUnknown}
 	}
 
-	refs := make([]*ua.ReferenceDescription, 0, len(n.refs))
+	nrefs := n.references()
+	refs := make([]*ua.ReferenceDescription, 0, len(nrefs))
 
-	for i := range n.refs {
-		r := n.refs[i]
+	for _, r := range nrefs {
 		// we can't have nils in these or the encoder will fail.
 		if r.NodeID == nil || r.BrowseName == nil || r.DisplayName == nil || r.TypeDefinition == nil {
 			continue
@@ -220,8 +334,6 @@ func (ns *NodeNameSpace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 			continue
 		}
 
-		td := ns.srv.Node(r.NodeID.NodeID)
-
 		rf := &ua.ReferenceDescription{
 			ReferenceTypeID: r.ReferenceTypeID,
 			IsForward:       r.IsForward,
@@ -229,7 +341,11 @@ func (ns *NodeNameSpace) Browse(bd *ua.BrowseDescription) *ua.BrowseResult {
 			BrowseName:      r.BrowseName,
 			DisplayName:     r.DisplayName,
 			NodeClass:       r.NodeClass,
-			TypeDefinition:  td.DataType(),
+			TypeDefinition:  r.TypeDefinition,
+		}
+		// nodes of other servers can't be looked up.
+		if r.NodeID.ServerIndex == 0 {
+			rf.TypeDefinition = ns.srv.Node(r.NodeID.NodeID).DataType()
 		}
 
 		if rf.ReferenceTypeID.IntID() == id.HasTypeDefinition && rf.IsForward {
@@ -260,7 +376,8 @@ func (as *NodeNameSpace) SetAttribute(id *ua.NodeID, attr ua.AttributeID, val *u
 		return ua.StatusBadNodeIDUnknown
 	}
 
-	if !n.Access(ua.AccessLevelTypeCurrentWrite) {
+	// the access level only applies to the value.
+	if attr == ua.AttributeIDValue && !n.Access(ua.AccessLevelTypeCurrentWrite) {
 		return ua.StatusBadUserAccessDenied
 	}
 
@@ -47,3 +47,44 @@ type NameSpace interface {
 	Attribute(*ua.NodeID, ua.AttributeID) *ua.DataValue
 	SetAttribute(*ua.NodeID, ua.AttributeID, *ua.DataValue) ua.StatusCode
 }
+
+// NodeManager is implemented by namespaces which allow clients to add and
+// delete nodes and references with the Node Management services. The
+// services must also be enabled for the namespace with the NodeManagement
+// option.
+//
+// The server validates the requests, e.g. that the parent node, the
+// reference type and the type definition exist, before it calls the hooks
+// of the namespace which owns the affected node. A namespace accepts a
+// change by applying it and returning StatusOK or rejects it by returning
+// a bad status code, e.g. StatusBadUserAccessDenied, which is returned to
+// the client.
+type NodeManager interface {
+	// InsertNode adds a node which was requested by a client. The node
+	// already has its reference to the parent and its type definition.
+	InsertNode(n *Node) ua.StatusCode
+
+	// RemoveNode deletes a node.
+	RemoveNode(nodeID *ua.NodeID) ua.StatusCode
+
+	// InsertReference adds a reference to the source node.
+	InsertReference(sourceID *ua.NodeID, ref *ua.ReferenceDescription) ua.StatusCode
+
+	// RemoveReference deletes the reference of the source node with the
+	// same reference type, direction and target node as ref.
+	RemoveReference(sourceID *ua.NodeID, ref *ua.ReferenceDescription) ua.StatusCode
+}
+
+// NodeRegistrar is implemented by namespaces which want to prepare for the
+// repeated access to nodes which clients register with the RegisterNodes
+// service, e.g. by resolving expensive lookups in advance.
+//
+// The server hands out aliases for registered nodes and resolves them
+// before it calls the namespace, so the namespace only sees the node ids of
+// the registered nodes. Every call of RegisterNode is followed by a call of
+// UnregisterNode for the same node id when the client unregisters the node
+// or closes its session.
+type NodeRegistrar interface {
+	RegisterNode(nodeID *ua.NodeID)
+	UnregisterNode(nodeID *ua.NodeID)
+}
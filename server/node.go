@@ -4,6 +4,7 @@ import (
 	"log"
 	"maps"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/gopcua/opcua/id"
@@ -67,14 +68,18 @@ func DataValueFromValue(val any) *ua.DataValue {
 type Node struct {
 	id   *ua.NodeID
 	attr Attributes
-	refs References
 	val  ValueFunc
 
 	ns NameSpace
+
+	// mu guards refs. The slice is never modified in place so that
+	// readers can use it after releasing the lock.
+	mu   sync.RWMutex
+	refs References
 }
 
 func NewNode(id *ua.NodeID, attr Attributes, refs References, val ValueFunc) *Node {
-	n := &Node{id, attr, refs, val, nil}
+	n := &Node{id: id, attr: attr, refs: refs, val: val}
 	n.sanitize()
 	return n
 }
@@ -149,6 +154,50 @@ func NewVariableNode(nodeID *ua.NodeID, name string, value any) *Node {
 	return n
 }
 
+// NewMethodNode creates a new method node. The Go function which implements
+// the method has to be bound to the node with Server.BindMethod.
+func NewMethodNode(nodeID *ua.NodeID, name string) *Node {
+	return NewNode(
+		nodeID,
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDNodeClass:      DataValueFromValue(uint32(ua.NodeClassMethod)),
+			ua.AttributeIDBrowseName:     DataValueFromValue(attrs.BrowseName(name)),
+			ua.AttributeIDDisplayName:    DataValueFromValue(attrs.DisplayName(name, name)),
+			ua.AttributeIDExecutable:     DataValueFromValue(true),
+			ua.AttributeIDUserExecutable: DataValueFromValue(true),
+		},
+		[]*ua.ReferenceDescription{},
+		nil,
+	)
+}
+
+// NewArgumentsNode creates an InputArguments or OutputArguments property
+// node for a method.
+func NewArgumentsNode(nodeID *ua.NodeID, name string, args []*ua.Argument) *Node {
+	return NewNode(
+		nodeID,
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDNodeClass:       DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDBrowseName:      DataValueFromValue(attrs.BrowseName(name)),
+			ua.AttributeIDDisplayName:     DataValueFromValue(attrs.DisplayName(name, name)),
+			ua.AttributeIDDataType:        DataValueFromValue(ua.NewNumericExpandedNodeID(0, id.Argument)),
+			ua.AttributeIDValueRank:       DataValueFromValue(int32(1)),
+			ua.AttributeIDArrayDimensions: DataValueFromValue([]uint32{0}),
+			ua.AttributeIDAccessLevel:     DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+		},
+		[]*ua.ReferenceDescription{{
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HasTypeDefinition),
+			IsForward:       true,
+			NodeID:          ua.NewNumericExpandedNodeID(0, id.PropertyType),
+			BrowseName:      attrs.BrowseName("PropertyType"),
+			DisplayName:     attrs.DisplayName("PropertyType", ""),
+			NodeClass:       ua.NodeClassVariableType,
+			TypeDefinition:  ua.NewTwoByteExpandedNodeID(0),
+		}},
+		func() *ua.DataValue { return argumentsValue(args) },
+	)
+}
+
 func (n *Node) sanitize() {
 	if n.attr == nil {
 		n.attr = Attributes{}
@@ -221,10 +270,13 @@ func (n *Node) SetAttribute(id ua.AttributeID, val *ua.DataValue) error {
 
 func (n *Node) BrowseName() *ua.QualifiedName {
 	v := n.attr[ua.AttributeIDBrowseName]
-	if v == nil || v.Value.Value() == nil {
+	if v == nil {
 		return &ua.QualifiedName{}
 	}
-	return v.Value.Value().(*ua.QualifiedName)
+	if qn, ok := v.Value.Value().(*ua.QualifiedName); ok && qn != nil {
+		return qn
+	}
+	return &ua.QualifiedName{}
 }
 
 func (n *Node) SetBrowseName(s string) {
@@ -233,10 +285,13 @@ func (n *Node) SetBrowseName(s string) {
 
 func (n *Node) DisplayName() *ua.LocalizedText {
 	v := n.attr[ua.AttributeIDDisplayName]
-	if v == nil || v.Value.Value() == nil {
+	if v == nil {
+		return &ua.LocalizedText{}
+	}
+	val, ok := v.Value.Value().(*ua.LocalizedText)
+	if !ok || val == nil {
 		return &ua.LocalizedText{}
 	}
-	val := v.Value.Value().(*ua.LocalizedText)
 	val.UpdateMask()
 	return val
 }
@@ -249,10 +304,13 @@ func (n *Node) SetDisplayName(text, locale string) {
 
 func (n *Node) Description() *ua.LocalizedText {
 	v := n.attr[ua.AttributeIDDescription]
-	if v == nil || v.Value.Value() == nil {
+	if v == nil {
 		return &ua.LocalizedText{}
 	}
-	return v.Value.Value().(*ua.LocalizedText)
+	if lt, ok := v.Value.Value().(*ua.LocalizedText); ok && lt != nil {
+		return lt
+	}
+	return &ua.LocalizedText{}
 }
 
 func (n *Node) SetDescription(text, locale string) {
@@ -267,8 +325,7 @@ func (n *Node) DataType() *ua.ExpandedNodeID {
 	v := n.attr[ua.AttributeIDDataType]
 	if v == nil || v.Value.Value() == nil {
 		// if we have a type definition, return that?
-		for i := range n.refs {
-			r := n.refs[i]
+		for _, r := range n.references() {
 			if r.ReferenceTypeID == nil {
 				log.Printf("reftypeid was nil!")
 			}
@@ -278,7 +335,100 @@ func (n *Node) DataType() *ua.ExpandedNodeID {
 		}
 		return ua.NewTwoByteExpandedNodeID(0)
 	}
-	return v.Value.Value().(*ua.ExpandedNodeID)
+	// written data types are node ids.
+	switch dt := v.Value.Value().(type) {
+	case *ua.ExpandedNodeID:
+		return dt
+	case *ua.NodeID:
+		return ua.NewExpandedNodeID(dt, "", 0)
+	}
+	return ua.NewTwoByteExpandedNodeID(0)
+}
+
+// typeDefinition returns the id of the type definition of an object or a
+// variable or nil if the node has no HasTypeDefinition reference.
+func (n *Node) typeDefinition() *ua.NodeID {
+	for _, r := range n.references() {
+		if r.IsForward && r.NodeID != nil && r.ReferenceTypeID.IntID() == id.HasTypeDefinition && r.ReferenceTypeID.Namespace() == 0 {
+			return r.NodeID.NodeID
+		}
+	}
+	return nil
+}
+
+// Executable returns the value of the Executable attribute of a method node.
+// Nodes without the attribute are considered executable.
+func (n *Node) Executable() bool {
+	v := n.attr[ua.AttributeIDExecutable]
+	if v == nil || v.Value == nil {
+		return true
+	}
+	b, ok := v.Value.Value().(bool)
+	return !ok || b
+}
+
+// SetRolePermissions sets the RolePermissions attribute of the node which
+// grants the permissions for the node to the roles of the users.
+func (n *Node) SetRolePermissions(perms ...*ua.RolePermissionType) {
+	eos := make([]*ua.ExtensionObject, len(perms))
+	for i, p := range perms {
+		eos[i] = ua.NewExtensionObject(p)
+	}
+	n.attr[ua.AttributeIDRolePermissions] = DataValueFromValue(eos)
+}
+
+// RolePermissions returns the value of the RolePermissions attribute of the
+// node. It returns nil if the node has no role permissions.
+func (n *Node) RolePermissions() []*ua.RolePermissionType {
+	v := n.attr[ua.AttributeIDRolePermissions]
+	if v == nil || v.Value == nil {
+		return nil
+	}
+	eos, ok := v.Value.Value().([]*ua.ExtensionObject)
+	if !ok {
+		return nil
+	}
+	perms := []*ua.RolePermissionType{}
+	for _, eo := range eos {
+		if p, ok := eo.Value.(*ua.RolePermissionType); ok {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// SetHistorizing sets the Historizing attribute of a variable node and
+// updates the HistoryRead bit of its access levels accordingly. If the
+// server has a history store every change of the value of a historizing
+// variable is recorded.
+func (n *Node) SetHistorizing(historizing bool) {
+	n.attr[ua.AttributeIDHistorizing] = DataValueFromValue(historizing)
+	for _, id := range []ua.AttributeID{ua.AttributeIDAccessLevel, ua.AttributeIDUserAccessLevel} {
+		v := n.attr[id]
+		if v == nil || v.Value == nil {
+			continue
+		}
+		access, ok := v.Value.Value().(uint8)
+		if !ok {
+			continue
+		}
+		if historizing {
+			access |= uint8(ua.AccessLevelTypeHistoryRead)
+		} else {
+			access &^= uint8(ua.AccessLevelTypeHistoryRead)
+		}
+		n.attr[id] = DataValueFromValue(access)
+	}
+}
+
+// Historizing returns the value of the Historizing attribute of a variable node.
+func (n *Node) Historizing() bool {
+	v := n.attr[ua.AttributeIDHistorizing]
+	if v == nil || v.Value == nil {
+		return false
+	}
+	b, _ := v.Value.Value().(bool)
+	return b
 }
 
 func (n *Node) SetNodeClass(nc ua.NodeClass) {
@@ -306,13 +456,13 @@ func (n *Node) AddObject(o *Node) *Node {
 	nn := &Node{
 		id:   o.id,
 		attr: maps.Clone(o.attr),
-		refs: slices.Clone(o.refs),
+		refs: slices.Clone(o.references()),
 	}
 	if n.attr == nil {
 		n.attr = Attributes{}
 	}
 	nn.SetNodeClass(ua.NodeClassObject)
-	n.refs = append(n.refs, refs.Organizes(nn.id, nn.BrowseName().Name, nn.DisplayName().Text, nn.DataType()))
+	n.appendReferences(refs.Organizes(nn.id, nn.BrowseName().Name, nn.DisplayName().Text, nn.DataType()))
 	return n.ns.AddNode(nn)
 }
 
@@ -320,14 +470,14 @@ func (n *Node) AddVariable(o *Node) *Node {
 	nn := &Node{
 		id:   o.id,
 		attr: maps.Clone(o.attr),
-		refs: slices.Clone(o.refs),
+		refs: slices.Clone(o.references()),
 		val:  o.val,
 	}
 	if n.attr == nil {
 		n.attr = Attributes{}
 	}
 	nn.SetNodeClass(ua.NodeClassVariable)
-	n.refs = append(n.refs, refs.Organizes(nn.id, nn.BrowseName().Name, nn.DisplayName().Text, nn.DataType()))
+	n.appendReferences(refs.Organizes(nn.id, nn.BrowseName().Name, nn.DisplayName().Text, nn.DataType()))
 	return nn
 }
 
@@ -351,17 +501,57 @@ func (n *Node) AddRef(o *Node, rt RefType, forward bool) {
 		NodeClass:       o.NodeClass(),
 		TypeDefinition:  o.DataType(),
 	}
-	n.refs = append(n.refs, &ref)
+	n.appendReferences(&ref)
+}
+
+// references returns the references of the node. The returned slice must
+// not be modified.
+func (n *Node) references() References {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.refs
+}
+
+// appendReferences adds references to the node.
+func (n *Node) appendReferences(r ...*ua.ReferenceDescription) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.refs = append(n.refs, r...)
+}
+
+// insertReference adds a reference to the node and returns false if the
+// node already has the same reference.
+func (n *Node) insertReference(ref *ua.ReferenceDescription) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if slices.ContainsFunc(n.refs, func(r *ua.ReferenceDescription) bool { return sameReference(r, ref) }) {
+		return false
+	}
+	n.refs = append(n.refs, ref)
+	return true
+}
+
+// removeReference deletes the reference of the node with the same
+// reference type, direction and target node as ref and returns false if
+// the node has no such reference.
+func (n *Node) removeReference(ref *ua.ReferenceDescription) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	i := slices.IndexFunc(n.refs, func(r *ua.ReferenceDescription) bool { return sameReference(r, ref) })
+	if i < 0 {
+		return false
+	}
+	n.refs = slices.Delete(slices.Clone(n.refs), i, i+1)
+	return true
 }
 
 // Access returns true if the node has the access level requested.
 // It checks both the UserAccessLevel and AccessLevel attributes.
 // If neither are present, it assumes global access and returns true.
 //
-// I'm not sure what the best way to implement "user" specific access levels
-// is presently.  Will need functioning user authentication first, and then a way to
-// pass it into the nodes user access attribute so it can be checked properly.
-func (n Node) Access(flag ua.AccessLevelType) bool {
+// The permissions of the roles of the users are checked by the services
+// of the server.
+func (n *Node) Access(flag ua.AccessLevelType) bool {
 
 	access, err := n.Attribute(ua.AttributeIDUserAccessLevel)
 	if err == nil { // if we have a user access level, we need to check it.
@@ -389,3 +579,154 @@ func (n Node) Access(flag ua.AccessLevelType) bool {
 	return true
 
 }
+
+// nodeFromAttributes creates a node from the NodeAttributes of an AddNodes
+// request. Attributes which are not specified get their default values.
+func nodeFromAttributes(nodeID *ua.NodeID, nc ua.NodeClass, browseName *ua.QualifiedName, eo *ua.ExtensionObject) (*Node, ua.StatusCode) {
+	n := NewNode(nodeID, Attributes{
+		ua.AttributeIDNodeClass:  DataValueFromValue(uint32(nc)),
+		ua.AttributeIDBrowseName: DataValueFromValue(browseName),
+	}, References{}, nil)
+
+	var v any
+	if eo != nil {
+		v = eo.Value
+	}
+	var specified uint32
+	set := func(mask ua.NodeAttributesMask, id ua.AttributeID, val any) {
+		if specified&uint32(mask) != 0 {
+			n.attr[id] = DataValueFromValue(val)
+		}
+	}
+	setCommon := func(displayName, description *ua.LocalizedText, writeMask, userWriteMask uint32) {
+		if displayName != nil {
+			set(ua.NodeAttributesMaskDisplayName, ua.AttributeIDDisplayName, displayName)
+		}
+		if description != nil {
+			set(ua.NodeAttributesMaskDescription, ua.AttributeIDDescription, description)
+		}
+		set(ua.NodeAttributesMaskWriteMask, ua.AttributeIDWriteMask, writeMask)
+		set(ua.NodeAttributesMaskUserWriteMask, ua.AttributeIDUserWriteMask, userWriteMask)
+	}
+	setValue := func(val *ua.Variant, dataType *ua.NodeID, valueRank int32, dims []uint32) {
+		if val == nil || specified&uint32(ua.NodeAttributesMaskValue) == 0 {
+			val = &ua.Variant{}
+		}
+		dv := DataValueFromValue(val)
+		n.val = func() *ua.DataValue { return dv }
+		if dataType == nil || specified&uint32(ua.NodeAttributesMaskDataType) == 0 {
+			dataType = ua.NewNumericNodeID(0, id.BaseDataType)
+		}
+		n.attr[ua.AttributeIDDataType] = DataValueFromValue(ua.NewExpandedNodeID(dataType, "", 0))
+		if specified&uint32(ua.NodeAttributesMaskValueRank) == 0 {
+			valueRank = -2 // any
+		}
+		n.attr[ua.AttributeIDValueRank] = DataValueFromValue(valueRank)
+		if dims != nil {
+			set(ua.NodeAttributesMaskArrayDimensions, ua.AttributeIDArrayDimensions, dims)
+		}
+	}
+
+	switch a := v.(type) {
+	case nil:
+		// all attributes get their default values
+	case *ua.ObjectAttributes:
+		if nc != ua.NodeClassObject {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		set(ua.NodeAttributesMaskEventNotifier, ua.AttributeIDEventNotifier, a.EventNotifier)
+	case *ua.VariableAttributes:
+		if nc != ua.NodeClassVariable {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		setValue(a.Value, a.DataType, a.ValueRank, a.ArrayDimensions)
+		access := byte(ua.AccessLevelTypeCurrentRead)
+		if specified&uint32(ua.NodeAttributesMaskAccessLevel) != 0 {
+			access = a.AccessLevel
+		}
+		userAccess := access
+		if specified&uint32(ua.NodeAttributesMaskUserAccessLevel) != 0 {
+			userAccess = a.UserAccessLevel
+		}
+		n.attr[ua.AttributeIDAccessLevel] = DataValueFromValue(access)
+		n.attr[ua.AttributeIDUserAccessLevel] = DataValueFromValue(userAccess)
+		set(ua.NodeAttributesMaskMinimumSamplingInterval, ua.AttributeIDMinimumSamplingInterval, a.MinimumSamplingInterval)
+		if specified&uint32(ua.NodeAttributesMaskHistorizing) != 0 {
+			n.SetHistorizing(a.Historizing)
+		}
+	case *ua.MethodAttributes:
+		if nc != ua.NodeClassMethod {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		set(ua.NodeAttributesMaskExecutable, ua.AttributeIDExecutable, a.Executable)
+		set(ua.NodeAttributesMaskUserExecutable, ua.AttributeIDUserExecutable, a.UserExecutable)
+	case *ua.ObjectTypeAttributes:
+		if nc != ua.NodeClassObjectType {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		set(ua.NodeAttributesMaskIsAbstract, ua.AttributeIDIsAbstract, a.IsAbstract)
+	case *ua.VariableTypeAttributes:
+		if nc != ua.NodeClassVariableType {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		setValue(a.Value, a.DataType, a.ValueRank, a.ArrayDimensions)
+		set(ua.NodeAttributesMaskIsAbstract, ua.AttributeIDIsAbstract, a.IsAbstract)
+	case *ua.ReferenceTypeAttributes:
+		if nc != ua.NodeClassReferenceType {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		set(ua.NodeAttributesMaskIsAbstract, ua.AttributeIDIsAbstract, a.IsAbstract)
+		set(ua.NodeAttributesMaskSymmetric, ua.AttributeIDSymmetric, a.Symmetric)
+		if a.InverseName != nil {
+			set(ua.NodeAttributesMaskInverseName, ua.AttributeIDInverseName, a.InverseName)
+		}
+	case *ua.DataTypeAttributes:
+		if nc != ua.NodeClassDataType {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		set(ua.NodeAttributesMaskIsAbstract, ua.AttributeIDIsAbstract, a.IsAbstract)
+	case *ua.ViewAttributes:
+		if nc != ua.NodeClassView {
+			return nil, ua.StatusBadNodeAttributesInvalid
+		}
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		set(ua.NodeAttributesMaskContainsNoLoops, ua.AttributeIDContainsNoLoops, a.ContainsNoLoops)
+		set(ua.NodeAttributesMaskEventNotifier, ua.AttributeIDEventNotifier, a.EventNotifier)
+	case *ua.GenericAttributes:
+		specified = a.SpecifiedAttributes
+		setCommon(a.DisplayName, a.Description, a.WriteMask, a.UserWriteMask)
+		for _, av := range a.AttributeValues {
+			switch av.AttributeID {
+			case ua.AttributeIDNodeID, ua.AttributeIDNodeClass, ua.AttributeIDBrowseName:
+				return nil, ua.StatusBadNodeAttributesInvalid
+			case ua.AttributeIDValue:
+				dv := DataValueFromValue(av.Value)
+				n.val = func() *ua.DataValue { return dv }
+			default:
+				n.attr[av.AttributeID] = DataValueFromValue(av.Value)
+			}
+		}
+	default:
+		return nil, ua.StatusBadNodeAttributesInvalid
+	}
+
+	if n.DisplayName().Text == "" {
+		n.SetDisplayName(browseName.Name, "")
+	}
+	return n, ua.StatusOK
+}
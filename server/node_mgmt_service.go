@@ -1,12 +1,20 @@
 package server
 
 import (
+	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 	"github.com/gopcua/opcua/uasc"
 )
 
 // NodeManagementService implements the Node Management Service Set.
 //
+// Nodes and references can only be changed in namespaces which implement
+// the NodeManager interface and for which node management was enabled with
+// the NodeManagement option. Namespace 0 can never be changed. The user
+// needs the AddNode permission for the parent node, the DeleteNode
+// permission for the deleted node and the AddReference or RemoveReference
+// permission for the source node.
+//
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.7
 type NodeManagementService struct {
 	srv *Server
@@ -22,7 +30,129 @@ func (s *NodeManagementService) AddNodes(sc *uasc.SecureChannel, r ua.Request, r
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.NodesToAdd) == 0 {
+		return &ua.AddNodesResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	results := make([]*ua.AddNodesResult, len(req.NodesToAdd))
+	var changes []*ua.ModelChangeStructureDataType
+	for i, item := range req.NodesToAdd {
+		nodeID, status := s.addNode(sess, item)
+		results[i] = &ua.AddNodesResult{StatusCode: status, AddedNodeID: nodeID}
+		if status != ua.StatusOK {
+			results[i].AddedNodeID = ua.NewTwoByteNodeID(0)
+			continue
+		}
+		changes = append(changes, modelChange(nodeID, item.TypeDefinition, ua.ModelChangeStructureVerbMaskNodeAdded))
+		if parentID := s.srv.localNodeID(item.ParentNodeID); parentID != nil {
+			changes = append(changes, modelChange(parentID, nil, ua.ModelChangeStructureVerbMaskReferenceAdded))
+		}
+	}
+	s.srv.modelChanged(changes)
+
+	return &ua.AddNodesResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// addNode validates and adds a single node and returns its node id.
+func (s *NodeManagementService) addNode(sess *session, item *ua.AddNodesItem) (*ua.NodeID, ua.StatusCode) {
+	parentID := s.srv.localNodeID(item.ParentNodeID)
+	parent := s.srv.nodeOrNil(parentID)
+	if parent == nil {
+		return nil, ua.StatusBadParentNodeIDInvalid
+	}
+	parentNS, ok := s.srv.nodeManager(parentID)
+	if !ok {
+		return nil, ua.StatusBadUserAccessDenied
+	}
+	if status := s.srv.allowed(sess, parentID, ua.PermissionTypeAddNode); status != ua.StatusOK {
+		return nil, status
+	}
+
+	if status := s.srv.checkReferenceType(item.ReferenceTypeID); status != ua.StatusOK {
+		return nil, status
+	}
+	if !suitableRefType(s.srv, ua.NewNumericNodeID(0, id.HierarchicalReferences), item.ReferenceTypeID, true) {
+		return nil, ua.StatusBadReferenceNotAllowed
+	}
+
+	// without a requested node id the node is created in the namespace of
+	// the parent.
+	var nodeID *ua.NodeID
+	if item.RequestedNewNodeID == nil || isNullNodeID(item.RequestedNewNodeID.NodeID) {
+		nodeID = s.srv.newNodeID(parentNS, parentID.Namespace())
+	} else {
+		nodeID = s.srv.localNodeID(item.RequestedNewNodeID)
+		if nodeID == nil {
+			return nil, ua.StatusBadNodeIDRejected
+		}
+		if s.srv.nodeOrNil(nodeID) != nil {
+			return nil, ua.StatusBadNodeIDExists
+		}
+	}
+	ns, ok := s.srv.nodeManager(nodeID)
+	if !ok {
+		return nil, ua.StatusBadNodeIDRejected
+	}
+
+	if item.BrowseName == nil || item.BrowseName.Name == "" {
+		return nil, ua.StatusBadBrowseNameInvalid
+	}
+	for _, ref := range parent.references() {
+		if ref.IsForward && ref.BrowseName != nil && *ref.BrowseName == *item.BrowseName &&
+			suitableRefType(s.srv, ua.NewNumericNodeID(0, id.HierarchicalReferences), ref.ReferenceTypeID, true) {
+			return nil, ua.StatusBadBrowseNameDuplicated
+		}
+	}
+
+	var typeDef *Node
+	switch item.NodeClass {
+	case ua.NodeClassObject, ua.NodeClassVariable:
+		wantClass := ua.NodeClassObjectType
+		if item.NodeClass == ua.NodeClassVariable {
+			wantClass = ua.NodeClassVariableType
+		}
+		typeDef = s.srv.nodeOrNil(s.srv.localNodeID(item.TypeDefinition))
+		if typeDef == nil || typeDef.NodeClass() != wantClass {
+			return nil, ua.StatusBadTypeDefinitionInvalid
+		}
+	case ua.NodeClassMethod, ua.NodeClassObjectType, ua.NodeClassVariableType,
+		ua.NodeClassReferenceType, ua.NodeClassDataType, ua.NodeClassView:
+		if !isNullNodeID(s.srv.localNodeID(item.TypeDefinition)) {
+			return nil, ua.StatusBadTypeDefinitionInvalid
+		}
+	default:
+		return nil, ua.StatusBadNodeClassInvalid
+	}
+
+	n, status := nodeFromAttributes(nodeID, item.NodeClass, item.BrowseName, item.NodeAttributes)
+	if status != ua.StatusOK {
+		return nil, status
+	}
+	n.appendReferences(reference(item.ReferenceTypeID, false, parent))
+	if typeDef != nil {
+		n.appendReferences(reference(ua.NewNumericNodeID(0, id.HasTypeDefinition), true, typeDef))
+	}
+
+	if status := ns.InsertNode(n); status != ua.StatusOK {
+		return nil, status
+	}
+	if status := parentNS.InsertReference(parentID, reference(item.ReferenceTypeID, true, n)); status != ua.StatusOK {
+		ns.RemoveNode(nodeID)
+		return nil, status
+	}
+	return nodeID, ua.StatusOK
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.7.3
@@ -35,7 +165,74 @@ func (s *NodeManagementService) AddReferences(sc *uasc.SecureChannel, r ua.Reque
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.ReferencesToAdd) == 0 {
+		return &ua.AddReferencesResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	results := make([]ua.StatusCode, len(req.ReferencesToAdd))
+	var changes []*ua.ModelChangeStructureDataType
+	for i, item := range req.ReferencesToAdd {
+		results[i] = s.addReference(sess, item)
+		if results[i] == ua.StatusOK {
+			changes = append(changes, modelChange(item.SourceNodeID, nil, ua.ModelChangeStructureVerbMaskReferenceAdded))
+		}
+	}
+	s.srv.modelChanged(changes)
+
+	return &ua.AddReferencesResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// addReference validates and adds a single reference and its inverse
+// reference.
+func (s *NodeManagementService) addReference(sess *session, item *ua.AddReferencesItem) ua.StatusCode {
+	source := s.srv.nodeOrNil(item.SourceNodeID)
+	if source == nil {
+		return ua.StatusBadSourceNodeIDInvalid
+	}
+	sourceNS, ok := s.srv.nodeManager(item.SourceNodeID)
+	if !ok {
+		return ua.StatusBadUserAccessDenied
+	}
+	if status := s.srv.allowed(sess, item.SourceNodeID, ua.PermissionTypeAddReference); status != ua.StatusOK {
+		return status
+	}
+	if status := s.srv.checkReferenceType(item.ReferenceTypeID); status != ua.StatusOK {
+		return status
+	}
+	if item.TargetServerURI != "" {
+		return ua.StatusBadReferenceLocalOnly
+	}
+	targetID := s.srv.localNodeID(item.TargetNodeID)
+	target := s.srv.nodeOrNil(targetID)
+	if target == nil {
+		return ua.StatusBadTargetNodeIDInvalid
+	}
+	if targetID.Equal(item.SourceNodeID) {
+		return ua.StatusBadInvalidSelfReference
+	}
+	if item.TargetNodeClass != ua.NodeClassUnspecified && item.TargetNodeClass != target.NodeClass() {
+		return ua.StatusBadNodeClassInvalid
+	}
+
+	if status := sourceNS.InsertReference(item.SourceNodeID, reference(item.ReferenceTypeID, item.IsForward, target)); status != ua.StatusOK {
+		return status
+	}
+	if targetNS, ok := s.srv.nodeManager(targetID); ok {
+		targetNS.InsertReference(targetID, reference(item.ReferenceTypeID, !item.IsForward, source))
+	}
+	return ua.StatusOK
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.7.4
@@ -48,7 +245,80 @@ func (s *NodeManagementService) DeleteNodes(sc *uasc.SecureChannel, r ua.Request
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.NodesToDelete) == 0 {
+		return &ua.DeleteNodesResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	results := make([]ua.StatusCode, len(req.NodesToDelete))
+	var changes []*ua.ModelChangeStructureDataType
+	for i, item := range req.NodesToDelete {
+		var affected []*ua.NodeID
+		results[i], affected = s.deleteNode(sess, item)
+		if results[i] != ua.StatusOK {
+			continue
+		}
+		changes = append(changes, modelChange(item.NodeID, nil, ua.ModelChangeStructureVerbMaskNodeDeleted))
+		for _, nodeID := range affected {
+			changes = append(changes, modelChange(nodeID, nil, ua.ModelChangeStructureVerbMaskReferenceDeleted))
+		}
+	}
+	s.srv.modelChanged(changes)
+
+	return &ua.DeleteNodesResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// deleteNode deletes a single node. If DeleteTargetReferences is set the
+// references of other nodes to the deleted node are deleted as well and
+// the ids of these nodes are returned.
+func (s *NodeManagementService) deleteNode(sess *session, item *ua.DeleteNodesItem) (ua.StatusCode, []*ua.NodeID) {
+	n := s.srv.nodeOrNil(item.NodeID)
+	if n == nil {
+		return ua.StatusBadNodeIDUnknown, nil
+	}
+	ns, ok := s.srv.nodeManager(item.NodeID)
+	if !ok {
+		return ua.StatusBadUserAccessDenied, nil
+	}
+	if status := s.srv.allowed(sess, item.NodeID, ua.PermissionTypeDeleteNode); status != ua.StatusOK {
+		return status, nil
+	}
+	refs := n.references()
+	if status := ns.RemoveNode(item.NodeID); status != ua.StatusOK {
+		return status, nil
+	}
+	if !item.DeleteTargetReferences {
+		return ua.StatusOK, nil
+	}
+
+	var affected []*ua.NodeID
+	for _, ref := range refs {
+		targetID := s.srv.localNodeID(ref.NodeID)
+		targetNS, ok := s.srv.nodeManager(targetID)
+		if !ok {
+			continue
+		}
+		inverse := &ua.ReferenceDescription{
+			ReferenceTypeID: ref.ReferenceTypeID,
+			IsForward:       !ref.IsForward,
+			NodeID:          ua.NewExpandedNodeID(item.NodeID, "", 0),
+		}
+		if targetNS.RemoveReference(targetID, inverse) == ua.StatusOK {
+			affected = append(affected, targetID)
+		}
+	}
+	return ua.StatusOK, affected
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.7.5
@@ -61,5 +331,112 @@ func (s *NodeManagementService) DeleteReferences(sc *uasc.SecureChannel, r ua.Re
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.ReferencesToDelete) == 0 {
+		return &ua.DeleteReferencesResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	results := make([]ua.StatusCode, len(req.ReferencesToDelete))
+	var changes []*ua.ModelChangeStructureDataType
+	for i, item := range req.ReferencesToDelete {
+		results[i] = s.deleteReference(sess, item)
+		if results[i] == ua.StatusOK {
+			changes = append(changes, modelChange(item.SourceNodeID, nil, ua.ModelChangeStructureVerbMaskReferenceDeleted))
+		}
+	}
+	s.srv.modelChanged(changes)
+
+	return &ua.DeleteReferencesResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// deleteReference deletes a single reference and optionally its inverse
+// reference.
+func (s *NodeManagementService) deleteReference(sess *session, item *ua.DeleteReferencesItem) ua.StatusCode {
+	if s.srv.nodeOrNil(item.SourceNodeID) == nil {
+		return ua.StatusBadSourceNodeIDInvalid
+	}
+	sourceNS, ok := s.srv.nodeManager(item.SourceNodeID)
+	if !ok {
+		return ua.StatusBadUserAccessDenied
+	}
+	if status := s.srv.allowed(sess, item.SourceNodeID, ua.PermissionTypeRemoveReference); status != ua.StatusOK {
+		return status
+	}
+	if status := s.srv.checkReferenceType(item.ReferenceTypeID); status != ua.StatusOK {
+		return status
+	}
+	targetID := s.srv.localNodeID(item.TargetNodeID)
+	if targetID == nil {
+		return ua.StatusBadTargetNodeIDInvalid
+	}
+
+	ref := &ua.ReferenceDescription{
+		ReferenceTypeID: item.ReferenceTypeID,
+		IsForward:       item.IsForward,
+		NodeID:          ua.NewExpandedNodeID(targetID, "", 0),
+	}
+	if status := sourceNS.RemoveReference(item.SourceNodeID, ref); status != ua.StatusOK {
+		return status
+	}
+	if item.DeleteBidirectional {
+		if targetNS, ok := s.srv.nodeManager(targetID); ok {
+			inverse := &ua.ReferenceDescription{
+				ReferenceTypeID: item.ReferenceTypeID,
+				IsForward:       !item.IsForward,
+				NodeID:          ua.NewExpandedNodeID(item.SourceNodeID, "", 0),
+			}
+			targetNS.RemoveReference(targetID, inverse)
+		}
+	}
+	return ua.StatusOK
+}
+
+// reference returns a reference to the target node.
+func reference(refType *ua.NodeID, forward bool, target *Node) *ua.ReferenceDescription {
+	return &ua.ReferenceDescription{
+		ReferenceTypeID: refType,
+		IsForward:       forward,
+		NodeID:          ua.NewExpandedNodeID(target.ID(), "", 0),
+		BrowseName:      target.BrowseName(),
+		DisplayName:     target.DisplayName(),
+		NodeClass:       target.NodeClass(),
+		TypeDefinition:  target.DataType(),
+	}
+}
+
+// sameReference returns true if both references have the same type,
+// direction and target node.
+func sameReference(a, b *ua.ReferenceDescription) bool {
+	return a.IsForward == b.IsForward &&
+		a.ReferenceTypeID != nil && a.ReferenceTypeID.Equal(b.ReferenceTypeID) &&
+		a.NodeID != nil && b.NodeID != nil && a.NodeID.NodeID.Equal(b.NodeID.NodeID)
+}
+
+// modelChange returns the description of a single change of the address
+// space for a GeneralModelChangeEvent.
+func modelChange(nodeID *ua.NodeID, typeDef *ua.ExpandedNodeID, verb ua.ModelChangeStructureVerbMask) *ua.ModelChangeStructureDataType {
+	affectedType := ua.NewTwoByteNodeID(0)
+	if typeDef != nil && typeDef.NodeID != nil {
+		affectedType = typeDef.NodeID
+	}
+	return &ua.ModelChangeStructureDataType{
+		Affected:     nodeID,
+		AffectedType: affectedType,
+		Verb:         uint8(verb),
+	}
+}
+
+func isNullNodeID(nodeID *ua.NodeID) bool {
+	return nodeID == nil || nodeID.Equal(ua.NewTwoByteNodeID(0))
 }
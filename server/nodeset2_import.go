@@ -262,6 +262,7 @@ func (srv *Server) nodesImportNodeSet(nodes *schema.UANodeSet) error {
 		}
 
 		attrs[ua.AttributeIDNodeClass] = DataValueFromValue(uint32(ua.NodeClassObject))
+		attrs[ua.AttributeIDEventNotifier] = DataValueFromValue(ot.EventNotifierAttr)
 
 		var refs References = make([]*ua.ReferenceDescription, 0)
 
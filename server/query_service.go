@@ -1,17 +1,32 @@
 package server
 
 import (
+	"math"
+
+	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 	"github.com/gopcua/opcua/uasc"
 )
 
 // QueryService implements the Query Service Set.
 //
+// The query searches the instances of the requested types in all
+// namespaces of type NodeNameSpace and evaluates the content filter for
+// each of them. Views and the MaxReferencesToReturn limit are not
+// supported.
+//
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.9
 type QueryService struct {
 	srv *Server
 }
 
+// queryContinuation holds the data sets of a Query which did not fit into
+// the previous response.
+type queryContinuation struct {
+	sets []*ua.QueryDataSet
+	max  uint32
+}
+
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.9.3
 func (s *QueryService) QueryFirst(sc *uasc.SecureChannel, r ua.Request, reqID uint32) (ua.Response, error) {
 	if s.srv.cfg.logger != nil {
@@ -22,7 +37,64 @@ func (s *QueryService) QueryFirst(sc *uasc.SecureChannel, r ua.Request, reqID ui
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	fail := func(status ua.StatusCode) (ua.Response, error) {
+		return &ua.QueryFirstResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, status),
+			FilterResult:   &ua.ContentFilterResult{},
+		}, nil
+	}
+
+	if len(req.NodeTypes) == 0 {
+		return fail(ua.StatusBadNothingToDo)
+	}
+	if req.View != nil && !isNullNodeID(req.View.ViewID) {
+		return fail(ua.StatusBadViewIDUnknown)
+	}
+
+	filter, filterResult, status := newContentFilter(s.srv, req.Filter)
+	if status != ua.StatusOK {
+		return &ua.QueryFirstResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, status),
+			FilterResult:   filterResult,
+		}, nil
+	}
+
+	parsingResults, ok := s.parseNodeTypes(req.NodeTypes)
+
+	var sets []*ua.QueryDataSet
+	for _, ns := range s.srv.Namespaces() {
+		nns, isNodeNS := ns.(*NodeNameSpace)
+		if !isNodeNS {
+			continue
+		}
+		for _, n := range nns.allNodes() {
+			if set := s.queryNode(sess, n, req.NodeTypes, parsingResults, filter); set != nil {
+				sets = append(sets, set)
+			}
+		}
+	}
+
+	res := &ua.QueryFirstResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		ParsingResults:  []*ua.ParsingResult{},
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+		FilterResult:    filterResult,
+	}
+	// the parsing results are only returned if a node type has errors.
+	if !ok {
+		res.ParsingResults = parsingResults
+	}
+	res.QueryDataSets, res.ContinuationPoint, err = s.page(sess, sets, req.MaxDataSetsToReturn)
+	if err != nil {
+		return fail(ua.StatusBadNoContinuationPoints)
+	}
+	return res, nil
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.9.4
@@ -35,5 +107,213 @@ func (s *QueryService) QueryNext(sc *uasc.SecureChannel, r ua.Request, reqID uin
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	c := sess.takeQueryContinuation(req.ContinuationPoint)
+	if c == nil {
+		return &ua.QueryNextResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadContinuationPointInvalid),
+		}, nil
+	}
+	if req.ReleaseContinuationPoint {
+		return &ua.QueryNextResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		}, nil
+	}
+
+	sets, cp, err := s.page(sess, c.sets, c.max)
+	if err != nil {
+		return &ua.QueryNextResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNoContinuationPoints),
+		}, nil
+	}
+	return &ua.QueryNextResponse{
+		ResponseHeader:           responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		QueryDataSets:            sets,
+		RevisedContinuationPoint: cp,
+	}, nil
+}
+
+// page limits the data sets to max and stores the remaining data sets in a
+// continuation point of the session.
+func (s *QueryService) page(sess *session, sets []*ua.QueryDataSet, max uint32) ([]*ua.QueryDataSet, []byte, error) {
+	if max == 0 || len(sets) <= int(max) {
+		return sets, nil, nil
+	}
+	next := &queryContinuation{sets: sets[max:], max: max}
+	cp, err := sess.addQueryContinuation(next, int(s.srv.cfg.cap.MaxQueryContinuationPoints))
+	if err != nil {
+		return nil, nil, err
+	}
+	return sets[:max], cp, nil
+}
+
+// parseNodeTypes validates the node types of a query. It returns false if
+// any node type has errors.
+func (s *QueryService) parseNodeTypes(nodeTypes []*ua.NodeTypeDescription) ([]*ua.ParsingResult, bool) {
+	ok := true
+	results := make([]*ua.ParsingResult, len(nodeTypes))
+	for i, nt := range nodeTypes {
+		r := &ua.ParsingResult{
+			StatusCode:          ua.StatusOK,
+			DataStatusCodes:     make([]ua.StatusCode, len(nt.DataToReturn)),
+			DataDiagnosticInfos: []*ua.DiagnosticInfo{},
+		}
+		results[i] = r
+
+		typeID := s.srv.localNodeID(nt.TypeDefinitionNode)
+		n := s.srv.nodeOrNil(typeID)
+		switch {
+		case typeID == nil:
+			r.StatusCode = ua.StatusBadNodeIDInvalid
+		case n == nil:
+			r.StatusCode = ua.StatusBadNodeIDUnknown
+		case n.NodeClass() != ua.NodeClassObjectType && n.NodeClass() != ua.NodeClassVariableType:
+			r.StatusCode = ua.StatusBadNotTypeDefinition
+		}
+		if r.StatusCode != ua.StatusOK {
+			ok = false
+		}
+
+		for j, d := range nt.DataToReturn {
+			if d.AttributeID < ua.AttributeIDNodeID || d.AttributeID > ua.AttributeIDAccessLevelEx {
+				r.DataStatusCodes[j] = ua.StatusBadAttributeIDInvalid
+				ok = false
+			}
+		}
+	}
+	return results, ok
+}
+
+// queryNode returns the data set of the node if it is an instance of one
+// of the node types and matches the filter. It returns nil otherwise or if
+// the user of the session is not allowed to browse the node. Values which
+// the user is not allowed to read are empty.
+func (s *QueryService) queryNode(sess *session, n *Node, nodeTypes []*ua.NodeTypeDescription, parsingResults []*ua.ParsingResult, filter *contentFilter) *ua.QueryDataSet {
+	typeDef := n.typeDefinition()
+	if typeDef == nil || s.srv.allowed(sess, n.ID(), ua.PermissionTypeBrowse) != ua.StatusOK {
+		return nil
+	}
+	t := &nodeTarget{srv: s.srv, sess: sess, n: n}
+	for i, nt := range nodeTypes {
+		if parsingResults[i].StatusCode != ua.StatusOK {
+			continue
+		}
+		typeID := s.srv.localNodeID(nt.TypeDefinitionNode)
+		if !typeDef.Equal(typeID) && !(nt.IncludeSubTypes && s.srv.isSubtype(typeDef, typeID)) {
+			continue
+		}
+		if !filter.match(t) {
+			return nil
+		}
+
+		values := make([]*ua.Variant, len(nt.DataToReturn))
+		for j, d := range nt.DataToReturn {
+			values[j] = &ua.Variant{}
+			if parsingResults[i].DataStatusCodes[j] != ua.StatusOK {
+				continue
+			}
+			v := t.value(s.srv.followPath(n.ID(), d.RelativePath), d.AttributeID)
+			if vv, err := ua.NewVariant(v); v != nil && err == nil {
+				values[j] = vv
+			}
+		}
+		return &ua.QueryDataSet{
+			NodeID:             ua.NewExpandedNodeID(n.ID(), "", 0),
+			TypeDefinitionNode: ua.NewExpandedNodeID(typeDef, "", 0),
+			Values:             values,
+		}
+	}
+	return nil
+}
+
+// nodeTarget evaluates a content filter for a node and the user of a
+// session.
+type nodeTarget struct {
+	srv  *Server
+	sess *session
+	n    *Node
+}
+
+func (t *nodeTarget) attribute(op *ua.AttributeOperand) any {
+	if !isNullNodeID(op.NodeID) && !t.ofType(op.NodeID) {
+		return nil
+	}
+	return t.value(t.srv.followPath(t.n.ID(), op.BrowsePath), op.AttributeID)
+}
+
+func (t *nodeTarget) simpleAttribute(op *ua.SimpleAttributeOperand) any {
+	if !isNullNodeID(op.TypeDefinitionID) && !t.ofType(op.TypeDefinitionID) {
+		return nil
+	}
+	return t.value(t.srv.followPath(t.n.ID(), simpleRelativePath(op.BrowsePath)), op.AttributeID)
+}
+
+// value returns the value of an attribute of a node or nil if the user is
+// not allowed to read it.
+func (t *nodeTarget) value(nodeID *ua.NodeID, attr ua.AttributeID) any {
+	if nodeID == nil || t.srv.allowed(t.sess, nodeID, readPermission(attr)) != ua.StatusOK {
+		return nil
+	}
+	return t.srv.attributeValue(nodeID, attr)
+}
+
+func (t *nodeTarget) ofType(typeID *ua.NodeID) bool {
+	typeDef := t.n.typeDefinition()
+	return typeDef != nil && t.srv.isSubtype(typeDef, typeID)
+}
+
+func (t *nodeTarget) node() *Node {
+	return t.n
+}
+
+// attributeValue returns the value of an attribute of a node or nil if the
+// node or the attribute does not exist.
+func (s *Server) attributeValue(nodeID *ua.NodeID, attrID ua.AttributeID) any {
+	if nodeID == nil {
+		return nil
+	}
+	ns, err := s.Namespace(int(nodeID.Namespace()))
+	if err != nil {
+		return nil
+	}
+	dv := ns.Attribute(nodeID, attrID)
+	if dv == nil || dv.Status != ua.StatusOK || dv.Value == nil {
+		return nil
+	}
+	return dv.Value.Value()
+}
+
+// followPath returns the id of the first node which is reached from the
+// start node with the relative path or nil if there is no such node. An
+// empty path returns the start node.
+func (s *Server) followPath(start *ua.NodeID, path *ua.RelativePath) *ua.NodeID {
+	if path == nil || len(path.Elements) == 0 {
+		return start
+	}
+	res := (&ViewService{srv: s}).translateBrowsePath(nil, &ua.BrowsePath{StartingNode: start, RelativePath: path})
+	for _, t := range res.Targets {
+		if t.RemainingPathIndex == math.MaxUint32 && t.TargetID.ServerIndex == 0 {
+			return t.TargetID.NodeID
+		}
+	}
+	return nil
+}
+
+// simpleRelativePath converts the browse path of a SimpleAttributeOperand
+// into a relative path which follows hierarchical references.
+func simpleRelativePath(names []*ua.QualifiedName) *ua.RelativePath {
+	path := &ua.RelativePath{Elements: make([]*ua.RelativePathElement, len(names))}
+	for i, name := range names {
+		path.Elements[i] = &ua.RelativePathElement{
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HierarchicalReferences),
+			IncludeSubtypes: true,
+			TargetName:      name,
+		}
+	}
+	return path
 }
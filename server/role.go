@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uapolicy"
+)
+
+// The well-known roles of the users of a server.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/4.9.2
+var (
+	RoleAnonymous         = ua.NewNumericNodeID(0, id.WellKnownRole_Anonymous)
+	RoleAuthenticatedUser = ua.NewNumericNodeID(0, id.WellKnownRole_AuthenticatedUser)
+	RoleObserver          = ua.NewNumericNodeID(0, id.WellKnownRole_Observer)
+	RoleOperator          = ua.NewNumericNodeID(0, id.WellKnownRole_Operator)
+	RoleEngineer          = ua.NewNumericNodeID(0, id.WellKnownRole_Engineer)
+	RoleSupervisor        = ua.NewNumericNodeID(0, id.WellKnownRole_Supervisor)
+	RoleConfigureAdmin    = ua.NewNumericNodeID(0, id.WellKnownRole_ConfigureAdmin)
+	RoleSecurityAdmin     = ua.NewNumericNodeID(0, id.WellKnownRole_SecurityAdmin)
+)
+
+// allPermissions are the permissions of all users for nodes without role
+// permissions.
+const allPermissions = ua.PermissionType(1<<17 - 1)
+
+// roleIdentities are the identity mapping rules of a role.
+type roleIdentities struct {
+	role  *ua.NodeID
+	rules []*ua.IdentityMappingRuleType
+}
+
+// defaultRoleIdentities returns the recommended identity mapping rules of
+// the Anonymous and the AuthenticatedUser roles.
+func defaultRoleIdentities() []*roleIdentities {
+	return []*roleIdentities{
+		{role: RoleAnonymous, rules: []*ua.IdentityMappingRuleType{{CriteriaType: ua.IdentityCriteriaTypeAnonymous}}},
+		{role: RoleAuthenticatedUser, rules: []*ua.IdentityMappingRuleType{{CriteriaType: ua.IdentityCriteriaTypeAuthenticatedUser}}},
+	}
+}
+
+// matchIdentity returns true if the user of a session with the identity
+// and the client application uri matches the rule.
+//
+// https://reference.opcfoundation.org/Core/Part18/v105/docs/4.4.2
+func matchIdentity(rule *ua.IdentityMappingRuleType, user *Identity, appURI string) bool {
+	if user == nil {
+		user = &Identity{TokenType: ua.UserTokenTypeAnonymous}
+	}
+
+	switch rule.CriteriaType {
+	case ua.IdentityCriteriaTypeUserName:
+		return user.TokenType == ua.UserTokenTypeUserName && user.UserName == rule.Criteria
+	case ua.IdentityCriteriaTypeThumbprint:
+		return user.Certificate != nil && strings.EqualFold(fmt.Sprintf("%x", uapolicy.Thumbprint(user.Certificate)), rule.Criteria)
+	case ua.IdentityCriteriaTypeRole, ua.IdentityCriteriaTypeGroupID:
+		return slices.Contains(user.Groups, rule.Criteria)
+	case ua.IdentityCriteriaTypeAnonymous:
+		return user.TokenType == ua.UserTokenTypeAnonymous
+	case ua.IdentityCriteriaTypeAuthenticatedUser:
+		return user.TokenType != ua.UserTokenTypeAnonymous
+	case ua.IdentityCriteriaTypeApplication:
+		return appURI != "" && appURI == rule.Criteria
+	case ua.IdentityCriteriaTypeX509Subject:
+		if user.Certificate == nil {
+			return false
+		}
+		cert, err := uapolicy.ParseCertificate(user.Certificate)
+		return err == nil && cert.Subject.String() == rule.Criteria
+	default:
+		return false
+	}
+}
+
+// userRoles returns the roles of the user of a session with the identity
+// and the client application uri.
+func (s *Server) userRoles(user *Identity, appURI string) []*ua.NodeID {
+	var roles []*ua.NodeID
+	for _, r := range s.cfg.roles {
+		for _, rule := range r.rules {
+			if matchIdentity(rule, user, appURI) {
+				roles = append(roles, r.role)
+				break
+			}
+		}
+	}
+	return roles
+}
+
+// SessionRoles returns the roles of the user of the session on whose
+// behalf the server is handling a request, e.g. in a method call. It
+// returns nil if ctx does not carry a session.
+func SessionRoles(ctx context.Context) []*ua.NodeID {
+	s := sessionFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.roles)
+}
+
+// hasRole returns true if the user of the session has the role.
+func (s *session) hasRole(role *ua.NodeID) bool {
+	if s == nil || role == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.roles {
+		if r.String() == role.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// rolePermissions returns the role permissions of a node. Nodes without
+// role permissions get the default role permissions of the server. It
+// returns nil if neither exist.
+func (s *Server) rolePermissions(nodeID *ua.NodeID) []*ua.RolePermissionType {
+	if n := s.Node(nodeID); n != nil {
+		if perms := n.RolePermissions(); perms != nil {
+			return perms
+		}
+	}
+	return s.cfg.defaultRolePermissions
+}
+
+// userRolePermissions returns the role permissions of a node for the roles
+// of the user of the session. It returns nil if the node has no role
+// permissions.
+func (s *Server) userRolePermissions(sess *session, nodeID *ua.NodeID) []*ua.RolePermissionType {
+	perms := s.rolePermissions(nodeID)
+	if perms == nil {
+		return nil
+	}
+	user := []*ua.RolePermissionType{}
+	for _, p := range perms {
+		if sess.hasRole(p.RoleID) {
+			user = append(user, p)
+		}
+	}
+	return user
+}
+
+// permissions returns the permissions of the user of the session for a
+// node. Without role permissions all users have all permissions.
+func (s *Server) permissions(sess *session, nodeID *ua.NodeID) ua.PermissionType {
+	perms := s.userRolePermissions(sess, nodeID)
+	if perms == nil {
+		return allPermissions
+	}
+	var p ua.PermissionType
+	for _, rp := range perms {
+		p |= rp.Permissions
+	}
+	return p
+}
+
+// allowed returns StatusOK if the user of the session has the permission
+// for a node and StatusBadUserAccessDenied otherwise.
+func (s *Server) allowed(sess *session, nodeID *ua.NodeID, perm ua.PermissionType) ua.StatusCode {
+	if s.permissions(sess, nodeID)&perm == 0 {
+		return ua.StatusBadUserAccessDenied
+	}
+	return ua.StatusOK
+}
+
+// browsable removes the references to the nodes of the server from the
+// browse result which the user of the session is not allowed to browse.
+func (s *Server) browsable(sess *session, res *ua.BrowseResult) *ua.BrowseResult {
+	if res == nil || len(res.References) == 0 {
+		return res
+	}
+	refs := res.References[:0:0]
+	for _, r := range res.References {
+		if r.NodeID != nil && r.NodeID.ServerIndex == 0 && s.allowed(sess, r.NodeID.NodeID, ua.PermissionTypeBrowse) != ua.StatusOK {
+			continue
+		}
+		refs = append(refs, r)
+	}
+	res.References = refs
+	return res
+}
+
+// readPermission returns the permission which is needed to read an
+// attribute.
+func readPermission(attr ua.AttributeID) ua.PermissionType {
+	switch attr {
+	case ua.AttributeIDValue:
+		return ua.PermissionTypeRead
+	case ua.AttributeIDRolePermissions:
+		return ua.PermissionTypeReadRolePermissions
+	default:
+		return ua.PermissionTypeBrowse
+	}
+}
+
+// writePermission returns the permission which is needed to write an
+// attribute.
+func writePermission(attr ua.AttributeID) ua.PermissionType {
+	switch attr {
+	case ua.AttributeIDValue:
+		return ua.PermissionTypeWrite
+	case ua.AttributeIDRolePermissions:
+		return ua.PermissionTypeWriteRolePermissions
+	case ua.AttributeIDHistorizing:
+		return ua.PermissionTypeWriteHistorizing
+	default:
+		return ua.PermissionTypeWriteAttribute
+	}
+}
+
+// userAccessLevel returns the access level of a variable for a user with
+// the permissions.
+func userAccessLevel(access byte, perms ua.PermissionType) byte {
+	if perms&ua.PermissionTypeRead == 0 {
+		access &^= byte(ua.AccessLevelTypeCurrentRead)
+	}
+	if perms&ua.PermissionTypeWrite == 0 {
+		access &^= byte(ua.AccessLevelTypeCurrentWrite | ua.AccessLevelTypeStatusWrite | ua.AccessLevelTypeTimestampWrite)
+	}
+	if perms&ua.PermissionTypeReadHistory == 0 {
+		access &^= byte(ua.AccessLevelTypeHistoryRead)
+	}
+	if perms&(ua.PermissionTypeInsertHistory|ua.PermissionTypeModifyHistory|ua.PermissionTypeDeleteHistory) == 0 {
+		access &^= byte(ua.AccessLevelTypeHistoryWrite)
+	}
+	return access
+}
+
+// userAttribute returns the value of the UserAccessLevel, UserExecutable
+// and UserRolePermissions attributes for the user of the session. dv is
+// the value of the attribute which the namespace returned. The values of
+// the user attributes default to the values of the AccessLevel and the
+// Executable attributes.
+func (s *Server) userAttribute(sess *session, ns NameSpace, nodeID *ua.NodeID, attr ua.AttributeID, dv *ua.DataValue) *ua.DataValue {
+	switch attr {
+	case ua.AttributeIDUserAccessLevel:
+		if dv.Status != ua.StatusOK {
+			dv = ns.Attribute(nodeID, ua.AttributeIDAccessLevel)
+		}
+		if dv.Status != ua.StatusOK || dv.Value == nil {
+			return dv
+		}
+		access, ok := dv.Value.Value().(uint8)
+		if !ok {
+			return dv
+		}
+		return DataValueFromValue(userAccessLevel(access, s.permissions(sess, nodeID)))
+
+	case ua.AttributeIDUserExecutable:
+		if dv.Status != ua.StatusOK {
+			dv = ns.Attribute(nodeID, ua.AttributeIDExecutable)
+		}
+		if dv.Status != ua.StatusOK || dv.Value == nil {
+			return dv
+		}
+		executable, ok := dv.Value.Value().(bool)
+		if !ok {
+			return dv
+		}
+		return DataValueFromValue(executable && s.permissions(sess, nodeID)&ua.PermissionTypeCall != 0)
+
+	case ua.AttributeIDUserRolePermissions:
+		perms := s.userRolePermissions(sess, nodeID)
+		if perms == nil {
+			return dv
+		}
+		eos := make([]*ua.ExtensionObject, len(perms))
+		for i, p := range perms {
+			eos[i] = ua.NewExtensionObject(p)
+		}
+		return DataValueFromValue(eos)
+
+	default:
+		return dv
+	}
+}
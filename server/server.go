@@ -6,19 +6,23 @@ package server
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"encoding/xml"
 	"fmt"
 	"log"
+	"maps"
 	"net"
 	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/schema"
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
 	"github.com/gopcua/opcua/uacp"
 	"github.com/gopcua/opcua/uapolicy"
 )
@@ -48,12 +52,21 @@ type Server struct {
 	// All services should have a method here.
 	handlers map[uint16]Handler
 
+	// methods contains the Go functions bound to method nodes
+	// indexed by the node id of the method.
+	methods map[string]*Method
+
+	// conditions contains the conditions maintained by the server indexed
+	// by the node ids of the conditions and of their ShelvingState objects.
+	conditions map[string]*Condition
+
 	SubscriptionService  *SubscriptionService
 	MonitoredItemService *MonitoredItemService
 }
 
 type serverConfig struct {
 	privateKey     *rsa.PrivateKey
+	ecKey          *ecdsa.PrivateKey
 	certificate    []byte
 	applicationURI string
 
@@ -67,23 +80,94 @@ type serverConfig struct {
 	enabledSec  []security
 	enabledAuth []authMode
 
+	// trustStore validates the certificates of the clients.
+	trustStore *uacert.Store
+
+	// authenticator authenticates the users of sessions.
+	authenticator Authenticator
+
+	// roles are the identity mapping rules of the roles.
+	roles []*roleIdentities
+
+	// defaultRolePermissions are the role permissions of nodes without
+	// role permissions.
+	defaultRolePermissions []*ua.RolePermissionType
+
 	cap ServerCapabilities
 
+	history HistoryStore
+
+	// retransmissionQueueSize is the maximum number of unacknowledged
+	// notification messages per subscription.
+	retransmissionQueueSize int
+
+	// maxSamplingInterval is the longest sampling interval of a monitored
+	// item.
+	maxSamplingInterval time.Duration
+
+	// implicitNumericConversion converts written numbers to the data type
+	// of the variable.
+	implicitNumericConversion bool
+
+	// nodeManagement are the names of the namespaces which clients can
+	// change with the Node Management services.
+	nodeManagement []string
+
 	logger Logger
 }
 
 var capabilities = ServerCapabilities{
 	OperationalLimits: OperationalLimits{
-		MaxNodesPerRead: 32,
+		MaxNodesPerRead:      32,
+		MaxReferencesPerNode: 1000,
 	},
+	MaxBrowseContinuationPoints:  10,
+	MaxQueryContinuationPoints:   10,
+	MaxHistoryContinuationPoints: 10,
+	MinSupportedSampleRate:       100,
+	MaxMonitoredItemsQueueSize:   1000,
+	MaxSessions:                  100,
+	MaxRegisteredNodes:           1000,
 }
 
 type ServerCapabilities struct {
 	OperationalLimits OperationalLimits
+
+	// MaxBrowseContinuationPoints is the maximum number of continuation
+	// points for Browse per session.
+	MaxBrowseContinuationPoints uint16
+
+	// MaxQueryContinuationPoints is the maximum number of continuation
+	// points for QueryFirst per session.
+	MaxQueryContinuationPoints uint16
+
+	// MaxHistoryContinuationPoints is the maximum number of continuation
+	// points for HistoryRead per session.
+	MaxHistoryContinuationPoints uint16
+
+	// MinSupportedSampleRate is the shortest sampling interval of a
+	// monitored item in milliseconds.
+	MinSupportedSampleRate float64
+
+	// MaxMonitoredItemsQueueSize is the maximum queue size of a monitored
+	// item.
+	MaxMonitoredItemsQueueSize uint32
+
+	// MaxSessions is the maximum number of sessions. Zero means no limit.
+	MaxSessions uint32
+
+	// MaxRegisteredNodes is the maximum number of registered nodes per
+	// session. Zero means no limit.
+	MaxRegisteredNodes uint32
 }
 
 type OperationalLimits struct {
 	MaxNodesPerRead uint32
+
+	// MaxReferencesPerNode is the maximum number of references the server
+	// returns per node in a Browse or BrowseNext response. The remaining
+	// references are returned with a continuation point.
+	MaxReferencesPerNode uint32
 }
 
 type authMode struct {
@@ -104,6 +188,10 @@ func New(opts ...Option) *Server {
 		manufacturerName: "The gopcua Team",      // override with the ManufacturerName option
 		productName:      "gopcua OPC/UA Server", // override with the ProductName option
 		softwareVersion:  "0.0.0-dev",            // override with the SoftwareVersion option
+
+		retransmissionQueueSize: 10,        // override with the RetransmissionQueueSize option
+		maxSamplingInterval:     time.Hour, // override with the MaxSamplingInterval option
+		roles:                   defaultRoleIdentities(),
 	}
 	for _, opt := range opts {
 		opt(cfg)
@@ -119,6 +207,9 @@ func New(opts ...Option) *Server {
 		cb:       newChannelBroker(cfg.logger),
 		sb:       newSessionBroker(cfg.logger),
 		handlers: make(map[uint16]Handler),
+		methods:  make(map[string]*Method),
+
+		conditions: make(map[string]*Condition),
 		namespaces: []NameSpace{
 			NewNameSpace("http://opcfoundation.org/UA/"), // ns:0
 		},
@@ -139,6 +230,10 @@ func New(opts ...Option) *Server {
 		},
 	}
 
+	// expired sessions lose their subscriptions like closed sessions
+	// which delete them.
+	s.sb.expired = func(sess *session) { s.releaseSession(sess, true) }
+
 	// init server address space
 	//for _, n := range PredefinedNodes() {
 	//s.namespaces[0].AddNode(n)
@@ -156,6 +251,7 @@ func New(opts ...Option) *Server {
 		log.Panic("Namespace 0 is not a node namespace!")
 	}
 	s.ImportNodeSet(&nodes)
+	s.bindConditionMethods()
 
 	s.namespaces[0].AddNode(CurrentTimeNode())
 	s.namespaces[0].AddNode(NamespacesNode(s))
@@ -189,7 +285,49 @@ func (s *Server) Namespaces() []NameSpace {
 }
 
 func (s *Server) ChangeNotification(n *ua.NodeID) {
-	s.MonitoredItemService.ChangeNotification(n)
+	s.historize(n)
+	if s.MonitoredItemService != nil {
+		s.MonitoredItemService.ChangeNotification(n)
+	}
+	for _, c := range s.allConditions() {
+		if c.input != nil && c.input.Equal(n) {
+			c.evaluate()
+		}
+	}
+}
+
+// historize records the current value of the node in the history store
+// if the node is a historizing variable.
+func (s *Server) historize(n *ua.NodeID) {
+	if s.cfg.history == nil {
+		return
+	}
+	ns, err := s.Namespace(int(n.Namespace()))
+	if err != nil {
+		return
+	}
+	h := ns.Attribute(n, ua.AttributeIDHistorizing)
+	if h == nil || h.Value == nil {
+		return
+	}
+	if b, ok := h.Value.Value().(bool); !ok || !b {
+		return
+	}
+
+	dv := ns.Attribute(n, ua.AttributeIDValue)
+	if dv == nil {
+		return
+	}
+	v := *dv
+	now := time.Now()
+	if v.SourceTimestamp.IsZero() {
+		v.SourceTimestamp = now
+	}
+	v.ServerTimestamp = now
+	v.EncodingMask |= ua.DataValueSourceTimestamp | ua.DataValueServerTimestamp
+	if err := s.cfg.history.RecordValue(n, &v); err != nil && s.cfg.logger != nil {
+		s.cfg.logger.Warn("error recording history of %s: %s", n, err)
+	}
 }
 
 // for now, the address space of the server is split up into namespaces.
@@ -215,6 +353,74 @@ func (s *Server) AddNamespace(ns NameSpace) int {
 	return len(s.namespaces) - 1
 }
 
+// BindMethod binds the Go function fn to the method node with the given id
+// so that clients can call it. See Method for the supported function
+// signatures.
+//
+// This can be used for method nodes which have been created manually or
+// which have been imported from a NodeSet2 file. If the method node is
+// known to the server then its InputArguments and OutputArguments
+// properties are created or updated to match the function.
+func (s *Server) BindMethod(methodID *ua.NodeID, fn any, opts ...MethodOption) error {
+	m, err := NewMethod(fn, opts...)
+	if err != nil {
+		return err
+	}
+
+	if n := s.Node(methodID); n != nil {
+		if n.NodeClass() != ua.NodeClassMethod {
+			return fmt.Errorf("node %s is not a method", methodID)
+		}
+		s.setMethodArguments(n, "InputArguments", m.Inputs)
+		s.setMethodArguments(n, "OutputArguments", m.Outputs)
+	}
+
+	s.mu.Lock()
+	s.methods[methodID.String()] = m
+	s.mu.Unlock()
+	return nil
+}
+
+// setMethodArguments updates the value of the argument property with the
+// given browse name or creates the property if it does not exist.
+func (s *Server) setMethodArguments(n *Node, name string, args []*ua.Argument) {
+	for _, r := range n.references() {
+		if r.ReferenceTypeID == nil || r.NodeID == nil || !r.IsForward || r.ReferenceTypeID.IntID() != id.HasProperty {
+			continue
+		}
+		p := s.Node(r.NodeID.NodeID)
+		if p == nil || p.BrowseName().Name != name {
+			continue
+		}
+		p.SetAttribute(ua.AttributeIDValue, argumentsValue(args))
+		return
+	}
+	if len(args) == 0 {
+		return
+	}
+
+	// there is no property yet. Create one if the method lives in a
+	// node namespace which allows adding nodes.
+	ns, err := s.Namespace(int(n.ID().Namespace()))
+	if err != nil {
+		return
+	}
+	nns, ok := ns.(*NodeNameSpace)
+	if !ok {
+		return
+	}
+	p := NewArgumentsNode(ua.NewNumericNodeID(nns.ID(), nns.GetNextNodeID()), name, args)
+	nns.AddNode(p)
+	n.AddRef(p, id.HasProperty, true)
+}
+
+// method returns the method bound to the method node with the given id or nil.
+func (s *Server) method(methodID *ua.NodeID) *Method {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.methods[methodID.String()]
+}
+
 func (s *Server) Endpoints() []*ua.EndpointDescription {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -245,6 +451,9 @@ func (s *Server) Start(ctx context.Context) error {
 	if len(s.cfg.endpoints) == 0 {
 		return fmt.Errorf("cannot start server: no endpoints defined")
 	}
+	if err := s.checkSecurity(); err != nil {
+		return err
+	}
 
 	// Register all service handlers
 	s.initHandlers()
@@ -271,6 +480,26 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// checkSecurity returns an error if an enabled security policy does not
+// match the key of the certificate of the server. The RSA policies need an
+// RSA key and the ECC policies an ECDSA key. Since the server has only one
+// certificate it cannot offer both.
+func (s *Server) checkSecurity() error {
+	for _, sec := range s.cfg.enabledSec {
+		if sec.secPolicy == ua.SecurityPolicyURINone {
+			continue
+		}
+		ecc := uapolicy.IsECC(sec.secPolicy)
+		switch {
+		case ecc && s.cfg.ecKey == nil:
+			return fmt.Errorf("cannot start server: %s requires a certificate with an ECDSA key", sec.secPolicy)
+		case !ecc && s.cfg.ecKey != nil:
+			return fmt.Errorf("cannot start server: %s requires a certificate with an RSA key", sec.secPolicy)
+		}
+	}
+	return nil
+}
+
 func (s *Server) setServerState(state ua.ServerState) {
 	s.mu.Lock()
 	s.status.State = state
@@ -322,7 +551,7 @@ func (s *Server) acceptAndRegister(ctx context.Context, l *uacp.Listener) {
 				}
 			}
 
-			go s.cb.RegisterConn(ctx, c, s.cfg.certificate, s.cfg.privateKey)
+			go s.cb.RegisterConn(ctx, c, s.cfg.certificate, s.cfg.localKey(), s.verifyCertificate)
 			if s.cfg.logger != nil {
 				s.cfg.logger.Info("registered connection: %s", c.RemoteAddr())
 			}
@@ -407,6 +636,19 @@ func (s *Server) initEndpoints() {
 						continue
 					}
 
+					// secured endpoints protect the user tokens with their
+					// own security policy. Only the endpoints without
+					// security offer the tokens of all policies.
+					if auth.tokenType != ua.UserTokenTypeAnonymous && sec.secPolicy != ua.SecurityPolicyURINone && authSec.secPolicy != sec.secPolicy {
+						continue
+					}
+
+					// the signatures of X509 user tokens are only
+					// supported with RSA keys.
+					if auth.tokenType == ua.UserTokenTypeCertificate && uapolicy.IsECC(authSec.secPolicy) {
+						continue
+					}
+
 					policyID := strings.ToLower(
 						strings.TrimPrefix(auth.tokenType.String(), "UserTokenType") +
 							"_" +
@@ -451,3 +693,241 @@ func (s *Server) Node(nid *ua.NodeID) *Node {
 	}
 	return nil
 }
+
+// nodeOrNil is like Node but also accepts a nil node id.
+func (s *Server) nodeOrNil(nid *ua.NodeID) *Node {
+	if nid == nil {
+		return nil
+	}
+	return s.Node(nid)
+}
+
+// localNodeID returns the node id of an expanded node id of this server or
+// nil if the node id belongs to another server or an unknown namespace.
+func (s *Server) localNodeID(eid *ua.ExpandedNodeID) *ua.NodeID {
+	if eid == nil || eid.NodeID == nil || eid.ServerIndex != 0 {
+		return nil
+	}
+	if eid.NamespaceURI == "" {
+		return eid.NodeID
+	}
+	for i, ns := range s.Namespaces() {
+		if ns.Name() == eid.NamespaceURI {
+			nid := *eid.NodeID
+			if err := nid.SetNamespace(uint16(i)); err != nil {
+				return nil
+			}
+			return &nid
+		}
+	}
+	return nil
+}
+
+// nodeManager returns the namespace of the node if it supports the Node
+// Management services and node management is enabled for it. Namespace 0
+// is never returned.
+func (s *Server) nodeManager(nid *ua.NodeID) (NodeManager, bool) {
+	if nid == nil || nid.Namespace() == 0 {
+		return nil, false
+	}
+	ns, err := s.Namespace(int(nid.Namespace()))
+	if err != nil || !slices.Contains(s.cfg.nodeManagement, ns.Name()) {
+		return nil, false
+	}
+	nm, ok := ns.(NodeManager)
+	return nm, ok
+}
+
+// nodeRegistrar returns the namespace of the node if it implements the
+// NodeRegistrar interface.
+func (s *Server) nodeRegistrar(nid *ua.NodeID) (NodeRegistrar, bool) {
+	ns, err := s.Namespace(int(nid.Namespace()))
+	if err != nil {
+		return nil, false
+	}
+	nr, ok := ns.(NodeRegistrar)
+	return nr, ok
+}
+
+// unregisterNodes calls the hooks of the namespaces of registered nodes.
+func (s *Server) unregisterNodes(nids ...*ua.NodeID) {
+	for _, nid := range nids {
+		if nr, ok := s.nodeRegistrar(nid); ok {
+			nr.UnregisterNode(nid)
+		}
+	}
+}
+
+// isSubtype reports whether the type is the super type or one of its
+// subtypes.
+func (s *Server) isSubtype(typeID, superID *ua.NodeID) bool {
+	// the depth limit guards against loops in the type hierarchy.
+	for depth := 0; typeID != nil && depth < 100; depth++ {
+		if typeID.Equal(superID) {
+			return true
+		}
+		typeID = s.superType(typeID)
+	}
+	return false
+}
+
+// superType returns the super type of a type or nil if the type is
+// unknown or has no super type.
+func (s *Server) superType(typeID *ua.NodeID) *ua.NodeID {
+	n := s.Node(typeID)
+	if n == nil {
+		return nil
+	}
+	for _, ref := range n.references() {
+		if !ref.IsForward && ref.NodeID != nil && ref.ReferenceTypeID.Equal(hasSubtype) {
+			return ref.NodeID.NodeID
+		}
+	}
+	return nil
+}
+
+// checkReferenceType checks that the node id is the id of a reference type.
+func (s *Server) checkReferenceType(nid *ua.NodeID) ua.StatusCode {
+	n := s.nodeOrNil(nid)
+	if n == nil || n.NodeClass() != ua.NodeClassReferenceType {
+		return ua.StatusBadReferenceTypeIDInvalid
+	}
+	return ua.StatusOK
+}
+
+// newNodeID returns an unused node id in the namespace with the given
+// index. Namespaces which provide a GetNextNodeID method get numeric node
+// ids and all others random GUID node ids.
+func (s *Server) newNodeID(ns NodeManager, idx uint16) *ua.NodeID {
+	for {
+		var nid *ua.NodeID
+		if g, ok := ns.(interface{ GetNextNodeID() uint32 }); ok {
+			nid = ua.NewNumericNodeID(idx, g.GetNextNodeID())
+		} else {
+			nid = ua.NewGUIDNodeID(idx, uuid.NewString())
+		}
+		if s.Node(nid) == nil {
+			return nid
+		}
+	}
+}
+
+// modelChanged reports a GeneralModelChangeEvent for changes of the
+// address space made with the Node Management services.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/9.32
+func (s *Server) modelChanged(changes []*ua.ModelChangeStructureDataType) {
+	if len(changes) == 0 {
+		return
+	}
+	eos := make([]*ua.ExtensionObject, len(changes))
+	for i, c := range changes {
+		eos[i] = ua.NewExtensionObject(c)
+	}
+	now := time.Now()
+	s.reportEvent(ua.NewNumericNodeID(0, id.Server), &Event{Fields: map[string]*ua.Variant{
+		"EventId":     ua.MustVariant(newEventID()),
+		"EventType":   ua.MustVariant(ua.NewNumericNodeID(0, id.GeneralModelChangeEventType)),
+		"SourceNode":  ua.MustVariant(ua.NewNumericNodeID(0, id.Server)),
+		"SourceName":  ua.MustVariant("Server"),
+		"Time":        ua.MustVariant(now),
+		"ReceiveTime": ua.MustVariant(now),
+		"Message":     ua.MustVariant(&ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: "The address space has changed"}),
+		"Severity":    ua.MustVariant(uint16(1)),
+		"Changes":     ua.MustVariant(eos),
+	}})
+}
+
+// FireEvent reports an event of the given type which originates from the
+// source node. The fields are indexed by their browse path relative to the
+// event type as described for Event. EventId, EventType, SourceNode,
+// SourceName, Time and ReceiveTime are filled in if they are missing.
+//
+// The event is reported by the source node and all event notifiers which
+// reach the source with inverse HasEventSource or HasNotifier references,
+// and always by the Server object.
+func (s *Server) FireEvent(source, eventType *ua.NodeID, fields map[string]*ua.Variant) (*Event, error) {
+	n := s.nodeOrNil(source)
+	if n == nil {
+		return nil, ua.StatusBadNodeIDUnknown
+	}
+	if eventType == nil || !s.isSubtype(eventType, baseEventType) {
+		return nil, ua.StatusBadTypeDefinitionInvalid
+	}
+
+	ev := &Event{Fields: maps.Clone(fields)}
+	if ev.Fields == nil {
+		ev.Fields = map[string]*ua.Variant{}
+	}
+	now := time.Now()
+	defaults := map[string]any{
+		"EventId":     newEventID(),
+		"EventType":   eventType,
+		"SourceNode":  source,
+		"SourceName":  n.BrowseName().Name,
+		"Time":        now,
+		"ReceiveTime": now,
+	}
+	for k, v := range defaults {
+		if ev.Fields[k] == nil {
+			ev.Fields[k] = ua.MustVariant(v)
+		}
+	}
+
+	for _, notifier := range s.eventNotifiers(source) {
+		s.reportEvent(notifier, ev)
+	}
+	return ev, nil
+}
+
+// eventNotifiers returns the source node and the event notifiers which
+// report the events of the source. The Server object is always the last
+// one.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/7.16
+func (s *Server) eventNotifiers(source *ua.NodeID) []*ua.NodeID {
+	hasEventSource := ua.NewNumericNodeID(0, id.HasEventSource)
+	server := ua.NewNumericNodeID(0, id.Server)
+
+	var notifiers []*ua.NodeID
+	seen := map[string]bool{server.String(): true}
+	queue := []*ua.NodeID{source}
+	for len(queue) > 0 {
+		nid := queue[0]
+		queue = queue[1:]
+		if seen[nid.String()] {
+			continue
+		}
+		seen[nid.String()] = true
+		notifiers = append(notifiers, nid)
+
+		n := s.Node(nid)
+		if n == nil {
+			continue
+		}
+		for _, ref := range n.references() {
+			if ref.IsForward || !s.isSubtype(ref.ReferenceTypeID, hasEventSource) {
+				continue
+			}
+			if parent := s.localNodeID(ref.NodeID); parent != nil {
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return append(notifiers, server)
+}
+
+// reportEvent reports an event of an event notifier to the monitored items
+// of the notifier. The event is also recorded in the history store of the
+// server if there is one.
+func (s *Server) reportEvent(notifier *ua.NodeID, ev *Event) {
+	if s.MonitoredItemService != nil {
+		s.MonitoredItemService.reportEvent(notifier, ev)
+	}
+	if s.cfg.history == nil {
+		return
+	}
+	if err := s.cfg.history.RecordEvent(notifier, ev); err != nil && s.cfg.logger != nil {
+		s.cfg.logger.Warn("error recording event of %s: %s", notifier, err)
+	}
+}
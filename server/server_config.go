@@ -5,6 +5,8 @@
 package server
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"fmt"
 	"log"
@@ -12,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
 	"github.com/gopcua/opcua/uapolicy"
 	"github.com/gopcua/opcua/uasc"
 )
@@ -54,6 +57,36 @@ func Certificate(cert []byte) Option {
 	}
 }
 
+// ApplicationCertificate sets the certificate and the private key of the
+// server from an application instance certificate, e.g. from
+// uacert.LoadOrCreate, and detects and sets the ApplicationURI from the URI
+// within the certificate. A certificate with an ECDSA key can only be used
+// with the ECC security policies and Start fails if RSA security policies
+// are enabled.
+func ApplicationCertificate(c *uacert.Certificate) Option {
+	return func(s *serverConfig) {
+		switch key := c.PrivateKey.(type) {
+		case *rsa.PrivateKey:
+			PrivateKey(key)(s)
+		case *ecdsa.PrivateKey:
+			// ECDSA keys are used with the ECC security policies.
+			s.ecKey = key
+		default:
+			log.Printf("error setting the application certificate, %T is unsupported", c.PrivateKey)
+			return
+		}
+		Certificate(c.Certificate)(s)
+	}
+}
+
+// localKey returns the private key of the certificate of the server.
+func (s *serverConfig) localKey() crypto.PrivateKey {
+	if s.ecKey != nil {
+		return s.ecKey
+	}
+	return s.privateKey
+}
+
 // EnableSecurity registers a new endpoint security mode to the server.
 // This will also register the security policy against each enabled auth mode
 func EnableSecurity(secPolicy string, secMode ua.MessageSecurityMode) Option {
@@ -95,7 +128,9 @@ func EnableSecurity(secPolicy string, secMode ua.MessageSecurityMode) Option {
 
 // EnableAuthMode registers a new user authentication mode to the server.
 // All AuthModes except Anonymous require encryption by default, so EnableSecurity()
-// must also be called with at least one non-"None" SecurityPolicy
+// must also be called with at least one non-"None" SecurityPolicy.
+// Users other than anonymous users are accepted only by the Authenticator
+// set with SetAuthenticator.
 func EnableAuthMode(tokenType ua.UserTokenType) Option {
 	return func(s *serverConfig) {
 
@@ -148,6 +183,156 @@ func SoftwareVersion(name string) Option {
 	}
 }
 
+// SetHistoryStore sets the history store which records the values of
+// historizing variables and which answers HistoryRead requests.
+func SetHistoryStore(store HistoryStore) Option {
+	return func(s *serverConfig) {
+		s.history = store
+	}
+}
+
+// SetAuthenticator sets the authenticator which checks the users of
+// sessions. Without an authenticator only anonymous users are accepted.
+func SetAuthenticator(a Authenticator) Option {
+	return func(s *serverConfig) {
+		s.authenticator = a
+	}
+}
+
+// RoleIdentities adds identity mapping rules to a role. The users of
+// sessions which match one of the rules have the role. By default the
+// Anonymous role maps anonymous users and the AuthenticatedUser role maps
+// all other users.
+//
+// https://reference.opcfoundation.org/Core/Part18/v105/docs/4.4.2
+func RoleIdentities(role *ua.NodeID, rules ...*ua.IdentityMappingRuleType) Option {
+	return func(s *serverConfig) {
+		for _, r := range s.roles {
+			if r.role.String() == role.String() {
+				r.rules = append(r.rules, rules...)
+				return
+			}
+		}
+		s.roles = append(s.roles, &roleIdentities{role: role, rules: rules})
+	}
+}
+
+// DefaultRolePermissions sets the role permissions of nodes without
+// RolePermissions attribute. Without role permissions all users have all
+// permissions for a node.
+func DefaultRolePermissions(perms ...*ua.RolePermissionType) Option {
+	return func(s *serverConfig) {
+		s.defaultRolePermissions = perms
+	}
+}
+
+// TrustStore validates the certificates of the clients with the store in
+// OpenSecureChannel and CreateSession if the security mode is not None.
+// The application uri of a client must also match the uri in its
+// certificate. Without a trust store all certificates are accepted.
+func TrustStore(store *uacert.Store) Option {
+	return func(s *serverConfig) {
+		s.trustStore = store
+	}
+}
+
+// ImplicitNumericConversion enables the conversion of numbers which are
+// written to variables of another numeric data type, e.g. a Double which
+// is written to an Int32 variable. Without it such writes fail with
+// BadTypeMismatch. Numbers which are out of the range of the data type
+// are never converted.
+func ImplicitNumericConversion() Option {
+	return func(s *serverConfig) {
+		s.implicitNumericConversion = true
+	}
+}
+
+// NodeManagement enables the Node Management services for the namespaces
+// with the given names. The namespaces must implement the NodeManager
+// interface. Namespace 0 can never be changed by clients.
+func NodeManagement(namespaces ...string) Option {
+	return func(s *serverConfig) {
+		s.nodeManagement = append(s.nodeManagement, namespaces...)
+	}
+}
+
+// MaxBrowseContinuationPoints sets the maximum number of continuation
+// points for Browse per session. Zero means no limit.
+func MaxBrowseContinuationPoints(n uint16) Option {
+	return func(s *serverConfig) {
+		s.cap.MaxBrowseContinuationPoints = n
+	}
+}
+
+// MaxQueryContinuationPoints sets the maximum number of continuation
+// points for QueryFirst per session. Zero means no limit.
+func MaxQueryContinuationPoints(n uint16) Option {
+	return func(s *serverConfig) {
+		s.cap.MaxQueryContinuationPoints = n
+	}
+}
+
+// MaxReferencesPerNode sets the maximum number of references the server
+// returns per node in a single Browse or BrowseNext response. Zero means
+// no limit.
+func MaxReferencesPerNode(n uint32) Option {
+	return func(s *serverConfig) {
+		s.cap.OperationalLimits.MaxReferencesPerNode = n
+	}
+}
+
+// RetransmissionQueueSize sets the maximum number of notification messages
+// per subscription which the server keeps for Republish until the client
+// acknowledges them. The oldest message is discarded when the queue is
+// full. Zero disables the retransmission of messages.
+func RetransmissionQueueSize(n int) Option {
+	return func(s *serverConfig) {
+		s.retransmissionQueueSize = n
+	}
+}
+
+// MinSamplingInterval sets the shortest sampling interval of a monitored
+// item. Shorter requested intervals are revised to it.
+func MinSamplingInterval(d time.Duration) Option {
+	return func(s *serverConfig) {
+		s.cap.MinSupportedSampleRate = float64(d) / float64(time.Millisecond)
+	}
+}
+
+// MaxSamplingInterval sets the longest sampling interval of a monitored
+// item. Longer requested intervals are revised to it.
+func MaxSamplingInterval(d time.Duration) Option {
+	return func(s *serverConfig) {
+		s.maxSamplingInterval = d
+	}
+}
+
+// MaxSessions sets the maximum number of sessions. CreateSession fails
+// with BadTooManySessions when the server has that many sessions. Zero
+// means no limit.
+func MaxSessions(n uint32) Option {
+	return func(s *serverConfig) {
+		s.cap.MaxSessions = n
+	}
+}
+
+// MaxRegisteredNodes sets the maximum number of registered nodes per
+// session. RegisterNodes fails with BadTooManyOperations when the session
+// would have more registered nodes. Zero means no limit.
+func MaxRegisteredNodes(n uint32) Option {
+	return func(s *serverConfig) {
+		s.cap.MaxRegisteredNodes = n
+	}
+}
+
+// MaxMonitoredItemsQueueSize sets the maximum queue size of a monitored
+// item. Larger requested queue sizes are revised to it.
+func MaxMonitoredItemsQueueSize(n uint32) Option {
+	return func(s *serverConfig) {
+		s.cap.MaxMonitoredItemsQueueSize = n
+	}
+}
+
 // this logger interface is used to allow the user to provide their own logger
 // it is compatible with slog.Logger
 type Logger interface {
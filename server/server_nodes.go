@@ -50,6 +50,60 @@ func ServerCapabilitiesNodes(s *Server) []*Node {
 		nil,
 		func() *ua.DataValue { return DataValueFromValue(s.cfg.cap.OperationalLimits.MaxNodesPerRead) },
 	))
+	nodes = append(nodes, NewNode(
+		ua.NewNumericNodeID(0, id.Server_ServerCapabilities_MaxBrowseContinuationPoints),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: DataValueFromValue(attrs.BrowseName("MaxBrowseContinuationPoints")),
+			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
+		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(s.cfg.cap.MaxBrowseContinuationPoints) },
+	))
+	nodes = append(nodes, NewNode(
+		ua.NewNumericNodeID(0, id.Server_ServerCapabilities_MaxQueryContinuationPoints),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: DataValueFromValue(attrs.BrowseName("MaxQueryContinuationPoints")),
+			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
+		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(s.cfg.cap.MaxQueryContinuationPoints) },
+	))
+	nodes = append(nodes, NewNode(
+		ua.NewNumericNodeID(0, id.Server_ServerCapabilities_MaxHistoryContinuationPoints),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: DataValueFromValue(attrs.BrowseName("MaxHistoryContinuationPoints")),
+			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
+		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(s.cfg.cap.MaxHistoryContinuationPoints) },
+	))
+	nodes = append(nodes, NewNode(
+		ua.NewNumericNodeID(0, id.Server_ServerCapabilities_MinSupportedSampleRate),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: DataValueFromValue(attrs.BrowseName("MinSupportedSampleRate")),
+			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
+		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(s.cfg.cap.MinSupportedSampleRate) },
+	))
+	nodes = append(nodes, NewNode(
+		ua.NewNumericNodeID(0, id.Server_ServerCapabilities_MaxMonitoredItemsQueueSize),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: DataValueFromValue(attrs.BrowseName("MaxMonitoredItemsQueueSize")),
+			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
+		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(s.cfg.cap.MaxMonitoredItemsQueueSize) },
+	))
+	nodes = append(nodes, NewNode(
+		ua.NewNumericNodeID(0, id.Server_ServerCapabilities_MaxSessions),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: DataValueFromValue(attrs.BrowseName("MaxSessions")),
+			ua.AttributeIDNodeClass:  DataValueFromValue(uint32(ua.NodeClassVariable)),
+		},
+		nil,
+		func() *ua.DataValue { return DataValueFromValue(s.cfg.cap.MaxSessions) },
+	))
 	return nodes
 }
 
@@ -111,7 +111,9 @@ func (s *Server) handleService(ctx context.Context, sc *uasc.SecureChannel, reqI
 	typeID := ua.ServiceTypeID(req)
 	h, ok := s.handlers[typeID]
 	if ok {
-		resp, err = h(sc, req, reqID)
+		if err = s.checkSession(sc, req); err == nil {
+			resp, err = h(sc, req, reqID)
+		}
 	} else {
 		if typeID == 0 {
 			if s.cfg.logger != nil {
@@ -141,6 +143,41 @@ func (s *Server) handleService(ctx context.Context, sc *uasc.SecureChannel, reqI
 	}
 }
 
+// checkSession checks that the session of a request was activated on the
+// secure channel of the request and records the activity of the session.
+// The discovery services and CreateSession do not need a session and a
+// session is activated and moved to another secure channel with
+// ActivateSession.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.6.1
+func (s *Server) checkSession(sc *uasc.SecureChannel, req ua.Request) error {
+	switch req.(type) {
+	case *ua.FindServersRequest, *ua.FindServersOnNetworkRequest, *ua.GetEndpointsRequest,
+		*ua.RegisterServerRequest, *ua.RegisterServer2Request, *ua.CreateSessionRequest:
+		return nil
+	}
+
+	if req.Header() == nil {
+		return ua.StatusBadSessionIDInvalid
+	}
+	sess := s.sb.Session(req.Header().AuthenticationToken)
+	if sess == nil {
+		return ua.StatusBadSessionIDInvalid
+	}
+
+	_, activate := req.(*ua.ActivateSessionRequest)
+	_, closing := req.(*ua.CloseSessionRequest)
+	switch ch := sess.secureChannel(); {
+	case activate:
+	case ch == nil && !closing:
+		return ua.StatusBadSessionNotActivated
+	case ch != nil && ch != sc:
+		return ua.StatusBadSecureChannelIDInvalid
+	}
+	sess.touch()
+	return nil
+}
+
 func responseHeader(reqID uint32, statusCode ua.StatusCode) *ua.ResponseHeader {
 	return &ua.ResponseHeader{
 		Timestamp:          time.Now(),
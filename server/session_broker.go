@@ -1,13 +1,17 @@
 package server
 
 import (
-	mrand "math/rand"
+	"bytes"
+	"context"
+	"crypto/rand"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uapolicy"
+	"github.com/gopcua/opcua/uasc"
 )
 
 type session struct {
@@ -18,9 +22,61 @@ type session struct {
 	serverNonce       []byte
 	remoteCertificate []byte
 
+	// channelCertificate is the certificate of the secure channel on
+	// which the session was created.
+	channelCertificate []byte
+
+	// ephemeralKey is the key of the EccEncryptedSecret of the user
+	// identity token in the next ActivateSession request. The server
+	// creates it when the client asks for it.
+	ephemeralKey *uapolicy.EphemeralKey
+
 	PublishRequests chan PubReq
+
+	// mu protects the continuation points and the registered nodes of
+	// the session.
+	mu         sync.Mutex
+	historyCPs map[string]*historyContinuation
+	browseCPs  map[string]*browseContinuation
+	queryCPs   map[string]*queryContinuation
+
+	// registered maps the aliases of registered nodes to their node ids.
+	registered map[string]*ua.NodeID
+	nextAlias  uint32
+
+	// identity is the user of the session and roles are its roles. They
+	// are set when the session is activated and protected by mu.
+	identity *Identity
+	roles    []*ua.NodeID
+
+	// applicationURI is the application uri of the client.
+	applicationURI string
+
+	// statusChanges are the status changes of subscriptions which were
+	// transferred to another session and which have not been published
+	// yet. They are protected by mu.
+	statusChanges []*statusChange
+
+	// channel is the secure channel on which the session was activated.
+	// It is nil until the session is activated. lastActivity is the time
+	// of the last request of the session and timer expires the session
+	// after the session timeout. They are protected by mu.
+	channel      *uasc.SecureChannel
+	lastActivity time.Time
+	timer        *time.Timer
+}
+
+// statusChange is a notification message with a StatusChangeNotification of
+// a subscription.
+type statusChange struct {
+	subID uint32
+	msg   *ua.NotificationMessage
 }
 
+// aliasBase is the first numeric identifier of the aliases of registered
+// nodes. It keeps the aliases out of the range of typical node ids.
+const aliasBase = 0x80000000
+
 type sessionConfig struct {
 	sessionTimeout time.Duration
 }
@@ -32,6 +88,10 @@ type sessionBroker struct {
 	// s contains all sessions watched by the session broker
 	s      map[string]*session
 	logger Logger
+
+	// expired is called with the sessions which expired after they have
+	// been removed from the broker.
+	expired func(*session)
 }
 
 func newSessionBroker(logger Logger) *sessionBroker {
@@ -41,34 +101,88 @@ func newSessionBroker(logger Logger) *sessionBroker {
 	}
 }
 
-func (sb *sessionBroker) NewSession() *session {
+// NewSession creates a session which expires when it has no activity
+// for the session timeout. max is the maximum number of sessions and zero
+// means no limit. It returns StatusBadTooManySessions if there are already
+// max sessions.
+func (sb *sessionBroker) NewSession(timeout time.Duration, max uint32) (*session, error) {
+	// the authentication token is a secret of the client and must not
+	// be guessable.
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
 	s := &session{
+		cfg:             sessionConfig{sessionTimeout: timeout},
 		ID:              ua.NewGUIDNodeID(1, uuid.New().String()),
-		AuthTokenID:     ua.NewNumericNodeID(0, uint32(mrand.Int31())),
+		AuthTokenID:     ua.NewByteStringNodeID(0, token),
 		PublishRequests: make(chan PubReq, 100),
+		lastActivity:    time.Now(),
 	}
 
 	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if max > 0 && len(sb.s) >= int(max) {
+		return nil, ua.StatusBadTooManySessions
+	}
 	sb.s[s.AuthTokenID.String()] = s
-	sb.mu.Unlock()
 
-	return s
+	s.mu.Lock()
+	s.timer = time.AfterFunc(timeout, func() { sb.expire(s) })
+	s.mu.Unlock()
+
+	return s, nil
 }
 
 func (sb *sessionBroker) Close(authToken *ua.NodeID) error {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
 
-	if sb.s[authToken.String()] == nil {
+	s := sb.s[authToken.String()]
+	if s == nil {
 		if sb.logger != nil {
 			sb.logger.Warn("sessionBroker.Close: error looking up session %v", authToken)
 		}
+	} else {
+		s.stopTimer()
 	}
 	delete(sb.s, authToken.String())
 
 	return nil
 }
 
+// expire removes the session if it had no activity for the session
+// timeout. Otherwise, it checks again when the session timeout since the
+// last activity has passed.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.6.2
+func (sb *sessionBroker) expire(s *session) {
+	s.mu.Lock()
+	idle := time.Since(s.lastActivity)
+	if idle < s.cfg.sessionTimeout {
+		s.timer.Reset(s.cfg.sessionTimeout - idle)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	sb.mu.Lock()
+	if sb.s[s.AuthTokenID.String()] != s {
+		sb.mu.Unlock()
+		return
+	}
+	delete(sb.s, s.AuthTokenID.String())
+	sb.mu.Unlock()
+
+	if sb.logger != nil {
+		sb.logger.Info("session %v expired after %v", s.ID, s.cfg.sessionTimeout)
+	}
+	if sb.expired != nil {
+		sb.expired(s)
+	}
+}
+
 func (sb *sessionBroker) Session(authToken *ua.NodeID) *session {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
@@ -82,3 +196,253 @@ func (sb *sessionBroker) Session(authToken *ua.NodeID) *session {
 
 	return s
 }
+
+type sessionKey struct{}
+
+func withSession(ctx context.Context, s *session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+func sessionFromContext(ctx context.Context) *session {
+	s, _ := ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+// SessionID returns the id of the session on whose behalf the server
+// is handling a request, e.g. in a method call. It returns nil if ctx
+// does not carry a session.
+func SessionID(ctx context.Context) *ua.NodeID {
+	s := sessionFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	return s.ID
+}
+
+// addContinuation stores the state c of a service call in the continuation
+// points cps of the session and returns the continuation point for it. max
+// is the maximum number of these continuation points per session.
+func addContinuation[T any](s *session, cps *map[string]T, c T, max int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if *cps == nil {
+		*cps = make(map[string]T)
+	}
+	if max > 0 && len(*cps) >= max {
+		return nil, ua.StatusBadNoContinuationPoints
+	}
+	cp := make([]byte, 16)
+	if _, err := rand.Read(cp); err != nil {
+		return nil, err
+	}
+	(*cps)[string(cp)] = c
+	return cp, nil
+}
+
+// takeContinuation removes the state of a service call from the
+// continuation points cps of the session and returns it. It returns the
+// zero value if the continuation point is unknown.
+func takeContinuation[T any](s *session, cps *map[string]T, cp []byte) T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := (*cps)[string(cp)]
+	delete(*cps, string(cp))
+	return c
+}
+
+// addHistoryContinuation stores the state of a HistoryRead and returns the
+// continuation point for it. max is the maximum number of history
+// continuation points per session.
+func (s *session) addHistoryContinuation(c *historyContinuation, max int) ([]byte, error) {
+	return addContinuation(s, &s.historyCPs, c, max)
+}
+
+// takeHistoryContinuation removes the state of a HistoryRead from the
+// session and returns it. It returns nil if the continuation point is
+// unknown.
+func (s *session) takeHistoryContinuation(cp []byte) *historyContinuation {
+	return takeContinuation(s, &s.historyCPs, cp)
+}
+
+// addBrowseContinuation stores the remaining references of a Browse and
+// returns the continuation point for them. max is the maximum number of
+// browse continuation points per session.
+func (s *session) addBrowseContinuation(c *browseContinuation, max int) ([]byte, error) {
+	return addContinuation(s, &s.browseCPs, c, max)
+}
+
+// takeBrowseContinuation removes the remaining references of a Browse from
+// the session and returns them. It returns nil if the continuation point is
+// unknown.
+func (s *session) takeBrowseContinuation(cp []byte) *browseContinuation {
+	return takeContinuation(s, &s.browseCPs, cp)
+}
+
+// addQueryContinuation stores the remaining data sets of a Query and
+// returns the continuation point for them. max is the maximum number of
+// query continuation points per session.
+func (s *session) addQueryContinuation(c *queryContinuation, max int) ([]byte, error) {
+	return addContinuation(s, &s.queryCPs, c, max)
+}
+
+// takeQueryContinuation removes the remaining data sets of a Query from the
+// session and returns them. It returns nil if the continuation point is
+// unknown.
+func (s *session) takeQueryContinuation(cp []byte) *queryContinuation {
+	return takeContinuation(s, &s.queryCPs, cp)
+}
+
+// registerNodes returns new aliases for the node ids in the namespaces of
+// the nodes. inUse reports whether an alias is already the id of a node.
+// It returns nil if the session would have more than max registered nodes.
+// Zero means no limit.
+func (s *session) registerNodes(nodeIDs []*ua.NodeID, max int, inUse func(*ua.NodeID) bool) []*ua.NodeID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if max > 0 && len(s.registered)+len(nodeIDs) > max {
+		return nil
+	}
+	if s.registered == nil {
+		s.registered = make(map[string]*ua.NodeID)
+	}
+	aliases := make([]*ua.NodeID, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		for aliases[i] == nil {
+			s.nextAlias++
+			alias := ua.NewNumericNodeID(nodeID.Namespace(), aliasBase+s.nextAlias%aliasBase)
+			if s.registered[alias.String()] != nil || inUse(alias) {
+				continue
+			}
+			s.registered[alias.String()] = nodeID
+			aliases[i] = alias
+		}
+	}
+	return aliases
+}
+
+// unregisterNode removes an alias and returns the node id of the alias. It
+// returns nil if the alias is unknown.
+func (s *session) unregisterNode(alias *ua.NodeID) *ua.NodeID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodeID := s.registered[alias.String()]
+	delete(s.registered, alias.String())
+	return nodeID
+}
+
+// unregisterAll removes all aliases and returns the node ids of the
+// registered nodes.
+func (s *session) unregisterAll() []*ua.NodeID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var nodeIDs []*ua.NodeID
+	for _, nodeID := range s.registered {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	s.registered = nil
+	return nodeIDs
+}
+
+// resolveNodeID returns the node id of a registered node if nodeID is an
+// alias and nodeID otherwise.
+func (s *session) resolveNodeID(nodeID *ua.NodeID) *ua.NodeID {
+	if s == nil || nodeID == nil {
+		return nodeID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.registered[nodeID.String()]; r != nil {
+		return r
+	}
+	return nodeID
+}
+
+// setIdentity sets the identity and the roles of the user of the session.
+func (s *session) setIdentity(identity *Identity, roles []*ua.NodeID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identity = identity
+	s.roles = roles
+}
+
+// touch records the activity of the session which keeps it alive.
+func (s *session) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastActivity = time.Now()
+}
+
+// stopTimer stops the expiry of the session.
+func (s *session) stopTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// secureChannel returns the secure channel on which the session was
+// activated or nil if the session is not activated.
+func (s *session) secureChannel() *uasc.SecureChannel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.channel
+}
+
+// bind binds the session to the secure channel on which it is activated.
+func (s *session) bind(sc *uasc.SecureChannel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channel = sc
+}
+
+// sameUser reports whether both sessions belong to the same user. Sessions
+// of anonymous users must also belong to the same client application which
+// is only known on secure channels. Anonymous sessions on channels without
+// a client certificate never match.
+func (s *session) sameUser(other *session) bool {
+	s.mu.Lock()
+	a := s.identity.key()
+	s.mu.Unlock()
+
+	other.mu.Lock()
+	b := other.identity.key()
+	other.mu.Unlock()
+
+	if a != b {
+		return false
+	}
+	if a != anonymousIdentity {
+		return true
+	}
+	return len(s.channelCertificate) > 0 && bytes.Equal(s.channelCertificate, other.channelCertificate)
+}
+
+// addStatusChange queues the status change of a subscription until the
+// next publish request of the session.
+func (s *session) addStatusChange(subID uint32, msg *ua.NotificationMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusChanges = append(s.statusChanges, &statusChange{subID: subID, msg: msg})
+}
+
+// takeStatusChange removes the oldest queued status change and returns it.
+// It returns nil if there is none.
+func (s *session) takeStatusChange() *statusChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.statusChanges) == 0 {
+		return nil
+	}
+	c := s.statusChanges[0]
+	s.statusChanges = s.statusChanges[1:]
+	return c
+}
@@ -1,12 +1,14 @@
 package server
 
 import (
+	"bytes"
 	"crypto/rand"
 	"log"
 	"strings"
 	"time"
 
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uapolicy"
 	"github.com/gopcua/opcua/uasc"
 )
 
@@ -36,13 +38,20 @@ func (s *SessionService) CreateSession(sc *uasc.SecureChannel, r ua.Request, req
 		return nil, err
 	}
 
-	// New session
-	sess := s.srv.sb.NewSession()
+	if err := s.srv.verifyClientCertificate(sc, req); err != nil {
+		return nil, err
+	}
 
 	// Ensure session timeout is reasonable
-	sess.cfg.sessionTimeout = time.Duration(req.RequestedSessionTimeout) * time.Millisecond
-	if sess.cfg.sessionTimeout > sessionTimeoutMax || sess.cfg.sessionTimeout < sessionTimeoutMin {
-		sess.cfg.sessionTimeout = sessionTimeoutDefault
+	timeout := time.Duration(req.RequestedSessionTimeout) * time.Millisecond
+	if timeout > sessionTimeoutMax || timeout < sessionTimeoutMin {
+		timeout = sessionTimeoutDefault
+	}
+
+	// New session
+	sess, err := s.srv.sb.NewSession(timeout, s.srv.cfg.cap.MaxSessions)
+	if err != nil {
+		return nil, err
 	}
 
 	nonce := make([]byte, sessionNonceLength)
@@ -52,6 +61,14 @@ func (s *SessionService) CreateSession(sc *uasc.SecureChannel, r ua.Request, req
 	}
 	sess.serverNonce = nonce
 	sess.remoteCertificate = req.ClientCertificate
+	sess.channelCertificate = sc.RemoteCertificate()
+	ecdhKey, err := newEphemeralKey(sc, sess, req.RequestHeader)
+	if err != nil {
+		return nil, err
+	}
+	if req.ClientDescription != nil {
+		sess.applicationURI = req.ClientDescription.ApplicationURI
+	}
 
 	sig, alg, err := sc.NewSessionSignature(req.ClientCertificate, req.ClientNonce)
 	if err != nil {
@@ -83,6 +100,7 @@ func (s *SessionService) CreateSession(sc *uasc.SecureChannel, r ua.Request, req
 		ServerNonce:       nonce,
 		ServerEndpoints:   matching_endpoints,
 	}
+	response.ResponseHeader.AdditionalHeader = ecdhKey
 
 	return response, nil
 }
@@ -111,12 +129,42 @@ func (s *SessionService) ActivateSession(sc *uasc.SecureChannel, r ua.Request, r
 		return nil, ua.StatusBadSecurityChecksFailed
 	}
 
+	// the user identity token is checked with the last nonce
+	identity, err := s.srv.authenticate(sc, sess, req, sess.serverNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// the session can only be activated on secure channels of the client
+	// which created it.
+	if !bytes.Equal(sc.RemoteCertificate(), sess.channelCertificate) {
+		return nil, ua.StatusBadSecurityChecksFailed
+	}
+
+	// a session which moves to another secure channel must keep its user.
+	if ch := sess.secureChannel(); ch != nil && ch != sc {
+		sess.mu.Lock()
+		user := sess.identity.key()
+		sess.mu.Unlock()
+		if identity.key() != user {
+			return nil, ua.StatusBadIdentityChangeNotSupported
+		}
+	}
+
 	nonce := make([]byte, sessionNonceLength)
 	if _, err := rand.Read(nonce); err != nil {
 		log.Printf("error creating session nonce")
 		return nil, ua.StatusBadInternalError
 	}
 	sess.serverNonce = nonce
+	// the ephemeral key is used only once.
+	sess.ephemeralKey = nil
+	ecdhKey, err := newEphemeralKey(sc, sess, req.RequestHeader)
+	if err != nil {
+		return nil, err
+	}
+	sess.setIdentity(identity, s.srv.userRoles(identity, sess.applicationURI))
+	sess.bind(sc)
 
 	response := &ua.ActivateSessionResponse{
 		ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
@@ -124,10 +172,39 @@ func (s *SessionService) ActivateSession(sc *uasc.SecureChannel, r ua.Request, r
 		// Results:         []ua.StatusCode{},
 		// DiagnosticInfos: []*ua.DiagnosticInfo{},
 	}
+	response.ResponseHeader.AdditionalHeader = ecdhKey
 
 	return response, nil
 }
 
+// newEphemeralKey creates the ephemeral key of the session for the
+// EccEncryptedSecret of the next ActivateSession request when the client
+// asks for it with the ECDHPolicyUri parameter in the additional header of
+// the request. It returns the additional header of the response with the
+// signed public key or nil.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.4
+func newEphemeralKey(sc *uasc.SecureChannel, sess *session, h *ua.RequestHeader) (*ua.ExtensionObject, error) {
+	if h == nil {
+		return nil, nil
+	}
+	v := ua.AdditionalParameter(h.AdditionalHeader, ua.ECDHPolicyURIParameter)
+	if v == nil {
+		return nil, nil
+	}
+	uri, _ := v.Value().(string)
+	if !uapolicy.IsECC(uri) {
+		return nil, ua.StatusBadSecurityPolicyRejected
+	}
+	key, ephemeralKey, err := sc.NewEphemeralKey(uri)
+	if err != nil {
+		log.Printf("error creating ephemeral key: %s", err)
+		return nil, ua.StatusBadInternalError
+	}
+	sess.ephemeralKey = key
+	return ua.NewAdditionalParameter(ua.ECDHKeyParameter, ua.MustVariant(ua.NewExtensionObject(ephemeralKey))), nil
+}
+
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.6.4
 func (s *SessionService) CloseSession(sc *uasc.SecureChannel, r ua.Request, reqID uint32) (ua.Response, error) {
 	if s.srv.cfg.logger != nil {
@@ -139,12 +216,15 @@ func (s *SessionService) CloseSession(sc *uasc.SecureChannel, r ua.Request, reqI
 		return nil, err
 	}
 
+	if sess := s.srv.Session(req.RequestHeader); sess != nil {
+		s.srv.releaseSession(sess, req.DeleteSubscriptions)
+	}
+
 	err = s.srv.sb.Close(req.RequestHeader.AuthenticationToken)
 	if err != nil {
 		return nil, ua.StatusBadSessionIDInvalid
 	}
 
-	//TODO: deal with 'delete subscriptions' field in request
 	response := &ua.CloseSessionResponse{
 		ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
 	}
@@ -152,6 +232,16 @@ func (s *SessionService) CloseSession(sc *uasc.SecureChannel, r ua.Request, reqI
 	return response, nil
 }
 
+// releaseSession releases the registered nodes of a closed session.
+// Subscriptions which are not deleted stay alive until their lifetime
+// expires and can be transferred to another session.
+func (s *Server) releaseSession(sess *session, deleteSubscriptions bool) {
+	s.unregisterNodes(sess.unregisterAll()...)
+	if deleteSubscriptions && s.SubscriptionService != nil {
+		s.SubscriptionService.deleteSessionSubscriptions(sess)
+	}
+}
+
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.6.5
 func (s *SessionService) Cancel(sc *uasc.SecureChannel, r ua.Request, reqID uint32) (ua.Response, error) {
 	if s.srv.cfg.logger != nil {
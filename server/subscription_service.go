@@ -158,8 +158,17 @@ func (s *SubscriptionService) Publish(sc *uasc.SecureChannel, r ua.Request, reqI
 		return response, nil
 	}
 
+	// the acknowledgements are processed now since the publish request
+	// may be answered by any subscription of the session.
+	pubreq := PubReq{Req: req, ID: reqID, Results: s.acknowledge(session, req.SubscriptionAcknowledgements)}
+
+	// the status changes of transferred subscriptions are published first.
+	if c := session.takeStatusChange(); c != nil {
+		return statusChangeResponse(pubreq, c), nil
+	}
+
 	select {
-	case session.PublishRequests <- PubReq{Req: req, ID: reqID}:
+	case session.PublishRequests <- pubreq:
 	default:
 		if s.srv.cfg.logger != nil {
 			s.srv.cfg.logger.Warn("Too many publish reqs.")
@@ -181,7 +190,59 @@ func (s *SubscriptionService) Republish(sc *uasc.SecureChannel, r ua.Request, re
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	session := s.srv.Session(req.RequestHeader)
+	if session == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	fail := func(status ua.StatusCode) (ua.Response, error) {
+		return &ua.RepublishResponse{
+			ResponseHeader:      responseHeader(req.RequestHeader.RequestHandle, status),
+			NotificationMessage: &ua.NotificationMessage{NotificationData: []*ua.ExtensionObject{}},
+		}, nil
+	}
+
+	sub := s.sessionSubscription(session, req.SubscriptionID)
+	if sub == nil {
+		return fail(ua.StatusBadSubscriptionIDInvalid)
+	}
+	msg := sub.republish(req.RetransmitSequenceNumber)
+	if msg == nil {
+		return fail(ua.StatusBadMessageNotAvailable)
+	}
+	return &ua.RepublishResponse{
+		ResponseHeader:      responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		NotificationMessage: msg,
+	}, nil
+}
+
+// sessionSubscription returns the subscription with the given id if it
+// belongs to the session.
+func (s *SubscriptionService) sessionSubscription(session *session, id uint32) *Subscription {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	sub, ok := s.Subs[id]
+	if !ok || sub.session() == nil || sub.session().AuthTokenID.String() != session.AuthTokenID.String() {
+		return nil
+	}
+	return sub
+}
+
+// acknowledge removes the acknowledged notification messages from the
+// retransmission queues of the subscriptions of the session.
+func (s *SubscriptionService) acknowledge(session *session, acks []*ua.SubscriptionAcknowledgement) []ua.StatusCode {
+	results := make([]ua.StatusCode, len(acks))
+	for i, ack := range acks {
+		sub := s.sessionSubscription(session, ack.SubscriptionID)
+		if sub == nil {
+			results[i] = ua.StatusBadSubscriptionIDInvalid
+			continue
+		}
+		results[i] = sub.acknowledge(ack.SequenceNumber)
+	}
+	return results
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.13.7
@@ -194,8 +255,113 @@ func (s *SubscriptionService) TransferSubscriptions(sc *uasc.SecureChannel, r ua
 	if err != nil {
 		return nil, err
 	}
-	// When this gets implemented, be sure to check the subscription session vs the request session!
-	return serviceUnsupported(req.RequestHeader), nil
+
+	session := s.srv.Session(req.RequestHeader)
+	if session == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.SubscriptionIDs) == 0 {
+		return &ua.TransferSubscriptionsResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	results := make([]*ua.TransferResult, len(req.SubscriptionIDs))
+	for i, id := range req.SubscriptionIDs {
+		results[i] = s.transfer(session, sc, id, req.SendInitialValues)
+	}
+	return &ua.TransferSubscriptionsResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// transfer moves a subscription to the session. The subscription may
+// belong to another session of the same user or to a session which has
+// been closed or lost while the subscription is still alive. The previous
+// session gets a StatusChangeNotification.
+func (s *SubscriptionService) transfer(session *session, sc *uasc.SecureChannel, id uint32, sendInitialValues bool) *ua.TransferResult {
+	res := &ua.TransferResult{AvailableSequenceNumbers: []uint32{}}
+
+	s.Mu.Lock()
+	sub, ok := s.Subs[id]
+	s.Mu.Unlock()
+	if !ok {
+		res.StatusCode = ua.StatusBadSubscriptionIDInvalid
+		return res
+	}
+	if old := sub.session(); old != session {
+		if old == nil || !session.sameUser(old) {
+			res.StatusCode = ua.StatusBadUserAccessDenied
+			return res
+		}
+
+		// like a keepalive the status change does not use up a sequence
+		// number so that the new session does not see a gap.
+		old, oldChannel := sub.transfer(session, sc)
+		msg := &ua.NotificationMessage{
+			SequenceNumber: sub.sequenceNumber() + 1,
+			PublishTime:    time.Now(),
+			NotificationData: []*ua.ExtensionObject{
+				ua.NewExtensionObject(&ua.StatusChangeNotification{
+					Status:         ua.StatusGoodSubscriptionTransferred,
+					DiagnosticInfo: &ua.DiagnosticInfo{},
+				}),
+			},
+		}
+		select {
+		case pubreq := <-old.PublishRequests:
+			if err := oldChannel.SendResponseWithContext(context.Background(), pubreq.ID, statusChangeResponse(pubreq, &statusChange{subID: id, msg: msg})); err != nil {
+				if s.srv.cfg.logger != nil {
+					s.srv.cfg.logger.Warn("problem sending status change of subscription %d: %v", id, err)
+				}
+			}
+		default:
+			old.addStatusChange(id, msg)
+		}
+
+		if s.srv.cfg.logger != nil {
+			s.srv.cfg.logger.Info("Subscription %d transferred to session %v", id, session.ID)
+		}
+	}
+
+	if sendInitialValues {
+		go s.srv.MonitoredItemService.InitialValues(id)
+	}
+	res.StatusCode = ua.StatusOK
+	res.AvailableSequenceNumbers = sub.available()
+	return res
+}
+
+// statusChangeResponse returns the response to a publish request with the
+// status change of a subscription.
+func statusChangeResponse(pubreq PubReq, c *statusChange) *ua.PublishResponse {
+	return &ua.PublishResponse{
+		ResponseHeader:           responseHeader(pubreq.Req.RequestHeader.RequestHandle, ua.StatusOK),
+		SubscriptionID:           c.subID,
+		AvailableSequenceNumbers: []uint32{},
+		NotificationMessage:      c.msg,
+		Results:                  publishResults(pubreq),
+		DiagnosticInfos:          []*ua.DiagnosticInfo{},
+	}
+}
+
+// deleteSessionSubscriptions deletes the subscriptions of the session.
+func (s *SubscriptionService) deleteSessionSubscriptions(session *session) {
+	s.Mu.Lock()
+	var ids []uint32
+	for id, sub := range s.Subs {
+		if sub.session() == session {
+			ids = append(ids, id)
+		}
+	}
+	s.Mu.Unlock()
+
+	for _, id := range ids {
+		s.DeleteSubscription(id)
+	}
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.13.8
@@ -225,7 +391,7 @@ func (s *SubscriptionService) DeleteSubscriptions(sc *uasc.SecureChannel, r ua.R
 			results[i] = ua.StatusBadSubscriptionIDInvalid
 			continue
 		}
-		if session.AuthTokenID.String() != sub.Session.AuthTokenID.String() {
+		if session.AuthTokenID.String() != sub.session().AuthTokenID.String() {
 			results[i] = ua.StatusBadSessionIDInvalid
 			continue
 		}
@@ -254,13 +420,20 @@ type PubReq struct {
 
 	// The request ID (from the header) of the publish request.  This has to be used when replying.
 	ID uint32
+
+	// Results are the results of the subscription acknowledgements of the
+	// publish request.
+	Results []ua.StatusCode
 }
 
 // This is the type that with its run() function will work in the bakground fullfilling subscription
 // publishes.
 //
-// MonitoredItems will send updates on the NotifyChannel to let the background task know that
-// an event has occured that needs to be published.
+// MonitoredItems queue their samples until the subscription publishes them. Updates sent on
+// the NotifyChannel are published as well.
+//
+// Session, Channel and SequenceID change when the subscription is transferred to another
+// session. Once the subscription is running they are protected by Mu.
 type Subscription struct {
 	srv                       *SubscriptionService
 	Session                   *session
@@ -270,8 +443,7 @@ type Subscription struct {
 	RevisedMaxKeepAliveCount  uint32
 	Channel                   *uasc.SecureChannel
 	SequenceID                uint32
-	//SeqNums                   map[uint32]struct{}
-	T *time.Ticker
+	T                         *time.Ticker
 
 	NotifyChannel chan *ua.MonitoredItemNotification
 	ModifyChannel chan *ua.ModifySubscriptionRequest
@@ -282,11 +454,15 @@ type Subscription struct {
 	Mu       sync.Mutex
 	running  bool
 	shutdown chan struct{}
+
+	// retransmit holds the sent notification messages which have not
+	// been acknowledged by the client in the order of their sequence
+	// numbers. It is protected by Mu.
+	retransmit []*ua.NotificationMessage
 }
 
 func NewSubscription() *Subscription {
 	return &Subscription{
-		//SeqNums:       map[uint32]struct{}{},
 		NotifyChannel: make(chan *ua.MonitoredItemNotification, 100),
 		ModifyChannel: make(chan *ua.ModifySubscriptionRequest, 2),
 		shutdown:      make(chan struct{}),
@@ -304,11 +480,125 @@ func (s *Subscription) Start() {
 
 }
 
+// session returns the session which owns the subscription.
+func (s *Subscription) session() *session {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	return s.Session
+}
+
+// channel returns the secure channel on which the subscription publishes.
+func (s *Subscription) channel() *uasc.SecureChannel {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	return s.Channel
+}
+
+// transfer moves the subscription to another session and channel and
+// returns the previous ones.
+func (s *Subscription) transfer(sess *session, sc *uasc.SecureChannel) (*session, *uasc.SecureChannel) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	oldSess, oldChannel := s.Session, s.Channel
+	s.Session, s.Channel = sess, sc
+	return oldSess, oldChannel
+}
+
+// sequenceNumber returns the sequence number of the last notification
+// message.
+func (s *Subscription) sequenceNumber() uint32 {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	return s.SequenceID
+}
+
+// nextSequenceNumber returns the sequence number for a new notification
+// message.
+func (s *Subscription) nextSequenceNumber() uint32 {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	s.SequenceID++
+	if s.SequenceID == 0 {
+		// per the spec, the sequence ID cannot be 0
+		s.SequenceID = 1
+	}
+	return s.SequenceID
+}
+
+// queue adds a sent notification message to the retransmission queue and
+// discards the oldest messages if the queue is full.
+func (s *Subscription) queue(msg *ua.NotificationMessage) {
+	max := s.srv.srv.cfg.retransmissionQueueSize
+	if max <= 0 {
+		return
+	}
+
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	s.retransmit = append(s.retransmit, msg)
+	if n := len(s.retransmit); n > max {
+		s.retransmit = append([]*ua.NotificationMessage(nil), s.retransmit[n-max:]...)
+	}
+}
+
+// acknowledge removes a notification message from the retransmission queue.
+func (s *Subscription) acknowledge(seq uint32) ua.StatusCode {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	for i, msg := range s.retransmit {
+		if msg.SequenceNumber == seq {
+			s.retransmit = append(s.retransmit[:i], s.retransmit[i+1:]...)
+			return ua.StatusOK
+		}
+	}
+	return ua.StatusBadSequenceNumberUnknown
+}
+
+// republish returns the notification message with the sequence number or
+// nil if it is not in the retransmission queue.
+func (s *Subscription) republish(seq uint32) *ua.NotificationMessage {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	for _, msg := range s.retransmit {
+		if msg.SequenceNumber == seq {
+			return msg
+		}
+	}
+	return nil
+}
+
+// available returns the sequence numbers of the messages in the
+// retransmission queue.
+func (s *Subscription) available() []uint32 {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	seqs := make([]uint32, len(s.retransmit))
+	for i, msg := range s.retransmit {
+		seqs[i] = msg.SequenceNumber
+	}
+	return seqs
+}
+
+// publishResults returns the results of the acknowledgements of a publish
+// request.
+func publishResults(pubreq PubReq) []ua.StatusCode {
+	if pubreq.Results == nil {
+		return []ua.StatusCode{}
+	}
+	return pubreq.Results
+}
+
 func (s *Subscription) keepalive(pubreq PubReq) error {
 	eo := make([]*ua.ExtensionObject, 0)
 
 	msg := ua.NotificationMessage{
-		SequenceNumber:   s.SequenceID + 1, // not sure why but ua expert wants the next sequence number on keepalives.
+		SequenceNumber:   s.sequenceNumber() + 1, // not sure why but ua expert wants the next sequence number on keepalives.
 		PublishTime:      time.Now(),
 		NotificationData: eo,
 	}
@@ -325,11 +615,11 @@ func (s *Subscription) keepalive(pubreq PubReq) error {
 		SubscriptionID:           s.ID,
 		MoreNotifications:        false,
 		NotificationMessage:      &msg,
-		AvailableSequenceNumbers: []uint32{}, // an empty array indicates taht we don't support retransmission of messages
-		Results:                  []ua.StatusCode{},
+		AvailableSequenceNumbers: s.available(),
+		Results:                  publishResults(pubreq),
 		DiagnosticInfos:          []*ua.DiagnosticInfo{},
 	}
-	err := s.Channel.SendResponseWithContext(context.Background(), pubreq.ID, response)
+	err := s.channel().SendResponseWithContext(context.Background(), pubreq.ID, response)
 	if err != nil {
 		return err
 	}
@@ -379,20 +669,22 @@ func (s *Subscription) run() {
 			case newNotification := <-s.NotifyChannel:
 				publishQueue[newNotification.ClientHandle] = newNotification
 			case <-s.T.C:
-				if len(publishQueue) == 0 {
+				if len(publishQueue) == 0 && !s.srv.srv.MonitoredItemService.hasNotifications(s.ID) {
 					// nothing to publish, increment the keepalive counter and send a keepalive if it
 					// has been enough intervals.
 					keepalive_counter++
 					if keepalive_counter > int(s.RevisedMaxKeepAliveCount) {
 						keepalive_counter = 0
 						select {
-						case pubreq := <-s.Session.PublishRequests:
+						case pubreq := <-s.session().PublishRequests:
+							// the session may have lost its channel. The
+							// subscription stays alive until its lifetime
+							// expires so that it can be transferred.
 							err := s.keepalive(pubreq)
 							if err != nil {
 								if s.srv.srv.cfg.logger != nil {
 									s.srv.srv.cfg.logger.Warn("problem sending keepalive to subscription #%d: %v", s.ID, err)
 								}
-								return
 							}
 						default:
 							lifetime_counter++
@@ -420,7 +712,7 @@ func (s *Subscription) run() {
 			select {
 			case <-s.shutdown:
 				return
-			case pubreq = <-s.Session.PublishRequests:
+			case pubreq = <-s.session().PublishRequests:
 				// once we get a publish request, we should move on to publish them back
 				break L2
 			case newNotification := <-s.NotifyChannel:
@@ -440,42 +732,50 @@ func (s *Subscription) run() {
 		lifetime_counter = 0
 		keepalive_counter = 0
 
-		s.SequenceID++
-		if s.SequenceID == 0 {
-			// per the spec, the sequence ID cannot be 0
-			s.SequenceID = 1
+		// the queued samples of the monitored items. They may be gone if
+		// the monitoring mode has changed in the meantime.
+		items, events := s.srv.srv.MonitoredItemService.notifications(s.ID)
+		if len(publishQueue) == 0 && len(items) == 0 && len(events) == 0 {
+			if err := s.keepalive(pubreq); err != nil {
+				if s.srv.srv.cfg.logger != nil {
+					s.srv.srv.cfg.logger.Warn("problem sending keepalive to subscription #%d: %v", s.ID, err)
+				}
+			}
+			continue
 		}
+
+		seq := s.nextSequenceNumber()
 		if s.srv.srv.cfg.logger != nil {
-			s.srv.srv.cfg.logger.Debug("Got publish req on sub #%d.  Sequence %d", s.ID, s.SequenceID)
+			s.srv.srv.cfg.logger.Debug("Got publish req on sub #%d.  Sequence %d", s.ID, seq)
 		}
 		// then get all the tags and send them back to the client
-
-		//for x := range pubreq.Req.SubscriptionAcknowledgements {
-		//a := pubreq.Req.SubscriptionAcknowledgements[x]
-		//delete(s.SeqNums, a.SequenceNumber)
-		//}
-
-		final_items := make([]*ua.MonitoredItemNotification, len(publishQueue))
-		i := 0
+		final_items := make([]*ua.MonitoredItemNotification, 0, len(publishQueue))
 		for k := range publishQueue {
-			final_items[i] = publishQueue[k]
-			i++
+			final_items = append(final_items, publishQueue[k])
 		}
-
-		dcn := ua.DataChangeNotification{
-			MonitoredItems:  final_items,
-			DiagnosticInfos: []*ua.DiagnosticInfo{},
+		final_items = append(final_items, items...)
+
+		var eo []*ua.ExtensionObject
+		if len(final_items) > 0 {
+			eo = append(eo, ua.NewExtensionObject(&ua.DataChangeNotification{
+				MonitoredItems:  final_items,
+				DiagnosticInfos: []*ua.DiagnosticInfo{},
+			}))
+		}
+		if len(events) > 0 {
+			eo = append(eo, ua.NewExtensionObject(&ua.EventNotificationList{Events: events}))
+		}
+		for _, e := range eo {
+			e.UpdateMask()
 		}
-		eo := make([]*ua.ExtensionObject, 1)
-		eo[0] = ua.NewExtensionObject(&dcn)
-		eo[0].UpdateMask()
 
 		msg := ua.NotificationMessage{
-			SequenceNumber:   s.SequenceID,
+			SequenceNumber:   seq,
 			PublishTime:      time.Now(),
 			NotificationData: eo,
 		}
-		//s.SeqNums[s.SequenceID] = struct{}{}
+		// keep the message until the client acknowledges it.
+		s.queue(&msg)
 
 		response := &ua.PublishResponse{
 			ResponseHeader: &ua.ResponseHeader{
@@ -489,20 +789,21 @@ func (s *Subscription) run() {
 			SubscriptionID:           s.ID,
 			MoreNotifications:        false,
 			NotificationMessage:      &msg,
-			AvailableSequenceNumbers: []uint32{}, // an empty array indicates taht we don't support retransmission of messages
-			Results:                  []ua.StatusCode{},
+			AvailableSequenceNumbers: s.available(),
+			Results:                  publishResults(pubreq),
 			DiagnosticInfos:          []*ua.DiagnosticInfo{},
 		}
-		err := s.Channel.SendResponseWithContext(context.Background(), pubreq.ID, response)
+		err := s.channel().SendResponseWithContext(context.Background(), pubreq.ID, response)
 		if err != nil {
+			// the message stays in the retransmission queue and can be
+			// republished after the subscription has been transferred.
 			if s.srv.srv.cfg.logger != nil {
-				s.srv.srv.cfg.logger.Error("problem sending channel response: %v", err)
-				s.srv.srv.cfg.logger.Error("Killing subscription %d", s.ID)
+				s.srv.srv.cfg.logger.Warn("problem sending channel response for subscription %d: %v", s.ID, err)
 			}
-			return
+			continue
 		}
 		if s.srv.srv.cfg.logger != nil {
-			s.srv.srv.cfg.logger.Debug("Published %d items OK for %d", len(publishQueue), s.ID)
+			s.srv.srv.cfg.logger.Debug("Published %d items and %d events OK for %d", len(final_items), len(events), s.ID)
 		}
 		// wait till we've got a publish request.
 	}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"math"
 	"slices"
 	"time"
 
@@ -20,6 +21,13 @@ type ViewService struct {
 	srv *Server
 }
 
+// browseContinuation holds the references of a Browse which did not fit
+// into the previous response.
+type browseContinuation struct {
+	refs []*ua.ReferenceDescription
+	max  uint32
+}
+
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.8.2
 func (s *ViewService) Browse(sc *uasc.SecureChannel, r ua.Request, reqID uint32) (ua.Response, error) {
 
@@ -31,6 +39,11 @@ func (s *ViewService) Browse(sc *uasc.SecureChannel, r ua.Request, reqID uint32)
 		s.srv.cfg.logger.Debug("=== Browse incoming")
 	}
 
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
 	resp := &ua.BrowseResponse{
 		ResponseHeader: &ua.ResponseHeader{
 			Timestamp:          time.Now(),
@@ -45,6 +58,12 @@ func (s *ViewService) Browse(sc *uasc.SecureChannel, r ua.Request, reqID uint32)
 		DiagnosticInfos: []*ua.DiagnosticInfo{{}},
 	}
 
+	// the server limit applies when the client requests no or a higher limit.
+	max := req.RequestedMaxReferencesPerNode
+	if limit := s.srv.cfg.cap.OperationalLimits.MaxReferencesPerNode; limit > 0 && (max == 0 || max > limit) {
+		max = limit
+	}
+
 	for i := range req.NodesToBrowse {
 		br := req.NodesToBrowse[i]
 		if s.srv.cfg.logger != nil {
@@ -55,13 +74,35 @@ func (s *ViewService) Browse(sc *uasc.SecureChannel, r ua.Request, reqID uint32)
 			resp.Results[i] = &ua.BrowseResult{StatusCode: ua.StatusBad}
 			continue
 		}
-		resp.Results[i] = ns.Browse(br)
+		if status := s.srv.allowed(sess, br.NodeID, ua.PermissionTypeBrowse); status != ua.StatusOK {
+			resp.Results[i] = &ua.BrowseResult{StatusCode: status}
+			continue
+		}
+		resp.Results[i] = s.page(sess, s.srv.browsable(sess, ns.Browse(br)), max)
 	}
 
 	return resp, nil
 
 }
 
+// page limits the references of res to max and stores the remaining
+// references in a continuation point of the session.
+func (s *ViewService) page(sess *session, res *ua.BrowseResult, max uint32) *ua.BrowseResult {
+	if max == 0 || len(res.References) <= int(max) {
+		return res
+	}
+	next := &browseContinuation{refs: res.References[max:], max: max}
+	cp, err := sess.addBrowseContinuation(next, int(s.srv.cfg.cap.MaxBrowseContinuationPoints))
+	if err != nil {
+		return &ua.BrowseResult{StatusCode: ua.StatusBadNoContinuationPoints}
+	}
+	return &ua.BrowseResult{
+		StatusCode:        res.StatusCode,
+		ContinuationPoint: cp,
+		References:        res.References[:max],
+	}
+}
+
 func suitableRef(srv *Server, desc *ua.BrowseDescription, ref *ua.ReferenceDescription) bool {
 	if !suitableDirection(desc.BrowseDirection, ref.IsForward) {
 		if srv.cfg.logger != nil {
@@ -105,15 +146,11 @@ func suitableRefType(srv *Server, ref1, ref2 *ua.NodeID, subtypes bool) bool {
 	if ref1.Equal(ref2) {
 		return true
 	}
-	hasRef2Fn := func(nid *ua.NodeID) bool { return nid.Equal(ref2) }
-	hasSubtypeFn := func(nid *ua.NodeID) bool { return nid.Equal(hasSubtype) }
-	oktypes := getSubRefs(srv, ref1)
-	if !subtypes && slices.ContainsFunc(oktypes, hasSubtypeFn) {
-		for n := slices.IndexFunc(oktypes, hasSubtypeFn); n > 0; {
-			oktypes = slices.Delete(oktypes, n, n+1)
-		}
+	if !subtypes {
+		return false
 	}
-	return slices.ContainsFunc(oktypes, hasRef2Fn)
+	hasRef2Fn := func(nid *ua.NodeID) bool { return nid.Equal(ref2) }
+	return slices.ContainsFunc(getSubRefs(srv, ref1), hasRef2Fn)
 }
 
 func getSubRefs(srv *Server, nid *ua.NodeID) []*ua.NodeID {
@@ -127,7 +164,7 @@ func getSubRefs(srv *Server, nid *ua.NodeID) []*ua.NodeID {
 	if node == nil {
 		return nil
 	}
-	for _, ref := range node.refs {
+	for _, ref := range node.references() {
 		if ref.ReferenceTypeID.Equal(hasSubtype) && ref.IsForward && ref.NodeID != nil {
 			refs = append(refs, ref.NodeID.NodeID)
 			refs = append(refs, getSubRefs(srv, ref.NodeID.NodeID)...)
@@ -146,7 +183,36 @@ func (s *ViewService) BrowseNext(sc *uasc.SecureChannel, r ua.Request, reqID uin
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.ContinuationPoints) == 0 {
+		return &ua.BrowseNextResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	results := make([]*ua.BrowseResult, len(req.ContinuationPoints))
+	for i, cp := range req.ContinuationPoints {
+		c := sess.takeBrowseContinuation(cp)
+		switch {
+		case c == nil:
+			results[i] = &ua.BrowseResult{StatusCode: ua.StatusBadContinuationPointInvalid}
+		case req.ReleaseContinuationPoints:
+			results[i] = &ua.BrowseResult{StatusCode: ua.StatusOK}
+		default:
+			results[i] = s.page(sess, &ua.BrowseResult{StatusCode: ua.StatusOK, References: c.refs}, c.max)
+		}
+	}
+
+	return &ua.BrowseNextResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.8.4
@@ -159,7 +225,139 @@ func (s *ViewService) TranslateBrowsePathsToNodeIDs(sc *uasc.SecureChannel, r ua
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.BrowsePaths) == 0 {
+		return &ua.TranslateBrowsePathsToNodeIDsResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	results := make([]*ua.BrowsePathResult, len(req.BrowsePaths))
+	for i, p := range req.BrowsePaths {
+		results[i] = s.translateBrowsePath(sess, p)
+	}
+
+	return &ua.TranslateBrowsePathsToNodeIDsResponse{
+		ResponseHeader:  responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		Results:         results,
+		DiagnosticInfos: []*ua.DiagnosticInfo{},
+	}, nil
+}
+
+// translateBrowsePath follows the elements of the relative path of p from
+// its starting node. Targets which are reached with all elements have a
+// RemainingPathIndex of math.MaxUint32. Targets on other servers end the
+// path early and have the index of the first element which still has to be
+// followed. Only nodes which the user of the session is allowed to browse
+// are followed. The server follows paths for itself without a session.
+func (s *ViewService) translateBrowsePath(sess *session, p *ua.BrowsePath) *ua.BrowsePathResult {
+	fail := func(status ua.StatusCode) *ua.BrowsePathResult {
+		return &ua.BrowsePathResult{StatusCode: status, Targets: []*ua.BrowsePathTarget{}}
+	}
+
+	if p.StartingNode == nil {
+		return fail(ua.StatusBadNodeIDInvalid)
+	}
+	if p.RelativePath == nil || len(p.RelativePath.Elements) == 0 {
+		return fail(ua.StatusBadNothingToDo)
+	}
+	elems := p.RelativePath.Elements
+	for i, e := range elems {
+		// only the last element may omit the target name to match all
+		// targets of the reference.
+		if i < len(elems)-1 && (e.TargetName == nil || e.TargetName.Name == "") {
+			return fail(ua.StatusBadBrowseNameInvalid)
+		}
+	}
+	if _, err := s.srv.Namespace(int(p.StartingNode.Namespace())); err != nil {
+		return fail(ua.StatusBadNodeIDUnknown)
+	}
+	if sess != nil {
+		if status := s.srv.allowed(sess, p.StartingNode, ua.PermissionTypeBrowse); status != ua.StatusOK {
+			return fail(status)
+		}
+	}
+
+	var targets []*ua.BrowsePathTarget
+	nodes := []*ua.NodeID{p.StartingNode}
+	for i, e := range elems {
+		var next []*ua.NodeID
+		seen := map[string]bool{}
+		for _, nid := range nodes {
+			res := s.follow(nid, e)
+			if sess != nil {
+				res = s.srv.browsable(sess, res)
+			}
+			if res.StatusCode == ua.StatusBadNodeIDUnknown && i == 0 {
+				return fail(ua.StatusBadNodeIDUnknown)
+			}
+			for _, ref := range res.References {
+				if e.TargetName != nil && e.TargetName.Name != "" && !sameQualifiedName(ref.BrowseName, e.TargetName) {
+					continue
+				}
+				if seen[ref.NodeID.String()] {
+					continue
+				}
+				seen[ref.NodeID.String()] = true
+
+				if ref.NodeID.ServerIndex != 0 {
+					remaining := uint32(i + 1)
+					if i == len(elems)-1 {
+						remaining = math.MaxUint32
+					}
+					targets = append(targets, &ua.BrowsePathTarget{TargetID: ref.NodeID, RemainingPathIndex: remaining})
+					continue
+				}
+				next = append(next, ref.NodeID.NodeID)
+			}
+		}
+		nodes = next
+	}
+	for _, nid := range nodes {
+		targets = append(targets, &ua.BrowsePathTarget{
+			TargetID:           ua.NewExpandedNodeID(nid, "", 0),
+			RemainingPathIndex: math.MaxUint32,
+		})
+	}
+
+	if len(targets) == 0 {
+		return fail(ua.StatusBadNoMatch)
+	}
+	return &ua.BrowsePathResult{StatusCode: ua.StatusOK, Targets: targets}
+}
+
+// follow returns the references of a node which match a relative path
+// element. The namespace of the node browses its references so that paths
+// can cross namespaces.
+func (s *ViewService) follow(nid *ua.NodeID, e *ua.RelativePathElement) *ua.BrowseResult {
+	ns, err := s.srv.Namespace(int(nid.Namespace()))
+	if err != nil {
+		return &ua.BrowseResult{StatusCode: ua.StatusBadNodeIDUnknown}
+	}
+	dir := ua.BrowseDirectionForward
+	if e.IsInverse {
+		dir = ua.BrowseDirectionInverse
+	}
+	refType := e.ReferenceTypeID
+	if refType == nil {
+		refType = ua.NewTwoByteNodeID(0)
+	}
+	return ns.Browse(&ua.BrowseDescription{
+		NodeID:          nid,
+		BrowseDirection: dir,
+		ReferenceTypeID: refType,
+		IncludeSubtypes: e.IncludeSubtypes,
+		ResultMask:      uint32(ua.BrowseResultMaskAll),
+	})
+}
+
+func sameQualifiedName(a, b *ua.QualifiedName) bool {
+	return a != nil && b != nil && a.NamespaceIndex == b.NamespaceIndex && a.Name == b.Name
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.8.5
@@ -172,7 +370,48 @@ func (s *ViewService) RegisterNodes(sc *uasc.SecureChannel, r ua.Request, reqID
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.NodesToRegister) == 0 {
+		return &ua.RegisterNodesResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+	for _, nid := range req.NodesToRegister {
+		if nid == nil {
+			return &ua.RegisterNodesResponse{
+				ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNodeIDInvalid),
+			}, nil
+		}
+	}
+
+	// the nodes are not validated since registering is only a hint for
+	// the server.
+	nodeIDs := make([]*ua.NodeID, len(req.NodesToRegister))
+	for i, nid := range req.NodesToRegister {
+		nodeIDs[i] = sess.resolveNodeID(nid)
+	}
+	inUse := func(nid *ua.NodeID) bool { return s.srv.Node(nid) != nil }
+	aliases := sess.registerNodes(nodeIDs, int(s.srv.cfg.cap.MaxRegisteredNodes), inUse)
+	if aliases == nil {
+		return &ua.RegisterNodesResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadTooManyOperations),
+		}, nil
+	}
+	for _, nid := range nodeIDs {
+		if nr, ok := s.srv.nodeRegistrar(nid); ok {
+			nr.RegisterNode(nid)
+		}
+	}
+
+	return &ua.RegisterNodesResponse{
+		ResponseHeader:    responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+		RegisteredNodeIDs: aliases,
+	}, nil
 }
 
 // https://reference.opcfoundation.org/Core/Part4/v105/docs/5.8.6
@@ -185,5 +424,29 @@ func (s *ViewService) UnregisterNodes(sc *uasc.SecureChannel, r ua.Request, reqI
 	if err != nil {
 		return nil, err
 	}
-	return serviceUnsupported(req.RequestHeader), nil
+
+	sess := s.srv.Session(req.RequestHeader)
+	if sess == nil {
+		return nil, ua.StatusBadSessionIDInvalid
+	}
+
+	if len(req.NodesToUnregister) == 0 {
+		return &ua.UnregisterNodesResponse{
+			ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusBadNothingToDo),
+		}, nil
+	}
+
+	// unknown aliases are ignored.
+	for _, alias := range req.NodesToUnregister {
+		if alias == nil {
+			continue
+		}
+		if nid := sess.unregisterNode(alias); nid != nil {
+			s.srv.unregisterNodes(nid)
+		}
+	}
+
+	return &ua.UnregisterNodesResponse{
+		ResponseHeader: responseHeader(req.RequestHeader.RequestHandle, ua.StatusOK),
+	}, nil
 }
@@ -0,0 +1,451 @@
+package server
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+var (
+	structureType   = ua.NewNumericNodeID(0, id.Structure)
+	enumerationType = ua.NewNumericNodeID(0, id.Enumeration)
+	hasEncoding     = ua.NewNumericNodeID(0, id.HasEncoding)
+)
+
+// writeMaskBits maps the attributes to their bits in the WriteMask.
+var writeMaskBits = map[ua.AttributeID]ua.AttributeWriteMask{
+	ua.AttributeIDNodeID:                  ua.AttributeWriteMaskNodeID,
+	ua.AttributeIDNodeClass:               ua.AttributeWriteMaskNodeClass,
+	ua.AttributeIDBrowseName:              ua.AttributeWriteMaskBrowseName,
+	ua.AttributeIDDisplayName:             ua.AttributeWriteMaskDisplayName,
+	ua.AttributeIDDescription:             ua.AttributeWriteMaskDescription,
+	ua.AttributeIDWriteMask:               ua.AttributeWriteMaskWriteMask,
+	ua.AttributeIDUserWriteMask:           ua.AttributeWriteMaskUserWriteMask,
+	ua.AttributeIDIsAbstract:              ua.AttributeWriteMaskIsAbstract,
+	ua.AttributeIDSymmetric:               ua.AttributeWriteMaskSymmetric,
+	ua.AttributeIDInverseName:             ua.AttributeWriteMaskInverseName,
+	ua.AttributeIDContainsNoLoops:         ua.AttributeWriteMaskContainsNoLoops,
+	ua.AttributeIDEventNotifier:           ua.AttributeWriteMaskEventNotifier,
+	ua.AttributeIDDataType:                ua.AttributeWriteMaskDataType,
+	ua.AttributeIDValueRank:               ua.AttributeWriteMaskValueRank,
+	ua.AttributeIDArrayDimensions:         ua.AttributeWriteMaskArrayDimensions,
+	ua.AttributeIDAccessLevel:             ua.AttributeWriteMaskAccessLevel,
+	ua.AttributeIDUserAccessLevel:         ua.AttributeWriteMaskUserAccessLevel,
+	ua.AttributeIDMinimumSamplingInterval: ua.AttributeWriteMaskMinimumSamplingInterval,
+	ua.AttributeIDHistorizing:             ua.AttributeWriteMaskHistorizing,
+	ua.AttributeIDExecutable:              ua.AttributeWriteMaskExecutable,
+	ua.AttributeIDUserExecutable:          ua.AttributeWriteMaskUserExecutable,
+	ua.AttributeIDDataTypeDefinition:      ua.AttributeWriteMaskDataTypeDefinition,
+	ua.AttributeIDRolePermissions:         ua.AttributeWriteMaskRolePermissions,
+	ua.AttributeIDAccessRestrictions:      ua.AttributeWriteMaskAccessRestrictions,
+	ua.AttributeIDAccessLevelEx:           ua.AttributeWriteMaskAccessLevelEx,
+}
+
+// attributeType is the built-in type of the value of an attribute.
+type attributeType struct {
+	typeID ua.TypeID
+	array  bool
+}
+
+// attributeTypes maps the attributes other than the Value to the type of
+// their values.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/5.9
+var attributeTypes = map[ua.AttributeID]attributeType{
+	ua.AttributeIDNodeID:                  {typeID: ua.TypeIDNodeID},
+	ua.AttributeIDNodeClass:               {typeID: ua.TypeIDInt32},
+	ua.AttributeIDBrowseName:              {typeID: ua.TypeIDQualifiedName},
+	ua.AttributeIDDisplayName:             {typeID: ua.TypeIDLocalizedText},
+	ua.AttributeIDDescription:             {typeID: ua.TypeIDLocalizedText},
+	ua.AttributeIDWriteMask:               {typeID: ua.TypeIDUint32},
+	ua.AttributeIDIsAbstract:              {typeID: ua.TypeIDBoolean},
+	ua.AttributeIDSymmetric:               {typeID: ua.TypeIDBoolean},
+	ua.AttributeIDInverseName:             {typeID: ua.TypeIDLocalizedText},
+	ua.AttributeIDContainsNoLoops:         {typeID: ua.TypeIDBoolean},
+	ua.AttributeIDEventNotifier:           {typeID: ua.TypeIDByte},
+	ua.AttributeIDDataType:                {typeID: ua.TypeIDNodeID},
+	ua.AttributeIDValueRank:               {typeID: ua.TypeIDInt32},
+	ua.AttributeIDArrayDimensions:         {typeID: ua.TypeIDUint32, array: true},
+	ua.AttributeIDAccessLevel:             {typeID: ua.TypeIDByte},
+	ua.AttributeIDMinimumSamplingInterval: {typeID: ua.TypeIDDouble},
+	ua.AttributeIDHistorizing:             {typeID: ua.TypeIDBoolean},
+	ua.AttributeIDExecutable:              {typeID: ua.TypeIDBoolean},
+	ua.AttributeIDDataTypeDefinition:      {typeID: ua.TypeIDExtensionObject},
+	ua.AttributeIDRolePermissions:         {typeID: ua.TypeIDExtensionObject, array: true},
+	ua.AttributeIDAccessRestrictions:      {typeID: ua.TypeIDUint16},
+	ua.AttributeIDAccessLevelEx:           {typeID: ua.TypeIDUint32},
+}
+
+// attributeValue returns the value of an attribute of a node. It returns
+// false if the node does not have the attribute or if its value is not of
+// type T.
+func attributeValue[T any](ns NameSpace, nodeID *ua.NodeID, attr ua.AttributeID) (T, bool) {
+	var zero T
+	dv := ns.Attribute(nodeID, attr)
+	if dv == nil || dv.Status != ua.StatusOK || dv.Value == nil {
+		return zero, false
+	}
+	v, ok := dv.Value.Value().(T)
+	return v, ok
+}
+
+// checkWrite checks a value of a Write request against the node and
+// returns the value which is written to the node. Attributes which the
+// node does not have do not restrict the write.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/5.10.4
+func (s *Server) checkWrite(ns NameSpace, nodeID *ua.NodeID, wv *ua.WriteValue) (*ua.DataValue, ua.StatusCode) {
+	if wv.Value == nil {
+		return nil, ua.StatusBadTypeMismatch
+	}
+	if wv.AttributeID != ua.AttributeIDValue {
+		if status := checkWriteMask(ns, nodeID, wv.AttributeID); status != ua.StatusOK {
+			return nil, status
+		}
+		if !hasAttributeType(wv.AttributeID, wv.Value.Value) {
+			return nil, ua.StatusBadTypeMismatch
+		}
+		return wv.Value, ua.StatusOK
+	}
+
+	// writing parts of arrays and strings is not supported.
+	if wv.IndexRange != "" {
+		return nil, ua.StatusBadWriteNotSupported
+	}
+
+	if access, ok := attributeValue[uint8](ns, nodeID, ua.AttributeIDAccessLevel); ok {
+		switch {
+		case access&uint8(ua.AccessLevelTypeCurrentWrite) == 0:
+			return nil, ua.StatusBadNotWritable
+		case wv.Value.Has(ua.DataValueStatusCode) && access&uint8(ua.AccessLevelTypeStatusWrite) == 0:
+			return nil, ua.StatusBadWriteNotSupported
+		case (wv.Value.Has(ua.DataValueSourceTimestamp) || wv.Value.Has(ua.DataValueServerTimestamp)) && access&uint8(ua.AccessLevelTypeTimestampWrite) == 0:
+			return nil, ua.StatusBadWriteNotSupported
+		}
+	}
+	return s.checkValue(ns, nodeID, wv.Value)
+}
+
+// checkWriteMask checks that the WriteMask of the node allows to write
+// the attribute. Nodes without WriteMask only allow to write their Value.
+// The attributes which depend on the user are never writable.
+func checkWriteMask(ns NameSpace, nodeID *ua.NodeID, attr ua.AttributeID) ua.StatusCode {
+	switch attr {
+	case ua.AttributeIDUserWriteMask, ua.AttributeIDUserAccessLevel, ua.AttributeIDUserExecutable, ua.AttributeIDUserRolePermissions:
+		return ua.StatusBadNotWritable
+	}
+	bit, ok := writeMaskBits[attr]
+	if !ok {
+		return ua.StatusOK
+	}
+
+	mask, ok := attributeValue[uint32](ns, nodeID, ua.AttributeIDWriteMask)
+	if !ok || mask&uint32(bit) == 0 {
+		return ua.StatusBadNotWritable
+	}
+	return ua.StatusOK
+}
+
+// hasAttributeType returns true if the value has the type of the
+// attribute. Attributes of unknown type are not checked.
+func hasAttributeType(attr ua.AttributeID, v *ua.Variant) bool {
+	t, ok := attributeTypes[attr]
+	if !ok {
+		return true
+	}
+	if v == nil || v.Value() == nil || v.Has(ua.VariantArrayDimensions) {
+		return false
+	}
+	return v.Type() == t.typeID && v.Has(ua.VariantArrayValues) == t.array
+}
+
+// checkValue checks the value of a variable against its DataType,
+// ValueRank and ArrayDimensions. With implicit numeric conversion numbers
+// are converted to the data type of the variable.
+func (s *Server) checkValue(ns NameSpace, nodeID *ua.NodeID, dv *ua.DataValue) (*ua.DataValue, ua.StatusCode) {
+	// null values can be written to all variables.
+	if dv.Value == nil || dv.Value.Value() == nil {
+		return dv, ua.StatusOK
+	}
+
+	dataType, rank := s.valueType(ns, nodeID)
+	if dataType == nil {
+		return dv, ua.StatusOK
+	}
+	dims, _ := attributeValue[[]uint32](ns, nodeID, ua.AttributeIDArrayDimensions)
+	if !matchValueRank(dv.Value, rank, dims) {
+		return nil, ua.StatusBadTypeMismatch
+	}
+	if s.hasDataType(dv.Value, dataType) {
+		return dv, ua.StatusOK
+	}
+	if !s.cfg.implicitNumericConversion {
+		return nil, ua.StatusBadTypeMismatch
+	}
+	v, ok := convertNumbers(dv.Value, s.builtinType(dataType))
+	if !ok {
+		return nil, ua.StatusBadTypeMismatch
+	}
+	c := *dv
+	c.Value = v
+	return &c, ua.StatusOK
+}
+
+// valueType returns the data type and the value rank of a variable. The
+// DataType attribute of variables which were created with NewVariableNode
+// does not contain a data type. Their data type and value rank are those
+// of their current value. It returns a nil data type if it is unknown.
+func (s *Server) valueType(ns NameSpace, nodeID *ua.NodeID) (*ua.NodeID, int32) {
+	rank, hasRank := attributeValue[int32](ns, nodeID, ua.AttributeIDValueRank)
+	if !hasRank {
+		rank = -2 // any
+	}
+
+	var dataType *ua.NodeID
+	switch v := ns.Attribute(nodeID, ua.AttributeIDDataType); {
+	case v == nil || v.Status != ua.StatusOK || v.Value == nil:
+	case v.Value.NodeID() != nil:
+		dataType = v.Value.NodeID()
+	case v.Value.ExpandedNodeID() != nil:
+		dataType = v.Value.ExpandedNodeID().NodeID
+	}
+	if n := s.nodeOrNil(dataType); n != nil && n.NodeClass() == ua.NodeClassDataType {
+		return dataType, rank
+	}
+
+	cur := ns.Attribute(nodeID, ua.AttributeIDValue)
+	if cur == nil || cur.Status != ua.StatusOK || cur.Value == nil || cur.Value.Value() == nil {
+		return nil, rank
+	}
+	if !hasRank {
+		rank = -1 // scalar
+		if cur.Value.Has(ua.VariantArrayValues) {
+			rank = 0 // one or more dimensions
+		}
+	}
+	return s.variantDataType(cur.Value), rank
+}
+
+// variantDataType returns the data type of the value of a variant. The
+// data type of extension objects is the data type of their encoding.
+func (s *Server) variantDataType(v *ua.Variant) *ua.NodeID {
+	if eo, ok := v.Value().(*ua.ExtensionObject); ok {
+		return s.extensionObjectDataType(eo)
+	}
+	return ua.NewNumericNodeID(0, uint32(v.Type()))
+}
+
+// extensionObjectDataType returns the data type of an extension object.
+// It returns the Structure data type if the encoding is unknown.
+func (s *Server) extensionObjectDataType(eo *ua.ExtensionObject) *ua.NodeID {
+	if eo == nil || eo.TypeID == nil {
+		return structureType
+	}
+	if n := s.nodeOrNil(eo.TypeID.NodeID); n != nil {
+		for _, ref := range n.references() {
+			if !ref.IsForward && ref.NodeID != nil && ref.ReferenceTypeID.Equal(hasEncoding) {
+				return ref.NodeID.NodeID
+			}
+		}
+	}
+	return structureType
+}
+
+// hasDataType returns true if the value of the variant can be written to
+// a variable of the data type. This is the case if the type of the value
+// is the data type or one of its subtypes or if the data type is a
+// subtype of the built-in type of the value, e.g. Duration for Double.
+// Enumerations are written as Int32.
+func (s *Server) hasDataType(v *ua.Variant, dataType *ua.NodeID) bool {
+	match := func(t *ua.NodeID) bool {
+		return s.isSubtype(t, dataType) || s.isSubtype(dataType, t)
+	}
+
+	switch val := v.Value().(type) {
+	case *ua.ExtensionObject:
+		return match(s.extensionObjectDataType(val))
+	case []*ua.ExtensionObject:
+		for _, eo := range val {
+			if !match(s.extensionObjectDataType(eo)) {
+				return false
+			}
+		}
+		return true
+	case []*ua.Variant:
+		for _, e := range val {
+			if e != nil && e.Value() != nil && !s.hasDataType(e, dataType) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if s.isSubtype(dataType, enumerationType) {
+		return v.Type() == ua.TypeIDInt32
+	}
+	return match(ua.NewNumericNodeID(0, uint32(v.Type())))
+}
+
+// builtinType returns the built-in type on which the data type is based.
+func (s *Server) builtinType(dataType *ua.NodeID) ua.TypeID {
+	for depth := 0; dataType != nil && depth < 100; depth++ {
+		if dataType.Namespace() == 0 && dataType.IntID() >= uint32(ua.TypeIDBoolean) && dataType.IntID() <= uint32(ua.TypeIDDiagnosticInfo) {
+			return ua.TypeID(dataType.IntID())
+		}
+		dataType = s.superType(dataType)
+	}
+	return ua.TypeIDNull
+}
+
+// matchValueRank returns true if the dimensions of the value of the
+// variant match the value rank and the array dimensions of a variable.
+//
+// https://reference.opcfoundation.org/Core/Part3/v105/docs/5.6.2
+func matchValueRank(v *ua.Variant, rank int32, dims []uint32) bool {
+	var size []int32
+	switch {
+	case v.Has(ua.VariantArrayDimensions):
+		size = v.ArrayDimensions()
+	case v.Has(ua.VariantArrayValues):
+		size = []int32{v.ArrayLength()}
+	}
+
+	switch {
+	case rank == -3: // scalar or one dimension
+		if len(size) > 1 {
+			return false
+		}
+	case rank == -2: // any
+	case rank == -1: // scalar
+		if len(size) > 0 {
+			return false
+		}
+	case rank == 0: // one or more dimensions
+		if len(size) == 0 {
+			return false
+		}
+	case int(rank) != len(size):
+		return false
+	}
+
+	// a zero array dimension has no maximum length.
+	if len(dims) != len(size) {
+		return true
+	}
+	for i, d := range dims {
+		if d != 0 && size[i] > 0 && uint32(size[i]) > d {
+			return false
+		}
+	}
+	return true
+}
+
+// numericTypes maps the numeric built-in types to their Go types.
+var numericTypes = map[ua.TypeID]reflect.Type{}
+
+func init() {
+	for t, typeID := range builtinTypes {
+		if typeID >= ua.TypeIDSByte && typeID <= ua.TypeIDDouble {
+			numericTypes[typeID] = t
+		}
+	}
+}
+
+// convertNumbers converts the number or the array of numbers of the
+// variant to the numeric built-in type. Integers must be in the range of
+// the type and floating point numbers are rounded to the nearest integer
+// with halves rounded away from zero. It returns false if the values
+// cannot be converted.
+func convertNumbers(v *ua.Variant, typeID ua.TypeID) (*ua.Variant, bool) {
+	t, ok := numericTypes[typeID]
+	if !ok || numericTypes[v.Type()] == nil || v.Has(ua.VariantArrayDimensions) {
+		return nil, false
+	}
+
+	val := reflect.ValueOf(v.Value())
+	if val.Kind() != reflect.Slice {
+		x, ok := convertNumber(val, t)
+		if !ok {
+			return nil, false
+		}
+		return ua.MustVariant(x.Interface()), true
+	}
+
+	s := reflect.MakeSlice(reflect.SliceOf(t), val.Len(), val.Len())
+	for i := 0; i < val.Len(); i++ {
+		x, ok := convertNumber(val.Index(i), t)
+		if !ok {
+			return nil, false
+		}
+		s.Index(i).Set(x)
+	}
+	return ua.MustVariant(s.Interface()), true
+}
+
+// convertNumber converts a number to the numeric Go type t.
+func convertNumber(v reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	x := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch {
+		case v.CanInt():
+			n = v.Int()
+		case v.CanUint():
+			if v.Uint() > math.MaxInt64 {
+				return x, false
+			}
+			n = int64(v.Uint())
+		default:
+			f := math.Round(v.Float())
+			if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return x, false
+			}
+			n = int64(f)
+		}
+		if x.OverflowInt(n) {
+			return x, false
+		}
+		x.SetInt(n)
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch {
+		case v.CanInt():
+			if v.Int() < 0 {
+				return x, false
+			}
+			n = uint64(v.Int())
+		case v.CanUint():
+			n = v.Uint()
+		default:
+			f := math.Round(v.Float())
+			if math.IsNaN(f) || f < 0 || f >= math.MaxUint64 {
+				return x, false
+			}
+			n = uint64(f)
+		}
+		if x.OverflowUint(n) {
+			return x, false
+		}
+		x.SetUint(n)
+
+	default:
+		var f float64
+		switch {
+		case v.CanInt():
+			f = float64(v.Int())
+		case v.CanUint():
+			f = float64(v.Uint())
+		default:
+			f = v.Float()
+		}
+		if !math.IsInf(f, 0) && x.OverflowFloat(f) {
+			return x, false
+		}
+		x.SetFloat(f)
+	}
+	return x, true
+}
@@ -34,6 +34,7 @@ type Subscription struct {
 	itemsMu                   sync.Mutex
 	lastSeq                   uint32
 	nextSeq                   uint32
+	notifyRestored            bool
 	c                         ClientInterface
 }
 
@@ -43,6 +44,9 @@ type SubscriptionParameters struct {
 	MaxKeepAliveCount          uint32
 	MaxNotificationsPerPublish uint32
 	Priority                   uint8
+
+	// NotifyRestored enables the SubscriptionRestored notification.
+	NotifyRestored bool
 }
 
 type monitoredItem struct {
@@ -78,6 +82,13 @@ type PublishNotificationData struct {
 	Value          interface{}
 }
 
+// SubscriptionRestored is the value of the notification which the client
+// sends after it has restored a subscription on a new session if the
+// subscription was created with NotifyRestored. Notifications may have been
+// lost while the client was disconnected. The notification follows the
+// republished notifications and precedes all new ones.
+type SubscriptionRestored struct{}
+
 // Cancel stops the subscription and removes it
 // from the client and the server.
 func (s *Subscription) Cancel(ctx context.Context) error {
@@ -335,6 +346,16 @@ func (s *Subscription) notify(ctx context.Context, data *PublishNotificationData
 	}
 }
 
+// notifyRestoredSubscription sends the SubscriptionRestored notification if
+// the subscription was created with NotifyRestored. It is sent before the
+// publish loop resumes so that it is ordered with the notifications.
+func (s *Subscription) notifyRestoredSubscription(ctx context.Context) {
+	if !s.notifyRestored {
+		return
+	}
+	s.notify(ctx, &PublishNotificationData{SubscriptionID: s.SubscriptionID, Value: &SubscriptionRestored{}})
+}
+
 // Stats returns a diagnostic struct with metadata about the current subscription
 func (s *Subscription) Stats(ctx context.Context) (*ua.SubscriptionDiagnosticsDataType, error) {
 	// TODO(kung-foo): once browsing feature is merged, attempt to get direct access to the
@@ -0,0 +1,327 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAlarms performs an integration test to evaluate limit alarms on the
+// server and to acknowledge, confirm, enable, shelve and refresh them.
+func TestAlarms(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	// the requests are sent directly so that the publish loop of the client
+	// does not interfere.
+	var sub *ua.CreateSubscriptionResponse
+	err = c.Send(ctx, &ua.CreateSubscriptionRequest{
+		RequestedPublishingInterval: 100,
+		RequestedLifetimeCount:      100,
+		RequestedMaxKeepAliveCount:  20,
+		PublishingEnabled:           true,
+	}, func(v ua.Response) error {
+		sub = v.(*ua.CreateSubscriptionResponse)
+		return nil
+	})
+	require.NoError(t, err, "CreateSubscription failed")
+
+	area := ua.NewStringNodeID(1, "Area")
+	levelAlarm := ua.NewStringNodeID(1, "LevelAlarm")
+	pressureAlarm := ua.NewStringNodeID(1, "PressureAlarm")
+	conditionType := ua.NewNumericNodeID(0, id.ConditionType)
+
+	field := func(typeID uint32, name ...string) *ua.SimpleAttributeOperand {
+		op := &ua.SimpleAttributeOperand{
+			TypeDefinitionID: ua.NewNumericNodeID(0, typeID),
+			BrowsePath:       []*ua.QualifiedName{},
+			AttributeID:      ua.AttributeIDValue,
+		}
+		for _, n := range name {
+			op.BrowsePath = append(op.BrowsePath, &ua.QualifiedName{Name: n})
+		}
+		return op
+	}
+	conditionID := &ua.SimpleAttributeOperand{
+		TypeDefinitionID: conditionType,
+		BrowsePath:       []*ua.QualifiedName{},
+		AttributeID:      ua.AttributeIDNodeID,
+	}
+
+	const (
+		fEventType = iota
+		fConditionID
+		fEventID
+		fBranchID
+		fRetain
+		fEnabled
+		fActive
+		fAcked
+		fConfirmed
+		fLimitState
+		fHighState
+		fShelvingState
+		fComment
+	)
+	item := opcua.NewMonitoredItemCreateRequestWithDefaults(area, ua.AttributeIDEventNotifier, 1)
+	item.RequestedParameters.Filter = ua.NewExtensionObject(&ua.EventFilter{
+		SelectClauses: []*ua.SimpleAttributeOperand{
+			field(id.BaseEventType, "EventType"),
+			conditionID,
+			field(id.BaseEventType, "EventId"),
+			field(id.ConditionType, "BranchId"),
+			field(id.ConditionType, "Retain"),
+			field(id.ConditionType, "EnabledState", "Id"),
+			field(id.AlarmConditionType, "ActiveState", "Id"),
+			field(id.AcknowledgeableConditionType, "AckedState", "Id"),
+			field(id.AcknowledgeableConditionType, "ConfirmedState", "Id"),
+			field(id.ExclusiveLimitAlarmType, "LimitState", "CurrentState"),
+			field(id.NonExclusiveLimitAlarmType, "HighState", "Id"),
+			field(id.AlarmConditionType, "ShelvingState", "CurrentState"),
+			field(id.ConditionType, "Comment"),
+		},
+		WhereClause: &ua.ContentFilter{},
+	})
+	var res *ua.CreateMonitoredItemsResponse
+	err = c.Send(ctx, &ua.CreateMonitoredItemsRequest{
+		SubscriptionID:     sub.SubscriptionID,
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+		ItemsToCreate:      []*ua.MonitoredItemCreateRequest{item},
+	}, func(v ua.Response) error {
+		res = v.(*ua.CreateMonitoredItemsResponse)
+		return nil
+	})
+	require.NoError(t, err, "CreateMonitoredItems failed")
+	require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+	fr, ok := res.Results[0].FilterResult.Value.(*ua.EventFilterResult)
+	require.True(t, ok, "got %T", res.Results[0].FilterResult.Value)
+	for i, status := range fr.SelectClauseResults {
+		require.Equal(t, ua.StatusOK, status, "select clause %d", i)
+	}
+
+	// publish returns the fields of the reported events.
+	publish := func(t *testing.T) [][]*ua.Variant {
+		t.Helper()
+		var res *ua.PublishResponse
+		err := c.Send(ctx, &ua.PublishRequest{SubscriptionAcknowledgements: []*ua.SubscriptionAcknowledgement{}}, func(v ua.Response) error {
+			res = v.(*ua.PublishResponse)
+			return nil
+		})
+		require.NoError(t, err, "Publish failed")
+		var events [][]*ua.Variant
+		for _, eo := range res.NotificationMessage.NotificationData {
+			list, ok := eo.Value.(*ua.EventNotificationList)
+			require.True(t, ok, "got %T", eo.Value)
+			for _, ev := range list.Events {
+				events = append(events, ev.EventFields)
+			}
+		}
+		return events
+	}
+	write := func(t *testing.T, name string, v float64) {
+		t.Helper()
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      ua.NewStringNodeID(1, name),
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+			}},
+		})
+	}
+	call := func(t *testing.T, objectID *ua.NodeID, methodID uint32, args ...any) ua.StatusCode {
+		t.Helper()
+		req := &ua.CallMethodRequest{
+			ObjectID:       objectID,
+			MethodID:       ua.NewNumericNodeID(0, methodID),
+			InputArguments: []*ua.Variant{},
+		}
+		for _, a := range args {
+			req.InputArguments = append(req.InputArguments, ua.MustVariant(a))
+		}
+		res, err := c.Call(ctx, req)
+		require.NoError(t, err, "Call failed")
+		return res.StatusCode
+	}
+	eventID := func(fields []*ua.Variant) []byte {
+		return fields[fEventID].Value().([]byte)
+	}
+	comment := ua.NewLocalizedText("checked")
+
+	var last []*ua.Variant
+
+	t.Run("limit alarm", func(t *testing.T) {
+		write(t, "level", 75)
+		events := publish(t)
+		require.Len(t, events, 1)
+		ev := events[0]
+		require.Equal(t, ua.NewNumericNodeID(0, id.ExclusiveLevelAlarmType).String(), ev[fEventType].Value().(*ua.NodeID).String())
+		require.Equal(t, levelAlarm.String(), ev[fConditionID].Value().(*ua.NodeID).String())
+		require.Equal(t, true, ev[fRetain].Value())
+		require.Equal(t, true, ev[fEnabled].Value())
+		require.Equal(t, true, ev[fActive].Value())
+		require.Equal(t, false, ev[fAcked].Value())
+		require.Equal(t, false, ev[fConfirmed].Value())
+		require.Equal(t, "High", ev[fLimitState].Value().(*ua.LocalizedText).Text)
+		require.Nil(t, ev[fHighState].Value(), "not a non-exclusive alarm")
+		require.Equal(t, "Unshelved", ev[fShelvingState].Value().(*ua.LocalizedText).Text)
+
+		write(t, "level", 95)
+		events = publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, "HighHigh", events[0][fLimitState].Value().(*ua.LocalizedText).Text)
+		last = events[0]
+	})
+
+	t.Run("acknowledge and confirm", func(t *testing.T) {
+		require.Equal(t, ua.StatusBadEventIDUnknown, call(t, levelAlarm, id.AcknowledgeableConditionType_Acknowledge, []byte("unknown"), comment))
+		require.Equal(t, ua.StatusOK, call(t, levelAlarm, id.AcknowledgeableConditionType_Acknowledge, eventID(last), comment))
+		events := publish(t)
+		require.Len(t, events, 1)
+		ev := events[0]
+		require.Equal(t, true, ev[fAcked].Value())
+		require.Equal(t, false, ev[fConfirmed].Value())
+		require.Equal(t, true, ev[fRetain].Value())
+		require.Equal(t, "checked", ev[fComment].Value().(*ua.LocalizedText).Text)
+		require.Equal(t, ua.StatusBadConditionBranchAlreadyAcked, call(t, levelAlarm, id.AcknowledgeableConditionType_Acknowledge, eventID(ev), comment))
+
+		require.Equal(t, ua.StatusOK, call(t, levelAlarm, id.AcknowledgeableConditionType_Confirm, eventID(ev), comment))
+		events = publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, true, events[0][fConfirmed].Value())
+		require.Equal(t, ua.StatusBadConditionBranchAlreadyConfirmed, call(t, levelAlarm, id.AcknowledgeableConditionType_Confirm, eventID(events[0]), comment))
+
+		// the alarm is no longer retained when it returns to normal.
+		write(t, "level", 50)
+		events = publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, false, events[0][fActive].Value())
+		require.Equal(t, false, events[0][fRetain].Value())
+		require.Nil(t, events[0][fLimitState].Value())
+	})
+
+	t.Run("branches", func(t *testing.T) {
+		write(t, "pressure", 6)
+		events := publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, pressureAlarm.String(), events[0][fConditionID].Value().(*ua.NodeID).String())
+		require.Equal(t, true, events[0][fHighState].Value())
+		require.Equal(t, false, events[0][fAcked].Value())
+		require.Nil(t, events[0][fConfirmed].Value(), "confirm is not required")
+
+		// the unacknowledged state moves into a branch.
+		write(t, "pressure", 1)
+		events = publish(t)
+		require.Len(t, events, 2)
+		branch, trunk := events[0], events[1]
+		require.NotEqual(t, uint8(0), branch[fBranchID].Value().(*ua.NodeID).Type(), "branch id")
+		require.Equal(t, true, branch[fActive].Value())
+		require.Equal(t, false, branch[fAcked].Value())
+		require.Equal(t, true, branch[fRetain].Value())
+		require.Equal(t, ua.NewTwoByteNodeID(0).String(), trunk[fBranchID].Value().(*ua.NodeID).String())
+		require.Equal(t, false, trunk[fActive].Value())
+		require.Equal(t, true, trunk[fAcked].Value())
+		require.Equal(t, false, trunk[fRetain].Value())
+		require.Equal(t, 1, srv.Condition(pressureAlarm).Branches())
+
+		// acknowledging the branch removes it.
+		require.Equal(t, ua.StatusOK, call(t, pressureAlarm, id.AcknowledgeableConditionType_Acknowledge, eventID(branch), comment))
+		events = publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, branch[fBranchID].Value().(*ua.NodeID).String(), events[0][fBranchID].Value().(*ua.NodeID).String())
+		require.Equal(t, false, events[0][fRetain].Value())
+		require.Equal(t, 0, srv.Condition(pressureAlarm).Branches())
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		write(t, "pressure", 7)
+		require.Len(t, publish(t), 1)
+
+		require.Equal(t, ua.StatusOK, call(t, conditionType, id.ConditionType_ConditionRefresh, sub.SubscriptionID))
+		events := publish(t)
+		require.Len(t, events, 3)
+		require.Equal(t, ua.NewNumericNodeID(0, id.RefreshStartEventType).String(), events[0][fEventType].Value().(*ua.NodeID).String())
+		require.Equal(t, pressureAlarm.String(), events[1][fConditionID].Value().(*ua.NodeID).String())
+		require.Equal(t, ua.NewNumericNodeID(0, id.RefreshEndEventType).String(), events[2][fEventType].Value().(*ua.NodeID).String())
+
+		require.Equal(t, ua.StatusOK, call(t, conditionType, id.ConditionType_ConditionRefresh2, sub.SubscriptionID, res.Results[0].MonitoredItemID))
+		require.Len(t, publish(t), 3)
+
+		require.Equal(t, ua.StatusBadSubscriptionIDInvalid, call(t, conditionType, id.ConditionType_ConditionRefresh, sub.SubscriptionID+100))
+		require.Equal(t, ua.StatusBadMonitoredItemIDInvalid, call(t, conditionType, id.ConditionType_ConditionRefresh2, sub.SubscriptionID, res.Results[0].MonitoredItemID+100))
+
+		write(t, "pressure", 1)
+		require.Len(t, publish(t), 2)
+	})
+
+	t.Run("enable and disable", func(t *testing.T) {
+		require.Equal(t, ua.StatusOK, call(t, levelAlarm, id.ConditionType_Disable))
+		events := publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, false, events[0][fEnabled].Value())
+		require.Equal(t, false, events[0][fRetain].Value())
+
+		require.Equal(t, ua.StatusBadConditionAlreadyDisabled, call(t, levelAlarm, id.ConditionType_Disable))
+		require.Equal(t, ua.StatusBadConditionDisabled, call(t, levelAlarm, id.AcknowledgeableConditionType_Acknowledge, eventID(events[0]), comment))
+		require.Equal(t, ua.StatusBadConditionDisabled, call(t, levelAlarm, id.ConditionType_AddComment, eventID(events[0]), comment))
+
+		require.Equal(t, ua.StatusOK, call(t, levelAlarm, id.ConditionType_Enable))
+		events = publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, true, events[0][fEnabled].Value())
+		require.Equal(t, ua.StatusBadConditionAlreadyEnabled, call(t, levelAlarm, id.ConditionType_Enable))
+
+		require.Equal(t, ua.StatusOK, call(t, levelAlarm, id.ConditionType_AddComment, eventID(events[0]), ua.NewLocalizedText("note")))
+		events = publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, "note", events[0][fComment].Value().(*ua.LocalizedText).Text)
+
+		// the methods are only available on conditions.
+		require.Equal(t, ua.StatusBadNodeIDUnknown, call(t, conditionType, id.ConditionType_Enable))
+	})
+
+	t.Run("shelving", func(t *testing.T) {
+		shelvingState := ua.NewStringNodeID(1, "LevelAlarm.ShelvingState")
+		require.Equal(t, ua.StatusOK, call(t, shelvingState, id.ShelvedStateMachineType_OneShotShelve))
+		events := publish(t)
+		require.Len(t, events, 1)
+		require.Equal(t, "One Shot Shelved", events[0][fShelvingState].Value().(*ua.LocalizedText).Text)
+		require.Equal(t, ua.StatusBadConditionAlreadyShelved, call(t, shelvingState, id.ShelvedStateMachineType_OneShotShelve))
+
+		// one shot shelving can be changed to timed shelving but a timed
+		// shelved alarm can't be shelved again.
+		require.Equal(t, ua.StatusOK, call(t, shelvingState, id.ShelvedStateMachineType_TimedShelve, 60000.0))
+		require.Equal(t, "Timed Shelved", publish(t)[0][fShelvingState].Value().(*ua.LocalizedText).Text)
+		require.Equal(t, ua.StatusBadConditionAlreadyShelved, call(t, shelvingState, id.ShelvedStateMachineType_TimedShelve, 1000.0))
+
+		require.Equal(t, ua.StatusOK, call(t, shelvingState, id.ShelvedStateMachineType_Unshelve))
+		require.Len(t, publish(t), 1)
+		require.Equal(t, ua.StatusBadConditionNotShelved, call(t, shelvingState, id.ShelvedStateMachineType_Unshelve))
+		require.Equal(t, ua.StatusBadShelvingTimeOutOfRange, call(t, shelvingState, id.ShelvedStateMachineType_TimedShelve, 0.0))
+
+		// timed shelving ends by itself.
+		require.Equal(t, ua.StatusOK, call(t, shelvingState, id.ShelvedStateMachineType_TimedShelve, 200.0))
+		require.Equal(t, "Timed Shelved", publish(t)[0][fShelvingState].Value().(*ua.LocalizedText).Text)
+		time.Sleep(300 * time.Millisecond)
+		require.Equal(t, "Unshelved", publish(t)[0][fShelvingState].Value().(*ua.LocalizedText).Text)
+	})
+}
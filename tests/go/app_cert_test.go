@@ -0,0 +1,96 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
+)
+
+// TestApplicationCertificate performs an integration test to connect a
+// client and a server with generated application instance certificates
+// which validate each other.
+func TestApplicationCertificate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	pki := func(t *testing.T, name, uri string) (*uacert.Certificate, *uacert.Store) {
+		t.Helper()
+		d := filepath.Join(dir, name)
+		c, err := uacert.LoadOrCreate(d, uacert.Options{ApplicationURI: uri, Hosts: []string{"localhost"}})
+		require.NoError(t, err, "LoadOrCreate failed")
+		store, err := uacert.NewStore(d)
+		require.NoError(t, err, "NewStore failed")
+		return c, store
+	}
+	srvCert, srvStore := pki(t, "server", "urn:gopcua:appcert:server")
+	cliCert, cliStore := pki(t, "client", "urn:gopcua:appcert:client")
+	require.NoError(t, srvStore.Trust(cliCert.Certificate), "Trust failed")
+	require.NoError(t, cliStore.Trust(srvCert.Certificate), "Trust failed")
+
+	const port = 48701
+	srv := server.New(
+		server.EnableSecurity("Basic256Sha256", ua.MessageSecurityModeSignAndEncrypt),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+		server.EndPoint("localhost", port),
+		server.ApplicationCertificate(srvCert),
+		server.TrustStore(srvStore),
+	)
+	require.NoError(t, srv.Start(ctx), "Start failed")
+	defer srv.Close()
+
+	addr := fmt.Sprintf("opc.tcp://localhost:%d", port)
+	var (
+		eps []*ua.EndpointDescription
+		err error
+	)
+	require.Eventually(t, func() bool {
+		eps, err = opcua.GetEndpoints(ctx, addr)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond, "GetEndpoints failed")
+	ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURIBasic256Sha256, ua.MessageSecurityModeSignAndEncrypt)
+	require.NoError(t, err, "SelectEndpoint failed")
+	require.Equal(t, "urn:gopcua:appcert:server", ep.Server.ApplicationURI)
+
+	c, err := opcua.NewClient(addr,
+		opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous),
+		opcua.ApplicationCertificate(cliCert),
+		opcua.TrustStore(cliStore),
+		opcua.AutoReconnect(false),
+	)
+	require.NoError(t, err, "NewClient failed")
+	require.NoError(t, c.Connect(ctx), "Connect failed")
+	defer c.Close(ctx)
+
+	_, err = c.Node(ua.NewNumericNodeID(0, 2258)).Value(ctx)
+	require.NoError(t, err, "Read failed")
+
+	// the server rejects untrusted clients when they open the secure
+	// channel.
+	otherCert, _ := pki(t, "other", "urn:gopcua:appcert:other")
+	other, err := opcua.NewClient(addr,
+		opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous),
+		opcua.ApplicationCertificate(otherCert),
+		opcua.TrustStore(cliStore),
+		opcua.AutoReconnect(false),
+	)
+	require.NoError(t, err, "NewClient failed")
+	require.ErrorIs(t, other.Dial(ctx), ua.StatusBadCertificateUntrusted)
+
+	rejected, err := srvStore.Rejected()
+	require.NoError(t, err, "Rejected failed")
+	require.Len(t, rejected, 1)
+	require.Equal(t, otherCert.Certificate, rejected[0].Raw)
+}
@@ -0,0 +1,117 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuthentication performs an integration test to authenticate the users
+// of sessions.
+func TestAuthentication(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	eps, err := opcua.GetEndpoints(ctx, "opc.tcp://localhost:4840")
+	require.NoError(t, err, "GetEndpoints failed")
+	ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURINone, ua.MessageSecurityModeNone)
+	require.NoError(t, err, "SelectEndpoint failed")
+
+	connect := func(t *testing.T, opts ...opcua.Option) error {
+		t.Helper()
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", append(opts, opcua.SecurityMode(ua.MessageSecurityModeNone), opcua.AutoReconnect(false))...)
+		require.NoError(t, err, "NewClient failed")
+		if err := c.Connect(ctx); err != nil {
+			return err
+		}
+		defer c.Close(ctx)
+
+		_, err = c.Node(ua.NewNumericNodeID(0, 2258)).Value(ctx)
+		require.NoError(t, err, "Read failed")
+		return nil
+	}
+
+	t.Run("anonymous", func(t *testing.T) {
+		require.NoError(t, connect(t))
+		require.NoError(t, connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous)))
+	})
+
+	t.Run("user name", func(t *testing.T) {
+		require.NoError(t, connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName), opcua.AuthUsername("user", "pass")))
+		require.NoError(t, connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName), opcua.AuthUsername("admin", "secret")))
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		err := connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName), opcua.AuthUsername("user", "secret"))
+		require.ErrorIs(t, err, ua.StatusBadUserAccessDenied)
+
+		err = connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName), opcua.AuthUsername("nobody", "pass"))
+		require.ErrorIs(t, err, ua.StatusBadUserAccessDenied)
+	})
+
+	t.Run("unencrypted password", func(t *testing.T) {
+		// the password is sent in plain text over the insecure channel.
+		err := connect(t, opcua.AuthUsername("user", "pass"), opcua.AuthPolicyID("username_basic256sha256"))
+		require.ErrorIs(t, err, ua.StatusBadIdentityTokenInvalid)
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		err := connect(t, opcua.AuthUsername("user", "pass"), opcua.AuthPolicyID("unknown"))
+		require.ErrorIs(t, err, ua.StatusBadIdentityTokenInvalid)
+	})
+
+	t.Run("policy of another endpoint", func(t *testing.T) {
+		secure, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURIBasic256Sha256, ua.MessageSecurityModeSignAndEncrypt)
+		require.NoError(t, err, "SelectEndpoint failed")
+
+		var other *ua.UserTokenPolicy
+		for _, p := range ep.UserIdentityTokens {
+			if p.TokenType == ua.UserTokenTypeUserName && p.SecurityPolicyURI == ua.SecurityPolicyURIAes128Sha256RsaOaep {
+				other = p
+			}
+		}
+		require.NotNil(t, other, "user token policy not found")
+		for _, p := range secure.UserIdentityTokens {
+			require.NotEqual(t, other.PolicyID, p.PolicyID, "user token policy offered on the secure endpoint")
+		}
+
+		// the token is valid for the endpoint without security but not
+		// for the secure channel.
+		forged := *secure
+		forged.UserIdentityTokens = []*ua.UserTokenPolicy{other}
+		cert, key := genSelfSignedCert(t, "urn:gopcua:test:client")
+		c, err := opcua.NewClient("opc.tcp://localhost:4840",
+			opcua.SecurityFromEndpoint(&forged, ua.UserTokenTypeUserName),
+			opcua.Certificate(cert),
+			opcua.PrivateKey(key),
+			opcua.AuthUsername("user", "pass"),
+			opcua.AutoReconnect(false),
+		)
+		require.NoError(t, err, "NewClient failed")
+		err = c.Connect(ctx)
+		defer c.Close(ctx)
+		require.ErrorIs(t, err, ua.StatusBadIdentityTokenInvalid)
+	})
+
+	t.Run("certificate", func(t *testing.T) {
+		cert, key := genSelfSignedCert(t, "urn:gopcua:test:user")
+		require.NoError(t, connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeCertificate), opcua.AuthCertificate(cert), opcua.AuthPrivateKey(key)))
+
+		// the token must be signed with the key of the certificate.
+		_, other := genSelfSignedCert(t, "urn:gopcua:test:other")
+		err := connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeCertificate), opcua.AuthCertificate(cert), opcua.AuthPrivateKey(other))
+		require.ErrorIs(t, err, ua.StatusBadUserSignatureInvalid)
+	})
+}
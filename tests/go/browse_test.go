@@ -0,0 +1,231 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBrowseNext performs an integration test to page through the
+// references of a node with continuation points.
+func TestBrowseNext(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	browse := func(t *testing.T, max uint32) *ua.BrowseResult {
+		t.Helper()
+		res, err := c.Browse(ctx, &ua.BrowseRequest{
+			View:                          &ua.ViewDescription{ViewID: ua.NewTwoByteNodeID(0)},
+			RequestedMaxReferencesPerNode: max,
+			NodesToBrowse: []*ua.BrowseDescription{{
+				NodeID:          ua.NewNumericNodeID(0, id.RootFolder),
+				BrowseDirection: ua.BrowseDirectionBoth,
+				ReferenceTypeID: ua.NewNumericNodeID(0, id.References),
+				IncludeSubtypes: true,
+				ResultMask:      uint32(ua.BrowseResultMaskAll),
+			}},
+		})
+		require.NoError(t, err, "Browse failed")
+		require.Len(t, res.Results, 1)
+		return res.Results[0]
+	}
+
+	browseNext := func(t *testing.T, release bool, cps ...[]byte) []*ua.BrowseResult {
+		t.Helper()
+		res, err := c.BrowseNext(ctx, &ua.BrowseNextRequest{
+			ContinuationPoints:        cps,
+			ReleaseContinuationPoints: release,
+		})
+		require.NoError(t, err, "BrowseNext failed")
+		require.Len(t, res.Results, len(cps))
+		return res.Results
+	}
+
+	all := browse(t, 0)
+	require.Equal(t, ua.StatusOK, all.StatusCode)
+	require.Empty(t, all.ContinuationPoint)
+	require.Greater(t, len(all.References), 2)
+
+	t.Run("paging", func(t *testing.T) {
+		res := browse(t, 2)
+		require.Len(t, res.References, 2)
+		require.NotEmpty(t, res.ContinuationPoint)
+
+		refs := res.References
+		for len(res.ContinuationPoint) > 0 {
+			cp := res.ContinuationPoint
+			res = browseNext(t, false, cp)[0]
+			require.Equal(t, ua.StatusOK, res.StatusCode)
+			require.LessOrEqual(t, len(res.References), 2)
+			refs = append(refs, res.References...)
+
+			// a continuation point can only be used once.
+			require.Equal(t, ua.StatusBadContinuationPointInvalid, browseNext(t, false, cp)[0].StatusCode)
+		}
+		require.Equal(t, all.References, refs)
+	})
+
+	t.Run("release", func(t *testing.T) {
+		cp := browse(t, 1).ContinuationPoint
+		require.NotEmpty(t, cp)
+
+		res := browseNext(t, true, cp, []byte("unknown"))
+		require.Equal(t, ua.StatusOK, res[0].StatusCode)
+		require.Empty(t, res[0].References)
+		require.Equal(t, ua.StatusBadContinuationPointInvalid, res[1].StatusCode)
+
+		require.Equal(t, ua.StatusBadContinuationPointInvalid, browseNext(t, false, cp)[0].StatusCode)
+	})
+
+	t.Run("max continuation points", func(t *testing.T) {
+		var cps [][]byte
+		for i := 0; i < 10; i++ {
+			res := browse(t, 1)
+			require.NotEmpty(t, res.ContinuationPoint)
+			cps = append(cps, res.ContinuationPoint)
+		}
+		require.Equal(t, ua.StatusBadNoContinuationPoints, browse(t, 1).StatusCode)
+
+		browseNext(t, true, cps...)
+		require.NotEmpty(t, browse(t, 1).ContinuationPoint)
+	})
+
+	t.Run("node references", func(t *testing.T) {
+		refs, err := c.Node(ua.NewNumericNodeID(0, id.RootFolder)).References(ctx, id.References, ua.BrowseDirectionBoth, ua.NodeClassAll, true)
+		require.NoError(t, err, "References failed")
+		require.Equal(t, all.References, refs)
+	})
+}
+
+// TestTranslateBrowsePaths performs an integration test to resolve node ids
+// from browse paths.
+func TestTranslateBrowsePaths(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	// add a reference to a node of another server to test partial matches.
+	ns, err := srv.Namespace(1)
+	require.NoError(t, err)
+	remoteID := ua.NewExpandedNodeID(ua.NewStringNodeID(1, "Elsewhere"), "", 1)
+	status := ns.(server.NodeManager).InsertReference(ua.NewNumericNodeID(1, id.ObjectsFolder), &ua.ReferenceDescription{
+		ReferenceTypeID: ua.NewNumericNodeID(0, id.Organizes),
+		IsForward:       true,
+		NodeID:          remoteID,
+		BrowseName:      &ua.QualifiedName{Name: "Elsewhere"},
+		DisplayName:     &ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: "Elsewhere"},
+		NodeClass:       ua.NodeClassObject,
+		TypeDefinition:  ua.NewNumericExpandedNodeID(0, id.FolderType),
+	})
+	require.Equal(t, ua.StatusOK, status)
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	hierarchical := ua.NewNumericNodeID(0, id.HierarchicalReferences)
+	elem := func(name string) *ua.RelativePathElement {
+		return &ua.RelativePathElement{
+			ReferenceTypeID: hierarchical,
+			IncludeSubtypes: true,
+			TargetName:      &ua.QualifiedName{Name: name},
+		}
+	}
+	translate := func(t *testing.T, start *ua.NodeID, elems ...*ua.RelativePathElement) *ua.BrowsePathResult {
+		t.Helper()
+		if elems == nil {
+			elems = []*ua.RelativePathElement{}
+		}
+		res, err := c.TranslateBrowsePathsToNodeIDs(ctx, &ua.TranslateBrowsePathsToNodeIDsRequest{
+			BrowsePaths: []*ua.BrowsePath{{
+				StartingNode: start,
+				RelativePath: &ua.RelativePath{Elements: elems},
+			}},
+		})
+		require.NoError(t, err, "TranslateBrowsePathsToNodeIDs failed")
+		require.Len(t, res.Results, 1)
+		return res.Results[0]
+	}
+
+	root := ua.NewNumericNodeID(0, id.RootFolder)
+	rwID := ua.NewStringNodeID(1, "ReadWriteVariable")
+
+	t.Run("across namespaces", func(t *testing.T) {
+		res := translate(t, root, elem("Objects"), elem("NodeNamespace"), elem("ReadWriteVariable"))
+		require.Equal(t, ua.StatusOK, res.StatusCode)
+		require.Len(t, res.Targets, 1)
+		require.Equal(t, rwID, res.Targets[0].TargetID.NodeID)
+		require.Equal(t, uint32(math.MaxUint32), res.Targets[0].RemainingPathIndex)
+	})
+
+	t.Run("inverse", func(t *testing.T) {
+		res := translate(t, ua.NewNumericNodeID(0, id.ObjectsFolder), &ua.RelativePathElement{
+			ReferenceTypeID: hierarchical,
+			IsInverse:       true,
+			IncludeSubtypes: true,
+			TargetName:      &ua.QualifiedName{},
+		})
+		require.Equal(t, ua.StatusOK, res.StatusCode)
+		require.Len(t, res.Targets, 1)
+		require.Equal(t, root.String(), res.Targets[0].TargetID.NodeID.String())
+	})
+
+	t.Run("subtypes", func(t *testing.T) {
+		e := elem("ReadWriteVariable")
+		e.IncludeSubtypes = false
+		require.Equal(t, ua.StatusBadNoMatch, translate(t, ua.NewNumericNodeID(1, id.ObjectsFolder), e).StatusCode)
+
+		e.ReferenceTypeID = ua.NewNumericNodeID(0, id.HasComponent)
+		require.Equal(t, ua.StatusOK, translate(t, ua.NewNumericNodeID(1, id.ObjectsFolder), e).StatusCode)
+	})
+
+	t.Run("partial match", func(t *testing.T) {
+		res := translate(t, root, elem("Objects"), elem("NodeNamespace"), elem("Elsewhere"), elem("Child"))
+		require.Equal(t, ua.StatusOK, res.StatusCode)
+		require.Len(t, res.Targets, 1)
+		require.Equal(t, remoteID, res.Targets[0].TargetID)
+		require.Equal(t, uint32(3), res.Targets[0].RemainingPathIndex)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		require.Equal(t, ua.StatusBadNoMatch, translate(t, root, elem("Objects"), elem("unknown")).StatusCode)
+		require.Equal(t, ua.StatusBadNodeIDUnknown, translate(t, ua.NewStringNodeID(1, "unknown"), elem("Objects")).StatusCode)
+		require.Equal(t, ua.StatusBadNothingToDo, translate(t, root).StatusCode)
+		require.Equal(t, ua.StatusBadBrowseNameInvalid, translate(t, root, elem(""), elem("Objects")).StatusCode)
+	})
+
+	t.Run("node", func(t *testing.T) {
+		nodeID, err := c.Node(root).TranslateBrowsePathsToNodeIDs(ctx, []*ua.QualifiedName{
+			{Name: "Objects"}, {Name: "NodeNamespace"}, {Name: "ReadWriteVariable"},
+		})
+		require.NoError(t, err, "TranslateBrowsePathsToNodeIDs failed")
+		require.Equal(t, rwID, nodeID)
+	})
+}
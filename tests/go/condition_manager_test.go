@@ -0,0 +1,123 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/monitor"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConditionManager performs an integration test to track the alarms of
+// the server with the condition manager of the monitor package.
+func TestConditionManager(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	levelAlarm := ua.NewStringNodeID(1, "LevelAlarm")
+	pressureAlarm := ua.NewStringNodeID(1, "PressureAlarm")
+
+	// the alarm is active before the manager is created.
+	write := func(t *testing.T, name string, v float64) {
+		t.Helper()
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      ua.NewStringNodeID(1, name),
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+			}},
+		})
+	}
+	write(t, "pressure", 6)
+
+	events := make(chan *monitor.Condition, 100)
+	m, err := monitor.NewConditionManager(ctx, c, &opcua.SubscriptionParameters{Interval: 100 * time.Millisecond}, ua.NewStringNodeID(1, "Area"),
+		func(_ *monitor.ConditionManager, cond *monitor.Condition) { events <- cond },
+	)
+	require.NoError(t, err, "NewConditionManager failed")
+	defer m.Close(ctx)
+
+	// next returns the next condition event.
+	next := func(t *testing.T) *monitor.Condition {
+		t.Helper()
+		select {
+		case cond := <-events:
+			return cond
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for condition event")
+			return nil
+		}
+	}
+
+	t.Run("refresh", func(t *testing.T) {
+		cond := next(t)
+		require.Equal(t, pressureAlarm.String(), cond.ConditionID.String())
+		require.Equal(t, "PressureAlarm", cond.ConditionName)
+		require.Equal(t, "Pump", cond.SourceName)
+		require.True(t, cond.Active)
+		require.False(t, cond.Acked)
+		require.False(t, cond.IsBranch())
+
+		require.Len(t, m.Conditions(), 1)
+		require.NotNil(t, m.Condition(pressureAlarm))
+		require.Nil(t, m.Condition(levelAlarm))
+	})
+
+	t.Run("acknowledge and confirm", func(t *testing.T) {
+		write(t, "level", 80)
+		cond := next(t)
+		require.Equal(t, levelAlarm.String(), cond.ConditionID.String())
+		require.Equal(t, "High", cond.LimitState)
+		require.Equal(t, uint16(700), cond.Severity)
+		require.Len(t, m.Conditions(), 2)
+
+		require.NoError(t, m.Acknowledge(ctx, cond, "seen"))
+		cond = next(t)
+		require.True(t, cond.Acked)
+		require.False(t, cond.Confirmed)
+		require.Equal(t, "seen", cond.Comment)
+		require.True(t, m.Condition(levelAlarm).Acked)
+		require.ErrorIs(t, m.Acknowledge(ctx, cond, "again"), ua.StatusBadConditionBranchAlreadyAcked)
+
+		require.NoError(t, m.Confirm(ctx, cond, "done"))
+		cond = next(t)
+		require.True(t, cond.Confirmed)
+
+		// conditions which are no longer retained leave the table.
+		write(t, "level", 50)
+		cond = next(t)
+		require.False(t, cond.Retain)
+		require.Nil(t, m.Condition(levelAlarm))
+		require.Len(t, m.Conditions(), 1)
+	})
+
+	t.Run("manual refresh", func(t *testing.T) {
+		require.NoError(t, m.Refresh(ctx))
+		cond := next(t)
+		require.Equal(t, pressureAlarm.String(), cond.ConditionID.String())
+		require.Len(t, m.Conditions(), 1)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		require.NoError(t, m.Close(ctx))
+		require.NoError(t, m.Close(ctx), "second Close must be a no-op")
+	})
+}
@@ -0,0 +1,227 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDataChangeFilter performs an integration test to filter the samples of
+// monitored items with deadbands and to modify the filters.
+func TestDataChangeFilter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	// the requests are sent directly so that the publish loop of the client
+	// does not interfere.
+	var sub *ua.CreateSubscriptionResponse
+	err = c.Send(ctx, &ua.CreateSubscriptionRequest{
+		RequestedPublishingInterval: 100,
+		RequestedLifetimeCount:      100,
+		RequestedMaxKeepAliveCount:  2,
+		PublishingEnabled:           true,
+	}, func(v ua.Response) error {
+		sub = v.(*ua.CreateSubscriptionResponse)
+		return nil
+	})
+	require.NoError(t, err, "CreateSubscription failed")
+
+	analog := ua.NewStringNodeID(1, "rw_analog")
+
+	item := func(nodeID *ua.NodeID, attrID ua.AttributeID, handle uint32, filter any) *ua.MonitoredItemCreateRequest {
+		req := opcua.NewMonitoredItemCreateRequestWithDefaults(nodeID, attrID, handle)
+		req.RequestedParameters.Filter = ua.NewExtensionObject(filter)
+		return req
+	}
+	create := func(t *testing.T, items ...*ua.MonitoredItemCreateRequest) []*ua.MonitoredItemCreateResult {
+		t.Helper()
+		var res *ua.CreateMonitoredItemsResponse
+		err := c.Send(ctx, &ua.CreateMonitoredItemsRequest{
+			SubscriptionID:     sub.SubscriptionID,
+			TimestampsToReturn: ua.TimestampsToReturnBoth,
+			ItemsToCreate:      items,
+		}, func(v ua.Response) error {
+			res = v.(*ua.CreateMonitoredItemsResponse)
+			return nil
+		})
+		require.NoError(t, err, "CreateMonitoredItems failed")
+		require.Len(t, res.Results, len(items))
+		return res.Results
+	}
+	// publish returns the client handles of the reported items.
+	publish := func(t *testing.T) []uint32 {
+		t.Helper()
+		var res *ua.PublishResponse
+		err := c.Send(ctx, &ua.PublishRequest{SubscriptionAcknowledgements: []*ua.SubscriptionAcknowledgement{}}, func(v ua.Response) error {
+			res = v.(*ua.PublishResponse)
+			return nil
+		})
+		require.NoError(t, err, "Publish failed")
+		var handles []uint32
+		for _, eo := range res.NotificationMessage.NotificationData {
+			dcn, ok := eo.Value.(*ua.DataChangeNotification)
+			require.True(t, ok, "got %T", eo.Value)
+			for _, n := range dcn.MonitoredItems {
+				handles = append(handles, n.ClientHandle)
+			}
+		}
+		sort.Slice(handles, func(i, j int) bool { return handles[i] < handles[j] })
+		return handles
+	}
+	write := func(t *testing.T, v float64) {
+		t.Helper()
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      analog,
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+			}},
+		})
+	}
+
+	res := create(t,
+		item(analog, ua.AttributeIDValue, 1, &ua.DataChangeFilter{
+			Trigger:       ua.DataChangeTriggerStatusValue,
+			DeadbandType:  uint32(ua.DeadbandTypeAbsolute),
+			DeadbandValue: 5,
+		}),
+		// 10% of the EURange 0..200
+		item(analog, ua.AttributeIDValue, 2, &ua.DataChangeFilter{
+			Trigger:       ua.DataChangeTriggerStatusValue,
+			DeadbandType:  uint32(ua.DeadbandTypePercent),
+			DeadbandValue: 10,
+		}),
+		item(analog, ua.AttributeIDValue, 3, &ua.DataChangeFilter{
+			Trigger: ua.DataChangeTriggerStatus,
+		}),
+	)
+	for _, r := range res {
+		require.Equal(t, ua.StatusOK, r.StatusCode)
+	}
+
+	t.Run("deadband", func(t *testing.T) {
+		// the first sample is always reported.
+		require.Equal(t, []uint32{1, 2, 3}, publish(t))
+
+		write(t, 53)
+		require.Empty(t, publish(t))
+
+		write(t, 60)
+		require.Equal(t, []uint32{1}, publish(t))
+
+		write(t, 75)
+		require.Equal(t, []uint32{1, 2}, publish(t))
+
+		// the percent deadband follows changes of the EURange.
+		setRange := func(t *testing.T, high float64) {
+			t.Helper()
+			testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+				NodesToWrite: []*ua.WriteValue{{
+					NodeID:      ua.NewStringNodeID(1, "rw_analog.EURange"),
+					AttributeID: ua.AttributeIDValue,
+					Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(ua.NewExtensionObject(&ua.Range{Low: 0, High: high}))},
+				}},
+			})
+		}
+		setRange(t, 10)
+		defer setRange(t, 200)
+		write(t, 73)
+		require.Equal(t, []uint32{2}, publish(t))
+	})
+
+	t.Run("modify", func(t *testing.T) {
+		var mod *ua.ModifyMonitoredItemsResponse
+		err := c.Send(ctx, &ua.ModifyMonitoredItemsRequest{
+			SubscriptionID:     sub.SubscriptionID,
+			TimestampsToReturn: ua.TimestampsToReturnBoth,
+			ItemsToModify: []*ua.MonitoredItemModifyRequest{
+				{
+					MonitoredItemID: res[1].MonitoredItemID,
+					RequestedParameters: &ua.MonitoringParameters{
+						ClientHandle:     2,
+						SamplingInterval: 200,
+						QueueSize:        2,
+						DiscardOldest:    true,
+						Filter: ua.NewExtensionObject(&ua.DataChangeFilter{
+							Trigger:       ua.DataChangeTriggerStatusValue,
+							DeadbandType:  uint32(ua.DeadbandTypeAbsolute),
+							DeadbandValue: 1,
+						}),
+					},
+				},
+				{
+					MonitoredItemID: res[2].MonitoredItemID + 100,
+					RequestedParameters: &ua.MonitoringParameters{
+						Filter: ua.NewExtensionObject(nil),
+					},
+				},
+			},
+		}, func(v ua.Response) error {
+			mod = v.(*ua.ModifyMonitoredItemsResponse)
+			return nil
+		})
+		require.NoError(t, err, "ModifyMonitoredItems failed")
+		require.Len(t, mod.Results, 2)
+		require.Equal(t, ua.StatusOK, mod.Results[0].StatusCode)
+		require.Equal(t, 200.0, mod.Results[0].RevisedSamplingInterval)
+		require.Equal(t, uint32(2), mod.Results[0].RevisedQueueSize)
+		require.Equal(t, ua.StatusBadMonitoredItemIDInvalid, mod.Results[1].StatusCode)
+
+		// only the modified filter reports a small change.
+		write(t, 77)
+		require.Equal(t, []uint32{2}, publish(t))
+	})
+
+	t.Run("invalid filters", func(t *testing.T) {
+		res := create(t,
+			item(ua.NewStringNodeID(1, "rw_int32"), ua.AttributeIDValue, 10, &ua.DataChangeFilter{
+				DeadbandType:  uint32(ua.DeadbandTypePercent),
+				DeadbandValue: 10,
+			}),
+			item(ua.NewStringNodeID(1, "rw_bool"), ua.AttributeIDValue, 11, &ua.DataChangeFilter{
+				DeadbandType:  uint32(ua.DeadbandTypeAbsolute),
+				DeadbandValue: 1,
+			}),
+			item(analog, ua.AttributeIDValue, 12, &ua.DataChangeFilter{
+				DeadbandType:  uint32(ua.DeadbandTypeAbsolute),
+				DeadbandValue: -1,
+			}),
+			item(analog, ua.AttributeIDValue, 13, &ua.DataChangeFilter{
+				DeadbandType:  uint32(ua.DeadbandTypePercent),
+				DeadbandValue: 101,
+			}),
+			item(analog, ua.AttributeIDBrowseName, 14, &ua.DataChangeFilter{}),
+			item(analog, ua.AttributeIDValue, 15, &ua.AggregateFilter{
+				StartTime:              time.Now(),
+				AggregateType:          ua.NewNumericNodeID(0, 2342),
+				AggregateConfiguration: &ua.AggregateConfiguration{},
+			}),
+		)
+		require.Equal(t, ua.StatusBadMonitoredItemFilterUnsupported, res[0].StatusCode)
+		require.Equal(t, ua.StatusBadDeadbandFilterInvalid, res[1].StatusCode)
+		require.Equal(t, ua.StatusBadDeadbandFilterInvalid, res[2].StatusCode)
+		require.Equal(t, ua.StatusBadDeadbandFilterInvalid, res[3].StatusCode)
+		require.Equal(t, ua.StatusBadFilterNotAllowed, res[4].StatusCode)
+		require.Equal(t, ua.StatusBadMonitoredItemFilterUnsupported, res[5].StatusCode)
+	})
+}
@@ -0,0 +1,146 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
+)
+
+// TestECC performs an integration test to connect a client and a server
+// with the ECC security policies and to authenticate users with passwords
+// in an EccEncryptedSecret.
+func TestECC(t *testing.T) {
+	// the ports are below the ephemeral port range so that the clients of
+	// one test cannot block the port of the next one.
+	tests := []struct {
+		policy  string
+		keyType uacert.KeyType
+		port    int
+	}{
+		{ua.SecurityPolicyURIECCNistP256, uacert.NistP256, 4851},
+		{ua.SecurityPolicyURIECCNistP384, uacert.NistP384, 4852},
+		{ua.SecurityPolicyURIECCBrainpoolP256r1, uacert.BrainpoolP256r1, 4854},
+		{ua.SecurityPolicyURIECCBrainpoolP384r1, uacert.BrainpoolP384r1, 4855},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+
+			generate := func(uri string) *uacert.Certificate {
+				c, err := uacert.Generate(uacert.Options{ApplicationURI: uri, Hosts: []string{"localhost"}, KeyType: tt.keyType})
+				require.NoError(t, err, "Generate failed")
+				return c
+			}
+			srvCert := generate("urn:gopcua:ecc:server")
+			cliCert := generate("urn:gopcua:ecc:client")
+
+			srv := server.New(
+				server.EnableSecurity(tt.policy, ua.MessageSecurityModeSign),
+				server.EnableSecurity(tt.policy, ua.MessageSecurityModeSignAndEncrypt),
+				server.EnableAuthMode(ua.UserTokenTypeUserName),
+				server.EnableAuthMode(ua.UserTokenTypeCertificate),
+				server.SetAuthenticator(server.AuthenticatorFunc(authenticate)),
+				server.EndPoint("localhost", tt.port),
+				server.ApplicationCertificate(srvCert),
+			)
+			require.NoError(t, srv.Start(ctx), "Start failed")
+			defer srv.Close()
+
+			addr := fmt.Sprintf("opc.tcp://localhost:%d", tt.port)
+			var (
+				eps []*ua.EndpointDescription
+				err error
+			)
+			require.Eventually(t, func() bool {
+				eps, err = opcua.GetEndpoints(ctx, addr)
+				return err == nil
+			}, 5*time.Second, 50*time.Millisecond, "GetEndpoints failed")
+
+			// X509 user tokens are only supported with RSA keys.
+			for _, ep := range eps {
+				for _, tok := range ep.UserIdentityTokens {
+					require.NotEqual(t, ua.UserTokenTypeCertificate, tok.TokenType, tok.PolicyID)
+				}
+			}
+
+			connect := func(t *testing.T, mode ua.MessageSecurityMode, user, pass string) error {
+				t.Helper()
+				ep, err := opcua.SelectEndpoint(eps, tt.policy, mode)
+				require.NoError(t, err, "SelectEndpoint failed")
+
+				c, err := opcua.NewClient(addr,
+					opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName),
+					opcua.AuthUsername(user, pass),
+					opcua.ApplicationCertificate(cliCert),
+					opcua.AutoReconnect(false),
+				)
+				require.NoError(t, err, "NewClient failed")
+				if err := c.Connect(ctx); err != nil {
+					return err
+				}
+				defer c.Close(ctx)
+
+				_, err = c.Node(ua.NewNumericNodeID(0, 2258)).Value(ctx)
+				require.NoError(t, err, "Read failed")
+				return nil
+			}
+
+			for _, mode := range []ua.MessageSecurityMode{ua.MessageSecurityModeSign, ua.MessageSecurityModeSignAndEncrypt} {
+				t.Run(mode.String(), func(t *testing.T) {
+					require.NoError(t, connect(t, mode, "user", "pass"))
+
+					err := connect(t, mode, "user", "secret")
+					require.ErrorIs(t, err, ua.StatusBadUserAccessDenied)
+				})
+			}
+		})
+	}
+}
+
+// TestECCMixedPolicies checks that a server with an ECDSA certificate does
+// not start with RSA security policies and vice versa.
+func TestECCMixedPolicies(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	eccCert, err := uacert.Generate(uacert.Options{ApplicationURI: "urn:gopcua:ecc:server", Hosts: []string{"localhost"}, KeyType: uacert.NistP256})
+	require.NoError(t, err, "Generate failed")
+	rsaCert, err := uacert.Generate(uacert.Options{ApplicationURI: "urn:gopcua:rsa:server", Hosts: []string{"localhost"}})
+	require.NoError(t, err, "Generate failed")
+
+	tests := []struct {
+		name string
+		cert *uacert.Certificate
+		sec  []string
+	}{
+		{"rsa policy with ecc certificate", eccCert, []string{ua.SecurityPolicyURIECCNistP256, ua.SecurityPolicyURIBasic256Sha256}},
+		{"ecc policy with rsa certificate", rsaCert, []string{ua.SecurityPolicyURIBasic256Sha256, ua.SecurityPolicyURIECCNistP256}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []server.Option{
+				server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+				server.EndPoint("localhost", 4853),
+				server.ApplicationCertificate(tt.cert),
+			}
+			for _, sec := range tt.sec {
+				opts = append(opts, server.EnableSecurity(sec, ua.MessageSecurityModeSignAndEncrypt))
+			}
+			srv := server.New(opts...)
+			require.ErrorContains(t, srv.Start(ctx), "requires a certificate")
+		})
+	}
+}
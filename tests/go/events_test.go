@@ -0,0 +1,189 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/filter"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEvents performs an integration test to fire events on the server and
+// to receive them with event filters.
+func TestEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	// the requests are sent directly so that the publish loop of the client
+	// does not interfere.
+	var sub *ua.CreateSubscriptionResponse
+	err = c.Send(ctx, &ua.CreateSubscriptionRequest{
+		RequestedPublishingInterval: 100,
+		RequestedLifetimeCount:      100,
+		RequestedMaxKeepAliveCount:  20,
+		PublishingEnabled:           true,
+	}, func(v ua.Response) error {
+		sub = v.(*ua.CreateSubscriptionResponse)
+		return nil
+	})
+	require.NoError(t, err, "CreateSubscription failed")
+
+	serverID := ua.NewNumericNodeID(0, id.Server)
+	area := ua.NewStringNodeID(1, "Area")
+	pump := ua.NewStringNodeID(1, "Pump")
+	baseEventType := ua.NewNumericNodeID(0, id.BaseEventType)
+
+	field := func(typeID *ua.NodeID, name string) *ua.SimpleAttributeOperand {
+		return &ua.SimpleAttributeOperand{
+			TypeDefinitionID: typeID,
+			BrowsePath:       []*ua.QualifiedName{{Name: name}},
+			AttributeID:      ua.AttributeIDValue,
+		}
+	}
+	item := func(nodeID *ua.NodeID, handle uint32, f *ua.EventFilter) *ua.MonitoredItemCreateRequest {
+		req := opcua.NewMonitoredItemCreateRequestWithDefaults(nodeID, ua.AttributeIDEventNotifier, handle)
+		req.RequestedParameters.Filter = ua.NewExtensionObject(f)
+		return req
+	}
+	create := func(t *testing.T, items ...*ua.MonitoredItemCreateRequest) []*ua.MonitoredItemCreateResult {
+		t.Helper()
+		var res *ua.CreateMonitoredItemsResponse
+		err := c.Send(ctx, &ua.CreateMonitoredItemsRequest{
+			SubscriptionID:     sub.SubscriptionID,
+			TimestampsToReturn: ua.TimestampsToReturnBoth,
+			ItemsToCreate:      items,
+		}, func(v ua.Response) error {
+			res = v.(*ua.CreateMonitoredItemsResponse)
+			return nil
+		})
+		require.NoError(t, err, "CreateMonitoredItems failed")
+		require.Len(t, res.Results, len(items))
+		return res.Results
+	}
+	// publish returns the event fields by client handle.
+	publish := func(t *testing.T) map[uint32][][]*ua.Variant {
+		t.Helper()
+		var res *ua.PublishResponse
+		err := c.Send(ctx, &ua.PublishRequest{SubscriptionAcknowledgements: []*ua.SubscriptionAcknowledgement{}}, func(v ua.Response) error {
+			res = v.(*ua.PublishResponse)
+			return nil
+		})
+		require.NoError(t, err, "Publish failed")
+		events := map[uint32][][]*ua.Variant{}
+		for _, eo := range res.NotificationMessage.NotificationData {
+			list, ok := eo.Value.(*ua.EventNotificationList)
+			require.True(t, ok, "got %T", eo.Value)
+			for _, ev := range list.Events {
+				events[ev.ClientHandle] = append(events[ev.ClientHandle], ev.EventFields)
+			}
+		}
+		return events
+	}
+
+	severe := filter.GreaterThanOrEqual(filter.Value(baseEventType, "Severity"), filter.Literal(uint16(500)))
+	res := create(t,
+		item(serverID, 1, &ua.EventFilter{
+			SelectClauses: []*ua.SimpleAttributeOperand{
+				field(baseEventType, "EventType"),
+				field(baseEventType, "SourceName"),
+				field(baseEventType, "Message"),
+				field(baseEventType, "Severity"),
+			},
+			WhereClause: severe.ContentFilter(),
+		}),
+		item(area, 2, &ua.EventFilter{
+			SelectClauses: []*ua.SimpleAttributeOperand{
+				field(baseEventType, "SourceName"),
+				field(ua.NewTwoByteNodeID(0), "Message"),
+				field(baseEventType, "Unknown"),
+				field(ua.NewNumericNodeID(0, id.GeneralModelChangeEventType), "Changes"),
+			},
+			WhereClause: &ua.ContentFilter{},
+		}),
+	)
+	require.Equal(t, ua.StatusOK, res[0].StatusCode)
+	require.Equal(t, ua.StatusOK, res[1].StatusCode)
+	require.Equal(t, 0.0, res[1].RevisedSamplingInterval)
+	fr, ok := res[1].FilterResult.Value.(*ua.EventFilterResult)
+	require.True(t, ok, "got %T", res[1].FilterResult.Value)
+	require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusOK, ua.StatusBadNodeIDUnknown, ua.StatusOK}, fr.SelectClauseResults)
+
+	t.Run("fire", func(t *testing.T) {
+		_, err := srv.FireEvent(pump, baseEventType, map[string]*ua.Variant{
+			"Message":  ua.MustVariant(ua.NewLocalizedText("overheated")),
+			"Severity": ua.MustVariant(uint16(700)),
+		})
+		require.NoError(t, err, "FireEvent failed")
+		_, err = srv.FireEvent(pump, baseEventType, map[string]*ua.Variant{
+			"Message":  ua.MustVariant(ua.NewLocalizedText("running")),
+			"Severity": ua.MustVariant(uint16(100)),
+		})
+		require.NoError(t, err, "FireEvent failed")
+
+		events := publish(t)
+
+		// the where clause of the server drops the second event.
+		require.Len(t, events[1], 1)
+		fields := events[1][0]
+		require.Equal(t, baseEventType.String(), fields[0].Value().(*ua.NodeID).String())
+		require.Equal(t, "Pump", fields[1].Value())
+		require.Equal(t, "overheated", fields[2].Value().(*ua.LocalizedText).Text)
+		require.Equal(t, uint16(700), fields[3].Value())
+
+		// the area reports both events of its event source.
+		require.Len(t, events[2], 2)
+		require.Equal(t, "Pump", events[2][0][0].Value())
+		require.Equal(t, "overheated", events[2][0][1].Value().(*ua.LocalizedText).Text)
+		require.Equal(t, "running", events[2][1][1].Value().(*ua.LocalizedText).Text)
+		require.Nil(t, events[2][0][2].Value())
+		require.Nil(t, events[2][0][3].Value(), "not a model change event")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := srv.FireEvent(ua.NewStringNodeID(1, "unknown"), baseEventType, nil)
+		require.ErrorIs(t, err, ua.StatusBadNodeIDUnknown)
+		_, err = srv.FireEvent(pump, ua.NewNumericNodeID(0, id.BaseObjectType), nil)
+		require.ErrorIs(t, err, ua.StatusBadTypeDefinitionInvalid)
+
+		selects := []*ua.SimpleAttributeOperand{field(baseEventType, "Message")}
+		value := opcua.NewMonitoredItemCreateRequestWithDefaults(ua.NewStringNodeID(1, "rw_int32"), ua.AttributeIDValue, 13)
+		value.RequestedParameters.Filter = ua.NewExtensionObject(&ua.EventFilter{SelectClauses: selects, WhereClause: &ua.ContentFilter{}})
+		res := create(t,
+			// not an event notifier
+			item(pump, 10, &ua.EventFilter{SelectClauses: selects, WhereClause: &ua.ContentFilter{}}),
+			item(serverID, 11, &ua.EventFilter{WhereClause: &ua.ContentFilter{}}),
+			item(serverID, 12, &ua.EventFilter{
+				SelectClauses: selects,
+				WhereClause:   &ua.ContentFilter{Elements: []*ua.ContentFilterElement{{FilterOperator: ua.FilterOperatorEquals}}},
+			}),
+			value,
+		)
+		require.Equal(t, ua.StatusBadNotSupported, res[0].StatusCode)
+		require.Equal(t, ua.StatusBadEventFilterInvalid, res[1].StatusCode)
+		require.Equal(t, ua.StatusBadEventFilterInvalid, res[2].StatusCode)
+		fr, ok := res[2].FilterResult.Value.(*ua.EventFilterResult)
+		require.True(t, ok, "got %T", res[2].FilterResult.Value)
+		require.Len(t, fr.WhereClauseResult.ElementResults, 1)
+		require.Equal(t, ua.StatusBadFilterOperandCountMismatch, fr.WhereClauseResult.ElementResults[0].StatusCode)
+		require.Equal(t, ua.StatusBadMonitoredItemFilterUnsupported, res[3].StatusCode)
+	})
+}
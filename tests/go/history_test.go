@@ -0,0 +1,386 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/aggregate"
+	"github.com/gopcua/opcua/filter"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHistoryRead performs an integration test to read the
+// history of a historizing variable and an event notifier.
+func TestHistoryRead(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	nodeID := ua.NewStringNodeID(1, "hist_float64")
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec float64) time.Time { return t0.Add(time.Duration(sec * float64(time.Second))) }
+
+	// every write to a historizing variable is recorded.
+	for i := 1; i <= 5; i++ {
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      nodeID,
+				AttributeID: ua.AttributeIDValue,
+				Value: &ua.DataValue{
+					EncodingMask:    ua.DataValueValue | ua.DataValueSourceTimestamp,
+					Value:           ua.MustVariant(float64(i)),
+					SourceTimestamp: at(float64(i)),
+				},
+			}},
+		})
+	}
+
+	nodes := func(nodeID *ua.NodeID, cp []byte) []*ua.HistoryReadValueID {
+		return []*ua.HistoryReadValueID{{NodeID: nodeID, DataEncoding: &ua.QualifiedName{}, ContinuationPoint: cp}}
+	}
+
+	values := func(t *testing.T, res *ua.HistoryReadResult) []any {
+		t.Helper()
+		data, ok := res.HistoryData.Value.(*ua.HistoryData)
+		require.True(t, ok, "got %T, want *ua.HistoryData", res.HistoryData.Value)
+		var vals []any
+		for _, dv := range data.DataValues {
+			if dv.Status != ua.StatusOK {
+				vals = append(vals, dv.Status)
+				continue
+			}
+			vals = append(vals, dv.Value.Value())
+		}
+		return vals
+	}
+
+	t.Run("raw", func(t *testing.T) {
+		res, err := c.HistoryReadRawModified(ctx, nodes(nodeID, nil), &ua.ReadRawModifiedDetails{
+			StartTime: at(0),
+			EndTime:   at(10),
+		})
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+		require.Equal(t, []any{1.0, 2.0, 3.0, 4.0, 5.0}, values(t, res.Results[0]))
+	})
+
+	t.Run("reverse", func(t *testing.T) {
+		res, err := c.HistoryReadRawModified(ctx, nodes(nodeID, nil), &ua.ReadRawModifiedDetails{
+			StartTime: at(10),
+			EndTime:   at(3),
+		})
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		require.Equal(t, []any{5.0, 4.0, 3.0}, values(t, res.Results[0]))
+
+		// without a start time the values are read backwards from the end time.
+		res, err = c.HistoryReadRawModified(ctx, nodes(nodeID, nil), &ua.ReadRawModifiedDetails{
+			EndTime:          at(3.5),
+			NumValuesPerNode: 2,
+		})
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		require.Equal(t, []any{3.0, 2.0}, values(t, res.Results[0]))
+		require.NotEmpty(t, res.Results[0].ContinuationPoint)
+	})
+
+	t.Run("bounds", func(t *testing.T) {
+		res, err := c.HistoryReadRawModified(ctx, nodes(nodeID, nil), &ua.ReadRawModifiedDetails{
+			StartTime:    at(1.5),
+			EndTime:      at(3.5),
+			ReturnBounds: true,
+		})
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		require.Equal(t, []any{1.0, 2.0, 3.0, 4.0}, values(t, res.Results[0]))
+
+		res, err = c.HistoryReadRawModified(ctx, nodes(nodeID, nil), &ua.ReadRawModifiedDetails{
+			StartTime:    at(4.5),
+			EndTime:      at(6),
+			ReturnBounds: true,
+		})
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		require.Equal(t, []any{4.0, 5.0, ua.StatusBadBoundNotFound}, values(t, res.Results[0]))
+	})
+
+	t.Run("continuation point", func(t *testing.T) {
+		details := &ua.ReadRawModifiedDetails{
+			StartTime:        at(0),
+			EndTime:          at(10),
+			NumValuesPerNode: 2,
+		}
+		var got []any
+		var cp []byte
+		for {
+			res, err := c.HistoryReadRawModified(ctx, nodes(nodeID, cp), details)
+			require.NoError(t, err, "HistoryReadRawModified failed")
+			require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+			got = append(got, values(t, res.Results[0])...)
+			cp = res.Results[0].ContinuationPoint
+			if len(cp) == 0 {
+				break
+			}
+		}
+		require.Equal(t, []any{1.0, 2.0, 3.0, 4.0, 5.0}, got)
+
+		// a continuation point can only be used once.
+		res, err := c.HistoryReadRawModified(ctx, nodes(nodeID, nil), details)
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		cp = res.Results[0].ContinuationPoint
+		require.NotEmpty(t, cp)
+
+		err = c.Send(ctx, &ua.HistoryReadRequest{
+			HistoryReadDetails:        ua.NewExtensionObject(details),
+			TimestampsToReturn:        ua.TimestampsToReturnBoth,
+			ReleaseContinuationPoints: true,
+			NodesToRead:               nodes(nodeID, cp),
+		}, func(ua.Response) error { return nil })
+		require.NoError(t, err, "release continuation point failed")
+
+		res, err = c.HistoryReadRawModified(ctx, nodes(nodeID, cp), details)
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		require.Equal(t, ua.StatusBadContinuationPointInvalid, res.Results[0].StatusCode)
+	})
+
+	t.Run("at time", func(t *testing.T) {
+		res, err := c.HistoryReadAtTime(ctx, nodes(nodeID, nil), &ua.ReadAtTimeDetails{
+			ReqTimes:        []time.Time{at(2), at(2.5), at(0)},
+			UseSimpleBounds: true,
+		})
+		require.NoError(t, err, "HistoryReadAtTime failed")
+		data := res.Results[0].HistoryData.Value.(*ua.HistoryData)
+		require.Len(t, data.DataValues, 3)
+		require.Equal(t, 2.0, data.DataValues[0].Value.Value())
+		require.Equal(t, 2.5, data.DataValues[1].Value.Value())
+		require.Equal(t, at(2.5), data.DataValues[1].SourceTimestamp)
+		require.Equal(t, ua.StatusBadNoData, data.DataValues[2].Status)
+	})
+
+	t.Run("processed", func(t *testing.T) {
+		details := &ua.ReadProcessedDetails{
+			StartTime:              at(1),
+			EndTime:                at(5),
+			ProcessingInterval:     2000,
+			AggregateType:          []*ua.NodeID{aggregate.Average},
+			AggregateConfiguration: &ua.AggregateConfiguration{UseServerCapabilitiesDefaults: true},
+		}
+		res, err := c.HistoryReadProcessed(ctx, nodes(nodeID, nil), details)
+		require.NoError(t, err, "HistoryReadProcessed failed")
+		require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+		data := res.Results[0].HistoryData.Value.(*ua.HistoryData)
+		require.Len(t, data.DataValues, 2)
+		require.Equal(t, 1.5, data.DataValues[0].Value.Value())
+		require.Equal(t, 3.5, data.DataValues[1].Value.Value())
+		require.Equal(t, aggregate.HistorianCalculated, data.DataValues[1].Status)
+		require.Equal(t, at(3), data.DataValues[1].SourceTimestamp)
+
+		// the same values can be calculated on the client from the raw data.
+		raw, err := c.HistoryReadRawModified(ctx, nodes(nodeID, nil), &ua.ReadRawModifiedDetails{
+			StartTime:    at(1),
+			EndTime:      at(5),
+			ReturnBounds: true,
+		})
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		local, err := aggregate.Process(aggregate.Average, raw.Results[0].HistoryData.Value.(*ua.HistoryData).DataValues, at(1), at(5), 2*time.Second, aggregate.DefaultConfig())
+		require.NoError(t, err, "Process failed")
+		require.Equal(t, data.DataValues[0].Value.Value(), local[0].Value.Value())
+		require.Equal(t, data.DataValues[1].Value.Value(), local[1].Value.Value())
+
+		// the values of variables which are not analog items are stepped.
+		res, err = c.HistoryReadProcessed(ctx, nodes(nodeID, nil), &ua.ReadProcessedDetails{
+			StartTime:              at(1.5),
+			EndTime:                at(3.5),
+			ProcessingInterval:     1000,
+			AggregateType:          []*ua.NodeID{aggregate.Interpolative},
+			AggregateConfiguration: &ua.AggregateConfiguration{UseServerCapabilitiesDefaults: true},
+		})
+		require.NoError(t, err, "HistoryReadProcessed failed")
+		data = res.Results[0].HistoryData.Value.(*ua.HistoryData)
+		require.Len(t, data.DataValues, 2)
+		require.Equal(t, 1.0, data.DataValues[0].Value.Value())
+		require.Equal(t, 2.0, data.DataValues[1].Value.Value())
+
+		details.AggregateType = []*ua.NodeID{ua.NewNumericNodeID(0, id.AggregateFunction_AnnotationCount)}
+		res, err = c.HistoryReadProcessed(ctx, nodes(nodeID, nil), details)
+		require.NoError(t, err, "HistoryReadProcessed failed")
+		require.Equal(t, ua.StatusBadAggregateNotSupported, res.Results[0].StatusCode)
+	})
+
+	t.Run("events", func(t *testing.T) {
+		res, err := c.HistoryReadEvent(ctx, nodes(ua.NewNumericNodeID(1, 85), nil), &ua.ReadEventDetails{
+			StartTime: t0,
+			EndTime:   t0.Add(time.Hour),
+			Filter: &ua.EventFilter{
+				SelectClauses: []*ua.SimpleAttributeOperand{
+					{TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType), BrowsePath: []*ua.QualifiedName{{Name: "Severity"}}, AttributeID: ua.AttributeIDValue},
+					{TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType), BrowsePath: []*ua.QualifiedName{{Name: "Message"}}, AttributeID: ua.AttributeIDValue},
+				},
+				WhereClause: &ua.ContentFilter{},
+			},
+		})
+		require.NoError(t, err, "HistoryReadEvent failed")
+		require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+		events := res.Results[0].HistoryData.Value.(*ua.HistoryEvent).Events
+		require.Len(t, events, 2)
+		require.Equal(t, uint16(100), events[0].EventFields[0].Value())
+		require.Equal(t, "stopped", events[1].EventFields[1].Value().(*ua.LocalizedText).Text)
+
+		// the where clause selects the events.
+		severity := &ua.SimpleAttributeOperand{TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType), BrowsePath: []*ua.QualifiedName{{Name: "Severity"}}, AttributeID: ua.AttributeIDValue}
+		res, err = c.HistoryReadEvent(ctx, nodes(ua.NewNumericNodeID(1, 85), nil), &ua.ReadEventDetails{
+			StartTime: t0,
+			EndTime:   t0.Add(time.Hour),
+			Filter: &ua.EventFilter{
+				SelectClauses: []*ua.SimpleAttributeOperand{severity},
+				WhereClause:   filter.GreaterThan(filter.Value(ua.NewNumericNodeID(0, id.BaseEventType), "Severity"), filter.Literal(uint16(150))).ContentFilter(),
+			},
+		})
+		require.NoError(t, err, "HistoryReadEvent failed")
+		require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+		events = res.Results[0].HistoryData.Value.(*ua.HistoryEvent).Events
+		require.Len(t, events, 1)
+		require.Equal(t, uint16(200), events[0].EventFields[0].Value())
+	})
+}
+
+// TestHistoryUpdate performs an integration test to modify the
+// history of a variable and an event notifier.
+func TestHistoryUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	nodeID := ua.NewStringNodeID(1, "hist_float64")
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return t0.Add(time.Duration(sec) * time.Second) }
+	value := func(v float64, ts time.Time) *ua.DataValue {
+		return &ua.DataValue{
+			EncodingMask:    ua.DataValueValue | ua.DataValueSourceTimestamp,
+			Value:           ua.MustVariant(v),
+			SourceTimestamp: ts,
+		}
+	}
+	results := func(t *testing.T, res *ua.HistoryUpdateResponse, err error) []ua.StatusCode {
+		t.Helper()
+		require.NoError(t, err, "HistoryUpdate failed")
+		require.Len(t, res.Results, 1)
+		require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+		return res.Results[0].OperationResults
+	}
+	read := func(t *testing.T) []any {
+		t.Helper()
+		res, err := c.HistoryReadRawModified(ctx, []*ua.HistoryReadValueID{{NodeID: nodeID, DataEncoding: &ua.QualifiedName{}}}, &ua.ReadRawModifiedDetails{
+			StartTime: at(0),
+			EndTime:   at(10),
+		})
+		require.NoError(t, err, "HistoryReadRawModified failed")
+		var vals []any
+		for _, dv := range res.Results[0].HistoryData.Value.(*ua.HistoryData).DataValues {
+			vals = append(vals, dv.Value.Value())
+		}
+		return vals
+	}
+
+	res, err := c.HistoryInsert(ctx, nodeID, value(1, at(1)), value(2, at(2)), value(3, at(3)))
+	require.Equal(t, []ua.StatusCode{ua.StatusGoodEntryInserted, ua.StatusGoodEntryInserted, ua.StatusGoodEntryInserted}, results(t, res, err))
+
+	res, err = c.HistoryInsert(ctx, nodeID, value(2, at(2)))
+	require.Equal(t, []ua.StatusCode{ua.StatusBadEntryExists}, results(t, res, err))
+
+	res, err = c.HistoryReplace(ctx, nodeID, value(20, at(2)), value(9, at(9)))
+	require.Equal(t, []ua.StatusCode{ua.StatusGoodEntryReplaced, ua.StatusBadNoEntryExists}, results(t, res, err))
+	require.Equal(t, []any{1.0, 20.0, 3.0}, read(t))
+
+	res, err = c.HistoryDeleteAtTime(ctx, nodeID, at(1), at(9))
+	require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusBadNoEntryExists}, results(t, res, err))
+	require.Equal(t, []any{20.0, 3.0}, read(t))
+
+	res, err = c.HistoryDelete(ctx, nodeID, at(0), at(10))
+	results(t, res, err)
+	require.Nil(t, read(t))
+
+	// events are inserted with the fields of the select clauses.
+	selectClause := func(name string) *ua.SimpleAttributeOperand {
+		return &ua.SimpleAttributeOperand{
+			TypeDefinitionID: ua.NewNumericNodeID(0, id.BaseEventType),
+			BrowsePath:       []*ua.QualifiedName{{Name: name}},
+			AttributeID:      ua.AttributeIDValue,
+		}
+	}
+	filter := &ua.EventFilter{
+		SelectClauses: []*ua.SimpleAttributeOperand{selectClause("Time"), selectClause("Message")},
+		WhereClause:   &ua.ContentFilter{},
+	}
+	notifier := ua.NewNumericNodeID(1, 85)
+	res, err = c.HistoryUpdateEvent(ctx, &ua.UpdateEventDetails{
+		NodeID:               notifier,
+		PerformInsertReplace: ua.PerformUpdateTypeInsert,
+		Filter:               filter,
+		EventData: []*ua.HistoryEventFieldList{{
+			EventFields: []*ua.Variant{ua.MustVariant(t0.Add(30 * time.Second)), ua.MustVariant(ua.NewLocalizedText("paused"))},
+		}},
+	})
+	require.Equal(t, []ua.StatusCode{ua.StatusGoodEntryInserted}, results(t, res, err))
+
+	hr, err := c.HistoryReadEvent(ctx, []*ua.HistoryReadValueID{{NodeID: notifier, DataEncoding: &ua.QualifiedName{}}}, &ua.ReadEventDetails{
+		StartTime: t0,
+		EndTime:   t0.Add(time.Hour),
+		Filter:    filter,
+	})
+	require.NoError(t, err, "HistoryReadEvent failed")
+	var msgs []string
+	for _, ev := range hr.Results[0].HistoryData.Value.(*ua.HistoryEvent).Events {
+		msgs = append(msgs, ev.EventFields[1].Value().(*ua.LocalizedText).Text)
+	}
+	require.Equal(t, []string{"started", "paused", "stopped"}, msgs)
+}
+
+// TestMemoryHistoryFull checks that the memory history rejects values
+// which are older than all values of its full buffer.
+func TestMemoryHistoryFull(t *testing.T) {
+	h := server.NewMemoryHistory(2)
+	nodeID := ua.NewStringNodeID(1, "full")
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	value := func(sec int) *ua.DataValue {
+		return &ua.DataValue{
+			EncodingMask:    ua.DataValueValue | ua.DataValueSourceTimestamp,
+			Value:           ua.MustVariant(float64(sec)),
+			SourceTimestamp: t0.Add(time.Duration(sec) * time.Second),
+		}
+	}
+
+	res, err := h.UpdateValues(nodeID, ua.PerformUpdateTypeInsert, []*ua.DataValue{value(2), value(3), value(1), value(4)})
+	require.NoError(t, err, "UpdateValues failed")
+	require.Equal(t, []ua.StatusCode{ua.StatusGoodEntryInserted, ua.StatusGoodEntryInserted, ua.StatusBadOutOfRange, ua.StatusGoodEntryInserted}, res)
+
+	vals, err := h.ReadValues(nodeID, time.Time{}, time.Time{}, false)
+	require.NoError(t, err, "ReadValues failed")
+	require.Len(t, vals, 2)
+	require.Equal(t, 3.0, vals[0].Value.Value())
+	require.Equal(t, 4.0, vals[1].Value.Value())
+}
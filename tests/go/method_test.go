@@ -0,0 +1,246 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallMethod(t *testing.T) {
+	obj := ua.NewNumericNodeID(1, id.ObjectsFolder)
+
+	tests := []struct {
+		name string
+		req  *ua.CallMethodRequest
+		resp *ua.CallMethodResult
+	}{
+		{
+			name: "even",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "even"),
+				InputArguments: []*ua.Variant{ua.MustVariant(int64(12))},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusOK,
+				InputArgumentResults:         []ua.StatusCode{ua.StatusOK},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{ua.MustVariant(true)},
+			},
+		},
+		{
+			name: "div",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "div"),
+				InputArguments: []*ua.Variant{ua.MustVariant(int32(9)), ua.MustVariant(int32(3))},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusOK,
+				InputArgumentResults:         []ua.StatusCode{ua.StatusOK, ua.StatusOK},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{ua.MustVariant(int32(3))},
+			},
+		},
+		{
+			name: "div_error",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "div"),
+				InputArguments: []*ua.Variant{ua.MustVariant(int32(9)), ua.MustVariant(int32(0))},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusBadInvalidArgument,
+				InputArgumentResults:         []ua.StatusCode{ua.StatusOK, ua.StatusOK},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{},
+			},
+		},
+		{
+			name: "concat",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "concat"),
+				InputArguments: []*ua.Variant{ua.MustVariant([]string{"a", "b"}), ua.MustVariant("-")},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusOK,
+				InputArgumentResults:         []ua.StatusCode{ua.StatusOK, ua.StatusOK},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{ua.MustVariant("a-b")},
+			},
+		},
+		{
+			name: "lookup",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "lookup"),
+				InputArguments: []*ua.Variant{ua.MustVariant("objects")},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusOK,
+				InputArgumentResults:         []ua.StatusCode{ua.StatusOK},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{ua.MustVariant(ua.NewNumericNodeID(0, id.ObjectsFolder))},
+			},
+		},
+		{
+			name: "lookup_nil",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "lookup"),
+				InputArguments: []*ua.Variant{ua.MustVariant("nope")},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusOK,
+				InputArgumentResults:         []ua.StatusCode{ua.StatusOK},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{{}},
+			},
+		},
+		{
+			name: "arguments_missing",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "div"),
+				InputArguments: []*ua.Variant{ua.MustVariant(int32(9))},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusBadArgumentsMissing,
+				InputArgumentResults:         []ua.StatusCode{},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{},
+			},
+		},
+		{
+			name: "too_many_arguments",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "even"),
+				InputArguments: []*ua.Variant{ua.MustVariant(int64(1)), ua.MustVariant(int64(2))},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusBadTooManyArguments,
+				InputArgumentResults:         []ua.StatusCode{},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{},
+			},
+		},
+		{
+			name: "type_mismatch",
+			req: &ua.CallMethodRequest{
+				ObjectID:       obj,
+				MethodID:       ua.NewStringNodeID(1, "div"),
+				InputArguments: []*ua.Variant{ua.MustVariant(int32(9)), ua.MustVariant("3")},
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusBadInvalidArgument,
+				InputArgumentResults:         []ua.StatusCode{ua.StatusOK, ua.StatusBadTypeMismatch},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{},
+			},
+		},
+		{
+			name: "panic",
+			req: &ua.CallMethodRequest{
+				ObjectID: obj,
+				MethodID: ua.NewStringNodeID(1, "panic"),
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusBadInternalError,
+				InputArgumentResults:         []ua.StatusCode{},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{},
+			},
+		},
+		{
+			name: "unknown_method",
+			req: &ua.CallMethodRequest{
+				ObjectID: obj,
+				MethodID: ua.NewStringNodeID(1, "nope"),
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusBadMethodInvalid,
+				InputArgumentResults:         []ua.StatusCode{},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{},
+			},
+		},
+		{
+			name: "unknown_object",
+			req: &ua.CallMethodRequest{
+				ObjectID: ua.NewStringNodeID(1, "nope"),
+				MethodID: ua.NewStringNodeID(1, "even"),
+			},
+			resp: &ua.CallMethodResult{
+				StatusCode:                   ua.StatusBadNodeIDUnknown,
+				InputArgumentResults:         []ua.StatusCode{},
+				InputArgumentDiagnosticInfos: []*ua.DiagnosticInfo{},
+				OutputArguments:              []*ua.Variant{},
+			},
+		},
+	}
+
+	t.Run("unregistered_struct", func(t *testing.T) {
+		type point struct{ X, Y int32 }
+		_, err := server.NewMethod(func() *point { return nil })
+		require.Error(t, err, "unregistered structures cannot be encoded")
+
+		_, err = server.NewMethod(func() *ua.Argument { return nil })
+		require.NoError(t, err, "registered structures are supported")
+	})
+
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := c.Call(ctx, tt.req)
+			require.NoError(t, err, "Call failed")
+			require.Equal(t, tt.resp, resp, "Response not equal")
+		})
+	}
+
+	t.Run("InputArguments", func(t *testing.T) {
+		refs, err := c.Node(ua.NewStringNodeID(1, "div")).References(ctx, id.HasProperty, ua.BrowseDirectionForward, ua.NodeClassAll, true)
+		require.NoError(t, err, "References failed")
+		require.Len(t, refs, 2, "expected InputArguments and OutputArguments")
+
+		for _, ref := range refs {
+			if ref.BrowseName.Name != "InputArguments" {
+				continue
+			}
+			v, err := c.NodeFromExpandedNodeID(ref.NodeID).Value(ctx)
+			require.NoError(t, err, "Value failed")
+			eos, ok := v.Value().([]*ua.ExtensionObject)
+			require.True(t, ok, "expected array of extension objects, got %T", v.Value())
+			require.Len(t, eos, 2)
+			arg, ok := eos[0].Value.(*ua.Argument)
+			require.True(t, ok, "expected argument, got %T", eos[0].Value)
+			require.Equal(t, "Input1", arg.Name)
+			require.Equal(t, ua.NewNumericNodeID(0, id.Int32), arg.DataType)
+			return
+		}
+		t.Fatal("InputArguments not found")
+	})
+}
@@ -0,0 +1,115 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/filter"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/monitor"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMonitorEvents performs an integration test to receive events with the
+// node monitor.
+func TestMonitorEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	m, err := monitor.NewNodeMonitor(c)
+	require.NoError(t, err, "NewNodeMonitor failed")
+
+	area := ua.NewStringNodeID(1, "Area")
+	pump := ua.NewStringNodeID(1, "Pump")
+	baseEventType := ua.NewNumericNodeID(0, id.BaseEventType)
+	params := &opcua.SubscriptionParameters{Interval: 100 * time.Millisecond}
+
+	fire := func(t *testing.T, message string, severity uint16) {
+		t.Helper()
+		_, err := srv.FireEvent(pump, baseEventType, map[string]*ua.Variant{
+			"Message":  ua.MustVariant(ua.NewLocalizedText(message)),
+			"Severity": ua.MustVariant(severity),
+		})
+		require.NoError(t, err, "FireEvent failed")
+	}
+
+	t.Run("channel", func(t *testing.T) {
+		ch := make(chan *monitor.EventMessage, 16)
+		sub, err := m.ChanSubscribeEvents(ctx, params, ch, monitor.Request{
+			NodeID:         area,
+			MonitoringMode: ua.MonitoringModeReporting,
+			EventFilter:    monitor.NewEventFilter("SourceName", "/Message", "Severity"),
+		})
+		require.NoError(t, err, "ChanSubscribeEvents failed")
+		defer sub.Unsubscribe(ctx)
+
+		fire(t, "overheated", 700)
+
+		select {
+		case msg := <-ch:
+			require.NoError(t, msg.Error)
+			require.Equal(t, area.String(), msg.NodeID.String())
+			require.Len(t, msg.Fields, 3)
+			require.Equal(t, "Pump", msg.Fields["SourceName"].Value())
+			require.Equal(t, "overheated", msg.Fields["Message"].Value().(*ua.LocalizedText).Text)
+			require.Equal(t, uint16(700), msg.Fields["Severity"].Value())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		require.Equal(t, uint64(1), sub.Delivered())
+		require.Equal(t, uint64(0), sub.Dropped())
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		ch := make(chan *monitor.EventMessage, 16)
+		f := monitor.NewEventFilter("Message").
+			Where(filter.GreaterThanOrEqual(filter.Value(baseEventType, "Severity"), filter.Literal(uint16(500))))
+		sub, err := m.SubscribeEvents(ctx, params, func(_ *monitor.Subscription, msg *monitor.EventMessage) { ch <- msg }, monitor.Request{
+			NodeID:         area,
+			MonitoringMode: ua.MonitoringModeReporting,
+			EventFilter:    f,
+		})
+		require.NoError(t, err, "SubscribeEvents failed")
+		defer sub.Unsubscribe(ctx)
+
+		// the where clause drops the first event.
+		fire(t, "running", 100)
+		fire(t, "stopped", 600)
+
+		select {
+		case msg := <-ch:
+			require.NoError(t, msg.Error)
+			require.Equal(t, "stopped", msg.Fields["Message"].Value().(*ua.LocalizedText).Text)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		require.Equal(t, uint64(1), sub.Delivered())
+	})
+
+	t.Run("not an event notifier", func(t *testing.T) {
+		_, err := m.SubscribeEvents(ctx, params, func(*monitor.Subscription, *monitor.EventMessage) {}, monitor.Request{
+			NodeID:         pump,
+			MonitoringMode: ua.MonitoringModeReporting,
+			EventFilter:    monitor.NewEventFilter("Message"),
+		})
+		require.ErrorIs(t, err, ua.StatusBadNotSupported)
+	})
+}
@@ -0,0 +1,239 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNodeManagement performs an integration test to add and delete nodes
+// and references at runtime.
+func TestNodeManagement(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	start := time.Now()
+	objects := ua.NewNumericExpandedNodeID(1, id.ObjectsFolder)
+	lineID := ua.NewStringNodeID(1, "line1")
+
+	addNode := func(t *testing.T, item *ua.AddNodesItem) *ua.AddNodesResult {
+		t.Helper()
+		res, err := c.AddNodes(ctx, &ua.AddNodesRequest{NodesToAdd: []*ua.AddNodesItem{item}})
+		require.NoError(t, err, "AddNodes failed")
+		require.Len(t, res.Results, 1)
+		return res.Results[0]
+	}
+
+	browse := func(t *testing.T, nodeID *ua.NodeID) []string {
+		t.Helper()
+		res, err := c.Browse(ctx, &ua.BrowseRequest{
+			NodesToBrowse: []*ua.BrowseDescription{{
+				NodeID:          nodeID,
+				BrowseDirection: ua.BrowseDirectionForward,
+				ReferenceTypeID: ua.NewNumericNodeID(0, id.HierarchicalReferences),
+				IncludeSubtypes: true,
+				ResultMask:      uint32(ua.BrowseResultMaskAll),
+			}},
+		})
+		require.NoError(t, err, "Browse failed")
+		var names []string
+		for _, ref := range res.Results[0].References {
+			names = append(names, ref.BrowseName.Name)
+		}
+		return names
+	}
+
+	line := &ua.AddNodesItem{
+		ParentNodeID:       objects,
+		ReferenceTypeID:    ua.NewNumericNodeID(0, id.Organizes),
+		RequestedNewNodeID: ua.NewExpandedNodeID(lineID, "", 0),
+		BrowseName:         &ua.QualifiedName{NamespaceIndex: 1, Name: "Line1"},
+		NodeClass:          ua.NodeClassObject,
+		NodeAttributes: ua.NewExtensionObject(&ua.ObjectAttributes{
+			SpecifiedAttributes: uint32(ua.NodeAttributesMaskDisplayName),
+			DisplayName:         &ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: "Line 1"},
+			Description:         &ua.LocalizedText{},
+		}),
+		TypeDefinition: ua.NewNumericExpandedNodeID(0, id.FolderType),
+	}
+
+	var speedID *ua.NodeID
+	t.Run("add nodes", func(t *testing.T) {
+		res := addNode(t, line)
+		require.Equal(t, ua.StatusOK, res.StatusCode)
+		require.Equal(t, lineID, res.AddedNodeID)
+
+		// without a requested node id the server assigns one.
+		res = addNode(t, &ua.AddNodesItem{
+			ParentNodeID:    ua.NewExpandedNodeID(lineID, "", 0),
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HasComponent),
+			BrowseName:      &ua.QualifiedName{NamespaceIndex: 1, Name: "Speed"},
+			NodeClass:       ua.NodeClassVariable,
+			NodeAttributes: ua.NewExtensionObject(&ua.VariableAttributes{
+				SpecifiedAttributes: uint32(ua.NodeAttributesMaskValue | ua.NodeAttributesMaskDataType | ua.NodeAttributesMaskAccessLevel | ua.NodeAttributesMaskUserAccessLevel),
+				DisplayName:         &ua.LocalizedText{},
+				Description:         &ua.LocalizedText{},
+				Value:               ua.MustVariant(42.0),
+				DataType:            ua.NewNumericNodeID(0, id.Double),
+				AccessLevel:         byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite),
+				UserAccessLevel:     byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite),
+			}),
+			TypeDefinition: ua.NewNumericExpandedNodeID(0, id.BaseDataVariableType),
+		})
+		require.Equal(t, ua.StatusOK, res.StatusCode)
+		require.Equal(t, uint16(1), res.AddedNodeID.Namespace())
+		speedID = res.AddedNodeID
+
+		require.Contains(t, browse(t, objects.NodeID), "Line1")
+		require.Equal(t, []string{"Speed"}, browse(t, lineID))
+
+		testRead(t, ctx, c, 42.0, speedID)
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      speedID,
+				AttributeID: ua.AttributeIDValue,
+				Value: &ua.DataValue{
+					EncodingMask: ua.DataValueValue,
+					Value:        ua.MustVariant(43.0),
+				},
+			}},
+		})
+		testRead(t, ctx, c, 43.0, speedID)
+	})
+
+	t.Run("add nodes errors", func(t *testing.T) {
+		item := *line
+		require.Equal(t, ua.StatusBadNodeIDExists, addNode(t, &item).StatusCode)
+
+		item.RequestedNewNodeID = ua.NewTwoByteExpandedNodeID(0)
+		require.Equal(t, ua.StatusBadBrowseNameDuplicated, addNode(t, &item).StatusCode)
+
+		item.BrowseName = &ua.QualifiedName{NamespaceIndex: 1, Name: "Line2"}
+		item.ParentNodeID = ua.NewStringExpandedNodeID(1, "unknown")
+		require.Equal(t, ua.StatusBadParentNodeIDInvalid, addNode(t, &item).StatusCode)
+
+		item.ParentNodeID = objects
+		item.TypeDefinition = ua.NewNumericExpandedNodeID(0, id.BaseDataVariableType)
+		require.Equal(t, ua.StatusBadTypeDefinitionInvalid, addNode(t, &item).StatusCode)
+
+		item.TypeDefinition = ua.NewNumericExpandedNodeID(0, id.FolderType)
+		item.ReferenceTypeID = ua.NewNumericNodeID(0, id.HasTypeDefinition)
+		require.Equal(t, ua.StatusBadReferenceNotAllowed, addNode(t, &item).StatusCode)
+
+		item.ReferenceTypeID = ua.NewNumericNodeID(0, id.Organizes)
+		item.NodeAttributes = ua.NewExtensionObject(&ua.MethodAttributes{DisplayName: &ua.LocalizedText{}, Description: &ua.LocalizedText{}})
+		require.Equal(t, ua.StatusBadNodeAttributesInvalid, addNode(t, &item).StatusCode)
+	})
+
+	t.Run("references", func(t *testing.T) {
+		ref := &ua.AddReferencesItem{
+			SourceNodeID:    objects.NodeID,
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.Organizes),
+			IsForward:       true,
+			TargetNodeID:    ua.NewExpandedNodeID(speedID, "", 0),
+			TargetNodeClass: ua.NodeClassVariable,
+		}
+		res, err := c.AddReferences(ctx, &ua.AddReferencesRequest{ReferencesToAdd: []*ua.AddReferencesItem{ref, ref}})
+		require.NoError(t, err, "AddReferences failed")
+		require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusBadDuplicateReferenceNotAllowed}, res.Results)
+		require.Contains(t, browse(t, objects.NodeID), "Speed")
+
+		del := &ua.DeleteReferencesItem{
+			SourceNodeID:        objects.NodeID,
+			ReferenceTypeID:     ua.NewNumericNodeID(0, id.Organizes),
+			IsForward:           true,
+			TargetNodeID:        ua.NewExpandedNodeID(speedID, "", 0),
+			DeleteBidirectional: true,
+		}
+		dres, err := c.DeleteReferences(ctx, &ua.DeleteReferencesRequest{ReferencesToDelete: []*ua.DeleteReferencesItem{del, del}})
+		require.NoError(t, err, "DeleteReferences failed")
+		require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusBadNotFound}, dres.Results)
+		require.NotContains(t, browse(t, objects.NodeID), "Speed")
+	})
+
+	t.Run("delete nodes", func(t *testing.T) {
+		res, err := c.DeleteNodes(ctx, &ua.DeleteNodesRequest{
+			NodesToDelete: []*ua.DeleteNodesItem{
+				{NodeID: speedID, DeleteTargetReferences: true},
+				{NodeID: ua.NewStringNodeID(1, "unknown")},
+			},
+		})
+		require.NoError(t, err, "DeleteNodes failed")
+		require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusBadNodeIDUnknown}, res.Results)
+		require.Empty(t, browse(t, lineID))
+	})
+
+	t.Run("namespace 0", func(t *testing.T) {
+		item := *line
+		item.ParentNodeID = ua.NewNumericExpandedNodeID(0, id.ObjectsFolder)
+		item.RequestedNewNodeID = ua.NewTwoByteExpandedNodeID(0)
+		item.BrowseName = &ua.QualifiedName{NamespaceIndex: 1, Name: "Line2"}
+		require.Equal(t, ua.StatusBadUserAccessDenied, addNode(t, &item).StatusCode)
+
+		res, err := c.DeleteNodes(ctx, &ua.DeleteNodesRequest{
+			NodesToDelete: []*ua.DeleteNodesItem{{NodeID: ua.NewNumericNodeID(0, id.Server_ServerStatus), DeleteTargetReferences: true}},
+		})
+		require.NoError(t, err, "DeleteNodes failed")
+		require.Equal(t, []ua.StatusCode{ua.StatusBadUserAccessDenied}, res.Results)
+		rres, err := c.Read(ctx, &ua.ReadRequest{NodesToRead: []*ua.ReadValueID{{NodeID: ua.NewNumericNodeID(0, id.Server_ServerStatus), AttributeID: ua.AttributeIDBrowseName}}})
+		require.NoError(t, err, "Read failed")
+		require.Equal(t, ua.StatusOK, rres.Results[0].Status)
+
+		dres, err := c.DeleteReferences(ctx, &ua.DeleteReferencesRequest{
+			ReferencesToDelete: []*ua.DeleteReferencesItem{{
+				SourceNodeID:    ua.NewNumericNodeID(0, id.Server),
+				ReferenceTypeID: ua.NewNumericNodeID(0, id.HasComponent),
+				IsForward:       true,
+				TargetNodeID:    ua.NewNumericExpandedNodeID(0, id.Server_ServerStatus),
+			}},
+		})
+		require.NoError(t, err, "DeleteReferences failed")
+		require.Equal(t, []ua.StatusCode{ua.StatusBadUserAccessDenied}, dres.Results)
+	})
+
+	t.Run("model change events", func(t *testing.T) {
+		res, err := c.HistoryReadEvent(ctx, []*ua.HistoryReadValueID{{
+			NodeID:       ua.NewNumericNodeID(0, id.Server),
+			DataEncoding: &ua.QualifiedName{},
+		}}, &ua.ReadEventDetails{
+			StartTime: start,
+			EndTime:   time.Now().Add(time.Minute),
+			Filter: &ua.EventFilter{
+				SelectClauses: []*ua.SimpleAttributeOperand{
+					{TypeDefinitionID: ua.NewNumericNodeID(0, id.GeneralModelChangeEventType), BrowsePath: []*ua.QualifiedName{{Name: "EventType"}}, AttributeID: ua.AttributeIDValue},
+					{TypeDefinitionID: ua.NewNumericNodeID(0, id.GeneralModelChangeEventType), BrowsePath: []*ua.QualifiedName{{Name: "Changes"}}, AttributeID: ua.AttributeIDValue},
+				},
+				WhereClause: &ua.ContentFilter{},
+			},
+		})
+		require.NoError(t, err, "HistoryReadEvent failed")
+		events := res.Results[0].HistoryData.Value.(*ua.HistoryEvent).Events
+		// add line, add speed, add reference, delete reference, delete speed
+		require.Len(t, events, 5)
+		require.Equal(t, ua.NewNumericNodeID(0, id.GeneralModelChangeEventType), events[0].EventFields[0].Value())
+
+		changes := events[4].EventFields[1].Value().([]*ua.ExtensionObject)
+		change := changes[0].Value.(*ua.ModelChangeStructureDataType)
+		require.Equal(t, speedID, change.Affected)
+		require.Equal(t, uint8(ua.ModelChangeStructureVerbMaskNodeDeleted), change.Verb)
+	})
+}
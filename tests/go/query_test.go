@@ -0,0 +1,222 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/filter"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQuery performs an integration test to search the address space with
+// QueryFirst and QueryNext.
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	machineType := ua.NewStringNodeID(1, "MachineType")
+	millType := ua.NewStringNodeID(1, "MillType")
+
+	addNode := func(t *testing.T, parent *ua.NodeID, refType uint32, nodeID *ua.NodeID, name string, class ua.NodeClass, attrs any, typeDef *ua.NodeID) {
+		t.Helper()
+		item := &ua.AddNodesItem{
+			ParentNodeID:       ua.NewExpandedNodeID(parent, "", 0),
+			ReferenceTypeID:    ua.NewNumericNodeID(0, refType),
+			RequestedNewNodeID: ua.NewExpandedNodeID(nodeID, "", 0),
+			BrowseName:         &ua.QualifiedName{Name: name},
+			NodeClass:          class,
+			NodeAttributes:     ua.NewExtensionObject(attrs),
+		}
+		if typeDef != nil {
+			item.TypeDefinition = ua.NewExpandedNodeID(typeDef, "", 0)
+		}
+		res, err := c.AddNodes(ctx, &ua.AddNodesRequest{NodesToAdd: []*ua.AddNodesItem{item}})
+		require.NoError(t, err, "AddNodes failed")
+		require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+	}
+	addType := func(t *testing.T, super, nodeID *ua.NodeID) {
+		t.Helper()
+		addNode(t, super, id.HasSubtype, nodeID, nodeID.StringID(), ua.NodeClassObjectType, &ua.ObjectTypeAttributes{
+			DisplayName: &ua.LocalizedText{},
+			Description: &ua.LocalizedText{},
+		}, nil)
+	}
+	addMachine := func(t *testing.T, name string, typeDef *ua.NodeID, vendor string, speed float64) {
+		t.Helper()
+		machineID := ua.NewStringNodeID(1, name)
+		addNode(t, ua.NewNumericNodeID(1, id.ObjectsFolder), id.Organizes, machineID, name, ua.NodeClassObject, &ua.ObjectAttributes{
+			DisplayName: &ua.LocalizedText{},
+			Description: &ua.LocalizedText{},
+		}, typeDef)
+		for _, prop := range []struct {
+			name     string
+			v        any
+			dataType uint32
+		}{{"Vendor", vendor, id.String}, {"Speed", speed, id.Double}} {
+			addNode(t, machineID, id.HasComponent, ua.NewStringNodeID(1, name+"."+prop.name), prop.name, ua.NodeClassVariable, &ua.VariableAttributes{
+				SpecifiedAttributes: uint32(ua.NodeAttributesMaskValue | ua.NodeAttributesMaskDataType | ua.NodeAttributesMaskAccessLevel | ua.NodeAttributesMaskUserAccessLevel),
+				DisplayName:         &ua.LocalizedText{},
+				Description:         &ua.LocalizedText{},
+				Value:               ua.MustVariant(prop.v),
+				DataType:            ua.NewNumericNodeID(0, prop.dataType),
+				AccessLevel:         byte(ua.AccessLevelTypeCurrentRead),
+				UserAccessLevel:     byte(ua.AccessLevelTypeCurrentRead),
+			}, ua.NewNumericNodeID(0, id.BaseDataVariableType))
+		}
+	}
+
+	addType(t, machineType, millType)
+	addMachine(t, "Lathe1", machineType, "Acme", 20)
+	addMachine(t, "Lathe2", machineType, "Initech", 60)
+	addMachine(t, "Mill1", millType, "Acme Tools", 80)
+
+	nodeTypes := func(typeID *ua.NodeID, subtypes bool) []*ua.NodeTypeDescription {
+		return []*ua.NodeTypeDescription{{
+			TypeDefinitionNode: ua.NewExpandedNodeID(typeID, "", 0),
+			IncludeSubTypes:    subtypes,
+			DataToReturn: []*ua.QueryDataDescription{
+				{
+					RelativePath: &ua.RelativePath{Elements: []*ua.RelativePathElement{}},
+					AttributeID:  ua.AttributeIDBrowseName,
+				},
+				{
+					RelativePath: &ua.RelativePath{Elements: []*ua.RelativePathElement{{
+						ReferenceTypeID: ua.NewNumericNodeID(0, id.HasComponent),
+						TargetName:      &ua.QualifiedName{Name: "Vendor"},
+					}}},
+					AttributeID: ua.AttributeIDValue,
+				},
+			},
+		}}
+	}
+	names := func(sets []*ua.QueryDataSet) []string {
+		var names []string
+		for _, set := range sets {
+			names = append(names, set.NodeID.NodeID.StringID())
+		}
+		return names
+	}
+	query := func(t *testing.T, f *filter.Expr, subtypes bool) []string {
+		t.Helper()
+		req := &ua.QueryFirstRequest{NodeTypes: nodeTypes(machineType, subtypes)}
+		if f != nil {
+			req.Filter = f.ContentFilter()
+		}
+		res, err := c.QueryFirst(ctx, req)
+		require.NoError(t, err, "QueryFirst failed")
+		require.Equal(t, ua.StatusOK, res.ResponseHeader.ServiceResult)
+		require.Empty(t, res.ParsingResults)
+		require.Empty(t, res.ContinuationPoint)
+		return names(res.QueryDataSets)
+	}
+
+	t.Run("node types", func(t *testing.T) {
+		res, err := c.QueryFirst(ctx, &ua.QueryFirstRequest{NodeTypes: nodeTypes(machineType, false)})
+		require.NoError(t, err, "QueryFirst failed")
+		require.ElementsMatch(t, []string{"Lathe1", "Lathe2"}, names(res.QueryDataSets))
+		for _, set := range res.QueryDataSets {
+			require.Equal(t, machineType.String(), set.TypeDefinitionNode.NodeID.String())
+			require.Len(t, set.Values, 2)
+			require.Equal(t, set.NodeID.NodeID.StringID(), set.Values[0].Value().(*ua.QualifiedName).Name)
+			require.IsType(t, "", set.Values[1].Value())
+		}
+		require.ElementsMatch(t, []string{"Lathe1", "Lathe2", "Mill1"}, query(t, nil, true))
+	})
+
+	vendor := filter.Value(machineType, "Vendor")
+	speed := filter.Value(machineType, "Speed")
+
+	t.Run("filter", func(t *testing.T) {
+		require.Equal(t, []string{"Lathe1"}, query(t, filter.Equals(vendor, filter.Literal("Acme")), true))
+		require.ElementsMatch(t, []string{"Lathe1", "Mill1"}, query(t, filter.Like(vendor, filter.Literal("Acme%")), true))
+		require.Equal(t, []string{"Lathe2"}, query(t, filter.Between(speed, filter.Literal(50.0), filter.Literal(70.0)), true))
+		require.ElementsMatch(t, []string{"Lathe2", "Mill1"}, query(t, filter.Not(filter.InList(vendor, filter.Literal("Acme"), filter.Literal("Globex"))), true))
+		require.Equal(t, []string{"Mill1"}, query(t, filter.OfType(millType), true))
+		require.Equal(t, []string{"Lathe1"}, query(t, filter.And(
+			filter.Not(filter.OfType(millType)),
+			filter.LessThan(filter.Cast(speed, ua.NewNumericNodeID(0, id.Int32)), filter.Literal(int32(50))),
+		), true))
+		require.Empty(t, query(t, filter.IsNull(vendor), true))
+
+		one := filter.Literal(uint64(1))
+		require.Len(t, query(t, filter.Equals(filter.BitwiseAnd(filter.Literal(uint64(3)), one), one), true), 3)
+		// UInt64 values which don't fit into an Int64 are not wrapped.
+		require.Empty(t, query(t, filter.Equals(filter.BitwiseAnd(filter.Literal(uint64(math.MaxUint64)), one), one), true))
+	})
+
+	t.Run("related to", func(t *testing.T) {
+		acme := filter.Equals(filter.Value(nil), filter.Literal("Acme"))
+		hasComponent := ua.NewNumericNodeID(0, id.HasComponent)
+		require.Equal(t, []string{"Lathe1"}, query(t, filter.RelatedTo(filter.Type(machineType), acme, hasComponent, 1), true))
+		require.Empty(t, query(t, filter.RelatedTo(filter.Type(millType), acme, hasComponent, 1), true))
+		require.Empty(t, query(t, filter.RelatedTo(filter.Type(machineType), acme, ua.NewNumericNodeID(0, id.Organizes), 1), true))
+	})
+
+	t.Run("paging", func(t *testing.T) {
+		res, err := c.QueryFirst(ctx, &ua.QueryFirstRequest{
+			NodeTypes:           nodeTypes(machineType, true),
+			MaxDataSetsToReturn: 2,
+		})
+		require.NoError(t, err, "QueryFirst failed")
+		require.Len(t, res.QueryDataSets, 2)
+		require.NotEmpty(t, res.ContinuationPoint)
+		got := names(res.QueryDataSets)
+
+		next, err := c.QueryNext(ctx, &ua.QueryNextRequest{ContinuationPoint: res.ContinuationPoint})
+		require.NoError(t, err, "QueryNext failed")
+		require.Equal(t, ua.StatusOK, next.ResponseHeader.ServiceResult)
+		require.Len(t, next.QueryDataSets, 1)
+		require.Empty(t, next.RevisedContinuationPoint)
+		got = append(got, names(next.QueryDataSets)...)
+		require.ElementsMatch(t, []string{"Lathe1", "Lathe2", "Mill1"}, got)
+
+		// release a continuation point.
+		res, err = c.QueryFirst(ctx, &ua.QueryFirstRequest{
+			NodeTypes:           nodeTypes(machineType, true),
+			MaxDataSetsToReturn: 1,
+		})
+		require.NoError(t, err, "QueryFirst failed")
+		require.NotEmpty(t, res.ContinuationPoint)
+		next, err = c.QueryNext(ctx, &ua.QueryNextRequest{ContinuationPoint: res.ContinuationPoint, ReleaseContinuationPoint: true})
+		require.NoError(t, err, "QueryNext failed")
+		require.Equal(t, ua.StatusOK, next.ResponseHeader.ServiceResult)
+		require.Empty(t, next.QueryDataSets)
+	})
+
+	t.Run("parsing results", func(t *testing.T) {
+		res, err := c.QueryFirst(ctx, &ua.QueryFirstRequest{
+			NodeTypes: append(nodeTypes(machineType, false), &ua.NodeTypeDescription{
+				TypeDefinitionNode: ua.NewStringExpandedNodeID(1, "unknown"),
+				DataToReturn:       []*ua.QueryDataDescription{},
+			}, &ua.NodeTypeDescription{
+				TypeDefinitionNode: ua.NewStringExpandedNodeID(1, "Lathe1"),
+				DataToReturn:       []*ua.QueryDataDescription{},
+			}),
+		})
+		require.NoError(t, err, "QueryFirst failed")
+		require.Len(t, res.ParsingResults, 3)
+		require.Equal(t, ua.StatusOK, res.ParsingResults[0].StatusCode)
+		require.Equal(t, ua.StatusBadNodeIDUnknown, res.ParsingResults[1].StatusCode)
+		require.Equal(t, ua.StatusBadNotTypeDefinition, res.ParsingResults[2].StatusCode)
+		require.Len(t, res.QueryDataSets, 2)
+	})
+}
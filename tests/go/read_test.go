@@ -49,8 +49,7 @@ func TestRead(t *testing.T) {
 				testRead(t, ctx, c, tt.v, tt.id)
 			})
 			t.Run("RegisteredRead", func(t *testing.T) {
-				t.Skip("Not implemented in server")
-				testRegisteredRead(t, ctx, c, tt.v, tt.id)
+				testRegisteredRead(t, ctx, c, tt.v, tt.id, ua.StatusOK)
 			})
 		})
 	}
@@ -87,7 +86,7 @@ func testRead(t *testing.T, ctx context.Context, c *opcua.Client, v interface{},
 	require.Equal(t, v, resp.Results[0].Value.Value(), "Results[0].Value not equal")
 }
 
-func testRegisteredRead(t *testing.T, ctx context.Context, c *opcua.Client, v interface{}, id *ua.NodeID) {
+func testRegisteredRead(t *testing.T, ctx context.Context, c *opcua.Client, v interface{}, id *ua.NodeID, resultCode ua.StatusCode) {
 	t.Helper()
 
 	resp, err := c.RegisterNodes(ctx, &ua.RegisterNodesRequest{
@@ -95,14 +94,14 @@ func testRegisteredRead(t *testing.T, ctx context.Context, c *opcua.Client, v in
 	})
 	require.NoError(t, err, "RegisterNodes failed")
 
-	testRead(t, ctx, c, v, resp.RegisteredNodeIDs[0])
-	testRead(t, ctx, c, v, resp.RegisteredNodeIDs[0])
-	testRead(t, ctx, c, v, resp.RegisteredNodeIDs[0])
-	testRead(t, ctx, c, v, resp.RegisteredNodeIDs[0])
-	testRead(t, ctx, c, v, resp.RegisteredNodeIDs[0])
+	testReadPerm(t, ctx, c, v, resp.RegisteredNodeIDs[0], resultCode)
+	testReadPerm(t, ctx, c, v, resp.RegisteredNodeIDs[0], resultCode)
+	testReadPerm(t, ctx, c, v, resp.RegisteredNodeIDs[0], resultCode)
+	testReadPerm(t, ctx, c, v, resp.RegisteredNodeIDs[0], resultCode)
+	testReadPerm(t, ctx, c, v, resp.RegisteredNodeIDs[0], resultCode)
 
 	_, err = c.UnregisterNodes(ctx, &ua.UnregisterNodesRequest{
-		NodesToUnregister: []*ua.NodeID{id},
+		NodesToUnregister: resp.RegisteredNodeIDs,
 	})
 	require.NoError(t, err, "UnregisterNodes failed")
 }
@@ -141,8 +140,7 @@ func TestReadPerms(t *testing.T) {
 				testReadPerm(t, ctx, c, tt.v, tt.id, tt.err)
 			})
 			t.Run("RegisteredRead", func(t *testing.T) {
-				t.Skip("Not implemented in server")
-				testRegisteredRead(t, ctx, c, tt.v, tt.id)
+				testRegisteredRead(t, ctx, c, tt.v, tt.id, tt.err)
 			})
 		})
 	}
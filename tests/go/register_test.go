@@ -0,0 +1,158 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// registrarNameSpace records the calls of the NodeRegistrar hooks.
+type registrarNameSpace struct {
+	*server.NodeNameSpace
+
+	mu         sync.Mutex
+	registered map[string]int
+}
+
+func (ns *registrarNameSpace) RegisterNode(nodeID *ua.NodeID) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.registered[nodeID.String()]++
+}
+
+func (ns *registrarNameSpace) UnregisterNode(nodeID *ua.NodeID) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.registered[nodeID.String()]--
+}
+
+func (ns *registrarNameSpace) count(nodeID *ua.NodeID) int {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return ns.registered[nodeID.String()]
+}
+
+// TestRegisterNodes performs an integration test to access nodes with the
+// aliases of RegisterNodes.
+func TestRegisterNodes(t *testing.T) {
+	ctx := context.Background()
+
+	srv := startServer(server.MaxRegisteredNodes(4))
+	defer srv.Close()
+
+	ns := &registrarNameSpace{
+		NodeNameSpace: server.NewNodeNameSpace(srv, "RegistrarNamespace"),
+		registered:    map[string]int{},
+	}
+	srv.AddNamespace(ns)
+	tagID := ns.AddNewVariableStringNode("tag", 7.0).ID()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+
+	register := func(t *testing.T, nodeIDs ...*ua.NodeID) []*ua.NodeID {
+		t.Helper()
+		res, err := c.RegisterNodes(ctx, &ua.RegisterNodesRequest{NodesToRegister: nodeIDs})
+		require.NoError(t, err, "RegisterNodes failed")
+		require.Len(t, res.RegisteredNodeIDs, len(nodeIDs))
+		return res.RegisteredNodeIDs
+	}
+
+	objID := ua.NewNumericNodeID(1, id.ObjectsFolder)
+	aliases := register(t, tagID, objID, ua.NewStringNodeID(1, "even"))
+	tagAlias := aliases[0]
+	require.Equal(t, ua.NodeIDTypeNumeric, tagAlias.Type())
+	require.Equal(t, 1, ns.count(tagID))
+
+	t.Run("read and write", func(t *testing.T) {
+		testRead(t, ctx, c, 7.0, tagAlias)
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      tagAlias,
+				AttributeID: ua.AttributeIDValue,
+				Value: &ua.DataValue{
+					EncodingMask: ua.DataValueValue,
+					Value:        ua.MustVariant(8.0),
+				},
+			}},
+		})
+		testRead(t, ctx, c, 8.0, tagID)
+	})
+
+	t.Run("call", func(t *testing.T) {
+		res, err := c.Call(ctx, &ua.CallMethodRequest{
+			ObjectID:       aliases[1],
+			MethodID:       aliases[2],
+			InputArguments: []*ua.Variant{ua.MustVariant(int64(12))},
+		})
+		require.NoError(t, err, "Call failed")
+		require.Equal(t, ua.StatusOK, res.StatusCode)
+		require.Equal(t, true, res.OutputArguments[0].Value())
+	})
+
+	t.Run("monitor", func(t *testing.T) {
+		notifyCh := make(chan *opcua.PublishNotificationData, 1)
+		sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: 100 * time.Millisecond}, notifyCh)
+		require.NoError(t, err, "Subscribe failed")
+		defer sub.Cancel(ctx)
+
+		res, err := sub.Monitor(ctx, ua.TimestampsToReturnBoth, opcua.NewMonitoredItemCreateRequestWithDefaults(tagAlias, ua.AttributeIDValue, 42))
+		require.NoError(t, err, "Monitor failed")
+		require.Equal(t, ua.StatusOK, res.Results[0].StatusCode)
+
+		select {
+		case msg := <-notifyCh:
+			require.NoError(t, msg.Error)
+			v, ok := msg.Value.(*ua.DataChangeNotification)
+			require.True(t, ok, "got %T, want *ua.DataChangeNotification", msg.Value)
+			require.Equal(t, uint32(42), v.MonitoredItems[0].ClientHandle)
+			require.Equal(t, 8.0, v.MonitoredItems[0].Value.Value.Value())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for data change")
+		}
+	})
+
+	t.Run("unregister", func(t *testing.T) {
+		_, err := c.UnregisterNodes(ctx, &ua.UnregisterNodesRequest{NodesToUnregister: []*ua.NodeID{tagAlias}})
+		require.NoError(t, err, "UnregisterNodes failed")
+		require.Equal(t, 0, ns.count(tagID))
+		testReadPerm(t, ctx, c, nil, tagAlias, ua.StatusBadNodeIDUnknown)
+	})
+
+	t.Run("close session", func(t *testing.T) {
+		register(t, tagID)
+		require.Equal(t, 1, ns.count(tagID))
+
+		require.NoError(t, c.Close(ctx), "Close failed")
+		require.Eventually(t, func() bool { return ns.count(tagID) == 0 }, time.Second, 10*time.Millisecond)
+	})
+	t.Run("limit", func(t *testing.T) {
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+		require.NoError(t, err, "NewClient failed")
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		defer c.Close(ctx)
+
+		res, err := c.RegisterNodes(ctx, &ua.RegisterNodesRequest{NodesToRegister: []*ua.NodeID{tagID, objID, tagID}})
+		require.NoError(t, err, "RegisterNodes failed")
+		require.Len(t, res.RegisteredNodeIDs, 3)
+
+		_, err = c.RegisterNodes(ctx, &ua.RegisterNodesRequest{NodesToRegister: []*ua.NodeID{tagID, objID}})
+		require.ErrorIs(t, err, ua.StatusBadTooManyOperations)
+		require.Equal(t, 2, ns.count(tagID))
+	})
+}
@@ -0,0 +1,123 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRepublish performs an integration test to acknowledge notification
+// messages and to retransmit them with Republish.
+func TestRepublish(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err, "NewClient failed")
+
+	err = c.Connect(ctx)
+	require.NoError(t, err, "Connect failed")
+	defer c.Close(ctx)
+
+	// the requests are sent directly so that the publish loop of the client
+	// does not acknowledge the notification messages.
+	var sub *ua.CreateSubscriptionResponse
+	err = c.Send(ctx, &ua.CreateSubscriptionRequest{
+		RequestedPublishingInterval: 100,
+		RequestedLifetimeCount:      100,
+		RequestedMaxKeepAliveCount:  2,
+		PublishingEnabled:           true,
+	}, func(v ua.Response) error {
+		sub = v.(*ua.CreateSubscriptionResponse)
+		return nil
+	})
+	require.NoError(t, err, "CreateSubscription failed")
+
+	nodeID := ua.NewStringNodeID(1, "rw_int32")
+	err = c.Send(ctx, &ua.CreateMonitoredItemsRequest{
+		SubscriptionID:     sub.SubscriptionID,
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+		ItemsToCreate: []*ua.MonitoredItemCreateRequest{
+			opcua.NewMonitoredItemCreateRequestWithDefaults(nodeID, ua.AttributeIDValue, 1),
+		},
+	}, func(v ua.Response) error { return nil })
+	require.NoError(t, err, "CreateMonitoredItems failed")
+
+	publish := func(t *testing.T, acks ...*ua.SubscriptionAcknowledgement) *ua.PublishResponse {
+		t.Helper()
+		if acks == nil {
+			acks = []*ua.SubscriptionAcknowledgement{}
+		}
+		var res *ua.PublishResponse
+		err := c.Send(ctx, &ua.PublishRequest{SubscriptionAcknowledgements: acks}, func(v ua.Response) error {
+			res = v.(*ua.PublishResponse)
+			return nil
+		})
+		require.NoError(t, err, "Publish failed")
+		require.Equal(t, sub.SubscriptionID, res.SubscriptionID)
+		return res
+	}
+	republish := func(seq uint32) (*ua.RepublishResponse, error) {
+		var res *ua.RepublishResponse
+		err := c.Send(ctx, &ua.RepublishRequest{
+			SubscriptionID:           sub.SubscriptionID,
+			RetransmitSequenceNumber: seq,
+		}, func(v ua.Response) error {
+			res = v.(*ua.RepublishResponse)
+			return nil
+		})
+		return res, err
+	}
+	write := func(t *testing.T, v int32) {
+		t.Helper()
+		testWrite(t, ctx, c, ua.StatusOK, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      nodeID,
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+			}},
+		})
+	}
+
+	write(t, 10)
+	first := publish(t)
+	require.NotEmpty(t, first.NotificationMessage.NotificationData)
+	seq := first.NotificationMessage.SequenceNumber
+	require.Equal(t, []uint32{seq}, first.AvailableSequenceNumbers)
+
+	// an unacknowledged message can be retransmitted.
+	res, err := republish(seq)
+	require.NoError(t, err, "Republish failed")
+	require.Equal(t, seq, res.NotificationMessage.SequenceNumber)
+	require.Len(t, res.NotificationMessage.NotificationData, len(first.NotificationMessage.NotificationData))
+
+	write(t, 11)
+	second := publish(t,
+		&ua.SubscriptionAcknowledgement{SubscriptionID: sub.SubscriptionID, SequenceNumber: seq},
+		&ua.SubscriptionAcknowledgement{SubscriptionID: sub.SubscriptionID, SequenceNumber: seq + 100},
+		&ua.SubscriptionAcknowledgement{SubscriptionID: sub.SubscriptionID + 100, SequenceNumber: seq},
+	)
+	require.Equal(t, []ua.StatusCode{
+		ua.StatusOK,
+		ua.StatusBadSequenceNumberUnknown,
+		ua.StatusBadSubscriptionIDInvalid,
+	}, second.Results)
+	require.NotContains(t, second.AvailableSequenceNumbers, seq)
+	require.Contains(t, second.AvailableSequenceNumbers, second.NotificationMessage.SequenceNumber)
+
+	// an acknowledged message is gone.
+	_, err = republish(seq)
+	require.ErrorIs(t, err, ua.StatusBadMessageNotAvailable)
+}
@@ -0,0 +1,305 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/filter"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoles performs an integration test to check the permissions of the
+// roles of the users.
+func TestRoles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	eps, err := opcua.GetEndpoints(ctx, "opc.tcp://localhost:4840")
+	require.NoError(t, err, "GetEndpoints failed")
+	ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURINone, ua.MessageSecurityModeNone)
+	require.NoError(t, err, "SelectEndpoint failed")
+
+	connect := func(t *testing.T, opts ...opcua.Option) *opcua.Client {
+		t.Helper()
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", append(opts, opcua.SecurityMode(ua.MessageSecurityModeNone))...)
+		require.NoError(t, err, "NewClient failed")
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		return c
+	}
+	login := func(t *testing.T, user, pass string) *opcua.Client {
+		t.Helper()
+		return connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName), opcua.AuthUsername(user, pass))
+	}
+
+	setpoint := ua.NewStringNodeID(1, "setpoint")
+	reset := ua.NewStringNodeID(1, "reset")
+
+	read := func(t *testing.T, c *opcua.Client, nodeID *ua.NodeID, attr ua.AttributeID) *ua.DataValue {
+		t.Helper()
+		res, err := c.Read(ctx, &ua.ReadRequest{NodesToRead: []*ua.ReadValueID{{NodeID: nodeID, AttributeID: attr}}})
+		require.NoError(t, err, "Read failed")
+		require.Len(t, res.Results, 1)
+		return res.Results[0]
+	}
+	write := func(t *testing.T, c *opcua.Client, status ua.StatusCode, v float64) {
+		t.Helper()
+		testWrite(t, ctx, c, status, &ua.WriteRequest{
+			NodesToWrite: []*ua.WriteValue{{
+				NodeID:      setpoint,
+				AttributeID: ua.AttributeIDValue,
+				Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+			}},
+		})
+	}
+	call := func(t *testing.T, c *opcua.Client) ua.StatusCode {
+		t.Helper()
+		res, err := c.Call(ctx, &ua.CallMethodRequest{ObjectID: ua.NewNumericNodeID(1, id.ObjectsFolder), MethodID: reset})
+		require.NoError(t, err, "Call failed")
+		return res.StatusCode
+	}
+	browse := func(t *testing.T, c *opcua.Client) []string {
+		t.Helper()
+		refs, err := c.Node(ua.NewNumericNodeID(1, id.ObjectsFolder)).References(ctx, id.HasComponent, ua.BrowseDirectionForward, ua.NodeClassAll, true)
+		require.NoError(t, err, "Browse failed")
+		var names []string
+		for _, r := range refs {
+			names = append(names, r.BrowseName.Name)
+		}
+		return names
+	}
+
+	t.Run("anonymous", func(t *testing.T) {
+		c := connect(t)
+		defer c.Close(ctx)
+
+		require.Equal(t, ua.StatusBadUserAccessDenied, read(t, c, setpoint, ua.AttributeIDValue).Status)
+		require.Equal(t, ua.StatusBadUserAccessDenied, read(t, c, setpoint, ua.AttributeIDBrowseName).Status)
+		write(t, c, ua.StatusBadUserAccessDenied, 30)
+		require.Equal(t, ua.StatusBadUserAccessDenied, call(t, c))
+
+		names := browse(t, c)
+		require.Contains(t, names, "rw_int32")
+		require.NotContains(t, names, "setpoint")
+		require.NotContains(t, names, "reset")
+	})
+
+	t.Run("authenticated user", func(t *testing.T) {
+		c := login(t, "user", "pass")
+		defer c.Close(ctx)
+
+		dv := read(t, c, setpoint, ua.AttributeIDValue)
+		require.Equal(t, ua.StatusOK, dv.Status)
+		require.Equal(t, byte(ua.AccessLevelTypeCurrentRead), read(t, c, setpoint, ua.AttributeIDUserAccessLevel).Value.Value())
+		require.Equal(t, false, read(t, c, reset, ua.AttributeIDUserExecutable).Value.Value())
+
+		perms := read(t, c, setpoint, ua.AttributeIDUserRolePermissions).Value.Value().([]*ua.ExtensionObject)
+		require.Len(t, perms, 1)
+		require.Equal(t, ua.PermissionTypeBrowse|ua.PermissionTypeRead, perms[0].Value.(*ua.RolePermissionType).Permissions)
+
+		write(t, c, ua.StatusBadUserAccessDenied, 30)
+		require.Equal(t, ua.StatusBadUserAccessDenied, call(t, c))
+		require.Contains(t, browse(t, c), "setpoint")
+	})
+
+	t.Run("operator", func(t *testing.T) {
+		c := login(t, "admin", "secret")
+		defer c.Close(ctx)
+
+		require.Equal(t, byte(ua.AccessLevelTypeCurrentRead|ua.AccessLevelTypeCurrentWrite), read(t, c, setpoint, ua.AttributeIDUserAccessLevel).Value.Value())
+		require.Equal(t, true, read(t, c, reset, ua.AttributeIDUserExecutable).Value.Value())
+		require.Len(t, read(t, c, setpoint, ua.AttributeIDUserRolePermissions).Value.Value(), 2)
+
+		write(t, c, ua.StatusOK, 30)
+		require.Equal(t, 30.0, read(t, c, setpoint, ua.AttributeIDValue).Value.Value())
+		require.Equal(t, ua.StatusOK, call(t, c))
+		require.Equal(t, 20.0, read(t, c, setpoint, ua.AttributeIDValue).Value.Value())
+	})
+}
+
+// TestRestrictedRole performs an integration test to check that a role
+// without the permissions can neither change the history nor the address
+// space and that queries and browse paths only return what the role may see.
+func TestRestrictedRole(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	all := ua.PermissionTypeBrowse | ua.PermissionTypeRead | ua.PermissionTypeWrite |
+		ua.PermissionTypeReadHistory | ua.PermissionTypeInsertHistory | ua.PermissionTypeModifyHistory | ua.PermissionTypeDeleteHistory |
+		ua.PermissionTypeAddNode | ua.PermissionTypeDeleteNode | ua.PermissionTypeAddReference | ua.PermissionTypeRemoveReference
+	srv := startServer(server.DefaultRolePermissions(
+		&ua.RolePermissionType{RoleID: server.RoleAnonymous, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeRead | ua.PermissionTypeReadHistory},
+		&ua.RolePermissionType{RoleID: server.RoleOperator, Permissions: all},
+	))
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	eps, err := opcua.GetEndpoints(ctx, "opc.tcp://localhost:4840")
+	require.NoError(t, err, "GetEndpoints failed")
+	ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURINone, ua.MessageSecurityModeNone)
+	require.NoError(t, err, "SelectEndpoint failed")
+
+	connect := func(t *testing.T, opts ...opcua.Option) *opcua.Client {
+		t.Helper()
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", append(opts, opcua.SecurityMode(ua.MessageSecurityModeNone))...)
+		require.NoError(t, err, "NewClient failed")
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		return c
+	}
+	anon := connect(t)
+	defer anon.Close(ctx)
+	admin := connect(t, opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName), opcua.AuthUsername("admin", "secret"))
+	defer admin.Close(ctx)
+
+	objects := ua.NewNumericNodeID(1, id.ObjectsFolder)
+	machineType := ua.NewStringNodeID(1, "MachineType")
+	lathe := ua.NewStringNodeID(1, "Lathe1")
+	vendor := ua.NewStringNodeID(1, "Lathe1.Vendor")
+
+	addNodes := func(t *testing.T, c *opcua.Client, items ...*ua.AddNodesItem) []ua.StatusCode {
+		t.Helper()
+		res, err := c.AddNodes(ctx, &ua.AddNodesRequest{NodesToAdd: items})
+		require.NoError(t, err, "AddNodes failed")
+		var status []ua.StatusCode
+		for _, r := range res.Results {
+			status = append(status, r.StatusCode)
+		}
+		return status
+	}
+	machine := &ua.AddNodesItem{
+		ParentNodeID:       ua.NewExpandedNodeID(objects, "", 0),
+		ReferenceTypeID:    ua.NewNumericNodeID(0, id.Organizes),
+		RequestedNewNodeID: ua.NewExpandedNodeID(lathe, "", 0),
+		BrowseName:         &ua.QualifiedName{Name: "Lathe1"},
+		NodeClass:          ua.NodeClassObject,
+		NodeAttributes:     ua.NewExtensionObject(&ua.ObjectAttributes{DisplayName: &ua.LocalizedText{}, Description: &ua.LocalizedText{}}),
+		TypeDefinition:     ua.NewExpandedNodeID(machineType, "", 0),
+	}
+	require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusOK}, addNodes(t, admin, machine, &ua.AddNodesItem{
+		ParentNodeID:       ua.NewExpandedNodeID(lathe, "", 0),
+		ReferenceTypeID:    ua.NewNumericNodeID(0, id.HasComponent),
+		RequestedNewNodeID: ua.NewExpandedNodeID(vendor, "", 0),
+		BrowseName:         &ua.QualifiedName{Name: "Vendor"},
+		NodeClass:          ua.NodeClassVariable,
+		NodeAttributes: ua.NewExtensionObject(&ua.VariableAttributes{
+			SpecifiedAttributes: uint32(ua.NodeAttributesMaskValue | ua.NodeAttributesMaskDataType | ua.NodeAttributesMaskAccessLevel | ua.NodeAttributesMaskUserAccessLevel),
+			DisplayName:         &ua.LocalizedText{},
+			Description:         &ua.LocalizedText{},
+			Value:               ua.MustVariant("Acme"),
+			DataType:            ua.NewNumericNodeID(0, id.String),
+			AccessLevel:         byte(ua.AccessLevelTypeCurrentRead),
+			UserAccessLevel:     byte(ua.AccessLevelTypeCurrentRead),
+		}),
+		TypeDefinition: ua.NewExpandedNodeID(ua.NewNumericNodeID(0, id.BaseDataVariableType), "", 0),
+	}))
+	// anonymous users may find the vendor but not read it.
+	srv.Node(vendor).SetRolePermissions(
+		&ua.RolePermissionType{RoleID: server.RoleAnonymous, Permissions: ua.PermissionTypeBrowse},
+		&ua.RolePermissionType{RoleID: server.RoleOperator, Permissions: all},
+	)
+
+	t.Run("history update", func(t *testing.T) {
+		nodeID := ua.NewStringNodeID(1, "hist_float64")
+		ts := time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)
+		dv := &ua.DataValue{EncodingMask: ua.DataValueValue | ua.DataValueSourceTimestamp, Value: ua.MustVariant(1.0), SourceTimestamp: ts}
+		status := func(t *testing.T, res *ua.HistoryUpdateResponse, err error) ua.StatusCode {
+			t.Helper()
+			require.NoError(t, err, "HistoryUpdate failed")
+			require.Len(t, res.Results, 1)
+			return res.Results[0].StatusCode
+		}
+
+		res, err := anon.HistoryInsert(ctx, nodeID, dv)
+		require.Equal(t, ua.StatusBadUserAccessDenied, status(t, res, err))
+		res, err = anon.HistoryReplace(ctx, nodeID, dv)
+		require.Equal(t, ua.StatusBadUserAccessDenied, status(t, res, err))
+		res, err = anon.HistoryDelete(ctx, nodeID, ts.Add(-time.Second), ts.Add(time.Second))
+		require.Equal(t, ua.StatusBadUserAccessDenied, status(t, res, err))
+
+		res, err = admin.HistoryInsert(ctx, nodeID, dv)
+		require.Equal(t, ua.StatusOK, status(t, res, err))
+		res, err = admin.HistoryDelete(ctx, nodeID, ts.Add(-time.Second), ts.Add(time.Second))
+		require.Equal(t, ua.StatusOK, status(t, res, err))
+	})
+
+	t.Run("node management", func(t *testing.T) {
+		item := *machine
+		item.RequestedNewNodeID = ua.NewStringExpandedNodeID(1, "Lathe2")
+		item.BrowseName = &ua.QualifiedName{Name: "Lathe2"}
+		require.Equal(t, []ua.StatusCode{ua.StatusBadUserAccessDenied}, addNodes(t, anon, &item))
+
+		res, err := anon.DeleteNodes(ctx, &ua.DeleteNodesRequest{NodesToDelete: []*ua.DeleteNodesItem{{NodeID: lathe, DeleteTargetReferences: true}}})
+		require.NoError(t, err, "DeleteNodes failed")
+		require.Equal(t, []ua.StatusCode{ua.StatusBadUserAccessDenied}, res.Results)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		query := func(t *testing.T, c *opcua.Client, f *filter.Expr) []*ua.QueryDataSet {
+			t.Helper()
+			req := &ua.QueryFirstRequest{NodeTypes: []*ua.NodeTypeDescription{{
+				TypeDefinitionNode: ua.NewExpandedNodeID(machineType, "", 0),
+				DataToReturn: []*ua.QueryDataDescription{{
+					RelativePath: &ua.RelativePath{Elements: []*ua.RelativePathElement{{
+						ReferenceTypeID: ua.NewNumericNodeID(0, id.HasComponent),
+						TargetName:      &ua.QualifiedName{Name: "Vendor"},
+					}}},
+					AttributeID: ua.AttributeIDValue,
+				}},
+			}}}
+			if f != nil {
+				req.Filter = f.ContentFilter()
+			}
+			res, err := c.QueryFirst(ctx, req)
+			require.NoError(t, err, "QueryFirst failed")
+			require.Equal(t, ua.StatusOK, res.ResponseHeader.ServiceResult)
+			return res.QueryDataSets
+		}
+		acme := filter.Equals(filter.Value(machineType, "Vendor"), filter.Literal("Acme"))
+
+		sets := query(t, admin, nil)
+		require.Len(t, sets, 1)
+		require.Equal(t, "Acme", sets[0].Values[0].Value())
+		require.Len(t, query(t, admin, acme), 1)
+
+		sets = query(t, anon, nil)
+		require.Len(t, sets, 1)
+		require.Nil(t, sets[0].Values[0].Value())
+		require.Empty(t, query(t, anon, acme))
+	})
+
+	t.Run("translate browse paths", func(t *testing.T) {
+		translate := func(t *testing.T, c *opcua.Client, name string) ua.StatusCode {
+			t.Helper()
+			res, err := c.TranslateBrowsePathsToNodeIDs(ctx, &ua.TranslateBrowsePathsToNodeIDsRequest{
+				BrowsePaths: []*ua.BrowsePath{{
+					StartingNode: objects,
+					RelativePath: &ua.RelativePath{Elements: []*ua.RelativePathElement{{
+						ReferenceTypeID: ua.NewNumericNodeID(0, id.HierarchicalReferences),
+						IncludeSubtypes: true,
+						TargetName:      &ua.QualifiedName{Name: name},
+					}}},
+				}},
+			})
+			require.NoError(t, err, "TranslateBrowsePathsToNodeIDs failed")
+			require.Len(t, res.Results, 1)
+			return res.Results[0].StatusCode
+		}
+
+		require.Equal(t, ua.StatusOK, translate(t, anon, "rw_int32"))
+		require.Equal(t, ua.StatusBadNoMatch, translate(t, anon, "setpoint"))
+		require.Equal(t, ua.StatusOK, translate(t, admin, "setpoint"))
+	})
+}
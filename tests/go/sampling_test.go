@@ -0,0 +1,175 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSampling performs an integration test to sample monitored items with
+// their revised parameters and monitoring modes.
+func TestSampling(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	counter := ua.NewStringNodeID(1, "counter")
+
+	// subscribe creates a subscription in a new session. The requests are
+	// sent directly so that the publish loop of the client does not
+	// interfere.
+	subscribe := func(t *testing.T, interval float64, items ...*ua.MonitoredItemCreateRequest) (*opcua.Client, uint32, []*ua.MonitoredItemCreateResult) {
+		t.Helper()
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+		require.NoError(t, err, "NewClient failed")
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		t.Cleanup(func() { c.Close(ctx) })
+
+		var sub *ua.CreateSubscriptionResponse
+		err = c.Send(ctx, &ua.CreateSubscriptionRequest{
+			RequestedPublishingInterval: interval,
+			RequestedLifetimeCount:      100,
+			RequestedMaxKeepAliveCount:  2,
+			PublishingEnabled:           true,
+		}, func(v ua.Response) error {
+			sub = v.(*ua.CreateSubscriptionResponse)
+			return nil
+		})
+		require.NoError(t, err, "CreateSubscription failed")
+
+		var res *ua.CreateMonitoredItemsResponse
+		err = c.Send(ctx, &ua.CreateMonitoredItemsRequest{
+			SubscriptionID:     sub.SubscriptionID,
+			TimestampsToReturn: ua.TimestampsToReturnBoth,
+			ItemsToCreate:      items,
+		}, func(v ua.Response) error {
+			res = v.(*ua.CreateMonitoredItemsResponse)
+			return nil
+		})
+		require.NoError(t, err, "CreateMonitoredItems failed")
+		require.Len(t, res.Results, len(items))
+		return c, sub.SubscriptionID, res.Results
+	}
+	item := func(nodeID *ua.NodeID, handle uint32, interval float64, queueSize uint32) *ua.MonitoredItemCreateRequest {
+		req := opcua.NewMonitoredItemCreateRequestWithDefaults(nodeID, ua.AttributeIDValue, handle)
+		req.RequestedParameters.SamplingInterval = interval
+		req.RequestedParameters.QueueSize = queueSize
+		return req
+	}
+	publish := func(t *testing.T, c *opcua.Client) []*ua.MonitoredItemNotification {
+		t.Helper()
+		var res *ua.PublishResponse
+		err := c.Send(ctx, &ua.PublishRequest{SubscriptionAcknowledgements: []*ua.SubscriptionAcknowledgement{}}, func(v ua.Response) error {
+			res = v.(*ua.PublishResponse)
+			return nil
+		})
+		require.NoError(t, err, "Publish failed")
+		if len(res.NotificationMessage.NotificationData) == 0 {
+			// keep alive
+			return nil
+		}
+		dcn, ok := res.NotificationMessage.NotificationData[0].Value.(*ua.DataChangeNotification)
+		require.True(t, ok, "got %T", res.NotificationMessage.NotificationData[0].Value)
+		return dcn.MonitoredItems
+	}
+	setMode := func(t *testing.T, c *opcua.Client, subID uint32, mode ua.MonitoringMode, ids ...uint32) []ua.StatusCode {
+		t.Helper()
+		var res *ua.SetMonitoringModeResponse
+		err := c.Send(ctx, &ua.SetMonitoringModeRequest{
+			SubscriptionID:   subID,
+			MonitoringMode:   mode,
+			MonitoredItemIDs: ids,
+		}, func(v ua.Response) error {
+			res = v.(*ua.SetMonitoringModeResponse)
+			return nil
+		})
+		require.NoError(t, err, "SetMonitoringMode failed")
+		return res.Results
+	}
+
+	t.Run("revised parameters", func(t *testing.T) {
+		_, _, res := subscribe(t, 200,
+			item(counter, 1, 0, 0),
+			item(counter, 2, -1, 5),
+			item(counter, 3, 1e9, 1e6),
+		)
+		for _, r := range res {
+			require.Equal(t, ua.StatusOK, r.StatusCode)
+		}
+		require.Equal(t, 100.0, res[0].RevisedSamplingInterval)
+		require.Equal(t, uint32(1), res[0].RevisedQueueSize)
+		require.Equal(t, 200.0, res[1].RevisedSamplingInterval)
+		require.Equal(t, uint32(5), res[1].RevisedQueueSize)
+		require.Equal(t, float64(time.Hour/time.Millisecond), res[2].RevisedSamplingInterval)
+		require.Equal(t, uint32(1000), res[2].RevisedQueueSize)
+	})
+
+	t.Run("value func", func(t *testing.T) {
+		c, _, _ := subscribe(t, 100, item(counter, 1, 100, 1))
+
+		// the value changes without a write.
+		first := publish(t, c)
+		require.Len(t, first, 1)
+		second := publish(t, c)
+		require.Len(t, second, 1)
+		require.Greater(t, second[0].Value.Value.Value().(int32), first[0].Value.Value.Value().(int32))
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		oldest := item(counter, 1, 100, 3)
+		oldest.RequestedParameters.DiscardOldest = true
+		newest := item(counter, 2, 100, 3)
+		newest.RequestedParameters.DiscardOldest = false
+		c, _, _ := subscribe(t, 1000, oldest, newest)
+
+		byHandle := map[uint32][]*ua.MonitoredItemNotification{}
+		for _, n := range publish(t, c) {
+			byHandle[n.ClientHandle] = append(byHandle[n.ClientHandle], n)
+		}
+		require.Len(t, byHandle[1], 3)
+		require.Len(t, byHandle[2], 3)
+
+		// the oldest values are gone and the first value marks the gap.
+		require.Equal(t, ua.StatusCode(0x0480), byHandle[1][0].Value.Status)
+		require.Equal(t, ua.StatusOK, byHandle[1][2].Value.Status)
+
+		// the newest values are gone and the last value marks the gap.
+		require.Equal(t, ua.StatusOK, byHandle[2][0].Value.Status)
+		require.Equal(t, ua.StatusCode(0x0480), byHandle[2][2].Value.Status)
+	})
+
+	t.Run("monitoring mode", func(t *testing.T) {
+		req := item(counter, 1, 100, 5)
+		req.MonitoringMode = ua.MonitoringModeSampling
+		c, subID, res := subscribe(t, 100, req)
+		id := res[0].MonitoredItemID
+
+		// sampled values are queued but not reported.
+		require.Empty(t, publish(t, c))
+		require.Equal(t, []ua.StatusCode{ua.StatusOK}, setMode(t, c, subID, ua.MonitoringModeReporting, id))
+		require.NotEmpty(t, publish(t, c))
+
+		// disabled items discard their queue and stop sampling.
+		require.Equal(t, []ua.StatusCode{ua.StatusOK, ua.StatusBadMonitoredItemIDInvalid}, setMode(t, c, subID, ua.MonitoringModeDisabled, id, id+100))
+		require.Empty(t, publish(t, c))
+
+		// an invalid mode fails the request.
+		err := c.Send(ctx, &ua.SetMonitoringModeRequest{
+			SubscriptionID:   subID,
+			MonitoringMode:   ua.MonitoringMode(42),
+			MonitoredItemIDs: []uint32{id},
+		}, func(v ua.Response) error { return nil })
+		require.ErrorIs(t, err, ua.StatusBadMonitoringModeInvalid)
+	})
+}
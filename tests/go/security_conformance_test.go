@@ -17,13 +17,8 @@ package uatest2
 
 import (
 	"context"
-	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
 	"fmt"
-	"math/big"
-	"net/url"
 	"testing"
 	"time"
 
@@ -32,28 +27,18 @@ import (
 	"github.com/gopcua/opcua"
 	"github.com/gopcua/opcua/server"
 	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
 )
 
 func genSelfSignedCert(t *testing.T, appURI string) ([]byte, *rsa.PrivateKey) {
 	t.Helper()
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c, err := uacert.Generate(uacert.Options{
+		ApplicationURI: appURI,
+		CommonName:     "gopcua conformance test",
+		Hosts:          []string{"localhost"},
+	})
 	require.NoError(t, err)
-	uri, err := url.Parse(appURI)
-	require.NoError(t, err)
-	tmpl := x509.Certificate{
-		SerialNumber:          big.NewInt(1),
-		Subject:               pkix.Name{CommonName: "gopcua conformance test"},
-		NotBefore:             time.Now().Add(-time.Hour),
-		NotAfter:              time.Now().Add(24 * time.Hour),
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageDataEncipherment | x509.KeyUsageCertSign,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
-		BasicConstraintsValid: true,
-		DNSNames:              []string{"localhost"},
-		URIs:                  []*url.URL{uri},
-	}
-	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
-	require.NoError(t, err)
-	return der, key
+	return c.Certificate, c.PrivateKey.(*rsa.PrivateKey)
 }
 
 // TestSecurityModeRoundtrip_Part6_674 pins OPC UA Part 6 v1.05 §6.7.4:
@@ -6,7 +6,12 @@ package uatest2
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"log"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/server"
@@ -14,7 +19,24 @@ import (
 	"github.com/gopcua/opcua/ua"
 )
 
-func startServer() *server.Server {
+// users are the user names and passwords of the users of the server.
+var users = map[string]string{
+	"user":  "pass",
+	"admin": "secret",
+}
+
+// authenticate accepts the users with a valid password and all users with
+// a certificate.
+func authenticate(ctx context.Context, token any) (*server.Identity, error) {
+	if tok, ok := token.(*ua.UserNameIdentityToken); ok {
+		if pass, ok := users[tok.UserName]; !ok || pass != string(tok.Password) {
+			return nil, ua.StatusBadUserAccessDenied
+		}
+	}
+	return nil, nil
+}
+
+func startServer(extra ...server.Option) *server.Server {
 	var opts []server.Option
 	port := 4840
 
@@ -37,11 +59,33 @@ func startServer() *server.Server {
 		server.EnableAuthMode(ua.UserTokenTypeUserName),
 		server.EnableAuthMode(ua.UserTokenTypeCertificate),
 		//		server.EnableAuthWithoutEncryption(), // Dangerous and not recommended, shown for illustration only
+		server.SetAuthenticator(server.AuthenticatorFunc(authenticate)),
+		server.RoleIdentities(server.RoleOperator, &ua.IdentityMappingRuleType{CriteriaType: ua.IdentityCriteriaTypeUserName, Criteria: "admin"}),
+		server.NodeManagement("NodeNamespace"),
+	)
+
+	// the certificate is needed to encrypt the passwords of users.
+	certPEM, keyPEM, err := GenerateCert("urn:gopcua:test:server,localhost", 2048, 24*time.Hour)
+	if err != nil {
+		log.Fatalf("error generating certificate: %s", err)
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		log.Fatalf("error parsing private key: %s", err)
+	}
+	opts = append(opts,
+		server.Certificate(certBlock.Bytes),
+		server.PrivateKey(key),
 	)
 
+	history := server.NewMemoryHistory(100)
 	opts = append(opts,
 		server.EndPoint("localhost", port),
+		server.SetHistoryStore(history),
 	)
+	opts = append(opts, extra...)
 
 	s := server.New(opts...)
 
@@ -58,17 +102,24 @@ func startServer() *server.Server {
 
 	// Create some nodes for it.
 	n := nodeNS.AddNewVariableStringNode("ro_bool", true)
-	n.SetAttribute(ua.AttributeIDUserAccessLevel, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(uint32(1))})
+	n.SetAttribute(ua.AttributeIDUserAccessLevel, server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)))
 	nns_obj.AddRef(n, id.HasComponent, true)
 	n = nodeNS.AddNewVariableStringNode("rw_bool", true)
 	nns_obj.AddRef(n, id.HasComponent, true)
 
 	n = nodeNS.AddNewVariableStringNode("ro_int32", int32(5))
-	n.SetAttribute(ua.AttributeIDUserAccessLevel, &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(uint32(1))})
+	n.SetAttribute(ua.AttributeIDUserAccessLevel, server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)))
 	nns_obj.AddRef(n, id.HasComponent, true)
 	n = nodeNS.AddNewVariableStringNode("rw_int32", int32(5))
 	nns_obj.AddRef(n, id.HasComponent, true)
 
+	// analog items have an EURange property for percent deadbands.
+	analog := nodeNS.AddNewVariableStringNode("rw_analog", 50.0)
+	nns_obj.AddRef(analog, id.HasComponent, true)
+	euRange := server.NewVariableNode(ua.NewStringNodeID(nodeNS.ID(), "rw_analog.EURange"), "EURange", ua.NewExtensionObject(&ua.Range{Low: 0, High: 200}))
+	nodeNS.AddNode(euRange)
+	analog.AddRef(euRange, id.HasProperty, true)
+
 	var3 := server.NewNode(
 		ua.NewStringNodeID(nodeNS.ID(), "NoPermVariable"), // you can use whatever node id you want here, whether it's numeric, string, guid, etc...
 		map[ua.AttributeID]*ua.DataValue{
@@ -88,6 +139,7 @@ func startServer() *server.Server {
 			ua.AttributeIDUserAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)),
 			ua.AttributeIDBrowseName:      server.DataValueFromValue(attrs.BrowseName("ReadWriteVariable")),
 			ua.AttributeIDNodeClass:       server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDWriteMask:       server.DataValueFromValue(uint32(ua.AttributeWriteMaskBrowseName | ua.AttributeWriteMaskDescription | ua.AttributeWriteMaskUserAccessLevel)),
 		},
 		nil,
 		func() *ua.DataValue { return server.DataValueFromValue(12.34) },
@@ -123,6 +175,212 @@ func startServer() *server.Server {
 	nodeNS.AddNode(var6)
 	nns_obj.AddRef(var6, id.HasComponent, true)
 
+	// the value of a variable with a value function changes without a write
+	// and is sampled by the monitored items.
+	var count atomic.Int32
+	counter := server.NewNode(
+		ua.NewStringNodeID(nodeNS.ID(), "counter"),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDAccessLevel:     server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+			ua.AttributeIDUserAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+			ua.AttributeIDBrowseName:      server.DataValueFromValue(attrs.BrowseName("counter")),
+			ua.AttributeIDNodeClass:       server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+		},
+		nil,
+		func() *ua.DataValue { return server.DataValueFromValue(count.Add(1)) },
+	)
+	nodeNS.AddNode(counter)
+	nns_obj.AddRef(counter, id.HasComponent, true)
+
+	// The events of the pump are reported by the area which is an event
+	// notifier of the server. FireEvent follows the inverse references.
+	area := server.NewNode(
+		ua.NewStringNodeID(nodeNS.ID(), "Area"),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName:    server.DataValueFromValue(attrs.BrowseName("Area")),
+			ua.AttributeIDNodeClass:     server.DataValueFromValue(uint32(ua.NodeClassObject)),
+			ua.AttributeIDEventNotifier: server.DataValueFromValue(byte(ua.EventNotifierTypeSubscribeToEvents)),
+		},
+		nil,
+		nil,
+	)
+	nodeNS.AddNode(area)
+	nns_obj.AddRef(area, id.Organizes, true)
+	pump := server.NewNode(
+		ua.NewStringNodeID(nodeNS.ID(), "Pump"),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName:    server.DataValueFromValue(attrs.BrowseName("Pump")),
+			ua.AttributeIDNodeClass:     server.DataValueFromValue(uint32(ua.NodeClassObject)),
+			ua.AttributeIDEventNotifier: server.DataValueFromValue(byte(ua.EventNotifierTypeNone)),
+		},
+		nil,
+		nil,
+	)
+	nodeNS.AddNode(pump)
+	area.AddRef(pump, id.HasComponent, true)
+	area.AddRef(pump, id.HasEventSource, true)
+	pump.AddRef(area, id.HasEventSource, false)
+	serverObj := s.Node(ua.NewNumericNodeID(0, id.Server))
+	serverObj.AddRef(area, id.HasNotifier, true)
+	area.AddRef(serverObj, id.HasNotifier, false)
+
+	// MachineType is the super type of the types which TestQuery adds
+	// with the Node Management services since clients can't change the
+	// types of namespace 0.
+	machineType := server.NewNode(
+		ua.NewStringNodeID(nodeNS.ID(), "MachineType"),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDBrowseName: server.DataValueFromValue(attrs.BrowseName("MachineType")),
+			ua.AttributeIDNodeClass:  server.DataValueFromValue(uint32(ua.NodeClassObjectType)),
+		},
+		nil,
+		nil,
+	)
+	nodeNS.AddNode(machineType)
+	baseObjectType := s.Node(ua.NewNumericNodeID(0, id.BaseObjectType))
+	baseObjectType.AddRef(machineType, id.HasSubtype, true)
+	machineType.AddRef(baseObjectType, id.HasSubtype, false)
+
+	// The alarms of the pump are evaluated from the values of their input
+	// variables and reported by the area and the server.
+	limit := func(v float64) *float64 { return &v }
+	level := nodeNS.AddNewVariableStringNode("level", 50.0)
+	nns_obj.AddRef(level, id.HasComponent, true)
+	_, err = s.AddCondition(ua.NewStringNodeID(nodeNS.ID(), "LevelAlarm"), pump.ID(), ua.NewNumericNodeID(0, id.ExclusiveLevelAlarmType), "LevelAlarm",
+		server.ConditionSeverity(700),
+		server.ConditionConfirm(),
+		server.ConditionLimits(level.ID(), server.Limits{HighHigh: limit(90), High: limit(70), Low: limit(20), LowLow: limit(10)}),
+	)
+	if err != nil {
+		log.Fatalf("Error adding condition: %s", err)
+	}
+	pressure := nodeNS.AddNewVariableStringNode("pressure", 1.0)
+	nns_obj.AddRef(pressure, id.HasComponent, true)
+	_, err = s.AddCondition(ua.NewStringNodeID(nodeNS.ID(), "PressureAlarm"), pump.ID(), ua.NewNumericNodeID(0, id.NonExclusiveLevelAlarmType), "PressureAlarm",
+		server.ConditionBranches(),
+		server.ConditionLimits(pressure.ID(), server.Limits{High: limit(5)}),
+	)
+	if err != nil {
+		log.Fatalf("Error adding condition: %s", err)
+	}
+
+	// Variables can be marked as historizing to record their values in the history store.
+	hist := nodeNS.AddNewVariableStringNode("hist_float64", 0.0)
+	hist.SetHistorizing(true)
+	nns_obj.AddRef(hist, id.HasComponent, true)
+
+	// Events can be added to the history store directly.
+	history.RecordEvent(nns_obj.ID(), &server.Event{Fields: map[string]*ua.Variant{
+		"Time":      ua.MustVariant(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		"EventType": ua.MustVariant(ua.NewNumericNodeID(0, id.BaseEventType)),
+		"Message":   ua.MustVariant(ua.NewLocalizedText("started")),
+		"Severity":  ua.MustVariant(uint16(100)),
+	}})
+	history.RecordEvent(nns_obj.ID(), &server.Event{Fields: map[string]*ua.Variant{
+		"Time":      ua.MustVariant(time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)),
+		"EventType": ua.MustVariant(ua.NewNumericNodeID(0, id.BaseEventType)),
+		"Message":   ua.MustVariant(ua.NewLocalizedText("stopped")),
+		"Severity":  ua.MustVariant(uint16(200)),
+	}})
+
+	// Add some methods.
+	even, err := nodeNS.AddNewMethodStringNode("even", func(n int64) bool { return n%2 == 0 },
+		server.InputArgumentNames("n"),
+		server.OutputArgumentNames("even"),
+	)
+	if err != nil {
+		log.Fatalf("Error adding method: %s", err)
+	}
+	nns_obj.AddRef(even, id.HasComponent, true)
+
+	div, err := nodeNS.AddNewMethodStringNode("div", func(ctx context.Context, a, b int32) (int32, error) {
+		if server.SessionID(ctx) == nil {
+			return 0, ua.StatusBadSessionIDInvalid
+		}
+		if b == 0 {
+			return 0, ua.StatusBadInvalidArgument
+		}
+		return a / b, nil
+	})
+	if err != nil {
+		log.Fatalf("Error adding method: %s", err)
+	}
+	nns_obj.AddRef(div, id.HasComponent, true)
+
+	// methods can also be bound to existing method nodes, e.g. from an imported NodeSet2 file.
+	concat := server.NewMethodNode(ua.NewStringNodeID(nodeNS.ID(), "concat"), "concat")
+	nodeNS.AddNode(concat)
+	nns_obj.AddRef(concat, id.HasComponent, true)
+	if err := s.BindMethod(concat.ID(), func(s []string, sep string) string { return strings.Join(s, sep) }); err != nil {
+		log.Fatalf("Error binding method: %s", err)
+	}
+
+	// nil outputs are returned as empty variants.
+	lookup, err := nodeNS.AddNewMethodStringNode("lookup", func(name string) *ua.NodeID {
+		if name == "objects" {
+			return ua.NewNumericNodeID(0, id.ObjectsFolder)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error adding method: %s", err)
+	}
+	nns_obj.AddRef(lookup, id.HasComponent, true)
+
+	// a panicking method must not crash the server.
+	fail, err := nodeNS.AddNewMethodStringNode("panic", func() { panic("boom") })
+	if err != nil {
+		log.Fatalf("Error adding method: %s", err)
+	}
+	nns_obj.AddRef(fail, id.HasComponent, true)
+
+	// the setpoint and the reset method are only for authenticated users
+	// and only operators may change or call them.
+	setpoint := nodeNS.AddNewVariableStringNode("setpoint", 20.0)
+	setpoint.SetAttribute(ua.AttributeIDAccessLevel, server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead|ua.AccessLevelTypeCurrentWrite)))
+	setpoint.SetRolePermissions(
+		&ua.RolePermissionType{RoleID: server.RoleAuthenticatedUser, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeRead},
+		&ua.RolePermissionType{RoleID: server.RoleOperator, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeRead | ua.PermissionTypeWrite},
+	)
+	nns_obj.AddRef(setpoint, id.HasComponent, true)
+
+	reset, err := nodeNS.AddNewMethodStringNode("reset", func() {
+		setpoint.SetAttribute(ua.AttributeIDValue, server.DataValueFromValue(20.0))
+	})
+	if err != nil {
+		log.Fatalf("Error adding method: %s", err)
+	}
+	reset.SetRolePermissions(
+		&ua.RolePermissionType{RoleID: server.RoleAuthenticatedUser, Permissions: ua.PermissionTypeBrowse},
+		&ua.RolePermissionType{RoleID: server.RoleOperator, Permissions: ua.PermissionTypeBrowse | ua.PermissionTypeCall},
+	)
+	nns_obj.AddRef(reset, id.HasComponent, true)
+
+	// variables with a data type only accept values of that type. Only the
+	// display name of the typed variables can be changed.
+	typed := func(name string, dataType uint32, rank int32, dims []uint32, v any) {
+		a := map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDBrowseName:  server.DataValueFromValue(attrs.BrowseName(name)),
+			ua.AttributeIDDisplayName: server.DataValueFromValue(attrs.DisplayName(name, name)),
+			ua.AttributeIDDataType:    server.DataValueFromValue(ua.NewNumericExpandedNodeID(0, dataType)),
+			ua.AttributeIDValueRank:   server.DataValueFromValue(rank),
+			ua.AttributeIDAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead | ua.AccessLevelTypeCurrentWrite)),
+			ua.AttributeIDWriteMask:   server.DataValueFromValue(uint32(ua.AttributeWriteMaskDisplayName)),
+		}
+		if dims != nil {
+			a[ua.AttributeIDArrayDimensions] = server.DataValueFromValue(dims)
+		}
+		val := server.DataValueFromValue(v)
+		n := server.NewNode(ua.NewStringNodeID(nodeNS.ID(), name), a, nil, func() *ua.DataValue { return val })
+		nodeNS.AddNode(n)
+		nns_obj.AddRef(n, id.HasComponent, true)
+	}
+	typed("typed_int32", id.Int32, -1, nil, int32(1))
+	typed("typed_duration", id.Duration, -1, nil, 1000.0)
+	typed("typed_number", id.Number, -1, nil, 1.0)
+	typed("typed_array", id.Double, 1, []uint32{3}, []float64{1, 2, 3})
+
 	// Create a new node namespace.  You can add namespaces before or after starting the server.
 	gopcuaNS := server.NewNodeNameSpace(s, "http://gopcua.com/")
 	// add it to the server.
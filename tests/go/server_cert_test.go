@@ -0,0 +1,127 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
+)
+
+// TestServerCertificate performs an integration test to validate the
+// certificate of the server in the client.
+func TestServerCertificate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	const port = 48691
+	srvCert, srvKey := genSelfSignedCert(t, "urn:gopcua:servercert:server")
+	srv := server.New(
+		server.EnableSecurity("Basic256Sha256", ua.MessageSecurityModeSignAndEncrypt),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+		server.EndPoint("localhost", port),
+		server.PrivateKey(srvKey),
+		server.Certificate(srvCert),
+	)
+	require.NoError(t, srv.Start(ctx), "Start failed")
+	defer srv.Close()
+
+	addr := fmt.Sprintf("opc.tcp://localhost:%d", port)
+	var (
+		eps []*ua.EndpointDescription
+		err error
+	)
+	require.Eventually(t, func() bool {
+		eps, err = opcua.GetEndpoints(ctx, addr)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond, "GetEndpoints failed")
+	ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURIBasic256Sha256, ua.MessageSecurityModeSignAndEncrypt)
+	require.NoError(t, err, "SelectEndpoint failed")
+
+	cliCert, cliKey := genSelfSignedCert(t, "urn:gopcua:servercert:client")
+	connect := func(t *testing.T, addr string, opts ...opcua.Option) error {
+		t.Helper()
+		opts = append([]opcua.Option{
+			opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous),
+			opcua.Certificate(cliCert),
+			opcua.PrivateKey(cliKey),
+			opcua.AutoReconnect(false),
+		}, opts...)
+		c, err := opcua.NewClient(addr, opts...)
+		require.NoError(t, err, "NewClient failed")
+		if err := c.Connect(ctx); err != nil {
+			return err
+		}
+		return c.Close(ctx)
+	}
+
+	leaf, err := x509.ParseCertificate(srvCert)
+	require.NoError(t, err, "ParseCertificate failed")
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	t.Run("trust store", func(t *testing.T) {
+		store, err := uacert.NewStore(t.TempDir())
+		require.NoError(t, err, "NewStore failed")
+
+		err = connect(t, addr, opcua.TrustStore(store))
+		require.ErrorIs(t, err, ua.StatusBadCertificateUntrusted)
+		rejected, err := store.Rejected()
+		require.NoError(t, err, "Rejected failed")
+		require.Len(t, rejected, 1)
+
+		require.NoError(t, store.Trust(srvCert), "Trust failed")
+		require.NoError(t, connect(t, addr, opcua.TrustStore(store)))
+	})
+
+	t.Run("trusted certificates", func(t *testing.T) {
+		require.NoError(t, connect(t, addr, opcua.TrustedCertificates(pool)))
+		require.ErrorIs(t, connect(t, addr, opcua.TrustedCertificates(x509.NewCertPool())), ua.StatusBadCertificateUntrusted)
+	})
+
+	t.Run("host name", func(t *testing.T) {
+		err := connect(t, fmt.Sprintf("opc.tcp://127.0.0.1:%d", port), opcua.TrustedCertificates(pool))
+		require.ErrorIs(t, err, ua.StatusBadCertificateHostNameInvalid)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		store, err := uacert.NewStore(t.TempDir())
+		require.NoError(t, err, "NewStore failed")
+
+		var calls int
+		trust := func(decision opcua.CertificateTrust) opcua.Option {
+			return opcua.CertificateTrustCallback(func(cert *x509.Certificate, err error) opcua.CertificateTrust {
+				calls++
+				require.Equal(t, leaf.Raw, cert.Raw)
+				require.Equal(t, ua.StatusBadCertificateUntrusted, err)
+				return decision
+			})
+		}
+
+		require.ErrorIs(t, connect(t, addr, opcua.TrustStore(store), trust(opcua.RejectCertificate)), ua.StatusBadCertificateUntrusted)
+		require.NoError(t, connect(t, addr, opcua.TrustStore(store), trust(opcua.TrustCertificateOnce)))
+		trusted, err := store.Trusted()
+		require.NoError(t, err, "Trusted failed")
+		require.Empty(t, trusted)
+
+		require.NoError(t, connect(t, addr, opcua.TrustStore(store), trust(opcua.TrustCertificateAlways)))
+		trusted, err = store.Trusted()
+		require.NoError(t, err, "Trusted failed")
+		require.Len(t, trusted, 1)
+		require.Equal(t, 3, calls)
+
+		// the store trusts the certificate now.
+		require.NoError(t, connect(t, addr, opcua.TrustStore(store), trust(opcua.RejectCertificate)))
+		require.Equal(t, 3, calls)
+	})
+}
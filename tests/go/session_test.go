@@ -0,0 +1,204 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessions performs an integration test to check the lifecycle of the
+// sessions of a server.
+func TestSessions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	newClient := func(t *testing.T, opts ...opcua.Option) *opcua.Client {
+		t.Helper()
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", append(opts, opcua.SecurityMode(ua.MessageSecurityModeNone), opcua.AutoReconnect(false))...)
+		require.NoError(t, err, "NewClient failed")
+		return c
+	}
+	connect := func(t *testing.T, opts ...opcua.Option) *opcua.Client {
+		t.Helper()
+		c := newClient(t, opts...)
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		return c
+	}
+	read := func(c *opcua.Client) error {
+		_, err := c.Node(ua.NewNumericNodeID(0, id.Server_ServerStatus_State)).Value(ctx)
+		return err
+	}
+	createSubscription := func(t *testing.T, c *opcua.Client) uint32 {
+		t.Helper()
+		// without publish requests only the session keeps the subscription
+		// alive.
+		var res *ua.CreateSubscriptionResponse
+		err := c.Send(ctx, &ua.CreateSubscriptionRequest{
+			RequestedPublishingInterval: 1000,
+			RequestedLifetimeCount:      10000,
+			RequestedMaxKeepAliveCount:  10,
+			PublishingEnabled:           true,
+		}, func(v ua.Response) error {
+			res = v.(*ua.CreateSubscriptionResponse)
+			return nil
+		})
+		require.NoError(t, err, "CreateSubscription failed")
+		return res.SubscriptionID
+	}
+	transfer := func(t *testing.T, c *opcua.Client, subID uint32) ua.StatusCode {
+		t.Helper()
+		var res *ua.TransferSubscriptionsResponse
+		err := c.Send(ctx, &ua.TransferSubscriptionsRequest{SubscriptionIDs: []uint32{subID}}, func(v ua.Response) error {
+			res = v.(*ua.TransferSubscriptionsResponse)
+			return nil
+		})
+		require.NoError(t, err, "TransferSubscriptions failed")
+		return res.Results[0].StatusCode
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		srv := startServer()
+		defer srv.Close()
+		time.Sleep(2 * time.Second)
+
+		// the subscriptions are transferred between sessions of one user.
+		eps, err := opcua.GetEndpoints(ctx, "opc.tcp://localhost:4840")
+		require.NoError(t, err, "GetEndpoints failed")
+		ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURINone, ua.MessageSecurityModeNone)
+		require.NoError(t, err, "SelectEndpoint failed")
+		user := []opcua.Option{opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName), opcua.AuthUsername("user", "pass")}
+
+		idle := connect(t, append(user, opcua.SessionTimeout(time.Second))...)
+		defer idle.Close(ctx)
+		busy := connect(t, append(user, opcua.SessionTimeout(time.Second))...)
+		defer busy.Close(ctx)
+		other := connect(t, user...)
+		defer other.Close(ctx)
+
+		idleSub := createSubscription(t, idle)
+		busySub := createSubscription(t, busy)
+
+		// requests keep the session alive.
+		for i := 0; i < 6; i++ {
+			time.Sleep(250 * time.Millisecond)
+			require.NoError(t, read(busy))
+		}
+		require.ErrorIs(t, read(idle), ua.StatusBadSessionIDInvalid)
+
+		// the subscriptions of the expired session are deleted.
+		require.Equal(t, ua.StatusBadSubscriptionIDInvalid, transfer(t, other, idleSub))
+		require.Equal(t, ua.StatusOK, transfer(t, other, busySub))
+	})
+
+	t.Run("max sessions", func(t *testing.T) {
+		srv := startServer(server.MaxSessions(1))
+		defer srv.Close()
+		time.Sleep(2 * time.Second)
+
+		c := connect(t)
+		defer c.Close(ctx)
+
+		err := newClient(t).Connect(ctx)
+		require.ErrorIs(t, err, ua.StatusBadTooManySessions)
+
+		// closed sessions free their slot.
+		require.NoError(t, c.CloseSession(ctx))
+		c2 := connect(t)
+		defer c2.Close(ctx)
+	})
+
+	t.Run("secure channel", func(t *testing.T) {
+		srv := startServer()
+		defer srv.Close()
+		time.Sleep(2 * time.Second)
+
+		a := connect(t)
+		defer a.Close(ctx)
+		b := connect(t)
+		defer b.Close(ctx)
+
+		// the session of a moves to the secure channel of b and can only
+		// be used there.
+		s := a.Session()
+		require.NoError(t, b.ActivateSession(ctx, s), "ActivateSession failed")
+		require.NoError(t, read(b))
+		require.ErrorIs(t, read(a), ua.StatusBadSecureChannelIDInvalid)
+
+		// and back again. The client would close the session if it still
+		// had it.
+		_, err := a.DetachSession(ctx)
+		require.NoError(t, err, "DetachSession failed")
+		require.NoError(t, a.ActivateSession(ctx, s), "ActivateSession failed")
+		require.NoError(t, read(a))
+		require.ErrorIs(t, read(b), ua.StatusBadSecureChannelIDInvalid)
+	})
+	t.Run("client certificate", func(t *testing.T) {
+		srv := startServer()
+		defer srv.Close()
+		time.Sleep(2 * time.Second)
+
+		eps, err := opcua.GetEndpoints(ctx, "opc.tcp://localhost:4840")
+		require.NoError(t, err, "GetEndpoints failed")
+		ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURIBasic256Sha256, ua.MessageSecurityModeSignAndEncrypt)
+		require.NoError(t, err, "SelectEndpoint failed")
+
+		connectSecure := func(t *testing.T, cert []byte, key *rsa.PrivateKey) *opcua.Client {
+			t.Helper()
+			c, err := opcua.NewClient("opc.tcp://localhost:4840",
+				opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous),
+				opcua.Certificate(cert),
+				opcua.PrivateKey(key),
+				opcua.AutoReconnect(false),
+			)
+			require.NoError(t, err, "NewClient failed")
+			require.NoError(t, c.Connect(ctx), "Connect failed")
+			return c
+		}
+		cert, key := genSelfSignedCert(t, "urn:gopcua:session:client")
+		otherCert, otherKey := genSelfSignedCert(t, "urn:gopcua:session:other")
+
+		a := connectSecure(t, cert, key)
+		defer a.Close(ctx)
+		b := connectSecure(t, otherCert, otherKey)
+		defer b.Close(ctx)
+		c := connectSecure(t, cert, key)
+		defer c.Close(ctx)
+
+		// the session cannot move to the secure channel of another client.
+		s := a.Session()
+		require.ErrorIs(t, b.ActivateSession(ctx, s), ua.StatusBadSecurityChecksFailed)
+		require.NoError(t, read(a))
+
+		// anonymous subscriptions only move between sessions of the same
+		// client.
+		subID := createSubscription(t, a)
+		require.Equal(t, ua.StatusBadUserAccessDenied, transfer(t, b, subID))
+		require.Equal(t, ua.StatusOK, transfer(t, c, subID))
+
+		// but to another secure channel of the same client.
+		require.NoError(t, c.ActivateSession(ctx, s), "ActivateSession failed")
+		require.NoError(t, read(c))
+
+		// a client cannot create a session with the certificate of
+		// another client.
+		err = b.Send(ctx, &ua.CreateSessionRequest{
+			ClientDescription:       &ua.ApplicationDescription{ApplicationURI: "urn:gopcua:session:client", ApplicationName: &ua.LocalizedText{}},
+			EndpointURL:             "opc.tcp://localhost:4840",
+			SessionName:             "stolen",
+			ClientNonce:             make([]byte, 32),
+			ClientCertificate:       cert,
+			RequestedSessionTimeout: 60000,
+		}, func(ua.Response) error { return nil })
+		require.ErrorIs(t, err, ua.StatusBadCertificateInvalid)
+	})
+}
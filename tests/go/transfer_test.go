@@ -0,0 +1,195 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransferSubscriptions performs an integration test to transfer a
+// subscription between sessions.
+func TestTransferSubscriptions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	srv := startServer()
+	defer srv.Close()
+
+	time.Sleep(2 * time.Second)
+
+	eps, err := opcua.GetEndpoints(ctx, "opc.tcp://localhost:4840")
+	require.NoError(t, err, "GetEndpoints failed")
+	ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURINone, ua.MessageSecurityModeNone)
+	require.NoError(t, err, "SelectEndpoint failed")
+
+	// anonymous sessions without a client certificate cannot be told apart
+	// so the subscriptions are transferred between sessions of one user.
+	connectAs := func(t *testing.T, user, pass string) *opcua.Client {
+		t.Helper()
+		c, err := opcua.NewClient("opc.tcp://localhost:4840",
+			opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName),
+			opcua.AuthUsername(user, pass),
+		)
+		require.NoError(t, err, "NewClient failed")
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		return c
+	}
+	connect := func(t *testing.T) *opcua.Client {
+		t.Helper()
+		return connectAs(t, "user", "pass")
+	}
+
+	// the requests are sent directly so that the publish loop of the client
+	// does not interfere.
+	publish := func(t *testing.T, c *opcua.Client) *ua.PublishResponse {
+		t.Helper()
+		var res *ua.PublishResponse
+		err := c.Send(ctx, &ua.PublishRequest{SubscriptionAcknowledgements: []*ua.SubscriptionAcknowledgement{}}, func(v ua.Response) error {
+			res = v.(*ua.PublishResponse)
+			return nil
+		})
+		require.NoError(t, err, "Publish failed")
+		return res
+	}
+	transfer := func(t *testing.T, c *opcua.Client, ids ...uint32) []*ua.TransferResult {
+		t.Helper()
+		var res *ua.TransferSubscriptionsResponse
+		err := c.Send(ctx, &ua.TransferSubscriptionsRequest{
+			SubscriptionIDs:   ids,
+			SendInitialValues: true,
+		}, func(v ua.Response) error {
+			res = v.(*ua.TransferSubscriptionsResponse)
+			return nil
+		})
+		require.NoError(t, err, "TransferSubscriptions failed")
+		require.Len(t, res.Results, len(ids))
+		return res.Results
+	}
+	dataChanges := func(t *testing.T, res *ua.PublishResponse) []*ua.MonitoredItemNotification {
+		t.Helper()
+		require.Len(t, res.NotificationMessage.NotificationData, 1)
+		dcn, ok := res.NotificationMessage.NotificationData[0].Value.(*ua.DataChangeNotification)
+		require.True(t, ok, "got %T", res.NotificationMessage.NotificationData[0].Value)
+		return dcn.MonitoredItems
+	}
+
+	c1 := connect(t)
+	defer c1.Close(ctx)
+
+	var sub *ua.CreateSubscriptionResponse
+	err = c1.Send(ctx, &ua.CreateSubscriptionRequest{
+		RequestedPublishingInterval: 100,
+		RequestedLifetimeCount:      100,
+		RequestedMaxKeepAliveCount:  20,
+		PublishingEnabled:           true,
+	}, func(v ua.Response) error {
+		sub = v.(*ua.CreateSubscriptionResponse)
+		return nil
+	})
+	require.NoError(t, err, "CreateSubscription failed")
+
+	err = c1.Send(ctx, &ua.CreateMonitoredItemsRequest{
+		SubscriptionID:     sub.SubscriptionID,
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+		ItemsToCreate: []*ua.MonitoredItemCreateRequest{
+			opcua.NewMonitoredItemCreateRequestWithDefaults(ua.NewStringNodeID(1, "rw_int32"), ua.AttributeIDValue, 42),
+		},
+	}, func(v ua.Response) error { return nil })
+	require.NoError(t, err, "CreateMonitoredItems failed")
+
+	first := publish(t, c1)
+	require.Equal(t, sub.SubscriptionID, first.SubscriptionID)
+	require.Len(t, dataChanges(t, first), 1)
+	seq := first.NotificationMessage.SequenceNumber
+
+	c2 := connect(t)
+	defer c2.Close(ctx)
+
+	t.Run("transfer", func(t *testing.T) {
+		res := transfer(t, c2, sub.SubscriptionID, sub.SubscriptionID+100)
+		require.Equal(t, ua.StatusOK, res[0].StatusCode)
+		require.Equal(t, []uint32{seq}, res[0].AvailableSequenceNumbers)
+		require.Equal(t, ua.StatusBadSubscriptionIDInvalid, res[1].StatusCode)
+
+		// the old session learns that the subscription is gone.
+		old := publish(t, c1)
+		require.Equal(t, sub.SubscriptionID, old.SubscriptionID)
+		require.Len(t, old.NotificationMessage.NotificationData, 1)
+		status, ok := old.NotificationMessage.NotificationData[0].Value.(*ua.StatusChangeNotification)
+		require.True(t, ok, "got %T", old.NotificationMessage.NotificationData[0].Value)
+		require.Equal(t, ua.StatusGoodSubscriptionTransferred, status.Status)
+
+		// the new session gets the initial values.
+		res2 := publish(t, c2)
+		require.Equal(t, sub.SubscriptionID, res2.SubscriptionID)
+		items := dataChanges(t, res2)
+		require.Len(t, items, 1)
+		require.Equal(t, uint32(42), items[0].ClientHandle)
+		require.Greater(t, res2.NotificationMessage.SequenceNumber, seq)
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		c3 := connectAs(t, "admin", "secret")
+		defer c3.Close(ctx)
+
+		res := transfer(t, c3, sub.SubscriptionID)
+		require.Equal(t, ua.StatusBadUserAccessDenied, res[0].StatusCode)
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		anonymous := func(t *testing.T) *opcua.Client {
+			t.Helper()
+			c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+			require.NoError(t, err, "NewClient failed")
+			require.NoError(t, c.Connect(ctx), "Connect failed")
+			return c
+		}
+		a := anonymous(t)
+		defer a.Close(ctx)
+		b := anonymous(t)
+		defer b.Close(ctx)
+
+		var res *ua.CreateSubscriptionResponse
+		err := a.Send(ctx, &ua.CreateSubscriptionRequest{
+			RequestedPublishingInterval: 100,
+			RequestedLifetimeCount:      100,
+			RequestedMaxKeepAliveCount:  20,
+			PublishingEnabled:           true,
+		}, func(v ua.Response) error {
+			res = v.(*ua.CreateSubscriptionResponse)
+			return nil
+		})
+		require.NoError(t, err, "CreateSubscription failed")
+
+		// without a client certificate the sessions belong to unknown
+		// client applications.
+		require.Equal(t, ua.StatusBadUserAccessDenied, transfer(t, b, res.SubscriptionID)[0].StatusCode)
+		require.Equal(t, ua.StatusBadUserAccessDenied, transfer(t, c1, res.SubscriptionID)[0].StatusCode)
+	})
+
+	t.Run("lost session", func(t *testing.T) {
+		// the subscription outlives the session which owns it.
+		err := c2.Send(ctx, &ua.CloseSessionRequest{DeleteSubscriptions: false}, func(v ua.Response) error { return nil })
+		require.NoError(t, err, "CloseSession failed")
+
+		c4 := connect(t)
+		res := transfer(t, c4, sub.SubscriptionID)
+		require.Equal(t, ua.StatusOK, res[0].StatusCode)
+		require.Equal(t, sub.SubscriptionID, publish(t, c4).SubscriptionID)
+
+		// closing the session deletes its subscriptions.
+		require.NoError(t, c4.Close(ctx))
+
+		c5 := connect(t)
+		defer c5.Close(ctx)
+		res = transfer(t, c5, sub.SubscriptionID)
+		require.Equal(t, ua.StatusBadSubscriptionIDInvalid, res[0].StatusCode)
+	})
+}
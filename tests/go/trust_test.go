@@ -0,0 +1,101 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uacert"
+)
+
+// TestTrustStore performs an integration test to validate the certificates
+// of the clients with the trust store of the server.
+func TestTrustStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	store, err := uacert.NewStore(t.TempDir())
+	require.NoError(t, err, "NewStore failed")
+
+	const port = 48681
+	srvCert, srvKey := genSelfSignedCert(t, "urn:gopcua:trust:server")
+	srv := server.New(
+		server.EnableSecurity("Basic256Sha256", ua.MessageSecurityModeSignAndEncrypt),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+		server.EndPoint("localhost", port),
+		server.PrivateKey(srvKey),
+		server.Certificate(srvCert),
+		server.TrustStore(store),
+	)
+	require.NoError(t, srv.Start(ctx), "Start failed")
+	defer srv.Close()
+
+	addr := fmt.Sprintf("opc.tcp://localhost:%d", port)
+	var eps []*ua.EndpointDescription
+	require.Eventually(t, func() bool {
+		eps, err = opcua.GetEndpoints(ctx, addr)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond, "GetEndpoints failed")
+	ep, err := opcua.SelectEndpoint(eps, ua.SecurityPolicyURIBasic256Sha256, ua.MessageSecurityModeSignAndEncrypt)
+	require.NoError(t, err, "SelectEndpoint failed")
+
+	connect := func(t *testing.T, cert []byte, key *rsa.PrivateKey, opts ...opcua.Option) error {
+		t.Helper()
+		opts = append([]opcua.Option{
+			opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous),
+			opcua.Certificate(cert),
+			opcua.PrivateKey(key),
+			opcua.AutoReconnect(false),
+		}, opts...)
+		c, err := opcua.NewClient(addr, opts...)
+		require.NoError(t, err, "NewClient failed")
+		if err := c.Connect(ctx); err != nil {
+			return err
+		}
+		return c.Close(ctx)
+	}
+
+	t.Run("untrusted", func(t *testing.T) {
+		cert, key := genSelfSignedCert(t, "urn:gopcua:trust:client")
+		err := connect(t, cert, key)
+		require.ErrorIs(t, err, ua.StatusBadCertificateUntrusted)
+
+		rejected, err := store.Rejected()
+		require.NoError(t, err, "Rejected failed")
+		require.Len(t, rejected, 1)
+
+		// the administrator approves the rejected certificate.
+		require.NoError(t, store.Trust(rejected[0].Raw), "Trust failed")
+		require.NoError(t, connect(t, cert, key))
+	})
+
+	t.Run("application uri", func(t *testing.T) {
+		cert, key := genSelfSignedCert(t, "urn:gopcua:trust:uri")
+		require.NoError(t, store.Trust(cert), "Trust failed")
+
+		err := connect(t, cert, key, opcua.ApplicationURI("urn:gopcua:trust:other"))
+		require.ErrorIs(t, err, ua.StatusBadCertificateURIInvalid)
+	})
+
+	t.Run("auto accept", func(t *testing.T) {
+		store.SetAutoAccept(true)
+		defer store.SetAutoAccept(false)
+
+		cert, key := genSelfSignedCert(t, "urn:gopcua:trust:commissioning")
+		require.NoError(t, connect(t, cert, key))
+
+		// the certificate stays trusted.
+		store.SetAutoAccept(false)
+		require.NoError(t, connect(t, cert, key))
+	})
+}
@@ -0,0 +1,171 @@
+//go:build integration
+// +build integration
+
+package uatest2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriteCheck performs an integration test to check the writes against
+// the access level, the write mask and the data type of the nodes.
+func TestWriteCheck(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	connect := func(t *testing.T, opts ...server.Option) (*server.Server, *opcua.Client) {
+		t.Helper()
+		srv := startServer(opts...)
+		time.Sleep(2 * time.Second)
+
+		c, err := opcua.NewClient("opc.tcp://localhost:4840", opcua.SecurityMode(ua.MessageSecurityModeNone))
+		require.NoError(t, err, "NewClient failed")
+		require.NoError(t, c.Connect(ctx), "Connect failed")
+		return srv, c
+	}
+	write := func(t *testing.T, c *opcua.Client, status ua.StatusCode, wv *ua.WriteValue) {
+		t.Helper()
+		if wv.AttributeID == 0 {
+			wv.AttributeID = ua.AttributeIDValue
+		}
+		testWrite(t, ctx, c, status, &ua.WriteRequest{NodesToWrite: []*ua.WriteValue{wv}})
+	}
+	value := func(name string, v any) *ua.WriteValue {
+		return &ua.WriteValue{
+			NodeID: ua.NewStringNodeID(1, name),
+			Value:  &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+		}
+	}
+
+	t.Run("without conversion", func(t *testing.T) {
+		srv, c := connect(t)
+		defer srv.Close()
+		defer c.Close(ctx)
+
+		t.Run("access level", func(t *testing.T) {
+			write(t, c, ua.StatusBadNotWritable, value("ReadOnlyVariable", 1.0))
+
+			wv := value("typed_int32", int32(2))
+			wv.Value.EncodingMask |= ua.DataValueStatusCode
+			wv.Value.Status = ua.StatusUncertain
+			write(t, c, ua.StatusBadWriteNotSupported, wv)
+
+			wv = value("typed_int32", int32(2))
+			wv.Value.EncodingMask |= ua.DataValueSourceTimestamp
+			wv.Value.SourceTimestamp = time.Now()
+			write(t, c, ua.StatusBadWriteNotSupported, wv)
+
+			wv = value("typed_array", []float64{4})
+			wv.IndexRange = "1"
+			write(t, c, ua.StatusBadWriteNotSupported, wv)
+		})
+
+		t.Run("data type", func(t *testing.T) {
+			write(t, c, ua.StatusOK, value("typed_int32", int32(2)))
+			testRead(t, ctx, c, int32(2), ua.NewStringNodeID(1, "typed_int32"))
+			write(t, c, ua.StatusBadTypeMismatch, value("typed_int32", "2"))
+			write(t, c, ua.StatusBadTypeMismatch, value("typed_int32", 2.0))
+			write(t, c, ua.StatusBadTypeMismatch, value("typed_int32", int64(2)))
+
+			// Duration is a subtype of Double and Double is a Number.
+			write(t, c, ua.StatusOK, value("typed_duration", 500.0))
+			write(t, c, ua.StatusOK, value("typed_number", int16(2)))
+			write(t, c, ua.StatusBadTypeMismatch, value("typed_number", "2"))
+
+			// variables without a data type keep the type of their value.
+			write(t, c, ua.StatusOK, value("rw_int32", int32(7)))
+			write(t, c, ua.StatusBadTypeMismatch, value("rw_int32", "7"))
+		})
+
+		t.Run("value rank", func(t *testing.T) {
+			write(t, c, ua.StatusOK, value("typed_array", []float64{4, 5}))
+			testRead(t, ctx, c, []float64{4, 5}, ua.NewStringNodeID(1, "typed_array"))
+			write(t, c, ua.StatusBadTypeMismatch, value("typed_array", []float64{1, 2, 3, 4}))
+			write(t, c, ua.StatusBadTypeMismatch, value("typed_array", 1.0))
+			write(t, c, ua.StatusBadTypeMismatch, value("typed_int32", []int32{1}))
+		})
+
+		t.Run("write mask", func(t *testing.T) {
+			wv := value("typed_int32", ua.NewLocalizedText("typed"))
+			wv.AttributeID = ua.AttributeIDDisplayName
+			write(t, c, ua.StatusOK, wv)
+
+			wv = value("typed_int32", ua.NewLocalizedText("typed"))
+			wv.AttributeID = ua.AttributeIDDescription
+			write(t, c, ua.StatusBadNotWritable, wv)
+
+			// only the value of nodes without write mask can be changed.
+			wv = value("rw_int32", ua.NewNumericNodeID(0, 12))
+			wv.AttributeID = ua.AttributeIDDataType
+			write(t, c, ua.StatusBadNotWritable, wv)
+
+			for _, attr := range []ua.AttributeID{ua.AttributeIDAccessLevel, ua.AttributeIDUserAccessLevel} {
+				wv = value("ReadOnlyVariable", byte(0xff))
+				wv.AttributeID = attr
+				write(t, c, ua.StatusBadNotWritable, wv)
+			}
+			write(t, c, ua.StatusBadNotWritable, value("ReadOnlyVariable", 1.0))
+
+			// the user attributes are derived and not writable even if
+			// the write mask allows it.
+			wv = value("ReadWriteVariable", byte(0))
+			wv.AttributeID = ua.AttributeIDUserAccessLevel
+			write(t, c, ua.StatusBadNotWritable, wv)
+
+			wv = value("ReadWriteVariable", uint32(0xffffffff))
+			wv.AttributeID = ua.AttributeIDUserWriteMask
+			write(t, c, ua.StatusBadNotWritable, wv)
+		})
+
+		t.Run("attribute type", func(t *testing.T) {
+			wv := value("ReadWriteVariable", int32(1))
+			wv.AttributeID = ua.AttributeIDBrowseName
+			write(t, c, ua.StatusBadTypeMismatch, wv)
+
+			wv = value("ReadWriteVariable", "description")
+			wv.AttributeID = ua.AttributeIDDescription
+			write(t, c, ua.StatusBadTypeMismatch, wv)
+
+			wv = value("typed_int32", int32(1))
+			wv.AttributeID = ua.AttributeIDDisplayName
+			write(t, c, ua.StatusBadTypeMismatch, wv)
+
+			name, err := c.Node(ua.NewStringNodeID(1, "ReadWriteVariable")).BrowseName(ctx)
+			require.NoError(t, err, "BrowseName failed")
+			require.Equal(t, "ReadWriteVariable", name.Name)
+
+			wv = value("ReadWriteVariable", &ua.QualifiedName{NamespaceIndex: 1, Name: "renamed"})
+			wv.AttributeID = ua.AttributeIDBrowseName
+			write(t, c, ua.StatusOK, wv)
+			name, err = c.Node(ua.NewStringNodeID(1, "ReadWriteVariable")).BrowseName(ctx)
+			require.NoError(t, err, "BrowseName failed")
+			require.Equal(t, "renamed", name.Name)
+		})
+	})
+
+	t.Run("implicit numeric conversion", func(t *testing.T) {
+		srv, c := connect(t, server.ImplicitNumericConversion())
+		defer srv.Close()
+		defer c.Close(ctx)
+
+		write(t, c, ua.StatusOK, value("typed_int32", 41.5))
+		testRead(t, ctx, c, int32(42), ua.NewStringNodeID(1, "typed_int32"))
+		write(t, c, ua.StatusOK, value("typed_int32", uint8(3)))
+		testRead(t, ctx, c, int32(3), ua.NewStringNodeID(1, "typed_int32"))
+		write(t, c, ua.StatusBadTypeMismatch, value("typed_int32", 1e10))
+		write(t, c, ua.StatusBadTypeMismatch, value("typed_int32", "2"))
+
+		write(t, c, ua.StatusOK, value("typed_duration", int32(250)))
+		testRead(t, ctx, c, 250.0, ua.NewStringNodeID(1, "typed_duration"))
+
+		write(t, c, ua.StatusOK, value("typed_array", []int32{7, 8}))
+		testRead(t, ctx, c, []float64{7, 8}, ua.NewStringNodeID(1, "typed_array"))
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+// The names of the parameters in the additional headers of CreateSession and
+// ActivateSession which exchange the ephemeral keys for the EccEncryptedSecrets
+// of the user identity tokens. The client requests an ephemeral key for a
+// security policy with ECDHPolicyUri and the server returns it as an
+// EphemeralKeyType in ECDHKey.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.31
+const (
+	ECDHPolicyURIParameter = "ECDHPolicyUri"
+	ECDHKeyParameter       = "ECDHKey"
+)
+
+// NewAdditionalParameter returns an AdditionalParametersType with a single
+// parameter for the additional header of a request or a response.
+func NewAdditionalParameter(name string, value *Variant) *ExtensionObject {
+	return NewExtensionObject(&AdditionalParametersType{
+		Parameters: []*KeyValuePair{{Key: &QualifiedName{Name: name}, Value: value}},
+	})
+}
+
+// AdditionalParameter returns the value of the parameter with the name in an
+// additional header or nil if the header is not an AdditionalParametersType or
+// does not have the parameter.
+func AdditionalParameter(header *ExtensionObject, name string) *Variant {
+	if header == nil {
+		return nil
+	}
+	p, ok := header.Value.(*AdditionalParametersType)
+	if !ok {
+		return nil
+	}
+	for _, kv := range p.Parameters {
+		if kv.Key != nil && kv.Key.Name == name {
+			return kv.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package ua
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdditionalParameter(t *testing.T) {
+	key := &EphemeralKeyType{PublicKey: []byte{1, 2, 3}, Signature: []byte{4, 5}}
+	b, err := Encode(NewAdditionalParameter(ECDHKeyParameter, MustVariant(NewExtensionObject(key))))
+	require.NoError(t, err)
+
+	h := new(ExtensionObject)
+	_, err = Decode(b, h)
+	require.NoError(t, err)
+
+	v := AdditionalParameter(h, ECDHKeyParameter)
+	require.NotNil(t, v)
+	eo, ok := v.Value().(*ExtensionObject)
+	require.True(t, ok)
+	require.Equal(t, key, eo.Value)
+
+	require.Nil(t, AdditionalParameter(h, ECDHPolicyURIParameter))
+	require.Nil(t, AdditionalParameter(NewExtensionObject(nil), ECDHKeyParameter))
+	require.Nil(t, AdditionalParameter(nil, ECDHKeyParameter))
+}
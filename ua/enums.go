@@ -97,6 +97,10 @@ const (
 	SecurityPolicyURIBasic256Sha256      = "http://opcfoundation.org/UA/SecurityPolicy#Basic256Sha256"
 	SecurityPolicyURIAes128Sha256RsaOaep = "http://opcfoundation.org/UA/SecurityPolicy#Aes128_Sha256_RsaOaep"
 	SecurityPolicyURIAes256Sha256RsaPss  = "http://opcfoundation.org/UA/SecurityPolicy#Aes256_Sha256_RsaPss"
+	SecurityPolicyURIECCNistP256         = "http://opcfoundation.org/UA/SecurityPolicy#ECC_nistP256"
+	SecurityPolicyURIECCNistP384         = "http://opcfoundation.org/UA/SecurityPolicy#ECC_nistP384"
+	SecurityPolicyURIECCBrainpoolP256r1  = "http://opcfoundation.org/UA/SecurityPolicy#ECC_brainpoolP256r1"
+	SecurityPolicyURIECCBrainpoolP384r1  = "http://opcfoundation.org/UA/SecurityPolicy#ECC_brainpoolP384r1"
 )
 
 var SecurityPolicyURIs = map[string]string{
@@ -0,0 +1,358 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uacert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/uapolicy"
+)
+
+// The directories of the own certificate of an application.
+const (
+	ownCerts   = "own/certs"
+	ownPrivate = "own/private"
+)
+
+// KeyType is the type of the key of a certificate.
+type KeyType int
+
+const (
+	// RSA2048 is a 2048 bit RSA key for the RSA security policies.
+	RSA2048 KeyType = iota
+
+	// RSA4096 is a 4096 bit RSA key for the RSA security policies.
+	RSA4096
+
+	// NistP256 is an ECDSA key on the NIST P-256 curve for the
+	// ECC_nistP256 security policy.
+	NistP256
+
+	// NistP384 is an ECDSA key on the NIST P-384 curve for the
+	// ECC_nistP384 security policy.
+	NistP384
+
+	// BrainpoolP256r1 is an ECDSA key on the brainpoolP256r1 curve for
+	// the ECC_brainpoolP256r1 security policy.
+	BrainpoolP256r1
+
+	// BrainpoolP384r1 is an ECDSA key on the brainpoolP384r1 curve for
+	// the ECC_brainpoolP384r1 security policy.
+	BrainpoolP384r1
+)
+
+// Options are the properties of an application instance certificate.
+type Options struct {
+	// ApplicationURI is the uri of the application. It is required.
+	ApplicationURI string
+
+	// CommonName is the common name of the subject of the certificate.
+	// It defaults to the application uri.
+	CommonName string
+
+	// Organization is the organization of the subject of the certificate.
+	Organization string
+
+	// Hosts are the DNS names and the ip addresses of the application. The
+	// host name of the system is used if it is empty.
+	Hosts []string
+
+	// KeyType is the type of the key. The default is RSA2048.
+	KeyType KeyType
+
+	// ValidFor is the validity of the certificate. The default is one
+	// year.
+	ValidFor time.Duration
+
+	// RenewBefore is the duration before the expiry of the certificate
+	// after which LoadOrCreate renews it. The default is a tenth of
+	// ValidFor. The certificate is only renewed when LoadOrCreate is
+	// called.
+	RenewBefore time.Duration
+}
+
+// Certificate is an application instance certificate with its private key.
+type Certificate struct {
+	// Certificate is the DER encoded certificate.
+	Certificate []byte
+
+	// PrivateKey is the private key of the certificate. It is a
+	// *rsa.PrivateKey or a *ecdsa.PrivateKey.
+	PrivateKey crypto.Signer
+}
+
+// Generate creates a self signed application instance certificate with
+// the subject alternative names, the key usage and the extended key usage
+// which Part 6 requires.
+//
+// https://reference.opcfoundation.org/Core/Part6/v105/docs/6.2.2
+func Generate(opts Options) (*Certificate, error) {
+	if opts.ApplicationURI == "" {
+		return nil, errors.New("uacert: missing application uri")
+	}
+	uri, err := url.Parse(opts.ApplicationURI)
+	if err != nil {
+		return nil, fmt.Errorf("uacert: invalid application uri: %w", err)
+	}
+	hosts := opts.Hosts
+	if len(hosts) == 0 {
+		h, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		hosts = []string{h}
+	}
+	name := opts.CommonName
+	if name == "" {
+		name = opts.ApplicationURI
+	}
+	validFor := opts.ValidFor
+	if validFor == 0 {
+		validFor = 365 * 24 * time.Hour
+	}
+
+	key, err := generateKey(opts.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := uapolicy.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	ski := sha1.Sum(pub)
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := pkix.Name{CommonName: name}
+	if opts.Organization != "" {
+		subject.Organization = []string{opts.Organization}
+	}
+
+	// self signed certificates are their own issuer and need the
+	// permission to sign certificates.
+	usage := x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageCertSign
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		usage |= x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             now,
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              usage,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		SubjectKeyId:          ski[:],
+		AuthorityKeyId:        ski[:],
+		URIs:                  []*url.URL{uri},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := uapolicy.CreateCertificate(tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{Certificate: der, PrivateKey: key}, nil
+}
+
+// generateKey creates a private key of the type.
+func generateKey(typ KeyType) (crypto.Signer, error) {
+	switch typ {
+	case RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case RSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case NistP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case NistP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case BrainpoolP256r1:
+		return ecdsa.GenerateKey(uapolicy.BrainpoolP256r1(), rand.Reader)
+	case BrainpoolP384r1:
+		return ecdsa.GenerateKey(uapolicy.BrainpoolP384r1(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("uacert: invalid key type %d", typ)
+	}
+}
+
+// LoadOrCreate loads the application instance certificate with the
+// application uri from the own directory of the PKI directory:
+//
+//	<dir>/own/certs    the DER encoded certificate
+//	<dir>/own/private  the PEM encoded private key with the same name
+//
+// It creates the certificate if there is none and renews it if it expires
+// within the renewal period. The renewed certificate replaces the old one.
+// The other options only apply to new certificates.
+//
+// LoadOrCreate does not watch the certificate. Long running applications
+// must call it again periodically, e.g. daily, and start using the
+// returned certificate when it differs from the one they use. Otherwise
+// the certificate expires while the application is running.
+func LoadOrCreate(dir string, opts Options) (*Certificate, error) {
+	for _, d := range []string{ownCerts, ownPrivate} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o700); err != nil {
+			return nil, err
+		}
+	}
+
+	renewBefore := opts.RenewBefore
+	if renewBefore == 0 {
+		validFor := opts.ValidFor
+		if validFor == 0 {
+			validFor = 365 * 24 * time.Hour
+		}
+		renewBefore = validFor / 10
+	}
+
+	c, files, err := loadOwn(dir, opts.ApplicationURI)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		cert, err := uapolicy.ParseCertificate(c.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		if time.Until(cert.NotAfter) > renewBefore {
+			return c, nil
+		}
+	}
+
+	nc, err := Generate(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeOwn(dir, nc); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nc, nil
+}
+
+// loadOwn returns the own certificate with the application uri which
+// expires last and the paths of its files. It returns nil if there is
+// none.
+func loadOwn(dir, uri string) (*Certificate, []string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, ownCerts))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		own      *Certificate
+		files    []string
+		notAfter time.Time
+	)
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		certFile := filepath.Join(dir, ownCerts, e.Name())
+		b, err := os.ReadFile(certFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		cert, err := uapolicy.ParseCertificate(b)
+		if err != nil || VerifyApplicationURI(b, uri) != nil || !cert.NotAfter.After(notAfter) {
+			continue
+		}
+		keyFile := filepath.Join(dir, ownPrivate, strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))+".pem")
+		key, err := readKey(keyFile)
+		if err != nil || !isKeyOf(key, cert) {
+			continue
+		}
+		own, files, notAfter = &Certificate{Certificate: b, PrivateKey: key}, []string{certFile, keyFile}, cert.NotAfter
+	}
+	return own, files, nil
+}
+
+// isKeyOf returns true if the private key belongs to the certificate.
+func isKeyOf(key crypto.Signer, cert *x509.Certificate) bool {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(cert.PublicKey)
+}
+
+// readKey reads a PEM encoded PKCS #1, PKCS #8 or EC private key.
+func readKey(filename string) (crypto.Signer, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("uacert: no PEM data in %s", filename)
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = uapolicy.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = uapolicy.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("uacert: unsupported private key type %T", key)
+	}
+}
+
+// writeOwn saves the own certificate and its private key.
+func writeOwn(dir string, c *Certificate) error {
+	cert, err := uapolicy.ParseCertificate(c.Certificate)
+	if err != nil {
+		return err
+	}
+	key, err := uapolicy.MarshalPKCS8PrivateKey(c.PrivateKey)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(fileName(cert), ".der")
+	if err := os.WriteFile(filepath.Join(dir, ownPrivate, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ownCerts, name+".der"), c.Certificate, 0o600)
+}
@@ -0,0 +1,115 @@
+package uacert
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gopcua/opcua/uapolicy"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  KeyType
+		bits int
+	}{
+		{"RSA2048", RSA2048, 2048},
+		{"RSA4096", RSA4096, 4096},
+		{"NistP256", NistP256, 256},
+		{"NistP384", NistP384, 384},
+		{"BrainpoolP256r1", BrainpoolP256r1, 256},
+		{"BrainpoolP384r1", BrainpoolP384r1, 384},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Generate(Options{
+				ApplicationURI: "urn:gopcua:test:app",
+				Organization:   "gopcua",
+				Hosts:          []string{"localhost", "127.0.0.1"},
+				KeyType:        tt.typ,
+			})
+			require.NoError(t, err)
+
+			cert, err := uapolicy.ParseCertificate(c.Certificate)
+			require.NoError(t, err)
+			require.Equal(t, "urn:gopcua:test:app", cert.Subject.CommonName)
+			require.Equal(t, []string{"gopcua"}, cert.Subject.Organization)
+			require.Equal(t, []string{"localhost"}, cert.DNSNames)
+			require.True(t, cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+			require.Equal(t, cert.SubjectKeyId, cert.AuthorityKeyId)
+			require.WithinDuration(t, time.Now().Add(365*24*time.Hour), cert.NotAfter, time.Minute)
+
+			switch k := c.PrivateKey.(type) {
+			case *rsa.PrivateKey:
+				require.Equal(t, tt.bits, k.N.BitLen())
+			case *ecdsa.PrivateKey:
+				require.Equal(t, tt.bits, k.Curve.Params().BitSize)
+			}
+
+			roots := x509.NewCertPool()
+			roots.AddCert(cert)
+			require.NoError(t, VerifyChain(c.Certificate, roots))
+			require.NoError(t, VerifyUsage(c.Certificate, x509.ExtKeyUsageServerAuth))
+			require.NoError(t, VerifyUsage(c.Certificate, x509.ExtKeyUsageClientAuth))
+			require.NoError(t, VerifyHostname(c.Certificate, "localhost"))
+			require.NoError(t, VerifyApplicationURI(c.Certificate, "urn:gopcua:test:app"))
+		})
+	}
+
+	_, err := Generate(Options{})
+	require.Error(t, err)
+}
+
+func TestLoadOrCreate(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{ApplicationURI: "urn:gopcua:test:app", Hosts: []string{"localhost"}, KeyType: NistP256}
+
+	files := func(t *testing.T, d string) int {
+		t.Helper()
+		entries, err := os.ReadDir(filepath.Join(dir, d))
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	c, err := LoadOrCreate(dir, opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, files(t, ownCerts))
+	require.Equal(t, 1, files(t, ownPrivate))
+
+	// the certificate is loaded on the next start.
+	loaded, err := LoadOrCreate(dir, opts)
+	require.NoError(t, err)
+	require.Equal(t, c.Certificate, loaded.Certificate)
+	require.True(t, c.PrivateKey.(*ecdsa.PrivateKey).Equal(loaded.PrivateKey))
+
+	// another application gets its own certificate.
+	other, err := LoadOrCreate(dir, Options{ApplicationURI: "urn:gopcua:test:other", Hosts: []string{"localhost"}, KeyType: NistP256})
+	require.NoError(t, err)
+	require.NotEqual(t, c.Certificate, other.Certificate)
+	require.Equal(t, 2, files(t, ownCerts))
+
+	// the certificate is renewed within the renewal period.
+	opts.RenewBefore = 2 * 365 * 24 * time.Hour
+	renewed, err := LoadOrCreate(dir, opts)
+	require.NoError(t, err)
+	require.NotEqual(t, c.Certificate, renewed.Certificate)
+	require.Equal(t, 2, files(t, ownCerts))
+	require.Equal(t, 2, files(t, ownPrivate))
+
+	// brainpool keys are saved and loaded as well.
+	bpOpts := Options{ApplicationURI: "urn:gopcua:test:brainpool", Hosts: []string{"localhost"}, KeyType: BrainpoolP256r1}
+	bp, err := LoadOrCreate(dir, bpOpts)
+	require.NoError(t, err)
+	loaded, err = LoadOrCreate(dir, bpOpts)
+	require.NoError(t, err)
+	require.Equal(t, bp.Certificate, loaded.Certificate)
+	require.True(t, bp.PrivateKey.(*ecdsa.PrivateKey).Equal(loaded.PrivateKey))
+}
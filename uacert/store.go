@@ -0,0 +1,428 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+// Package uacert manages the certificates of OPC UA applications.
+package uacert
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uapolicy"
+)
+
+// The directories of a store.
+const (
+	trustedCerts  = "trusted/certs"
+	trustedCRL    = "trusted/crl"
+	issuersCerts  = "issuers/certs"
+	issuersCRL    = "issuers/crl"
+	rejectedCerts = "rejected/certs"
+)
+
+// Store is a store for the certificates of other applications with the
+// directory layout of OPC UA Part 12:
+//
+//	<dir>/trusted/certs   certificates of trusted applications and CAs
+//	<dir>/trusted/crl     revocation lists of the trusted CAs
+//	<dir>/issuers/certs   certificates of CAs which complete the chains
+//	<dir>/issuers/crl     revocation lists of the issuer CAs
+//	<dir>/rejected/certs  rejected certificates
+//
+// A certificate is trusted if it or one of the CAs in its chain is in the
+// trusted directory. Certificates and revocation lists are DER or PEM
+// encoded files. The directories are read on every verification so that
+// an administrator can trust a rejected certificate by moving it to the
+// trusted directory.
+//
+// https://reference.opcfoundation.org/Core/Part12/v105/docs/F.1
+type Store struct {
+	dir string
+
+	// mu protects autoAccept and maxRejected and serializes the writes
+	// to the directories.
+	mu          sync.Mutex
+	autoAccept  bool
+	maxRejected int
+}
+
+// DefaultMaxRejected is the default number of certificates which a store
+// keeps in the rejected directory.
+const DefaultMaxRejected = 100
+
+// NewStore returns a store in the directory and creates the directories
+// of the store if they do not exist.
+func NewStore(dir string) (*Store, error) {
+	for _, d := range []string{trustedCerts, trustedCRL, issuersCerts, issuersCRL, rejectedCerts} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{dir: dir, maxRejected: DefaultMaxRejected}, nil
+}
+
+// Dir returns the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// SetAutoAccept enables or disables trusting untrusted certificates on
+// their first use instead of rejecting them. It is meant for the
+// commissioning of a system and should be disabled in production.
+func (s *Store) SetAutoAccept(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.autoAccept = on
+}
+
+// Verify validates the DER encoded certificate of an application. cert
+// may contain the chain of the certificate after the certificate itself.
+// The returned error is a ua.StatusCode:
+//
+//   - BadCertificateInvalid if the certificate cannot be parsed or no
+//     chain can be built.
+//   - BadCertificateTimeInvalid if the certificate is expired or not yet
+//     valid and BadCertificateIssuerTimeInvalid if this is the case for
+//     one of its issuers.
+//   - BadCertificateUntrusted if neither the certificate nor one of its
+//     issuers is trusted.
+//   - BadCertificateRevoked if the certificate is revoked and
+//     BadCertificateIssuerRevoked if one of its issuers is revoked.
+//   - BadCertificateRevocationUnknown if the issuer of the certificate
+//     has no current revocation list and
+//     BadCertificateIssuerRevocationUnknown if this is the case for the
+//     issuer of one of its issuers.
+//
+// Rejected certificates are saved in the rejected directory which keeps
+// the most recent ones up to the limit of SetMaxRejected. Untrusted
+// certificates are saved in the trusted directory instead if auto accept
+// is enabled.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/6.1.3
+func (s *Store) Verify(cert []byte) error {
+	certs, err := uapolicy.ParseCertificates(cert)
+	if err != nil || len(certs) == 0 {
+		return ua.StatusBadCertificateInvalid
+	}
+	leaf := certs[0]
+
+	code := s.verify(leaf, certs[1:])
+	switch {
+	case code == ua.StatusOK:
+		return nil
+	case code == ua.StatusBadCertificateUntrusted && s.autoAccepting():
+		return s.write(trustedCerts, leaf)
+	default:
+		if err := s.reject(leaf); err != nil {
+			return err
+		}
+		return code
+	}
+}
+
+// SetMaxRejected sets the number of certificates which are kept in the
+// rejected directory. The oldest certificates are removed when a new one
+// is rejected so that unknown clients cannot fill the disk. Zero means no
+// limit.
+func (s *Store) SetMaxRejected(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxRejected = n
+}
+
+// autoAccepting returns true if untrusted certificates are trusted on
+// their first use.
+func (s *Store) autoAccepting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.autoAccept
+}
+
+// verify validates the certificate with the chain which the application
+// sent.
+func (s *Store) verify(leaf *x509.Certificate, chain []*x509.Certificate) ua.StatusCode {
+	now := time.Now()
+	if code := validity(leaf, now); code != ua.StatusOK {
+		return code
+	}
+
+	trusted, err := readCerts(filepath.Join(s.dir, trustedCerts))
+	if err != nil {
+		return ua.StatusBadInternalError
+	}
+	issuers, err := readCerts(filepath.Join(s.dir, issuersCerts))
+	if err != nil {
+		return ua.StatusBadInternalError
+	}
+
+	// the issuers are roots as well so that chains to untrusted CAs can
+	// be told apart from invalid chains.
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, c := range trusted {
+		roots.AddCert(c)
+	}
+	for _, c := range issuers {
+		roots.AddCert(c)
+		intermediates.AddCert(c)
+	}
+	for _, c := range chain {
+		intermediates.AddCert(c)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return chainStatus(err)
+	}
+
+	for _, c := range chains {
+		if !isTrusted(c, trusted) {
+			continue
+		}
+		crls, err := s.readCRLs()
+		if err != nil {
+			return ua.StatusBadInternalError
+		}
+		return revoked(c, crls, now)
+	}
+	return ua.StatusBadCertificateUntrusted
+}
+
+// isTrusted returns true if one of the certificates of the chain is
+// trusted.
+func isTrusted(chain, trusted []*x509.Certificate) bool {
+	for _, c := range chain {
+		for _, t := range trusted {
+			if c.Equal(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// revoked checks the certificates of the chain against the revocation
+// lists of their issuers. Every CA in the chain must have a current
+// revocation list. Otherwise it is unknown whether the certificates which
+// it issued are revoked.
+func revoked(chain []*x509.Certificate, crls []*x509.RevocationList, now time.Time) ua.StatusCode {
+	for i := 0; i < len(chain)-1; i++ {
+		c, issuer := chain[i], chain[i+1]
+		known := false
+		for _, crl := range crls {
+			if crl.CheckSignatureFrom(issuer) != nil {
+				continue
+			}
+			// expired revocation lists are ignored.
+			if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+				continue
+			}
+			known = true
+			for _, e := range crl.RevokedCertificateEntries {
+				if e.SerialNumber.Cmp(c.SerialNumber) != 0 {
+					continue
+				}
+				if i == 0 {
+					return ua.StatusBadCertificateRevoked
+				}
+				return ua.StatusBadCertificateIssuerRevoked
+			}
+		}
+		if !known {
+			if i == 0 {
+				return ua.StatusBadCertificateRevocationUnknown
+			}
+			return ua.StatusBadCertificateIssuerRevocationUnknown
+		}
+	}
+	return ua.StatusOK
+}
+
+// Trust adds the DER encoded certificate to the trusted certificates and
+// removes it from the rejected certificates. If cert contains a chain
+// only the first certificate is trusted.
+func (s *Store) Trust(cert []byte) error {
+	c, err := parseLeaf(cert)
+	if err != nil {
+		return err
+	}
+	if err := s.write(trustedCerts, c); err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(s.dir, rejectedCerts, fileName(c)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Trusted returns the trusted certificates.
+func (s *Store) Trusted() ([]*x509.Certificate, error) {
+	return readCerts(filepath.Join(s.dir, trustedCerts))
+}
+
+// Rejected returns the rejected certificates.
+func (s *Store) Rejected() ([]*x509.Certificate, error) {
+	return readCerts(filepath.Join(s.dir, rejectedCerts))
+}
+
+// write saves the DER encoded certificate in the directory.
+func (s *Store) write(dir string, c *x509.Certificate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.WriteFile(filepath.Join(s.dir, dir, fileName(c)), c.Raw, 0o600)
+}
+
+// reject saves the certificate in the rejected directory and removes the
+// oldest rejected certificates above the limit. A certificate which is
+// rejected again replaces its file.
+func (s *Store) reject(c *x509.Certificate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := filepath.Join(s.dir, rejectedCerts)
+	if err := os.WriteFile(filepath.Join(dir, fileName(c)), c.Raw, 0o600); err != nil {
+		return err
+	}
+	if s.maxRejected <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type file struct {
+		name string
+		mod  time.Time
+	}
+	var files []file
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{e.Name(), info.ModTime()})
+	}
+	if len(files) <= s.maxRejected {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	for _, f := range files[:len(files)-s.maxRejected] {
+		if f.name == fileName(c) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, f.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCRLs returns the revocation lists of the trusted and the issuer CAs.
+func (s *Store) readCRLs() ([]*x509.RevocationList, error) {
+	var crls []*x509.RevocationList
+	for _, d := range []string{trustedCRL, issuersCRL} {
+		files, err := readFiles(filepath.Join(s.dir, d), "X509 CRL")
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range files {
+			if crl, err := x509.ParseRevocationList(b); err == nil {
+				crls = append(crls, crl)
+			}
+		}
+	}
+	return crls, nil
+}
+
+// readCerts returns the certificates in the directory. Files which do not
+// contain certificates are ignored.
+func readCerts(dir string) ([]*x509.Certificate, error) {
+	files, err := readFiles(dir, "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for _, b := range files {
+		if c, err := uapolicy.ParseCertificates(b); err == nil {
+			certs = append(certs, c...)
+		}
+	}
+	return certs, nil
+}
+
+// readFiles returns the DER encoded content of the files in the directory.
+// PEM encoded files are decoded and only the blocks of the type are kept.
+func readFiles(dir, typ string) ([][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files [][]byte
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		block, rest := pem.Decode(b)
+		if block == nil {
+			files = append(files, b)
+			continue
+		}
+		for block != nil {
+			if block.Type == typ {
+				files = append(files, block.Bytes)
+			}
+			block, rest = pem.Decode(rest)
+		}
+	}
+	return files, nil
+}
+
+// parseLeaf parses the first certificate of a DER encoded chain.
+func parseLeaf(cert []byte) (*x509.Certificate, error) {
+	certs, err := uapolicy.ParseCertificates(cert)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, ua.StatusBadCertificateInvalid
+	}
+	return certs[0], nil
+}
+
+// fileName returns the name of the file of a certificate in the form
+// "<CommonName> [<Thumbprint>].der" which Part 12 recommends.
+func fileName(c *x509.Certificate) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == ' ', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, c.Subject.CommonName)
+	return fmt.Sprintf("%s [%X].der", name, sha1.Sum(c.Raw))
+}
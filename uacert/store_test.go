@@ -0,0 +1,216 @@
+package uacert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newCert creates a certificate which is signed by the issuer or a self
+// signed certificate if the issuer is nil.
+func newCert(t *testing.T, name string, serial int64, ca bool, notAfter time.Time, issuer *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	uri, err := url.Parse("urn:gopcua:test:" + name)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-2 * time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+		URIs:                  []*url.URL{uri},
+	}
+	parent, signer := tmpl, key
+	if issuer != nil {
+		parent, signer = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	c, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: c, key: key}
+}
+
+func writeFile(t *testing.T, s *Store, dir string, name string, b []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(s.Dir(), dir, name), b, 0o600))
+}
+
+// newCRL creates a revocation list of the issuer.
+func newCRL(t *testing.T, issuer *testCert, number int64, nextUpdate time.Time, revoked ...*testCert) []byte {
+	t.Helper()
+	var entries []x509.RevocationListEntry
+	for _, c := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: c.cert.SerialNumber, RevocationTime: time.Now()})
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                time.Now().Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, issuer.cert, issuer.key)
+	require.NoError(t, err)
+	return crl
+}
+
+func TestStore(t *testing.T) {
+	valid := time.Now().Add(time.Hour)
+
+	ca := newCert(t, "ca", 1, true, valid, nil)
+	app := newCert(t, "app", 2, false, valid, ca)
+	revokedApp := newCert(t, "revoked", 3, false, valid, ca)
+	self := newCert(t, "self", 4, false, valid, nil)
+	expired := newCert(t, "expired", 5, false, time.Now().Add(-time.Hour), ca)
+
+	crl := newCRL(t, ca, 1, valid, revokedApp)
+
+	t.Run("untrusted", func(t *testing.T) {
+		s, err := NewStore(t.TempDir())
+		require.NoError(t, err)
+
+		require.Equal(t, ua.StatusBadCertificateUntrusted, s.Verify(self.cert.Raw))
+		require.Equal(t, ua.StatusBadCertificateUntrusted, s.Verify(app.cert.Raw))
+
+		// the ca is known but not trusted.
+		writeFile(t, s, issuersCerts, "ca.der", ca.cert.Raw)
+		require.Equal(t, ua.StatusBadCertificateUntrusted, s.Verify(app.cert.Raw))
+
+		rejected, err := s.Rejected()
+		require.NoError(t, err)
+		require.Len(t, rejected, 2)
+
+		// the administrator approves a rejected certificate.
+		require.NoError(t, s.Trust(self.cert.Raw))
+		require.NoError(t, s.Verify(self.cert.Raw))
+		rejected, err = s.Rejected()
+		require.NoError(t, err)
+		require.Len(t, rejected, 1)
+	})
+
+	t.Run("trusted ca", func(t *testing.T) {
+		s, err := NewStore(t.TempDir())
+		require.NoError(t, err)
+		writeFile(t, s, trustedCerts, "ca.der", ca.cert.Raw)
+		writeFile(t, s, trustedCRL, "ca.crl", crl)
+
+		require.NoError(t, s.Verify(app.cert.Raw))
+		require.Equal(t, ua.StatusBadCertificateRevoked, s.Verify(revokedApp.cert.Raw))
+		require.Equal(t, ua.StatusBadCertificateTimeInvalid, s.Verify(expired.cert.Raw))
+		require.Equal(t, ua.StatusBadCertificateUntrusted, s.Verify(self.cert.Raw))
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		s, err := NewStore(t.TempDir())
+		require.NoError(t, err)
+		writeFile(t, s, trustedCerts, "ca.der", ca.cert.Raw)
+		writeFile(t, s, trustedCRL, "ca.crl", crl)
+
+		sub := newCert(t, "sub", 6, true, valid, ca)
+		leaf := newCert(t, "leaf", 7, false, valid, sub)
+		writeFile(t, s, issuersCRL, "sub.crl", newCRL(t, sub, 1, valid))
+
+		// the application sends the chain after its certificate.
+		require.Equal(t, ua.StatusBadCertificateUntrusted, s.Verify(leaf.cert.Raw))
+		require.NoError(t, s.Verify(append(leaf.cert.Raw, sub.cert.Raw...)))
+
+		// the sub ca is revoked by the ca.
+		writeFile(t, s, trustedCRL, "ca.crl", newCRL(t, ca, 2, valid, sub))
+		require.Equal(t, ua.StatusBadCertificateIssuerRevoked, s.Verify(append(leaf.cert.Raw, sub.cert.Raw...)))
+	})
+
+	t.Run("revocation unknown", func(t *testing.T) {
+		s, err := NewStore(t.TempDir())
+		require.NoError(t, err)
+		writeFile(t, s, trustedCerts, "ca.der", ca.cert.Raw)
+
+		sub := newCert(t, "sub", 8, true, valid, ca)
+		leaf := newCert(t, "leaf", 9, false, valid, sub)
+		chain := append(leaf.cert.Raw, sub.cert.Raw...)
+
+		// without revocation lists it is unknown whether a certificate is
+		// revoked.
+		require.Equal(t, ua.StatusBadCertificateRevocationUnknown, s.Verify(app.cert.Raw))
+		require.Equal(t, ua.StatusBadCertificateRevocationUnknown, s.Verify(chain))
+		writeFile(t, s, issuersCRL, "sub.crl", newCRL(t, sub, 1, valid))
+		require.Equal(t, ua.StatusBadCertificateIssuerRevocationUnknown, s.Verify(chain))
+
+		// expired revocation lists are not current.
+		writeFile(t, s, trustedCRL, "ca.crl", newCRL(t, ca, 1, time.Now().Add(-time.Hour)))
+		require.Equal(t, ua.StatusBadCertificateRevocationUnknown, s.Verify(app.cert.Raw))
+		require.Equal(t, ua.StatusBadCertificateIssuerRevocationUnknown, s.Verify(chain))
+
+		writeFile(t, s, trustedCRL, "ca.crl", crl)
+		require.NoError(t, s.Verify(app.cert.Raw))
+		require.NoError(t, s.Verify(chain))
+
+		// self signed certificates have no issuer.
+		require.NoError(t, s.Trust(self.cert.Raw))
+		require.NoError(t, s.Verify(self.cert.Raw))
+	})
+
+	t.Run("max rejected", func(t *testing.T) {
+		s, err := NewStore(t.TempDir())
+		require.NoError(t, err)
+		s.SetMaxRejected(2)
+
+		var certs []*testCert
+		for i := 0; i < 3; i++ {
+			c := newCert(t, "rejected", int64(10+i), false, valid, nil)
+			certs = append(certs, c)
+			require.Equal(t, ua.StatusBadCertificateUntrusted, s.Verify(c.cert.Raw))
+			// the file times must differ to tell the oldest certificate.
+			time.Sleep(10 * time.Millisecond)
+		}
+		// rejecting a certificate again does not add a file.
+		require.Equal(t, ua.StatusBadCertificateUntrusted, s.Verify(certs[2].cert.Raw))
+
+		rejected, err := s.Rejected()
+		require.NoError(t, err)
+		require.Len(t, rejected, 2)
+		for _, c := range rejected {
+			require.False(t, c.Equal(certs[0].cert), "oldest certificate not removed")
+		}
+	})
+
+	t.Run("auto accept", func(t *testing.T) {
+		s, err := NewStore(t.TempDir())
+		require.NoError(t, err)
+		s.SetAutoAccept(true)
+
+		require.NoError(t, s.Verify(self.cert.Raw))
+		trusted, err := s.Trusted()
+		require.NoError(t, err)
+		require.Len(t, trusted, 1)
+
+		// only untrusted certificates are accepted.
+		writeFile(t, s, trustedCerts, "ca.der", ca.cert.Raw)
+		require.Equal(t, ua.StatusBadCertificateTimeInvalid, s.Verify(expired.cert.Raw))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		s, err := NewStore(t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, ua.StatusBadCertificateInvalid, s.Verify([]byte("garbage")))
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uacert
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/gopcua/opcua/uapolicy"
+)
+
+// VerifyChain validates the DER encoded certificate of an application with
+// the root certificates. cert may contain the chain of the certificate
+// after the certificate itself. No certificate is trusted if roots is nil.
+// The returned error is a ua.StatusCode like the one of Store.Verify.
+func VerifyChain(cert []byte, roots *x509.CertPool) error {
+	certs, err := uapolicy.ParseCertificates(cert)
+	if err != nil || len(certs) == 0 {
+		return ua.StatusBadCertificateInvalid
+	}
+	leaf := certs[0]
+
+	now := time.Now()
+	if code := validity(leaf, now); code != ua.StatusOK {
+		return code
+	}
+
+	if roots == nil {
+		roots = x509.NewCertPool()
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return chainStatus(err)
+	}
+	return nil
+}
+
+// validity returns BadCertificateTimeInvalid if the certificate is not
+// valid at the time.
+func validity(c *x509.Certificate, now time.Time) ua.StatusCode {
+	if now.Before(c.NotBefore) || now.After(c.NotAfter) {
+		return ua.StatusBadCertificateTimeInvalid
+	}
+	return ua.StatusOK
+}
+
+// chainStatus returns the status code of an error of a chain
+// verification.
+func chainStatus(err error) ua.StatusCode {
+	var (
+		unknown x509.UnknownAuthorityError
+		invalid x509.CertificateInvalidError
+	)
+	switch {
+	case errors.As(err, &unknown):
+		return ua.StatusBadCertificateUntrusted
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return ua.StatusBadCertificateIssuerTimeInvalid
+	default:
+		return ua.StatusBadCertificateInvalid
+	}
+}
+
+// VerifyUsage returns BadCertificateUseNotAllowed if the key usage of the
+// DER encoded certificate of an application does not allow signatures and,
+// for RSA keys, the encryption of keys, or if its extended key usage does
+// not contain the usage. Part 6 requires these usages for application
+// instance certificates.
+//
+// https://reference.opcfoundation.org/Core/Part6/v105/docs/6.2.2
+func VerifyUsage(cert []byte, usage x509.ExtKeyUsage) error {
+	c, err := parseLeaf(cert)
+	if err != nil {
+		return ua.StatusBadCertificateInvalid
+	}
+	if c.KeyUsage != 0 {
+		if c.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+			return ua.StatusBadCertificateUseNotAllowed
+		}
+		if _, ok := c.PublicKey.(*rsa.PublicKey); ok && c.KeyUsage&x509.KeyUsageKeyEncipherment == 0 {
+			return ua.StatusBadCertificateUseNotAllowed
+		}
+	}
+	if len(c.ExtKeyUsage) == 0 {
+		return nil
+	}
+	for _, u := range c.ExtKeyUsage {
+		if u == usage || u == x509.ExtKeyUsageAny {
+			return nil
+		}
+	}
+	return ua.StatusBadCertificateUseNotAllowed
+}
+
+// VerifyHostname returns BadCertificateHostNameInvalid if the DER encoded
+// certificate of an application does not contain the host name or the ip
+// address in the subject alternative name.
+func VerifyHostname(cert []byte, host string) error {
+	c, err := parseLeaf(cert)
+	if err != nil {
+		return ua.StatusBadCertificateInvalid
+	}
+	if err := c.VerifyHostname(host); err != nil {
+		return ua.StatusBadCertificateHostNameInvalid
+	}
+	return nil
+}
+
+// VerifyApplicationURI returns BadCertificateUriInvalid if the DER encoded
+// certificate of an application does not contain its application uri in
+// the subject alternative name.
+func VerifyApplicationURI(cert []byte, uri string) error {
+	c, err := parseLeaf(cert)
+	if err != nil {
+		return ua.StatusBadCertificateInvalid
+	}
+	for _, u := range c.URIs {
+		if u.String() == uri {
+			return nil
+		}
+	}
+	return ua.StatusBadCertificateURIInvalid
+}
@@ -0,0 +1,106 @@
+package uacert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// selfSigned returns a DER encoded self signed certificate of the template.
+func selfSigned(t *testing.T, tmpl *x509.Certificate, key crypto.Signer) []byte {
+	t.Helper()
+	tmpl.SerialNumber = big.NewInt(1)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	return der
+}
+
+func TestVerifyChain(t *testing.T) {
+	valid := time.Now().Add(time.Hour)
+	ca := newCert(t, "ca", 1, true, valid, nil)
+	app := newCert(t, "app", 2, false, valid, ca)
+	expired := newCert(t, "expired", 3, false, time.Now().Add(-time.Hour), ca)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	require.NoError(t, VerifyChain(app.cert.Raw, roots))
+	require.Equal(t, ua.StatusBadCertificateTimeInvalid, VerifyChain(expired.cert.Raw, roots))
+	require.Equal(t, ua.StatusBadCertificateUntrusted, VerifyChain(app.cert.Raw, nil))
+	require.Equal(t, ua.StatusBadCertificateInvalid, VerifyChain([]byte("garbage"), roots))
+}
+
+func TestVerifyUsage(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cert []byte
+		err  error
+	}{
+		{
+			name: "rsa",
+			cert: selfSigned(t, &x509.Certificate{
+				KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			}, rsaKey),
+		},
+		{
+			name: "rsa without key encipherment",
+			cert: selfSigned(t, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature}, rsaKey),
+			err:  ua.StatusBadCertificateUseNotAllowed,
+		},
+		{
+			name: "ecc",
+			cert: selfSigned(t, &x509.Certificate{KeyUsage: x509.KeyUsageDigitalSignature}, ecKey),
+		},
+		{
+			name: "client only",
+			cert: selfSigned(t, &x509.Certificate{
+				KeyUsage:    x509.KeyUsageDigitalSignature,
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}, ecKey),
+			err: ua.StatusBadCertificateUseNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.err, VerifyUsage(tt.cert, x509.ExtKeyUsageServerAuth))
+		})
+	}
+}
+
+func TestVerifyHostname(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := selfSigned(t, &x509.Certificate{
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, key)
+
+	require.NoError(t, VerifyHostname(cert, "localhost"))
+	require.NoError(t, VerifyHostname(cert, "127.0.0.1"))
+	require.Equal(t, ua.StatusBadCertificateHostNameInvalid, VerifyHostname(cert, "example.com"))
+}
+
+func TestVerifyApplicationURI(t *testing.T) {
+	c := newCert(t, "app", 1, false, time.Now().Add(time.Hour), nil)
+	require.NoError(t, VerifyApplicationURI(c.cert.Raw, "urn:gopcua:test:app"))
+	require.Equal(t, ua.StatusBadCertificateURIInvalid, VerifyApplicationURI(c.cert.Raw, "urn:gopcua:test:other"))
+}
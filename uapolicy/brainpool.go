@@ -0,0 +1,337 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uapolicy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"io"
+	"math/big"
+
+	"github.com/gopcua/opcua/errors"
+)
+
+// brainpoolCurve is a brainpool curve y² = x³ + ax + b of RFC 5639.
+// elliptic.CurveParams only implements curves with a = -3 and crypto/ecdh
+// has no brainpool curves, so the arithmetic is implemented here with
+// math/big in Jacobian coordinates. crypto/ecdsa signs and verifies with
+// custom curves through the elliptic.Curve interface.
+//
+// The arithmetic is not constant time and the brainpool curves cannot be
+// used in FIPS 140-only mode.
+//
+// https://www.rfc-editor.org/rfc/rfc5639
+type brainpoolCurve struct {
+	params *elliptic.CurveParams
+	a      *big.Int
+	oid    asn1.ObjectIdentifier
+}
+
+var (
+	brainpoolP256r1 = newBrainpoolCurve("brainpoolP256r1", 256, asn1.ObjectIdentifier{1, 3, 36, 3, 3, 2, 8, 1, 1, 7},
+		"a9fb57dba1eea9bc3e660a909d838d726e3bf623d52620282013481d1f6e5377",
+		"7d5a0975fc2c3057eef67530417affe7fb8055c126dc5c6ce94a4b44f330b5d9",
+		"26dc5c6ce94a4b44f330b5d9bbd77cbf958416295cf7e1ce6bccdc18ff8c07b6",
+		"8bd2aeb9cb7e57cb2c4b482ffc81b7afb9de27e1e3bd23c23a4453bd9ace3262",
+		"547ef835c3dac4fd97f8461a14611dc9c27745132ded8e545c1d54c72f046997",
+		"a9fb57dba1eea9bc3e660a909d838d718c397aa3b561a6f7901e0e82974856a7",
+	)
+	brainpoolP384r1 = newBrainpoolCurve("brainpoolP384r1", 384, asn1.ObjectIdentifier{1, 3, 36, 3, 3, 2, 8, 1, 1, 11},
+		"8cb91e82a3386d280f5d6f7e50e641df152f7109ed5456b412b1da197fb71123acd3a729901d1a71874700133107ec53",
+		"7bc382c63d8c150c3c72080ace05afa0c2bea28e4fb22787139165efba91f90f8aa5814a503ad4eb04a8c7dd22ce2826",
+		"04a8c7dd22ce28268b39b55416f0447c2fb77de107dcd2a62e880ea53eeb62d57cb4390295dbc9943ab78696fa504c11",
+		"1d1c64f068cf45ffa2a63a81b7c13f6b8847a3e77ef14fe3db7fcafe0cbd10e8e826e03436d646aaef87b2e247d4af1e",
+		"8abe1d7520f9c2a45cb1eb8e95cfd55262b70b29feec5864e19c054ff99129280e4646217791811142820341263c5315",
+		"8cb91e82a3386d280f5d6f7e50e641df152f7109ed5456b31f166e6cac0425a7cf3ab6af6b7fc3103b883202e9046565",
+	)
+)
+
+func newBrainpoolCurve(name string, bitSize int, oid asn1.ObjectIdentifier, p, a, b, gx, gy, n string) *brainpoolCurve {
+	hex := func(s string) *big.Int {
+		i, _ := new(big.Int).SetString(s, 16)
+		return i
+	}
+	return &brainpoolCurve{
+		params: &elliptic.CurveParams{
+			Name:    name,
+			BitSize: bitSize,
+			P:       hex(p),
+			N:       hex(n),
+			B:       hex(b),
+			Gx:      hex(gx),
+			Gy:      hex(gy),
+		},
+		a:   hex(a),
+		oid: oid,
+	}
+}
+
+// BrainpoolP256r1 returns the brainpoolP256r1 curve of the
+// ECC_brainpoolP256r1 security policy. Its keys are used with
+// crypto/ecdsa like the keys of the NIST curves.
+func BrainpoolP256r1() elliptic.Curve {
+	return brainpoolP256r1
+}
+
+// BrainpoolP384r1 returns the brainpoolP384r1 curve of the
+// ECC_brainpoolP384r1 security policy.
+func BrainpoolP384r1() elliptic.Curve {
+	return brainpoolP384r1
+}
+
+// brainpoolCurveByOID returns the brainpool curve with the object
+// identifier or nil.
+func brainpoolCurveByOID(oid asn1.ObjectIdentifier) *brainpoolCurve {
+	for _, c := range []*brainpoolCurve{brainpoolP256r1, brainpoolP384r1} {
+		if c.oid.Equal(oid) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (c *brainpoolCurve) Params() *elliptic.CurveParams {
+	return c.params
+}
+
+func (c *brainpoolCurve) IsOnCurve(x, y *big.Int) bool {
+	p := c.params.P
+	if x.Sign() < 0 || x.Cmp(p) >= 0 || y.Sign() < 0 || y.Cmp(p) >= 0 {
+		return false
+	}
+
+	// y² = x³ + ax + b
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, p)
+	x3 := new(big.Int).Mul(x, x)
+	x3.Add(x3, c.a)
+	x3.Mul(x3, x)
+	x3.Add(x3, c.params.B)
+	x3.Mod(x3, p)
+	return x3.Cmp(y2) == 0
+}
+
+func (c *brainpoolCurve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
+	return c.affine(c.add(c.jacobian(x1, y1), c.jacobian(x2, y2)))
+}
+
+func (c *brainpoolCurve) Double(x1, y1 *big.Int) (x, y *big.Int) {
+	return c.affine(c.double(c.jacobian(x1, y1)))
+}
+
+// ScalarMult multiplies the point with the big-endian scalar k with a
+// Montgomery ladder over all bits of k.
+func (c *brainpoolCurve) ScalarMult(x1, y1 *big.Int, k []byte) (x, y *big.Int) {
+	r0, r1 := jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}, c.jacobian(x1, y1)
+	for _, b := range k {
+		for i := 7; i >= 0; i-- {
+			if (b>>i)&1 == 0 {
+				r1 = c.add(r0, r1)
+				r0 = c.double(r0)
+			} else {
+				r0 = c.add(r0, r1)
+				r1 = c.double(r1)
+			}
+		}
+	}
+	return c.affine(r0)
+}
+
+func (c *brainpoolCurve) ScalarBaseMult(k []byte) (x, y *big.Int) {
+	return c.ScalarMult(c.params.Gx, c.params.Gy, k)
+}
+
+// jacobianPoint is the point (x/z², y/z³). z is zero for the point at
+// infinity.
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+// jacobian converts the affine point. (0, 0) is the point at infinity as
+// in crypto/elliptic.
+func (c *brainpoolCurve) jacobian(x, y *big.Int) jacobianPoint {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+	return jacobianPoint{new(big.Int).Set(x), new(big.Int).Set(y), big.NewInt(1)}
+}
+
+func (c *brainpoolCurve) affine(p jacobianPoint) (x, y *big.Int) {
+	if p.z.Sign() == 0 {
+		return new(big.Int), new(big.Int)
+	}
+	m := c.params.P
+	zinv := new(big.Int).ModInverse(p.z, m)
+	zinv2 := new(big.Int).Mul(zinv, zinv)
+	x = new(big.Int).Mul(p.x, zinv2)
+	x.Mod(x, m)
+	y = zinv2.Mul(zinv2, zinv)
+	y.Mul(y, p.y)
+	y.Mod(y, m)
+	return x, y
+}
+
+// double uses the doubling formula for arbitrary a:
+//
+//	S  = 4·X·Y²
+//	M  = 3·X² + a·Z⁴
+//	X' = M² - 2·S
+//	Y' = M·(S - X') - 8·Y⁴
+//	Z' = 2·Y·Z
+func (c *brainpoolCurve) double(p jacobianPoint) jacobianPoint {
+	m := c.params.P
+	if p.z.Sign() == 0 || p.y.Sign() == 0 {
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+
+	yy := new(big.Int).Mul(p.y, p.y)
+	yy.Mod(yy, m)
+	s := new(big.Int).Mul(p.x, yy)
+	s.Lsh(s, 2)
+	s.Mod(s, m)
+
+	zz := new(big.Int).Mul(p.z, p.z)
+	zz.Mod(zz, m)
+	az4 := zz.Mul(zz, zz)
+	az4.Mod(az4, m)
+	az4.Mul(az4, c.a)
+	mm := new(big.Int).Mul(p.x, p.x)
+	mm.Mul(mm, big.NewInt(3))
+	mm.Add(mm, az4)
+	mm.Mod(mm, m)
+
+	x := new(big.Int).Mul(mm, mm)
+	x.Sub(x, s)
+	x.Sub(x, s)
+	x.Mod(x, m)
+
+	yyyy := yy.Mul(yy, yy)
+	yyyy.Lsh(yyyy, 3)
+	y := s.Sub(s, x)
+	y.Mul(y, mm)
+	y.Sub(y, yyyy)
+	y.Mod(y, m)
+
+	z := new(big.Int).Mul(p.y, p.z)
+	z.Lsh(z, 1)
+	z.Mod(z, m)
+	return jacobianPoint{x, y, z}
+}
+
+// add uses the addition formula:
+//
+//	U1 = X1·Z2², U2 = X2·Z1², S1 = Y1·Z2³, S2 = Y2·Z1³
+//	H  = U2 - U1, R = S2 - S1
+//	X' = R² - H³ - 2·U1·H²
+//	Y' = R·(U1·H² - X') - S1·H³
+//	Z' = Z1·Z2·H
+func (c *brainpoolCurve) add(p, q jacobianPoint) jacobianPoint {
+	m := c.params.P
+	if p.z.Sign() == 0 {
+		return q
+	}
+	if q.z.Sign() == 0 {
+		return p
+	}
+
+	z1z1 := new(big.Int).Mul(p.z, p.z)
+	z1z1.Mod(z1z1, m)
+	z2z2 := new(big.Int).Mul(q.z, q.z)
+	z2z2.Mod(z2z2, m)
+	u1 := new(big.Int).Mul(p.x, z2z2)
+	u1.Mod(u1, m)
+	u2 := new(big.Int).Mul(q.x, z1z1)
+	u2.Mod(u2, m)
+	s1 := new(big.Int).Mul(p.y, q.z)
+	s1.Mul(s1, z2z2)
+	s1.Mod(s1, m)
+	s2 := new(big.Int).Mul(q.y, p.z)
+	s2.Mul(s2, z1z1)
+	s2.Mod(s2, m)
+
+	h := new(big.Int).Sub(u2, u1)
+	h.Mod(h, m)
+	r := new(big.Int).Sub(s2, s1)
+	r.Mod(r, m)
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return c.double(p)
+		}
+		return jacobianPoint{new(big.Int), new(big.Int), new(big.Int)}
+	}
+
+	hh := new(big.Int).Mul(h, h)
+	hh.Mod(hh, m)
+	hhh := new(big.Int).Mul(h, hh)
+	hhh.Mod(hhh, m)
+	v := u1.Mul(u1, hh)
+	v.Mod(v, m)
+
+	x := new(big.Int).Mul(r, r)
+	x.Sub(x, hhh)
+	x.Sub(x, v)
+	x.Sub(x, v)
+	x.Mod(x, m)
+
+	y := v.Sub(v, x)
+	y.Mul(y, r)
+	s1.Mul(s1, hhh)
+	y.Sub(y, s1)
+	y.Mod(y, m)
+
+	z := new(big.Int).Mul(p.z, q.z)
+	z.Mul(z, h)
+	z.Mod(z, m)
+	return jacobianPoint{x, y, z}
+}
+
+// generateKey creates an ephemeral ECDH key on the curve.
+func (c *brainpoolCurve) generateKey(rand io.Reader) (ecdhKey, error) {
+	key, err := ecdsa.GenerateKey(c, rand)
+	if err != nil {
+		return nil, err
+	}
+	return &brainpoolKey{curve: c, d: key.D, x: key.X, y: key.Y}, nil
+}
+
+// newPrivateKey returns the ECDH key with the big-endian private key d.
+func (c *brainpoolCurve) newPrivateKey(d []byte) (ecdhKey, error) {
+	k := new(big.Int).SetBytes(d)
+	if len(d) != curveSize(c) || k.Sign() == 0 || k.Cmp(c.params.N) >= 0 {
+		return nil, errors.Errorf("invalid private key for %s", c.params.Name)
+	}
+	x, y := c.ScalarBaseMult(d)
+	return &brainpoolKey{curve: c, d: k, x: x, y: y}, nil
+}
+
+// brainpoolKey is an ECDH key on a brainpool curve.
+type brainpoolKey struct {
+	curve *brainpoolCurve
+	d     *big.Int
+	x, y  *big.Int
+}
+
+func (k *brainpoolKey) publicKey() []byte {
+	n := curveSize(k.curve)
+	b := make([]byte, 2*n)
+	k.x.FillBytes(b[:n])
+	k.y.FillBytes(b[n:])
+	return b
+}
+
+func (k *brainpoolKey) ecdh(nonce []byte) ([]byte, error) {
+	n := curveSize(k.curve)
+	x := new(big.Int).SetBytes(nonce[:n])
+	y := new(big.Int).SetBytes(nonce[n:])
+	if !k.curve.IsOnCurve(x, y) {
+		return nil, errors.Errorf("public key is not on curve %s", k.curve.params.Name)
+	}
+	x, y = k.curve.ScalarMult(x, y, k.d.FillBytes(make([]byte, n)))
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.New("shared secret is the point at infinity")
+	}
+	return x.FillBytes(make([]byte, n)), nil
+}
@@ -0,0 +1,221 @@
+package uapolicy
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The vectors in this file were computed with OpenSSL 3.0 from the private
+// keys which consist of bytes with the value 0x11 and 0x22.
+
+func TestBrainpoolCurves(t *testing.T) {
+	for _, c := range []*brainpoolCurve{brainpoolP256r1, brainpoolP384r1} {
+		t.Run(c.Params().Name, func(t *testing.T) {
+			p := c.Params()
+			require.True(t, c.IsOnCurve(p.Gx, p.Gy), "generator")
+
+			// n·G is the point at infinity and (n-1)·G is -G.
+			x, y := c.ScalarBaseMult(p.N.Bytes())
+			require.Zero(t, x.Sign())
+			require.Zero(t, y.Sign())
+			x, y = c.ScalarBaseMult(new(big.Int).Sub(p.N, big.NewInt(1)).Bytes())
+			require.Equal(t, p.Gx, x)
+			require.Equal(t, new(big.Int).Sub(p.P, p.Gy), y)
+
+			// 2·G + G = 3·G
+			x2, y2 := c.Double(p.Gx, p.Gy)
+			x3, y3 := c.Add(x2, y2, p.Gx, p.Gy)
+			x, y = c.ScalarBaseMult([]byte{3})
+			require.Equal(t, x3, x)
+			require.Equal(t, y3, y)
+			require.True(t, c.IsOnCurve(x, y))
+			require.False(t, c.IsOnCurve(x, new(big.Int).Add(y, big.NewInt(1))))
+		})
+	}
+}
+
+func TestBrainpoolECDH(t *testing.T) {
+	tests := []struct {
+		policy       *eccPolicy
+		nonce1       string
+		nonce2       string
+		sharedSecret string
+	}{
+		{
+			eccBrainpoolP256r1,
+			"0117a18051447117d2d529054ef0136e8ff6bd2602f2f8aba5ab3d74e72aaa7060bc4efa21f12bf007ee36dae9720975e2f54cda9ceb403a241fa977124b1aa2",
+			"6674461d273a4ee6d2cededa425696ddfdd8cbbe0e36ce9244ec611fe027b5312e6d0798f28b235c9bdbc96ae40e4c5695f1f07f2489cacea981ec0e83bb9de5",
+			"18c0a8a43bfe303ec725ab8dcb319c89165cc5a6b522afa4bf7c44ae56c47536",
+		},
+		{
+			eccBrainpoolP384r1,
+			"488b808872a8313e91ac8c6ac15b32837edfbf6513864d5b01c0b10a8054bae3f07c60a718d862857004b83d04664f7137b41ef3b1982bf576056387a9699fb492401c024b58f77adb96ecf876a191f781010129c32f5ded2142ca08edc2423d",
+			"52f7b2411607b7a16e58f2b89bc198e9544da9536629adbad9cc49edf0dab4c171b894fa0991263951085fe750642e3f0f7bd21d5aeac9e2ddb677a6dedb4d1e7ae9d2a9a14680bbd88d12ac82c55011c7cb7bca4a38c76b383909cf23faa9d4",
+			"1b1bd7e5e51064effd2bde04c7b9c85717ca7daa955f48623f501efa487b26fe24b0031e8623df4ebefb2ed3c3293a34",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.uri, func(t *testing.T) {
+			k1 := fixedEphemeralKey(t, tt.policy, 0x11)
+			k2 := fixedEphemeralKey(t, tt.policy, 0x22)
+			require.Equal(t, mustHex(t, tt.nonce1), k1.Nonce())
+			require.Equal(t, mustHex(t, tt.nonce2), k2.Nonce())
+
+			s1, err := k1.sharedSecret(k2.Nonce())
+			require.NoError(t, err)
+			s2, err := k2.sharedSecret(k1.Nonce())
+			require.NoError(t, err)
+			require.Equal(t, mustHex(t, tt.sharedSecret), s1)
+			require.Equal(t, s1, s2)
+
+			invalid := append([]byte{}, k2.Nonce()...)
+			invalid[len(invalid)-1] ^= 1
+			_, err = k1.sharedSecret(invalid)
+			require.Error(t, err, "nonce is not a point on the curve")
+		})
+	}
+}
+
+func TestBrainpoolECDSA(t *testing.T) {
+	tests := []struct {
+		policy *eccPolicy
+		sig    string
+	}{
+		{
+			eccBrainpoolP256r1,
+			"83f9a177dd807b8f319b4c2e2de8ff8d41946c65b9795ab091feed8798631616" +
+				"6478657fa57350306c750ab7bdc541da88c1bc9598a01828178e0741e2a7a1d8",
+		},
+		{
+			eccBrainpoolP384r1,
+			"85ebe4d9ee7c1f14bb6a9bc226dbf6a426d5ec606a5ca08a319b7ba3ab1674d37da55436387c67005f4285271730ad4a" +
+				"2134e6e73c737fa7f32baf223adc00b718ed56bdf7c3bb5b08c7ae41b8ede6b4e6c196eb3855612a31a9deb63b008c49",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.uri, func(t *testing.T) {
+			key := fixedSigningKey(t, tt.policy.signatureCurve.(*brainpoolCurve), 0x11)
+			verifier := &ECDSA{Hash: tt.policy.hash, PublicKey: &key.PublicKey}
+			require.NoError(t, verifier.Verify([]byte("opcua"), mustHex(t, tt.sig)))
+			require.Error(t, verifier.Verify([]byte("opcub"), mustHex(t, tt.sig)))
+
+			signer := &ECDSA{Hash: tt.policy.hash, PrivateKey: key}
+			sig, err := signer.Signature([]byte("opcua"))
+			require.NoError(t, err)
+			require.NoError(t, verifier.Verify([]byte("opcua"), sig))
+		})
+	}
+}
+
+func TestBrainpoolCertificate(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		der := loadLeafDER(t, "testdata/certs/brainpoolP256r1.pem")
+		_, err := x509.ParseCertificate(der)
+		require.Error(t, err, "crypto/x509 does not support brainpool keys")
+
+		cert, err := ParseCertificate(der)
+		require.NoError(t, err)
+		require.Equal(t, der, cert.Raw)
+		require.Equal(t, "brainpool", cert.Subject.CommonName)
+		require.Equal(t, "urn:gopcua:brainpool", cert.URIs[0].String())
+		require.True(t, fixedSigningKey(t, brainpoolP256r1, 0x11).PublicKey.Equal(cert.PublicKey))
+		require.NoError(t, cert.CheckSignatureFrom(cert))
+
+		spki, err := MarshalPKIXPublicKey(cert.PublicKey)
+		require.NoError(t, err)
+		require.Equal(t, cert.RawSubjectPublicKeyInfo, spki)
+
+		certs, err := ParseCertificates(append(append([]byte{}, der...), der...))
+		require.NoError(t, err)
+		require.Len(t, certs, 2)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		for _, c := range []elliptic.Curve{BrainpoolP256r1(), BrainpoolP384r1()} {
+			key, err := ecdsa.GenerateKey(c, rand.Reader)
+			require.NoError(t, err)
+			tmpl := &x509.Certificate{
+				SerialNumber:          big.NewInt(1),
+				Subject:               pkix.Name{CommonName: c.Params().Name},
+				NotBefore:             time.Now(),
+				NotAfter:              time.Now().Add(time.Hour),
+				KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+				BasicConstraintsValid: true,
+				IsCA:                  true,
+			}
+			der, err := CreateCertificate(tmpl, tmpl, &key.PublicKey, key)
+			require.NoError(t, err)
+			ca, err := ParseCertificate(der)
+			require.NoError(t, err)
+			require.True(t, key.PublicKey.Equal(ca.PublicKey))
+			require.NoError(t, ca.CheckSignatureFrom(ca))
+			require.NotEmpty(t, ca.SubjectKeyId)
+
+			// an RSA certificate signed by the brainpool certificate.
+			leaf := &x509.Certificate{
+				SerialNumber: big.NewInt(2),
+				Subject:      pkix.Name{CommonName: "leaf"},
+				NotBefore:    time.Now(),
+				NotAfter:     time.Now().Add(time.Hour),
+			}
+			der, err = CreateCertificate(leaf, ca, &rsaKey.PublicKey, key)
+			require.NoError(t, err)
+			cert, err := ParseCertificate(der)
+			require.NoError(t, err)
+			require.NoError(t, cert.CheckSignatureFrom(ca))
+			require.Equal(t, ca.SubjectKeyId, cert.AuthorityKeyId)
+
+			roots := x509.NewCertPool()
+			roots.AddCert(ca)
+			_, err = cert.Verify(x509.VerifyOptions{Roots: roots})
+			require.NoError(t, err)
+		}
+	})
+}
+
+func TestBrainpoolPrivateKey(t *testing.T) {
+	key := fixedSigningKey(t, brainpoolP256r1, 0x11)
+	want := mustHex(t, "308188020100301406072a8648ce3d020106092b2403030208010107046d306b02010104201111111111111111111111111111111111111111111111111111111111111111a144034200040117a18051447117d2d529054ef0136e8ff6bd2602f2f8aba5ab3d74e72aaa7060bc4efa21f12bf007ee36dae9720975e2f54cda9ceb403a241fa977124b1aa2")
+
+	der, err := MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.Equal(t, want, der)
+
+	parsed, err := ParsePKCS8PrivateKey(der)
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	parsed, err = ParseECPrivateKey(mustHex(t, "303202010104201111111111111111111111111111111111111111111111111111111111111111a00b06092b2403030208010107"))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsed))
+
+	// other keys are handled by crypto/x509.
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err = MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	parsed, err = ParsePKCS8PrivateKey(der)
+	require.NoError(t, err)
+	require.True(t, rsaKey.Equal(parsed))
+}
+
+// fixedSigningKey returns the ECDSA key on the curve whose private key
+// consists of bytes with the value b.
+func fixedSigningKey(t *testing.T, c *brainpoolCurve, b byte) *ecdsa.PrivateKey {
+	t.Helper()
+	d := bytes.Repeat([]byte{b}, curveSize(c))
+	x, y := c.ScalarBaseMult(d)
+	return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: c, X: x, Y: y}, D: new(big.Int).SetBytes(d)}
+}
@@ -0,0 +1,433 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uapolicy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/gopcua/opcua/errors"
+)
+
+// crypto/x509 rejects certificates and keys on curves which it does not
+// implement. The functions in this file handle brainpool keys themselves
+// and use crypto/x509 for all other keys.
+
+var oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+// subjectPublicKeyInfo is the SubjectPublicKeyInfo of RFC 5280, 4.1.
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ecPrivateKey is the ECPrivateKey of RFC 5915, 3.
+type ecPrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// pkcs8 is the PrivateKeyInfo of RFC 5208, 5.
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// ParseCertificates parses one or more concatenated DER-encoded
+// certificates like x509.ParseCertificates. It also parses certificates
+// with brainpool keys.
+func ParseCertificates(der []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for len(der) > 0 {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(der, &raw)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := parseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+		der = rest
+	}
+	return certs, nil
+}
+
+// parseCertificate parses a certificate with x509.ParseCertificate. If
+// the certificate has a brainpool key it is parsed with a placeholder key
+// which is replaced by the brainpool key afterwards.
+func parseCertificate(der []byte) (*x509.Certificate, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err == nil {
+		return cert, nil
+	}
+
+	tbs, spki, sigAlg, sig, ok := splitCertificate(der)
+	if !ok {
+		return nil, err
+	}
+	pub, ok := parseBrainpoolPublicKey(spki.FullBytes)
+	if !ok {
+		return nil, err
+	}
+	placeholder, perr := placeholderPublicKeyInfo()
+	if perr != nil {
+		return nil, perr
+	}
+	cert, perr = x509.ParseCertificate(joinCertificate(replaceRaw(tbs, spki, placeholder), sigAlg, sig))
+	if perr != nil {
+		return nil, err
+	}
+	cert.Raw = der
+	cert.RawTBSCertificate = tbs.FullBytes
+	cert.RawSubjectPublicKeyInfo = spki.FullBytes
+	cert.PublicKey = pub
+	return cert, nil
+}
+
+// splitCertificate returns the TBSCertificate, its SubjectPublicKeyInfo,
+// the signature algorithm and the signature of the certificate.
+func splitCertificate(der []byte) (tbs, spki, sigAlg, sig asn1.RawValue, ok bool) {
+	var cert asn1.RawValue
+	if rest, err := asn1.Unmarshal(der, &cert); err != nil || len(rest) > 0 {
+		return
+	}
+	rest, err := asn1.Unmarshal(cert.Bytes, &tbs)
+	if err != nil {
+		return
+	}
+	if rest, err = asn1.Unmarshal(rest, &sigAlg); err != nil {
+		return
+	}
+	if _, err = asn1.Unmarshal(rest, &sig); err != nil {
+		return
+	}
+
+	// version (optional), serialNumber, signature, issuer, validity,
+	// subject, subjectPublicKeyInfo
+	var f asn1.RawValue
+	fields, err := asn1.Unmarshal(tbs.Bytes, &f)
+	if err != nil {
+		return
+	}
+	n := 5
+	if f.Class != asn1.ClassContextSpecific || f.Tag != 0 {
+		n = 4
+	}
+	for i := 0; i <= n; i++ {
+		if fields, err = asn1.Unmarshal(fields, &spki); err != nil {
+			return
+		}
+	}
+	return tbs, spki, sigAlg, sig, true
+}
+
+// replaceRaw returns the DER encoding of the sequence with the element
+// old replaced by the DER encoding b.
+func replaceRaw(seq, old asn1.RawValue, b []byte) asn1.RawValue {
+	var content []byte
+	rest := seq.Bytes
+	for len(rest) > 0 {
+		var f asn1.RawValue
+		rest, _ = asn1.Unmarshal(rest, &f)
+		if string(f.FullBytes) == string(old.FullBytes) {
+			content = append(content, b...)
+		} else {
+			content = append(content, f.FullBytes...)
+		}
+	}
+	out := asn1.RawValue{Class: seq.Class, Tag: seq.Tag, IsCompound: true, Bytes: content}
+	out.FullBytes, _ = asn1.Marshal(out)
+	return out
+}
+
+// joinCertificate returns the DER encoding of the certificate.
+func joinCertificate(tbs, sigAlg, sig asn1.RawValue) []byte {
+	content := append(append(append([]byte{}, tbs.FullBytes...), sigAlg.FullBytes...), sig.FullBytes...)
+	b, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: content})
+	return b
+}
+
+// placeholderPublicKeyInfo returns a SubjectPublicKeyInfo which
+// crypto/x509 can parse.
+func placeholderPublicKeyInfo() ([]byte, error) {
+	p := elliptic.P256().Params()
+	return x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: p.Gx, Y: p.Gy})
+}
+
+// parseBrainpoolPublicKey parses a SubjectPublicKeyInfo with a brainpool
+// key.
+func parseBrainpoolPublicKey(der []byte) (*ecdsa.PublicKey, bool) {
+	var spki subjectPublicKeyInfo
+	if rest, err := asn1.Unmarshal(der, &spki); err != nil || len(rest) > 0 {
+		return nil, false
+	}
+	if !spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, false
+	}
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &oid); err != nil {
+		return nil, false
+	}
+	c := brainpoolCurveByOID(oid)
+	if c == nil {
+		return nil, false
+	}
+	return unmarshalPoint(c, spki.PublicKey.RightAlign())
+}
+
+// unmarshalPoint parses an uncompressed point on the curve.
+func unmarshalPoint(c *brainpoolCurve, b []byte) (*ecdsa.PublicKey, bool) {
+	n := curveSize(c)
+	if len(b) != 1+2*n || b[0] != 4 {
+		return nil, false
+	}
+	x := new(big.Int).SetBytes(b[1 : 1+n])
+	y := new(big.Int).SetBytes(b[1+n:])
+	if !c.IsOnCurve(x, y) {
+		return nil, false
+	}
+	return &ecdsa.PublicKey{Curve: c, X: x, Y: y}, true
+}
+
+// marshalPoint returns the uncompressed point.
+func marshalPoint(key *ecdsa.PublicKey) []byte {
+	n := curveSize(key.Curve)
+	b := make([]byte, 1+2*n)
+	b[0] = 4
+	key.X.FillBytes(b[1 : 1+n])
+	key.Y.FillBytes(b[1+n:])
+	return b
+}
+
+// brainpoolPublicKey returns the key and its curve if it is a brainpool
+// key.
+func brainpoolPublicKey(pub crypto.PublicKey) (*ecdsa.PublicKey, *brainpoolCurve) {
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil
+	}
+	c, ok := key.Curve.(*brainpoolCurve)
+	if !ok {
+		return nil, nil
+	}
+	return key, c
+}
+
+// MarshalPKIXPublicKey returns the SubjectPublicKeyInfo of the public key
+// like x509.MarshalPKIXPublicKey. It also supports brainpool keys.
+func MarshalPKIXPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	key, c := brainpoolPublicKey(pub)
+	if key == nil {
+		return x509.MarshalPKIXPublicKey(pub)
+	}
+	params, err := asn1.Marshal(c.oid)
+	if err != nil {
+		return nil, err
+	}
+	point := marshalPoint(key)
+	return asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: params}},
+		PublicKey: asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+}
+
+// CreateCertificate creates a certificate like x509.CreateCertificate. It
+// also supports brainpool keys for pub and priv.
+//
+// With brainpool keys the certificate is created with placeholder keys.
+// Then the placeholder key is replaced by pub and the certificate is
+// signed again with priv.
+func CreateCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) ([]byte, error) {
+	bpPub, pubCurve := brainpoolPublicKey(pub)
+	_, privCurve := brainpoolPublicKey(priv.Public())
+	if pubCurve == nil && privCurve == nil {
+		return x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	}
+
+	tmpl, par := *template, *parent
+	if parent == template {
+		par = tmpl
+	}
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer := priv
+	if privCurve != nil {
+		signer = placeholder
+		par.PublicKey = nil
+		if tmpl.SignatureAlgorithm == x509.UnknownSignatureAlgorithm && privCurve == brainpoolP384r1 {
+			tmpl.SignatureAlgorithm = x509.ECDSAWithSHA384
+		}
+	}
+	var spki []byte
+	if pubCurve != nil {
+		if spki, err = MarshalPKIXPublicKey(bpPub); err != nil {
+			return nil, err
+		}
+		if len(tmpl.SubjectKeyId) == 0 && tmpl.IsCA {
+			h := sha256.Sum256(marshalPoint(bpPub))
+			tmpl.SubjectKeyId = h[:20]
+		}
+		pub = &placeholder.PublicKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &par, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	tbs, placeholderSPKI, sigAlg, _, ok := splitCertificate(der)
+	if !ok {
+		return nil, errors.New("invalid certificate")
+	}
+	if spki != nil {
+		tbs = replaceRaw(tbs, placeholderSPKI, spki)
+	}
+
+	opts, err := signerOpts(cert.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := opts.HashFunc().New()
+	h.Write(tbs.FullBytes)
+	sig, err := priv.Sign(rand.Reader, h.Sum(nil), opts)
+	if err != nil {
+		return nil, err
+	}
+	sigValue, err := asn1.Marshal(asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)})
+	if err != nil {
+		return nil, err
+	}
+	return joinCertificate(tbs, sigAlg, asn1.RawValue{FullBytes: sigValue}), nil
+}
+
+// signerOpts returns the options of crypto.Signer for the signature
+// algorithm.
+func signerOpts(alg x509.SignatureAlgorithm) (crypto.SignerOpts, error) {
+	switch alg {
+	case x509.ECDSAWithSHA256, x509.SHA256WithRSA:
+		return crypto.SHA256, nil
+	case x509.ECDSAWithSHA384, x509.SHA384WithRSA:
+		return crypto.SHA384, nil
+	case x509.ECDSAWithSHA512, x509.SHA512WithRSA:
+		return crypto.SHA512, nil
+	case x509.SHA256WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, nil
+	case x509.SHA384WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}, nil
+	case x509.SHA512WithRSAPSS:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, nil
+	default:
+		return nil, errors.Errorf("unsupported signature algorithm %s", alg)
+	}
+}
+
+// MarshalPKCS8PrivateKey returns the PKCS #8 encoding of the private key
+// like x509.MarshalPKCS8PrivateKey. It also supports brainpool keys.
+func MarshalPKCS8PrivateKey(key any) ([]byte, error) {
+	k, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return x509.MarshalPKCS8PrivateKey(key)
+	}
+	pub, c := brainpoolPublicKey(&k.PublicKey)
+	if pub == nil {
+		return x509.MarshalPKCS8PrivateKey(key)
+	}
+
+	// the curve is in the algorithm and not in the ECPrivateKey.
+	point := marshalPoint(pub)
+	ecKey, err := asn1.Marshal(ecPrivateKey{
+		Version:    1,
+		PrivateKey: k.D.FillBytes(make([]byte, curveSize(c))),
+		PublicKey:  asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(c.oid)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs8{
+		Algo:       pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: params}},
+		PrivateKey: ecKey,
+	})
+}
+
+// ParsePKCS8PrivateKey parses a PKCS #8 private key like
+// x509.ParsePKCS8PrivateKey. It also supports brainpool keys.
+func ParsePKCS8PrivateKey(der []byte) (any, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err == nil {
+		return key, nil
+	}
+
+	var p pkcs8
+	if _, perr := asn1.Unmarshal(der, &p); perr != nil || !p.Algo.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, err
+	}
+	var oid asn1.ObjectIdentifier
+	if _, perr := asn1.Unmarshal(p.Algo.Parameters.FullBytes, &oid); perr != nil {
+		return nil, err
+	}
+	c := brainpoolCurveByOID(oid)
+	if c == nil {
+		return nil, err
+	}
+	return parseBrainpoolPrivateKey(c, p.PrivateKey)
+}
+
+// ParseECPrivateKey parses a SEC 1 EC private key like
+// x509.ParseECPrivateKey. It also supports brainpool keys.
+func ParseECPrivateKey(der []byte) (*ecdsa.PrivateKey, error) {
+	key, err := x509.ParseECPrivateKey(der)
+	if err == nil {
+		return key, nil
+	}
+
+	var k ecPrivateKey
+	if _, perr := asn1.Unmarshal(der, &k); perr != nil {
+		return nil, err
+	}
+	c := brainpoolCurveByOID(k.NamedCurveOID)
+	if c == nil {
+		return nil, err
+	}
+	return parseBrainpoolPrivateKey(c, der)
+}
+
+// parseBrainpoolPrivateKey parses the ECPrivateKey on the brainpool curve.
+func parseBrainpoolPrivateKey(c *brainpoolCurve, der []byte) (*ecdsa.PrivateKey, error) {
+	var k ecPrivateKey
+	if _, err := asn1.Unmarshal(der, &k); err != nil {
+		return nil, err
+	}
+	if k.Version != 1 {
+		return nil, errors.Errorf("unknown EC private key version %d", k.Version)
+	}
+	d := new(big.Int).SetBytes(k.PrivateKey)
+	if d.Sign() == 0 || d.Cmp(c.params.N) >= 0 {
+		return nil, errors.Errorf("invalid private key for %s", c.params.Name)
+	}
+	x, y := c.ScalarBaseMult(d.FillBytes(make([]byte, curveSize(c))))
+	return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: c, X: x, Y: y}, D: d}, nil
+}
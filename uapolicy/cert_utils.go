@@ -16,7 +16,7 @@ import (
 // Thumbprint returns the thumbprint of the first DER-encoded certificate.
 // If c contains a certificate chain, only the first (leaf) certificate is used.
 func Thumbprint(c []byte) []byte {
-	certs, err := x509.ParseCertificates(c)
+	certs, err := ParseCertificates(c)
 	if err != nil || len(certs) == 0 {
 		// Fallback: hash the raw bytes when the certificate cannot be parsed.
 		// This thumbprint won't match any well-formed peer certificate, so the
@@ -35,7 +35,7 @@ func Thumbprint(c []byte) []byte {
 // (multiple concatenated DER-encoded certificates) by returning only
 // the first (leaf) certificate.
 func ParseCertificate(c []byte) (*x509.Certificate, error) {
-	certs, err := x509.ParseCertificates(c)
+	certs, err := ParseCertificates(c)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,140 @@
+package uapolicy
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+
+	"github.com/gopcua/opcua/errors"
+)
+
+// EphemeralKey is an ephemeral ECDH key of an ECC security policy. Its
+// public key is the nonce of an OpenSecureChannel message or the key of
+// the sender or the receiver of an EccEncryptedSecret.
+type EphemeralKey struct {
+	policy *eccPolicy
+	key    ecdhKey
+}
+
+// ecdhCurve is the curve of the ECDH key agreement of an ECC policy.
+// crypto/ecdh implements the NIST curves and brainpoolCurve the brainpool
+// curves.
+type ecdhCurve interface {
+	generateKey(rand io.Reader) (ecdhKey, error)
+	newPrivateKey(d []byte) (ecdhKey, error)
+}
+
+// ecdhKey is the private key of an ECDH key agreement.
+type ecdhKey interface {
+	// publicKey returns the public key in the nonce encoding.
+	publicKey() []byte
+
+	// ecdh returns the x coordinate of the shared secret of the key and
+	// the public key in the nonce encoding.
+	ecdh(nonce []byte) ([]byte, error)
+}
+
+// nistCurve is a NIST curve of crypto/ecdh.
+type nistCurve struct {
+	curve ecdh.Curve
+}
+
+func (c nistCurve) generateKey(rand io.Reader) (ecdhKey, error) {
+	key, err := c.curve.GenerateKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	return nistKey{key}, nil
+}
+
+func (c nistCurve) newPrivateKey(d []byte) (ecdhKey, error) {
+	key, err := c.curve.NewPrivateKey(d)
+	if err != nil {
+		return nil, err
+	}
+	return nistKey{key}, nil
+}
+
+// nistKey is an ECDH key on a NIST curve.
+type nistKey struct {
+	key *ecdh.PrivateKey
+}
+
+func (k nistKey) publicKey() []byte {
+	// strip the 0x04 prefix of the uncompressed point
+	return k.key.PublicKey().Bytes()[1:]
+}
+
+func (k nistKey) ecdh(nonce []byte) ([]byte, error) {
+	pub, err := k.key.Curve().NewPublicKey(append([]byte{4}, nonce...))
+	if err != nil {
+		return nil, err
+	}
+	return k.key.ECDH(pub)
+}
+
+// NewEphemeralKey creates an ephemeral key for the ECC security policy.
+func NewEphemeralKey(uri string) (*EphemeralKey, error) {
+	p, ok := eccPolicies[uri]
+	if !ok {
+		return nil, errors.Errorf("%s is not an ECC security policy", uri)
+	}
+	key, err := p.curve.generateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &EphemeralKey{policy: p, key: key}, nil
+}
+
+// SecurityPolicyURI returns the security policy of the key.
+func (k *EphemeralKey) SecurityPolicyURI() string {
+	return k.policy.uri
+}
+
+// Nonce returns the public key in the encoding of Part 6 which is the
+// concatenation of the x and the y coordinate.
+//
+// https://reference.opcfoundation.org/Core/Part6/v105/docs/6.8.2
+func (k *EphemeralKey) Nonce() []byte {
+	return k.key.publicKey()
+}
+
+// sharedSecret returns the x coordinate of the ECDH shared secret of the
+// key and the public key of the other side in the nonce encoding.
+func (k *EphemeralKey) sharedSecret(nonce []byte) ([]byte, error) {
+	if len(nonce) != k.policy.nonceLength() {
+		return nil, errors.Errorf("invalid nonce length %d for %s", len(nonce), k.policy.uri)
+	}
+	return k.key.ecdh(nonce)
+}
+
+// salt returns the salt of the key derivation of the ECC security
+// policies: the length of the derived keys as UInt16, the label and the
+// public keys of the two sides.
+//
+// https://reference.opcfoundation.org/Core/Part6/v105/docs/6.8.1
+func salt(length int, label string, a, b []byte) []byte {
+	s := binary.LittleEndian.AppendUint16(nil, uint16(length))
+	s = append(s, label...)
+	s = append(s, a...)
+	return append(s, b...)
+}
+
+// hkdf derives length bytes from the secret with the HMAC based key
+// derivation function of RFC 5869.
+func hkdf(hash crypto.Hash, salt, secret, info []byte, length int) []byte {
+	// extract
+	prk, _ := (&HMAC{Hash: hash, Secret: salt}).Signature(secret)
+
+	// expand
+	h := &HMAC{Hash: hash, Secret: prk}
+	var p, t []byte
+	for i := byte(1); len(p) < length; i++ {
+		input := append(append(t, info...), i)
+		t, _ = h.Signature(input)
+		p = append(p, t...)
+	}
+	return p[:length]
+}
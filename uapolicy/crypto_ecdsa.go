@@ -0,0 +1,73 @@
+package uapolicy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+
+	// Force compilation of required hashing algorithms, although we don't directly use the packages
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/ua"
+)
+
+// ECDSA creates and verifies ECDSA signatures. The signatures are the
+// concatenation of r and s which are both padded to the size of the
+// curve as Part 6 requires.
+type ECDSA struct {
+	Hash       crypto.Hash
+	PublicKey  *ecdsa.PublicKey
+	PrivateKey *ecdsa.PrivateKey
+}
+
+func (s *ECDSA) Signature(msg []byte) ([]byte, error) {
+	if s.PrivateKey == nil {
+		return nil, ua.StatusBadSecurityChecksFailed
+	}
+
+	h := s.Hash.New()
+	if _, err := h.Write(msg); err != nil {
+		return nil, err
+	}
+	r, ss, err := ecdsa.Sign(rand.Reader, s.PrivateKey, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	n := curveSize(s.PrivateKey.Curve)
+	sig := make([]byte, 2*n)
+	r.FillBytes(sig[:n])
+	ss.FillBytes(sig[n:])
+	return sig, nil
+}
+
+func (s *ECDSA) Verify(msg, signature []byte) error {
+	if s.PublicKey == nil {
+		return ua.StatusBadSecurityChecksFailed
+	}
+
+	n := curveSize(s.PublicKey.Curve)
+	if len(signature) != 2*n {
+		return errors.New("signature validation failed")
+	}
+
+	h := s.Hash.New()
+	if _, err := h.Write(msg); err != nil {
+		return err
+	}
+	r := new(big.Int).SetBytes(signature[:n])
+	ss := new(big.Int).SetBytes(signature[n:])
+	if !ecdsa.Verify(s.PublicKey, h.Sum(nil), r, ss) {
+		return errors.New("signature validation failed")
+	}
+	return nil
+}
+
+// curveSize returns the size of the coordinates of the curve in bytes.
+func curveSize(c elliptic.Curve) int {
+	return (c.Params().BitSize + 7) / 8
+}
@@ -0,0 +1,186 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uapolicy
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"time"
+
+	"github.com/gopcua/opcua/errors"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// eccSecretEncodingMask is the encoding mask of an EccEncryptedSecret
+// which has a binary body.
+const eccSecretEncodingMask = 0x01
+
+// EncryptSecret returns the EccEncryptedSecret of the secret of a user
+// identity token, e.g. a password, for the ECC security policy.
+// receiverKey is the ephemeral public key of the server in the nonce
+// encoding and nonce is the last nonce of the server. The secret is signed
+// with the key of the signing certificate.
+//
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.41.2.3
+func EncryptSecret(uri string, signingCert []byte, signingKey *ecdsa.PrivateKey, receiverKey, secret, nonce []byte) ([]byte, error) {
+	if signingKey == nil {
+		return nil, errors.New("missing signing key")
+	}
+	senderKey, err := NewEphemeralKey(uri)
+	if err != nil {
+		return nil, err
+	}
+	return encryptSecret(senderKey, signingCert, signingKey, receiverKey, secret, nonce, time.Now())
+}
+
+// encryptSecret returns the EccEncryptedSecret of the secret which is
+// encrypted with the ephemeral key of the sender at the time.
+func encryptSecret(senderKey *EphemeralKey, signingCert []byte, signingKey *ecdsa.PrivateKey, receiverKey, secret, nonce []byte, now time.Time) ([]byte, error) {
+	p := senderKey.policy
+	if err := p.checkKey(&signingKey.PublicKey); err != nil {
+		return nil, err
+	}
+	shared, err := senderKey.sharedSecret(receiverKey)
+	if err != nil {
+		return nil, err
+	}
+	keys := p.secretKeys(shared, senderKey.Nonce(), receiverKey)
+
+	// the payload is padded to a multiple of the block size and every
+	// padding byte is the least significant byte of the padding size.
+	payload := ua.NewBuffer(nil)
+	payload.WriteByteString(nonce)
+	payload.WriteByteString(secret)
+	padding := 0
+	if r := (payload.Len() + 2) % AESBlockSize; r != 0 {
+		padding = AESBlockSize - r
+	}
+	for i := 0; i < padding; i++ {
+		payload.WriteByte(byte(padding))
+	}
+	payload.WriteUint16(uint16(padding))
+	encrypted, err := (&AES{KeyLength: p.encryptionKeyLength * 8, IV: keys.iv, Secret: keys.encryption}).Encrypt(payload.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	keyData := ua.NewBuffer(nil)
+	keyData.WriteByteString(senderKey.Nonce())
+	keyData.WriteByteString(receiverKey)
+
+	body := ua.NewBuffer(nil)
+	body.WriteString(p.uri)
+	body.WriteByteString(signingCert)
+	body.WriteTime(now)
+	body.WriteUint16(uint16(keyData.Len()))
+	body.Write(keyData.Bytes())
+	body.Write(encrypted)
+
+	signer := &ECDSA{Hash: p.hash, PrivateKey: signingKey}
+	b := ua.NewBuffer(nil)
+	b.WriteStruct(ua.NewNumericNodeID(0, id.EccEncryptedSecret))
+	b.WriteByte(eccSecretEncodingMask)
+	b.WriteInt32(int32(body.Len() + 2*curveSize(signingKey.Curve)))
+	b.Write(body.Bytes())
+	if b.Error() != nil {
+		return nil, b.Error()
+	}
+	sig, err := signer.Signature(b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return append(b.Bytes(), sig...), nil
+}
+
+// DecryptSecret returns the secret of an EccEncryptedSecret which was
+// created with EncryptSecret for the ephemeral key of the receiver. nonce
+// is the last nonce of the server. It also returns the signing certificate
+// whose signature is verified. The caller must check that the certificate
+// belongs to the sender.
+func DecryptSecret(localKey *EphemeralKey, b, nonce []byte) (secret, signingCert []byte, err error) {
+	if localKey == nil {
+		return nil, nil, errors.New("missing ephemeral key")
+	}
+	p := localKey.policy
+
+	buf := ua.NewBuffer(b)
+	typeID := new(ua.NodeID)
+	buf.ReadStruct(typeID)
+	mask := buf.ReadByte()
+	length := buf.ReadInt32()
+	if buf.Error() != nil {
+		return nil, nil, buf.Error()
+	}
+	if typeID.IntID() != id.EccEncryptedSecret || mask != eccSecretEncodingMask || int(length) != buf.Len() {
+		return nil, nil, errors.New("invalid EccEncryptedSecret")
+	}
+
+	uri := buf.ReadString()
+	signingCert = buf.ReadBytes()
+	buf.ReadTime()
+	keyDataLength := int(buf.ReadUint16())
+	keyDataPos := buf.Pos()
+	senderKey := buf.ReadBytes()
+	receiverKey := buf.ReadBytes()
+	if buf.Error() != nil {
+		return nil, nil, buf.Error()
+	}
+	if buf.Pos()-keyDataPos != keyDataLength {
+		return nil, nil, errors.New("invalid EccEncryptedSecret key data")
+	}
+	if uri != p.uri {
+		return nil, nil, errors.Errorf("invalid security policy %s of EccEncryptedSecret", uri)
+	}
+	if !bytes.Equal(receiverKey, localKey.Nonce()) {
+		return nil, nil, errors.New("EccEncryptedSecret is encrypted for another key")
+	}
+
+	cert, err := ParseCertificate(signingCert)
+	if err != nil {
+		return nil, nil, err
+	}
+	signingKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, errors.Errorf("unsupported signing key %T", cert.PublicKey)
+	}
+	sigLen := 2 * curveSize(signingKey.Curve)
+	if buf.Len() < sigLen {
+		return nil, nil, errors.New("invalid EccEncryptedSecret signature")
+	}
+	verifier := &ECDSA{Hash: p.hash, PublicKey: signingKey}
+	if err := verifier.Verify(b[:len(b)-sigLen], b[len(b)-sigLen:]); err != nil {
+		return nil, nil, err
+	}
+
+	shared, err := localKey.sharedSecret(senderKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := p.secretKeys(shared, senderKey, receiverKey)
+	payload, err := (&AES{KeyLength: p.encryptionKeyLength * 8, IV: keys.iv, Secret: keys.encryption}).Decrypt(b[buf.Pos() : len(b)-sigLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(payload) < 2 {
+		return nil, nil, errors.New("invalid EccEncryptedSecret padding")
+	}
+	padding := int(binary.LittleEndian.Uint16(payload[len(payload)-2:]))
+	if padding > len(payload)-2 {
+		return nil, nil, errors.New("invalid EccEncryptedSecret padding")
+	}
+
+	pbuf := ua.NewBuffer(payload[:len(payload)-2-padding])
+	n := pbuf.ReadBytes()
+	secret = pbuf.ReadBytes()
+	if pbuf.Error() != nil {
+		return nil, nil, pbuf.Error()
+	}
+	if !bytes.Equal(n, nonce) {
+		return nil, nil, errors.New("invalid EccEncryptedSecret nonce")
+	}
+	return secret, signingCert, nil
+}
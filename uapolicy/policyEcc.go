@@ -0,0 +1,138 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uapolicy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+
+	"github.com/gopcua/opcua/errors"
+)
+
+// eccPolicy is a security policy which uses elliptic curve cryptography.
+// Unlike the RSA policies the OpenSecureChannel messages are only signed
+// and the nonces are ephemeral ECDH keys whose shared secret is the input
+// of the HKDF key derivation.
+//
+// https://reference.opcfoundation.org/Core/Part6/v105/docs/6.8
+type eccPolicy struct {
+	uri                 string
+	curve               ecdhCurve
+	signatureCurve      elliptic.Curve
+	hash                crypto.Hash
+	signatureKeyLength  int
+	encryptionKeyLength int
+
+	asymmetricSignatureURI string
+	encryptionURI          string
+	signatureURI           string
+}
+
+// nonceLength returns the length of the public keys in the nonce encoding.
+func (p *eccPolicy) nonceLength() int {
+	return 2 * curveSize(p.signatureCurve)
+}
+
+// checkKey returns an error if the key is not on the curve of the policy.
+func (p *eccPolicy) checkKey(key *ecdsa.PublicKey) error {
+	if key.Curve.Params().Name != p.signatureCurve.Params().Name {
+		return errors.Errorf("key on curve %s is invalid for %s", key.Curve.Params().Name, p.uri)
+	}
+	return nil
+}
+
+func (p *eccPolicy) asymmetric(localKey *ecdsa.PrivateKey, remoteKey *ecdsa.PublicKey) (*EncryptionAlgorithm, error) {
+	if localKey != nil {
+		if err := p.checkKey(&localKey.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+	if remoteKey != nil {
+		if err := p.checkKey(remoteKey); err != nil {
+			return nil, err
+		}
+	}
+
+	signatureLength := p.nonceLength()
+	return &EncryptionAlgorithm{
+		// OpenSecureChannel messages are not encrypted.
+		blockSize:             NoneBlockSize,
+		plainttextBlockSize:   NoneBlockSize - NoneMinPadding,
+		signature:             &ECDSA{Hash: p.hash, PrivateKey: localKey},
+		verifySignature:       &ECDSA{Hash: p.hash, PublicKey: remoteKey},
+		nonceLength:           p.nonceLength(),
+		signatureLength:       signatureLength,
+		remoteSignatureLength: signatureLength,
+		signatureURI:          p.asymmetricSignatureURI,
+	}, nil
+}
+
+// symmetric derives the keys of both sides from the ephemeral key of this
+// side and the nonce of the other side:
+//
+//	ClientSalt = L | "opcua-client" | ClientNonce | ServerNonce
+//	ServerSalt = L | "opcua-server" | ServerNonce | ClientNonce
+//	ClientKeys = HKDF(ClientSalt, SharedSecret, ClientSalt, L)
+//	ServerKeys = HKDF(ServerSalt, SharedSecret, ServerSalt, L)
+//
+// where L is the length of the signing key, the encryption key and the
+// initialization vector. Each side signs and encrypts with its own keys.
+func (p *eccPolicy) symmetric(localKey *EphemeralKey, remoteNonce []byte, client bool) (*EncryptionAlgorithm, error) {
+	secret, err := localKey.sharedSecret(remoteNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	length := p.signatureKeyLength + p.encryptionKeyLength + AESBlockSize
+	localLabel, remoteLabel := "opcua-server", "opcua-client"
+	if client {
+		localLabel, remoteLabel = remoteLabel, localLabel
+	}
+	localNonce := localKey.Nonce()
+	localKeys := p.deriveKeys(secret, salt(length, localLabel, localNonce, remoteNonce))
+	remoteKeys := p.deriveKeys(secret, salt(length, remoteLabel, remoteNonce, localNonce))
+
+	return &EncryptionAlgorithm{
+		blockSize:             AESBlockSize,
+		plainttextBlockSize:   AESBlockSize - AESMinPadding,
+		encrypt:               &AES{KeyLength: p.encryptionKeyLength * 8, IV: localKeys.iv, Secret: localKeys.encryption},
+		decrypt:               &AES{KeyLength: p.encryptionKeyLength * 8, IV: remoteKeys.iv, Secret: remoteKeys.encryption},
+		signature:             &HMAC{Hash: p.hash, Secret: localKeys.signing},
+		verifySignature:       &HMAC{Hash: p.hash, Secret: remoteKeys.signing},
+		signatureLength:       p.hash.Size(),
+		remoteSignatureLength: p.hash.Size(),
+		encryptionURI:         p.encryptionURI,
+		signatureURI:          p.signatureURI,
+	}, nil
+}
+
+// deriveKeys derives the signing key, the encryption key and the
+// initialization vector with the salt.
+func (p *eccPolicy) deriveKeys(secret, salt []byte) *derivedKeys {
+	n := p.signatureKeyLength + p.encryptionKeyLength
+	b := hkdf(p.hash, salt, secret, salt, n+AESBlockSize)
+	return &derivedKeys{
+		signing:    b[:p.signatureKeyLength],
+		encryption: b[p.signatureKeyLength:n],
+		iv:         b[n:],
+	}
+}
+
+// secretKeys derives the encryption key and the initialization vector of
+// an EccEncryptedSecret from the public keys of the sender and the
+// receiver:
+//
+//	SecretSalt = L | "opcua-secret" | SenderPublicKey | ReceiverPublicKey
+//	Keys       = HKDF(SecretSalt, SharedSecret, SecretSalt, L)
+func (p *eccPolicy) secretKeys(secret, senderKey, receiverKey []byte) *derivedKeys {
+	length := p.encryptionKeyLength + AESBlockSize
+	s := salt(length, "opcua-secret", senderKey, receiverKey)
+	b := hkdf(p.hash, s, secret, s, length)
+	return &derivedKeys{
+		encryption: b[:p.encryptionKeyLength],
+		iv:         b[p.encryptionKeyLength:],
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uapolicy
+
+import (
+	"crypto"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+/*
+"SecurityPolicy - ECC-brainpoolP384r1" Profile
+http://opcfoundation.org/UA/SecurityPolicy#ECC_brainpoolP384r1
+
+Include 	 Name 	Opt. 	 Description 	 From Profile
+	Security Certificate Validation 		A certificate will be validated as specified in Part 4. This includes among others structure and signature examination. Allowing for some validation errors to be suppressed by administration directive.
+	Security Encryption Required 		Encryption is required using the algorithms provided in the security algorithm suite.
+	Security Signing Required 		Signing is required using the algorithms provided in the security algorithm suite.
+	SymmetricSignatureAlgorithm_HMAC-SHA2-384 		A keyed hash used for message authentication which is defined in https://tools.ietf.org/html/rfc2104.
+The hash algorithm is SHA2 with 384 bits and described in https://tools.ietf.org/html/rfc4634
+
+	SymmetricEncryptionAlgorithm_AES256-CBC 		The AES encryption algorithm which is defined in http://nvlpubs.nist.gov/nistpubs/FIPS/NIST.FIPS.197.pdf.
+Multiple blocks encrypted using the CBC mode described in http://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf.
+The key size is 256 bits. The block size is 16 bytes.
+The URI is http://www.w3.org/2001/04/xmlenc#aes256-cbc.
+	AsymmetricSignatureAlgorithm_ECDSA-SHA2-384 		The ECDSA signature algorithm which is defined in https://tools.ietf.org/html/rfc5656.
+The hash algorithm is SHA2 with 384 bits.
+The URI is http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384.
+	KeyAgreementAlgorithm_ECDH_brainpoolP384r1 		The ephemeral Elliptic Curve Diffie-Hellman key agreement with the brainpoolP384r1 curve.
+	KeyDerivationAlgorithm_HKDF-SHA2-384 		The HKDF pseudo-random function defined in https://tools.ietf.org/html/rfc5869.
+The hash algorithm is SHA2 with 384 bits.
+	CertificateSignatureAlgorithm_ECDSA-SHA2-384 		The ECDSA signature algorithm with the brainpoolP384r1 curve.
+	ECC-brainpoolP384r1_Limits 		-> DerivedSignatureKeyLength: 384 bits
+-> SecureChannelNonceLength: 96 bytes
+*/
+
+var eccBrainpoolP384r1 = &eccPolicy{
+	uri:                    ua.SecurityPolicyURIECCBrainpoolP384r1,
+	curve:                  brainpoolP384r1,
+	signatureCurve:         brainpoolP384r1,
+	hash:                   crypto.SHA384,
+	signatureKeyLength:     48,
+	encryptionKeyLength:    32,
+	asymmetricSignatureURI: "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384",
+	encryptionURI:          "http://www.w3.org/2001/04/xmlenc#aes256-cbc",
+	signatureURI:           "http://www.w3.org/2001/04/xmldsig-more#hmac-sha384",
+}
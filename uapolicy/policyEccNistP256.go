@@ -0,0 +1,51 @@
+// Copyright 2018-2026 opcua authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+package uapolicy
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/elliptic"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+/*
+"SecurityPolicy - ECC-nistP256" Profile
+http://opcfoundation.org/UA/SecurityPolicy#ECC_nistP256
+
+Include 	 Name 	Opt. 	 Description 	 From Profile
+	Security Certificate Validation 		A certificate will be validated as specified in Part 4. This includes among others structure and signature examination. Allowing for some validation errors to be suppressed by administration directive.
+	Security Encryption Required 		Encryption is required using the algorithms provided in the security algorithm suite.
+	Security Signing Required 		Signing is required using the algorithms provided in the security algorithm suite.
+	SymmetricSignatureAlgorithm_HMAC-SHA2-256 		A keyed hash used for message authentication which is defined in https://tools.ietf.org/html/rfc2104.
+The hash algorithm is SHA2 with 256 bits and described in https://tools.ietf.org/html/rfc4634
+
+	SymmetricEncryptionAlgorithm_AES128-CBC 		The AES encryption algorithm which is defined in http://nvlpubs.nist.gov/nistpubs/FIPS/NIST.FIPS.197.pdf.
+Multiple blocks encrypted using the CBC mode described in http://nvlpubs.nist.gov/nistpubs/Legacy/SP/nistspecialpublication800-38a.pdf.
+The key size is 128 bits. The block size is 16 bytes.
+The URI is http://www.w3.org/2001/04/xmlenc#aes128-cbc.
+	AsymmetricSignatureAlgorithm_ECDSA-SHA2-256 		The ECDSA signature algorithm which is defined in https://tools.ietf.org/html/rfc5656.
+The hash algorithm is SHA2 with 256 bits.
+The URI is http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256.
+	KeyAgreementAlgorithm_ECDH_nistP256 		The ephemeral Elliptic Curve Diffie-Hellman key agreement with the NIST P-256 curve.
+	KeyDerivationAlgorithm_HKDF-SHA2-256 		The HKDF pseudo-random function defined in https://tools.ietf.org/html/rfc5869.
+The hash algorithm is SHA2 with 256 bits.
+	CertificateSignatureAlgorithm_ECDSA-SHA2-256 		The ECDSA signature algorithm with the NIST P-256 curve.
+	ECC-nistP256_Limits 		-> DerivedSignatureKeyLength: 256 bits
+-> SecureChannelNonceLength: 64 bytes
+*/
+
+var eccNistP256 = &eccPolicy{
+	uri:                    ua.SecurityPolicyURIECCNistP256,
+	curve:                  nistCurve{ecdh.P256()},
+	signatureCurve:         elliptic.P256(),
+	hash:                   crypto.SHA256,
+	signatureKeyLength:     32,
+	encryptionKeyLength:    16,
+	asymmetricSignatureURI: "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256",
+	encryptionURI:          "http://www.w3.org/2001/04/xmlenc#aes128-cbc",
+	signatureURI:           "http://www.w3.org/2000/09/xmldsig#hmac-sha256",
+}
@@ -8,6 +8,8 @@
 package uapolicy
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
 	"io"
@@ -24,12 +26,27 @@ func SupportedPolicies() []string {
 	for k := range policies {
 		uris = append(uris, k)
 	}
+	for k := range eccPolicies {
+		uris = append(uris, k)
+	}
 	sort.Strings(uris)
 	return uris
 }
 
+// IsECC returns true if the security policy uses elliptic curve
+// cryptography. The nonces of these policies are ephemeral keys which are
+// created with NewEphemeralKey and the symmetric algorithms are created
+// with SymmetricECC.
+func IsECC(uri string) bool {
+	_, ok := eccPolicies[uri]
+	return ok
+}
+
 // Asymmetric returns the asymmetric encryption algorithm for the given security policy.
 func Asymmetric(uri string, localKey *rsa.PrivateKey, remoteKey *rsa.PublicKey) (*EncryptionAlgorithm, error) {
+	if IsECC(uri) {
+		return nil, errors.Errorf("security policy %s requires ECC keys", uri)
+	}
 	p, ok := policies[uri]
 	if !ok {
 		return nil, errors.Errorf("unsupported security policy %s", uri)
@@ -38,8 +55,52 @@ func Asymmetric(uri string, localKey *rsa.PrivateKey, remoteKey *rsa.PublicKey)
 	return p.asymmetric(localKey, remoteKey)
 }
 
+// AsymmetricKeys returns the asymmetric encryption algorithm for the given
+// security policy and keys of any type. The keys are RSA keys for the RSA
+// policies and ECDSA keys for the ECC policies. Either key may be nil.
+func AsymmetricKeys(uri string, localKey crypto.PrivateKey, remoteKey crypto.PublicKey) (*EncryptionAlgorithm, error) {
+	if p, ok := eccPolicies[uri]; ok {
+		var (
+			local  *ecdsa.PrivateKey
+			remote *ecdsa.PublicKey
+		)
+		if localKey != nil {
+			if local, ok = localKey.(*ecdsa.PrivateKey); !ok {
+				return nil, errors.Errorf("security policy %s requires an ECDSA key, got %T", uri, localKey)
+			}
+		}
+		if remoteKey != nil {
+			if remote, ok = remoteKey.(*ecdsa.PublicKey); !ok {
+				return nil, errors.Errorf("security policy %s requires an ECDSA key, got %T", uri, remoteKey)
+			}
+		}
+		return p.asymmetric(local, remote)
+	}
+
+	var (
+		local  *rsa.PrivateKey
+		remote *rsa.PublicKey
+		ok     bool
+	)
+	if localKey != nil && uri != ua.SecurityPolicyURINone {
+		if local, ok = localKey.(*rsa.PrivateKey); !ok {
+			return nil, errors.Errorf("security policy %s requires an RSA key, got %T", uri, localKey)
+		}
+	}
+	if remoteKey != nil && uri != ua.SecurityPolicyURINone {
+		if remote, ok = remoteKey.(*rsa.PublicKey); !ok {
+			return nil, errors.Errorf("security policy %s requires an RSA key, got %T", uri, remoteKey)
+		}
+	}
+	return Asymmetric(uri, local, remote)
+}
+
 // Symmetric returns the symmetric encryption algorithm for the given security policy.
+// The ECC policies require SymmetricECC instead.
 func Symmetric(uri string, localNonce, remoteNonce []byte) (*EncryptionAlgorithm, error) {
+	if IsECC(uri) {
+		return nil, errors.Errorf("security policy %s requires ephemeral keys", uri)
+	}
 	p, ok := policies[uri]
 	if !ok {
 		return nil, errors.Errorf("unsupported security policy %s", uri)
@@ -52,6 +113,18 @@ func Symmetric(uri string, localNonce, remoteNonce []byte) (*EncryptionAlgorithm
 	return p.symmetric(localNonce, remoteNonce)
 }
 
+// SymmetricECC returns the symmetric encryption algorithm for the ECC
+// security policy of the ephemeral key. localKey is the ephemeral key of
+// this side whose public key was sent as nonce, remoteNonce is the nonce
+// of the other side and client is true on the client side of the secure
+// channel.
+func SymmetricECC(localKey *EphemeralKey, remoteNonce []byte, client bool) (*EncryptionAlgorithm, error) {
+	if localKey == nil || remoteNonce == nil {
+		return nil, errors.New("invalid symmetric security policy config: both nonces required")
+	}
+	return localKey.policy.symmetric(localKey, remoteNonce, client)
+}
+
 // EncryptionAlgorithm wraps the functions used to return the various
 // methods required to implement the symmetric and asymmetric algorithms
 // Function variables were used instead of an interface to make better use
@@ -184,6 +257,14 @@ type policy struct {
 	symmetric  func(localNonce []byte, remoteNonce []byte) (*EncryptionAlgorithm, error)
 }
 
+var eccPolicies = map[string]*eccPolicy{
+	ua.SecurityPolicyURIECCNistP256: eccNistP256,
+	ua.SecurityPolicyURIECCNistP384: eccNistP384,
+
+	ua.SecurityPolicyURIECCBrainpoolP256r1: eccBrainpoolP256r1,
+	ua.SecurityPolicyURIECCBrainpoolP384r1: eccBrainpoolP384r1,
+}
+
 // SecurityLevel returns the recommended security level for endpoints
 // It is a ranking of security quality, higher is better
 var securityLevels = map[string][4]uint8{
@@ -194,6 +275,10 @@ var securityLevels = map[string][4]uint8{
 	ua.SecurityPolicyURIBasic256Sha256:      {00, 00, 32, 33},
 	ua.SecurityPolicyURIAes128Sha256RsaOaep: {00, 00, 42, 43},
 	ua.SecurityPolicyURIAes256Sha256RsaPss:  {00, 00, 52, 53},
+	ua.SecurityPolicyURIECCNistP256:         {00, 00, 62, 63},
+	ua.SecurityPolicyURIECCNistP384:         {00, 00, 72, 73},
+	ua.SecurityPolicyURIECCBrainpoolP256r1:  {00, 00, 64, 65},
+	ua.SecurityPolicyURIECCBrainpoolP384r1:  {00, 00, 74, 75},
 }
 
 func SecurityLevel(policy string, mode ua.MessageSecurityMode) uint8 {
@@ -5,11 +5,19 @@
 package uapolicy
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/gopcua/opcua/ua"
 	"github.com/stretchr/testify/require"
@@ -21,6 +29,9 @@ func TestSupportedPolicies(t *testing.T) {
 	for k := range policies {
 		want = append(want, k)
 	}
+	for k := range eccPolicies {
+		want = append(want, k)
+	}
 	sort.Strings(want)
 	require.Equal(t, want, got)
 }
@@ -261,3 +272,233 @@ func generatePrivateKey(bitSize int) (*rsa.PrivateKey, error) {
 
 	return privateKey, nil
 }
+
+func TestHKDF(t *testing.T) {
+	// RFC 5869, A.1. Test Case 1
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt, err := hex.DecodeString("000102030405060708090a0b0c")
+	require.NoError(t, err)
+	info, err := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	require.NoError(t, err)
+	want, err := hex.DecodeString("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+	require.NoError(t, err)
+	require.Equal(t, want, hkdf(crypto.SHA256, salt, secret, info, 42))
+}
+
+// fixedEphemeralKey returns an ephemeral key whose private key consists of
+// bytes with the value b.
+func fixedEphemeralKey(t *testing.T, p *eccPolicy, b byte) *EphemeralKey {
+	t.Helper()
+	key, err := p.curve.newPrivateKey(bytes.Repeat([]byte{b}, curveSize(p.signatureCurve)))
+	require.NoError(t, err)
+	return &EphemeralKey{policy: p, key: key}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// TestECCKeyDerivation checks the salts and the keys of the ECC_nistP256
+// policy against vectors which were computed independently with the
+// formulas of Part 6, 6.8.1.
+func TestECCKeyDerivation(t *testing.T) {
+	client := fixedEphemeralKey(t, eccNistP256, 0x11)
+	server := fixedEphemeralKey(t, eccNistP256, 0x22)
+
+	clientNonce := mustHex(t, "0217e617f0b6443928278f96999e69a23a4f2c152bdf6d6cdf66e5b80282d4ed194a7debcb97712d2dda3ca85aa8765a56f45fc758599652f2897c65306e5794")
+	serverNonce := mustHex(t, "d65a93977caa3d1b081852ff57a79e465f1660577304baead505dd3a48589cf350185e895372df6221ea3a137557e473fddb6755f05bd507c3c533fce9c91285")
+	require.Equal(t, clientNonce, client.Nonce())
+	require.Equal(t, serverNonce, server.Nonce())
+
+	// L | "opcua-client" | ClientNonce | ServerNonce
+	require.Equal(t, mustHex(t, "4000"+hex.EncodeToString([]byte("opcua-client"))+hex.EncodeToString(clientNonce)+hex.EncodeToString(serverNonce)), salt(64, "opcua-client", clientNonce, serverNonce))
+
+	clientKeys := mustHex(t, "570f73b1e1db965a40ecdf52272294b04231c5a88cd2ef4a6e84bfc134adb44165f2d360e3635ff3d3839dcafd53f733e0ab04ee8ec9f10875ab396698bebc81")
+	serverKeys := mustHex(t, "39bf8b169ae782ad84c9993d0f8937574b37550713630c963cfccc888b5b5963892667d12b0bed1a4ff84822592efdbde45e5660896711e3b7d1102b494f2a24")
+	check := func(t *testing.T, alg *EncryptionAlgorithm, local, remote []byte) {
+		t.Helper()
+		require.Equal(t, local[:32], alg.signature.(*HMAC).Secret, "signing key")
+		require.Equal(t, local[32:48], alg.encrypt.(*AES).Secret, "encryption key")
+		require.Equal(t, local[48:], alg.encrypt.(*AES).IV, "initialization vector")
+		require.Equal(t, remote[:32], alg.verifySignature.(*HMAC).Secret, "remote signing key")
+		require.Equal(t, remote[32:48], alg.decrypt.(*AES).Secret, "remote encryption key")
+		require.Equal(t, remote[48:], alg.decrypt.(*AES).IV, "remote initialization vector")
+	}
+
+	alg, err := SymmetricECC(client, serverNonce, true)
+	require.NoError(t, err)
+	check(t, alg, clientKeys, serverKeys)
+
+	alg, err = SymmetricECC(server, clientNonce, false)
+	require.NoError(t, err)
+	check(t, alg, serverKeys, clientKeys)
+}
+
+func TestECCPolicies(t *testing.T) {
+	payload := make([]byte, 5000)
+	_, err := rand.Read(payload)
+	require.NoError(t, err, "could not generate random payload")
+
+	for uri, p := range eccPolicies {
+		t.Run(uri, func(t *testing.T) {
+			clientKey, err := ecdsa.GenerateKey(p.signatureCurve, rand.Reader)
+			require.NoError(t, err)
+			serverKey, err := ecdsa.GenerateKey(p.signatureCurve, rand.Reader)
+			require.NoError(t, err)
+
+			// OpenSecureChannel messages are signed but not encrypted.
+			clientAsymmetric, err := AsymmetricKeys(uri, clientKey, &serverKey.PublicKey)
+			require.NoError(t, err)
+			serverAsymmetric, err := AsymmetricKeys(uri, serverKey, &clientKey.PublicKey)
+			require.NoError(t, err)
+			require.Equal(t, p.nonceLength(), clientAsymmetric.SignatureLength())
+
+			sig, err := clientAsymmetric.Signature(payload)
+			require.NoError(t, err)
+			require.Len(t, sig, clientAsymmetric.SignatureLength())
+			require.NoError(t, serverAsymmetric.VerifySignature(payload, sig))
+			require.Error(t, serverAsymmetric.VerifySignature(payload[1:], sig))
+
+			// the nonces are ephemeral keys.
+			clientEphemeral, err := NewEphemeralKey(uri)
+			require.NoError(t, err)
+			serverEphemeral, err := NewEphemeralKey(uri)
+			require.NoError(t, err)
+			require.Len(t, clientEphemeral.Nonce(), clientAsymmetric.NonceLength())
+
+			clientSymmetric, err := SymmetricECC(clientEphemeral, serverEphemeral.Nonce(), true)
+			require.NoError(t, err)
+			serverSymmetric, err := SymmetricECC(serverEphemeral, clientEphemeral.Nonce(), false)
+			require.NoError(t, err)
+
+			for _, c := range []struct {
+				name           string
+				sender, reader *EncryptionAlgorithm
+			}{
+				{"client to server", clientSymmetric, serverSymmetric},
+				{"server to client", serverSymmetric, clientSymmetric},
+			} {
+				ciphertext, err := c.sender.Encrypt(payload[:4992])
+				require.NoError(t, err, c.name)
+				plaintext, err := c.reader.Decrypt(ciphertext)
+				require.NoError(t, err, c.name)
+				require.Equal(t, payload[:4992], plaintext, c.name)
+
+				sig, err := c.sender.Signature(payload)
+				require.NoError(t, err, c.name)
+				require.NoError(t, c.reader.VerifySignature(payload, sig), c.name)
+			}
+
+			// both sides use different keys.
+			ciphertext, err := clientSymmetric.Encrypt(payload[:4992])
+			require.NoError(t, err)
+			plaintext, err := clientSymmetric.Decrypt(ciphertext)
+			require.NoError(t, err)
+			require.NotEqual(t, payload[:4992], plaintext)
+
+			_, err = SymmetricECC(clientEphemeral, payload[:p.nonceLength()], true)
+			require.Error(t, err, "nonce is not a point on the curve")
+			_, err = Symmetric(uri, clientEphemeral.Nonce(), serverEphemeral.Nonce())
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("invalid keys", func(t *testing.T) {
+		p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		rsaKey, err := generatePrivateKey(2048)
+		require.NoError(t, err)
+
+		_, err = AsymmetricKeys(ua.SecurityPolicyURIECCNistP384, p256, nil)
+		require.Error(t, err)
+		_, err = AsymmetricKeys(ua.SecurityPolicyURIECCNistP256, rsaKey, nil)
+		require.Error(t, err)
+		_, err = AsymmetricKeys(ua.SecurityPolicyURIBasic256Sha256, p256, nil)
+		require.Error(t, err)
+		_, err = Asymmetric(ua.SecurityPolicyURIECCNistP256, rsaKey, nil)
+		require.Error(t, err)
+		_, err = AsymmetricKeys(ua.SecurityPolicyURIECCBrainpoolP256r1, p256, nil)
+		require.Error(t, err)
+		_, err = NewEphemeralKey(ua.SecurityPolicyURIBasic256Sha256)
+		require.Error(t, err)
+	})
+}
+
+func TestEccEncryptedSecret(t *testing.T) {
+	for uri, p := range eccPolicies {
+		t.Run(uri, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(p.signatureCurve, rand.Reader)
+			require.NoError(t, err)
+			cert := selfSignedCert(t, key)
+			receiver, err := NewEphemeralKey(uri)
+			require.NoError(t, err)
+			nonce := []byte("0123456789abcdef0123456789abcdef")
+
+			b, err := EncryptSecret(uri, cert, key, receiver.Nonce(), []byte("secret"), nonce)
+			require.NoError(t, err)
+
+			secret, signingCert, err := DecryptSecret(receiver, b, nonce)
+			require.NoError(t, err)
+			require.Equal(t, []byte("secret"), secret)
+			require.Equal(t, cert, signingCert)
+
+			_, _, err = DecryptSecret(receiver, b, nonce[1:])
+			require.Error(t, err, "wrong nonce")
+
+			other, err := NewEphemeralKey(uri)
+			require.NoError(t, err)
+			_, _, err = DecryptSecret(other, b, nonce)
+			require.Error(t, err, "wrong receiver")
+
+			tampered := append([]byte{}, b...)
+			tampered[len(tampered)-p.nonceLength()-1] ^= 0xff
+			_, _, err = DecryptSecret(receiver, tampered, nonce)
+			require.Error(t, err, "tampered payload")
+		})
+	}
+}
+
+// TestEccEncryptedSecretVector checks the encoding of an EccEncryptedSecret
+// of the ECC_nistP256 policy against a vector which was computed
+// independently with Part 4, 7.41.2.3 and Part 6, 6.8.1.
+func TestEccEncryptedSecretVector(t *testing.T) {
+	sender := fixedEphemeralKey(t, eccNistP256, 0x33)
+	receiver := fixedEphemeralKey(t, eccNistP256, 0x44)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	nonce := []byte("0123456789abcdef0123456789abcdef")
+
+	// SecretSalt = L | "opcua-secret" | SenderPublicKey | ReceiverPublicKey
+	shared, err := sender.sharedSecret(receiver.Nonce())
+	require.NoError(t, err)
+	keys := eccNistP256.secretKeys(shared, sender.Nonce(), receiver.Nonce())
+	require.Equal(t, mustHex(t, "517177056549aae5292a98977d07f108"), keys.encryption)
+	require.Equal(t, mustHex(t, "0456e8feb8f4a8bb56b3d59332c29b57"), keys.iv)
+
+	b, err := encryptSecret(sender, []byte("cert"), key, receiver.Nonce(), []byte("secret"), nonce, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	want := mustHex(t, "0200008a440000014501000037000000687474703a2f2f6f7063666f756e646174696f6e2e6f72672f55412f5365637572697479506f6c696379234543435f6e69737450323536040000006365727400c08976453cda0188004000000051a7580833898ea1b183cbd7350a4099078c6ef1c1e18e970cd7683035f25e7d0110522712b0b5a7cff081685486984a94e6831edac46e7360fa9d834a7a81a1400000005b36890dacbd7c9a96bb74a1ee28b3d2d75b72e09a20ef25cf8e6fd8a9f0350d0e14bed8d4682a34d83538bdff5b96e89a6666ec0db5745d02fa1210072df75ae33658a7ed0189125d3fb88a6da6f8fe3d2ac7dbf7a2f23a9054609307a9c345843c1bf4503952154efd4d1f726b8197")
+	require.Len(t, b, len(want)+64)
+	require.Equal(t, want, b[:len(want)])
+
+	// the ECDSA signature is random but verifiable.
+	verifier := &ECDSA{Hash: crypto.SHA256, PublicKey: &key.PublicKey}
+	require.NoError(t, verifier.Verify(b[:len(want)], b[len(want):]))
+}
+
+func selfSignedCert(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := CreateCertificate(tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return der
+}
@@ -5,6 +5,8 @@
 package uasc
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"time"
 
@@ -33,6 +35,11 @@ type Config struct {
 	// messages.  It is the key associated with Certificate
 	LocalKey *rsa.PrivateKey
 
+	// LocalECKey is the ECDSA Private Key associated with Certificate for the
+	// ECC security policies. It is used instead of LocalKey to sign the
+	// OpenSecureChannel messages and the session signatures.
+	LocalECKey *ecdsa.PrivateKey
+
 	// UserKey is a RSA Private Key which will be used to sign the UserTokenSignature.
 	// It is the key associated with AuthCertificate
 	UserKey *rsa.PrivateKey
@@ -49,6 +56,13 @@ type Config struct {
 	// Used to encrypt the message chunks in the OpenSecureChannel phase.
 	RemoteCertificate []byte
 
+	// VerifyCertificate validates the certificate of the client in the
+	// OpenSecureChannel request of a server if the security mode is not
+	// None. The channel is not opened if it returns an error. The client
+	// gets the error if it is a ua.StatusCode and BadSecurityChecksFailed
+	// otherwise.
+	VerifyCertificate func(cert []byte) error
+
 	// RequestIDSeed is the initial value for RequestID counter in each new SecureChannel
 	RequestIDSeed uint32
 
@@ -82,6 +96,18 @@ type Config struct {
 	RequestTimeout time.Duration
 }
 
+// privateKey returns the private key of Certificate or nil.
+func (c *Config) privateKey() crypto.PrivateKey {
+	switch {
+	case c.LocalECKey != nil:
+		return c.LocalECKey
+	case c.LocalKey != nil:
+		return c.LocalKey
+	default:
+		return nil
+	}
+}
+
 // SessionConfig is a set of common configurations used in Session.
 type SessionConfig struct {
 	// AuthenticationToken is the secret Session identifier used to verify that the request is
@@ -6,8 +6,10 @@ package uasc
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/binary"
 	"io"
 	"math"
@@ -212,7 +214,7 @@ func newSecureChannel(endpoint string, c *uacp.Conn, cfg *Config, kind channelKi
 		return nil, errors.Errorf("invalid channel config: Security policy '%s' cannot be used with '%s'", cfg.SecurityPolicyURI, cfg.SecurityMode)
 	case cfg.SecurityPolicyURI != ua.SecurityPolicyURINone && (cfg.SecurityMode != ua.MessageSecurityModeSignAndEncrypt && cfg.SecurityMode != ua.MessageSecurityModeSign):
 		return nil, errors.Errorf("invalid channel config: Security policy '%s' can only be used with '%s' or '%s'", cfg.SecurityPolicyURI, ua.MessageSecurityModeSign, ua.MessageSecurityModeSignAndEncrypt)
-	case cfg.SecurityPolicyURI != ua.SecurityPolicyURINone && cfg.LocalKey == nil:
+	case cfg.SecurityPolicyURI != ua.SecurityPolicyURINone && cfg.privateKey() == nil:
 		return nil, errors.Errorf("invalid channel config: Security policy '%s' requires a private key", cfg.SecurityPolicyURI)
 	}
 
@@ -281,6 +283,15 @@ func (s *SecureChannel) dispatcher() {
 				return
 			}
 
+			// the remote side closes the connection after an error
+			// message which is the response to all pending requests.
+			var uacperr *uacp.Error
+			if errors.As(msg.Err, &uacperr) {
+				debug.Printf("uasc %d: err: %v", s.c.ID(), msg.Err)
+				s.failHandlers(msg.Err)
+				return
+			}
+
 			if msg.Err != nil {
 				debug.Printf("uasc %d/%d: err: %v", s.c.ID(), msg.RequestID, msg.Err)
 			} else {
@@ -437,14 +448,14 @@ func (s *SecureChannel) Receive(ctx context.Context) *MessageBody {
 func (s *SecureChannel) readChunk() (*MessageChunk, error) {
 	// read a full message from the underlying conn.
 	b, err := s.c.Receive()
-	if err == io.EOF || len(b) == 0 {
-		return nil, io.EOF
-	}
 	// do not wrap this error since it hides conn error
 	var uacperr *uacp.Error
 	if errors.As(err, &uacperr) {
 		return nil, err
 	}
+	if err == io.EOF || len(b) == 0 {
+		return nil, io.EOF
+	}
 	if err != nil {
 		return nil, errors.Errorf("sechan: read header failed: %s %#v", err, err)
 	}
@@ -490,11 +501,11 @@ func (s *SecureChannel) readChunk() (*MessageChunk, error) {
 			if err != nil {
 				return nil, err
 			}
-			remoteKey, ok := remoteCert.PublicKey.(*rsa.PublicKey)
-			if !ok {
-				return nil, ua.StatusBadCertificateInvalid
+			remoteKey, err := publicKey(remoteCert)
+			if err != nil {
+				return nil, err
 			}
-			algo, err := uapolicy.Asymmetric(s.cfg.SecurityPolicyURI, s.openingInstance.sc.cfg.LocalKey, remoteKey)
+			algo, err := uapolicy.AsymmetricKeys(s.cfg.SecurityPolicyURI, s.openingInstance.sc.cfg.privateKey(), remoteKey)
 			if err != nil {
 				return nil, err
 			}
@@ -569,6 +580,22 @@ func (s *SecureChannel) getInstancesBySecureChannelID(id uint32) []*channelInsta
 	return instances
 }
 
+// SecurityPolicyURI returns the uri of the security policy of the channel.
+func (s *SecureChannel) SecurityPolicyURI() string {
+	return s.cfg.SecurityPolicyURI
+}
+
+// SecurityMode returns the message security mode of the channel.
+func (s *SecureChannel) SecurityMode() ua.MessageSecurityMode {
+	return s.cfg.SecurityMode
+}
+
+// RemoteCertificate returns the certificate of the remote side of the
+// channel. It is empty if the channel is not secured.
+func (s *SecureChannel) RemoteCertificate() []byte {
+	return s.cfg.RemoteCertificate
+}
+
 func (s *SecureChannel) LocalEndpoint() string {
 	return s.endpointURL
 }
@@ -590,8 +617,8 @@ func (s *SecureChannel) open(ctx context.Context, instance *channelInstance, req
 
 	var (
 		err       error
-		localKey  *rsa.PrivateKey
-		remoteKey *rsa.PublicKey
+		localKey  crypto.PrivateKey
+		remoteKey crypto.PublicKey
 	)
 
 	s.startDispatcher.Do(func() {
@@ -604,20 +631,19 @@ func (s *SecureChannel) open(ctx context.Context, instance *channelInstance, req
 	// The default value of the encryption algorithm method is the
 	// SecurityModeNone so no additional work is required for that case
 	if s.cfg.SecurityMode != ua.MessageSecurityModeNone {
-		localKey = s.cfg.LocalKey
+		localKey = s.cfg.privateKey()
 		// todo(dh): move this into the uapolicy package proper or
 		// adjust the Asymmetric method to receive a certificate instead
 		remoteCert, err := uapolicy.ParseCertificate(s.cfg.RemoteCertificate)
 		if err != nil {
 			return err
 		}
-		var ok bool
-		if remoteKey, ok = remoteCert.PublicKey.(*rsa.PublicKey); !ok {
-			return ua.StatusBadCertificateInvalid
+		if remoteKey, err = publicKey(remoteCert); err != nil {
+			return err
 		}
 	}
 
-	algo, err := uapolicy.Asymmetric(s.cfg.SecurityPolicyURI, localKey, remoteKey)
+	algo, err := uapolicy.AsymmetricKeys(s.cfg.SecurityPolicyURI, localKey, remoteKey)
 	if err != nil {
 		return err
 	}
@@ -648,7 +674,7 @@ func (s *SecureChannel) open(ctx context.Context, instance *channelInstance, req
 	s.openingInstance.algo = algo
 	s.openingInstance.SetMaximumBodySize(int(s.c.SendBufSize()))
 
-	localNonce, err := algo.MakeNonce()
+	localNonce, ephemeralKey, err := s.newNonce(algo)
 	if err != nil {
 		return err
 	}
@@ -667,11 +693,46 @@ func (s *SecureChannel) open(ctx context.Context, instance *channelInstance, req
 		if !ok {
 			return errors.Errorf("got %T, want OpenSecureChannelResponse", v)
 		}
-		return s.handleOpenSecureChannelResponse(resp, localNonce, s.openingInstance)
+		return s.handleOpenSecureChannelResponse(resp, localNonce, ephemeralKey, s.openingInstance)
 	})
 }
 
-func (s *SecureChannel) handleOpenSecureChannelResponse(resp *ua.OpenSecureChannelResponse, localNonce []byte, instance *channelInstance) (err error) {
+// newNonce returns the nonce of this side of the secure channel for
+// OpenSecureChannel. The nonce of the ECC security policies is the public
+// key of an ephemeral key which is returned as well.
+func (s *SecureChannel) newNonce(algo *uapolicy.EncryptionAlgorithm) ([]byte, *uapolicy.EphemeralKey, error) {
+	if !uapolicy.IsECC(s.cfg.SecurityPolicyURI) {
+		nonce, err := algo.MakeNonce()
+		return nonce, nil, err
+	}
+	key, err := uapolicy.NewEphemeralKey(s.cfg.SecurityPolicyURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key.Nonce(), key, nil
+}
+
+// symmetric returns the symmetric encryption algorithm for the nonces of
+// both sides of the secure channel. ephemeralKey is the ephemeral key of
+// the local nonce of the ECC security policies.
+func (s *SecureChannel) symmetric(localNonce []byte, ephemeralKey *uapolicy.EphemeralKey, remoteNonce []byte) (*uapolicy.EncryptionAlgorithm, error) {
+	if ephemeralKey != nil {
+		return uapolicy.SymmetricECC(ephemeralKey, remoteNonce, s.kind == client)
+	}
+	return uapolicy.Symmetric(s.cfg.SecurityPolicyURI, localNonce, remoteNonce)
+}
+
+// publicKey returns the RSA or ECDSA public key of the certificate.
+func publicKey(cert *x509.Certificate) (crypto.PublicKey, error) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	default:
+		return nil, ua.StatusBadCertificateInvalid
+	}
+}
+
+func (s *SecureChannel) handleOpenSecureChannelResponse(resp *ua.OpenSecureChannelResponse, localNonce []byte, ephemeralKey *uapolicy.EphemeralKey, instance *channelInstance) (err error) {
 	debug.Printf("sc.handleOpenSecureChannelResponse")
 	instance.state = channelActive
 	instance.secureChannelID = resp.SecurityToken.ChannelID
@@ -684,7 +745,7 @@ func (s *SecureChannel) handleOpenSecureChannelResponse(resp *ua.OpenSecureChann
 		instance.revisedLifetime = time.Millisecond * time.Duration(s.cfg.Lifetime)
 	}
 
-	if instance.algo, err = uapolicy.Symmetric(s.cfg.SecurityPolicyURI, localNonce, resp.ServerNonce); err != nil {
+	if instance.algo, err = s.symmetric(localNonce, ephemeralKey, resp.ServerNonce); err != nil {
 		return err
 	}
 
@@ -753,8 +814,8 @@ func (s *SecureChannel) handleOpenSecureChannelRequest(reqID uint32, svc ua.Requ
 	// decrypt the thing before you even know you have an open message.
 	// so this is redundant.
 	var (
-		localKey  *rsa.PrivateKey
-		remoteKey *rsa.PublicKey
+		localKey  crypto.PrivateKey
+		remoteKey crypto.PublicKey
 	)
 
 	// Set the encryption methods to Asymmetric with the appropriate
@@ -763,20 +824,29 @@ func (s *SecureChannel) handleOpenSecureChannelRequest(reqID uint32, svc ua.Requ
 	// The default value of the encryption algorithm method is the
 	// SecurityModeNone so no additional work is required for that case
 	if s.cfg.SecurityMode != ua.MessageSecurityModeNone {
-		localKey = s.cfg.LocalKey
+		localKey = s.cfg.privateKey()
 		// todo(dh): move this into the uapolicy package proper or
 		// adjust the Asymmetric method to receive a certificate instead
 		remoteCert, err := uapolicy.ParseCertificate(s.cfg.RemoteCertificate)
 		if err != nil {
 			return err
 		}
-		var ok bool
-		if remoteKey, ok = remoteCert.PublicKey.(*rsa.PublicKey); !ok {
-			return ua.StatusBadCertificateInvalid
+		if remoteKey, err = publicKey(remoteCert); err != nil {
+			return err
+		}
+		if s.cfg.VerifyCertificate != nil {
+			if err := s.cfg.VerifyCertificate(s.cfg.RemoteCertificate); err != nil {
+				code, ok := err.(ua.StatusCode)
+				if !ok {
+					code = ua.StatusBadSecurityChecksFailed
+				}
+				s.c.SendError(code)
+				return err
+			}
 		}
 	}
 
-	algo, err := uapolicy.Asymmetric(s.cfg.SecurityPolicyURI, localKey, remoteKey)
+	algo, err := uapolicy.AsymmetricKeys(s.cfg.SecurityPolicyURI, localKey, remoteKey)
 	if err != nil {
 		return err
 	}
@@ -785,8 +855,14 @@ func (s *SecureChannel) handleOpenSecureChannelRequest(reqID uint32, svc ua.Requ
 	instance.algo = algo
 	instance.sc.requestID = req.RequestHeader.RequestHandle // todo(fs): is this correct?
 
-	nonce := make([]byte, instance.algo.NonceLength())
-	if _, err := rand.Read(nonce); err != nil {
+	nonce, ephemeralKey, err := s.newNonce(instance.algo)
+	if err != nil {
+		return err
+	}
+	// derive the keys before the response so that an invalid client
+	// nonce fails the request.
+	symmetric, err := s.symmetric(nonce, ephemeralKey, req.ClientNonce)
+	if err != nil {
 		return err
 	}
 	resp := &ua.OpenSecureChannelResponse{
@@ -812,10 +888,7 @@ func (s *SecureChannel) handleOpenSecureChannelRequest(reqID uint32, svc ua.Requ
 		return err
 	}
 
-	instance.algo, err = uapolicy.Symmetric(s.cfg.SecurityPolicyURI, nonce, req.ClientNonce)
-	if err != nil {
-		return err
-	}
+	instance.algo = symmetric
 	instance.SetMaximumBodySize(int(s.c.SendBufSize()))
 
 	instance.state = channelActive // todo(fs): is this correct?
@@ -962,6 +1035,21 @@ func (s *SecureChannel) popHandler(reqID uint32) (chan *MessageBody, bool) {
 	return ch, ok
 }
 
+// failHandlers sends the error to all registered callback channels and
+// removes them.
+func (s *SecureChannel) failHandlers(err error) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+
+	for reqID, ch := range s.handlers {
+		select {
+		case ch <- &MessageBody{RequestID: reqID, Err: err}:
+		default:
+		}
+		delete(s.handlers, reqID)
+	}
+}
+
 func (s *SecureChannel) Renew(ctx context.Context) error {
 	instance, err := s.getActiveChannelInstance()
 	if err != nil {
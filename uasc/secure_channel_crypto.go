@@ -5,9 +5,11 @@
 package uasc
 
 import (
+	"bytes"
 	"crypto/rsa"
 	"encoding/binary"
 
+	"github.com/gopcua/opcua/errors"
 	"github.com/gopcua/opcua/ua"
 	"github.com/gopcua/opcua/uapolicy"
 )
@@ -22,9 +24,12 @@ func (s *SecureChannel) NewSessionSignature(cert, nonce []byte) ([]byte, string,
 	if err != nil {
 		return nil, "", err
 	}
-	remoteKey := remoteX509Cert.PublicKey.(*rsa.PublicKey)
+	remoteKey, err := publicKey(remoteX509Cert)
+	if err != nil {
+		return nil, "", err
+	}
 
-	enc, err := uapolicy.Asymmetric(s.cfg.SecurityPolicyURI, s.cfg.LocalKey, remoteKey)
+	enc, err := uapolicy.AsymmetricKeys(s.cfg.SecurityPolicyURI, s.cfg.privateKey(), remoteKey)
 	if err != nil {
 		return nil, "", err
 	}
@@ -48,9 +53,12 @@ func (s *SecureChannel) VerifySessionSignature(cert, nonce, signature []byte) er
 	if err != nil {
 		return err
 	}
-	remoteKey := remoteX509Cert.PublicKey.(*rsa.PublicKey)
+	remoteKey, err := publicKey(remoteX509Cert)
+	if err != nil {
+		return err
+	}
 
-	enc, err := uapolicy.Asymmetric(s.cfg.SecurityPolicyURI, s.cfg.LocalKey, remoteKey)
+	enc, err := uapolicy.AsymmetricKeys(s.cfg.SecurityPolicyURI, s.cfg.privateKey(), remoteKey)
 	if err != nil {
 		return err
 	}
@@ -73,11 +81,18 @@ func (s *SecureChannel) EncryptUserPassword(policyURI, password string, cert, no
 		return []byte(password), "", nil
 	}
 
+	if uapolicy.IsECC(policyURI) {
+		return nil, "", errors.Errorf("security policy %s requires EncryptUserSecret", policyURI)
+	}
+
 	remoteX509Cert, err := uapolicy.ParseCertificate(cert)
 	if err != nil {
 		return nil, "", err
 	}
-	remoteKey := remoteX509Cert.PublicKey.(*rsa.PublicKey)
+	remoteKey, ok := remoteX509Cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, "", errors.Errorf("unsupported server key %T", remoteX509Cert.PublicKey)
+	}
 
 	enc, err := uapolicy.Asymmetric(policyURI, s.cfg.LocalKey, remoteKey)
 	if err != nil {
@@ -98,6 +113,121 @@ func (s *SecureChannel) EncryptUserPassword(policyURI, password string, cert, no
 	return pass, passAlg, nil
 }
 
+// DecryptUserPassword decrypts the password of a UserNameIdentityToken which was
+// encrypted with EncryptUserPassword. alg is the encryption algorithm of the token
+// and nonce is the last nonce the server sent to the client.
+// The security policy for the SecureChannel is used if policyURI value is null or empty
+func (s *SecureChannel) DecryptUserPassword(policyURI, alg string, secret, nonce []byte) ([]byte, error) {
+	if policyURI == "" {
+		policyURI = s.cfg.SecurityPolicyURI
+	}
+
+	if policyURI == ua.SecurityPolicyURINone {
+		if alg != "" {
+			return nil, errors.Errorf("unexpected encryption algorithm %s", alg)
+		}
+		return secret, nil
+	}
+
+	enc, err := uapolicy.Asymmetric(policyURI, s.cfg.LocalKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if alg != enc.EncryptionURI() {
+		return nil, errors.Errorf("invalid encryption algorithm %q for %s", alg, policyURI)
+	}
+
+	b, err := enc.Decrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 {
+		return nil, errors.New("user password too short")
+	}
+	l := int(binary.LittleEndian.Uint32(b))
+	if l != len(b)-4 || l < len(nonce) {
+		return nil, errors.New("invalid user password length")
+	}
+	pass, tail := b[4:len(b)-len(nonce)], b[len(b)-len(nonce):]
+	if !bytes.Equal(tail, nonce) {
+		return nil, errors.New("invalid user password nonce")
+	}
+	return pass, nil
+}
+
+// EncryptUserSecret encrypts the password or the token data of a user identity
+// token as an EccEncryptedSecret for the ECC security policies. cert is the
+// certificate of the server, key is the ephemeral key from the ECDHKey of the
+// last CreateSession or ActivateSession response and nonce is the last nonce
+// of the server.
+// The security policy for the SecureChannel is used if policyURI value is null or empty
+// https://reference.opcfoundation.org/Core/Part4/v105/docs/7.41.2.3
+func (s *SecureChannel) EncryptUserSecret(policyURI string, secret, cert []byte, key *ua.EphemeralKeyType, nonce []byte) ([]byte, error) {
+	if policyURI == "" {
+		policyURI = s.cfg.SecurityPolicyURI
+	}
+	if key == nil {
+		return nil, errors.Errorf("no ephemeral key of the server for %s", policyURI)
+	}
+
+	// the server signs its ephemeral key with the key of its certificate.
+	serverCert, err := uapolicy.ParseCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := uapolicy.AsymmetricKeys(policyURI, nil, serverCert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.VerifySignature(key.PublicKey, key.Signature); err != nil {
+		return nil, err
+	}
+
+	return uapolicy.EncryptSecret(policyURI, s.cfg.Certificate, s.cfg.LocalECKey, key.PublicKey, secret, nonce)
+}
+
+// NewEphemeralKey creates an ephemeral key of the ECC security policy for the
+// EccEncryptedSecrets of the user identity tokens. It returns the key and the
+// ECDHKey for the client whose public key is signed with the key of Certificate.
+func (s *SecureChannel) NewEphemeralKey(policyURI string) (*uapolicy.EphemeralKey, *ua.EphemeralKeyType, error) {
+	key, err := uapolicy.NewEphemeralKey(policyURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, err := uapolicy.AsymmetricKeys(policyURI, s.cfg.privateKey(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := enc.Signature(key.Nonce())
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &ua.EphemeralKeyType{PublicKey: key.Nonce(), Signature: sig}, nil
+}
+
+// DecryptUserSecret decrypts an EccEncryptedSecret which was created with
+// EncryptUserSecret. key is the ephemeral key which the server sent to the
+// client and nonce is the last nonce the server sent to the client. The
+// secret must be signed with the certificate of the client of the channel.
+// The security policy for the SecureChannel is used if policyURI value is null or empty
+func (s *SecureChannel) DecryptUserSecret(policyURI string, secret []byte, key *uapolicy.EphemeralKey, nonce []byte) ([]byte, error) {
+	if policyURI == "" {
+		policyURI = s.cfg.SecurityPolicyURI
+	}
+	if key == nil || key.SecurityPolicyURI() != policyURI {
+		return nil, errors.Errorf("no ephemeral key for %s", policyURI)
+	}
+
+	b, cert, err := uapolicy.DecryptSecret(key, secret, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(uapolicy.Thumbprint(cert), uapolicy.Thumbprint(s.cfg.RemoteCertificate)) {
+		return nil, errors.New("user secret is not signed by the client")
+	}
+	return b, nil
+}
+
 // NewUserTokenSignature issues a new signature for the client to send in ActivateSessionRequest
 // The security policy for the SecureChannel is used if policyURI value is null or empty
 // https://reference.opcfoundation.org/Core/Part4/v104/docs/7.37
@@ -114,7 +244,10 @@ func (s *SecureChannel) NewUserTokenSignature(policyURI string, cert, nonce []by
 	if err != nil {
 		return nil, "", err
 	}
-	remoteKey := remoteX509Cert.PublicKey.(*rsa.PublicKey)
+	remoteKey, ok := remoteX509Cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, "", errors.Errorf("unsupported server key %T", remoteX509Cert.PublicKey)
+	}
 
 	enc, err := uapolicy.Asymmetric(policyURI, s.cfg.UserKey, remoteKey)
 	if err != nil {
@@ -129,3 +262,35 @@ func (s *SecureChannel) NewUserTokenSignature(policyURI string, cert, nonce []by
 
 	return sig, sigAlg, nil
 }
+
+// VerifyUserTokenSignature checks the signature of an X509IdentityToken which was
+// created with NewUserTokenSignature. cert is the certificate of the user and nonce
+// is the last nonce the server sent to the client. Only user certificates
+// with RSA keys are supported.
+// The security policy for the SecureChannel is used if policyURI value is null or empty
+func (s *SecureChannel) VerifyUserTokenSignature(policyURI string, cert, nonce, signature []byte) error {
+	if policyURI == "" {
+		policyURI = s.cfg.SecurityPolicyURI
+	}
+
+	if policyURI == ua.SecurityPolicyURINone {
+		return nil
+	}
+
+	userCert, err := uapolicy.ParseCertificate(cert)
+	if err != nil {
+		return err
+	}
+	userKey, ok := userCert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.Errorf("unsupported user key %T", userCert.PublicKey)
+	}
+
+	enc, err := uapolicy.Asymmetric(policyURI, nil, userKey)
+	if err != nil {
+		return err
+	}
+
+	msg := append(append([]byte{}, s.cfg.Certificate...), nonce...)
+	return enc.VerifySignature(msg, signature)
+}
@@ -0,0 +1,65 @@
+package uasc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserPassword(t *testing.T) {
+	srvCert, srvKey := genThumbprintTestCert(t, "server")
+	nonce := bytes.Repeat([]byte{0x5a}, 32)
+
+	cli := &SecureChannel{cfg: &Config{SecurityPolicyURI: ua.SecurityPolicyURINone}}
+	srv := &SecureChannel{cfg: &Config{SecurityPolicyURI: ua.SecurityPolicyURINone, LocalKey: srvKey, Certificate: srvCert}}
+
+	for _, uri := range []string{
+		ua.SecurityPolicyURIBasic128Rsa15,
+		ua.SecurityPolicyURIBasic256,
+		ua.SecurityPolicyURIBasic256Sha256,
+		ua.SecurityPolicyURIAes128Sha256RsaOaep,
+		ua.SecurityPolicyURIAes256Sha256RsaPss,
+	} {
+		t.Run(uri, func(t *testing.T) {
+			secret, alg, err := cli.EncryptUserPassword(uri, "secret", srvCert, nonce)
+			require.NoError(t, err)
+
+			pass, err := srv.DecryptUserPassword(uri, alg, secret, nonce)
+			require.NoError(t, err)
+			require.Equal(t, []byte("secret"), pass)
+
+			_, err = srv.DecryptUserPassword(uri, alg, secret, bytes.Repeat([]byte{0xa5}, 32))
+			require.Error(t, err, "wrong nonce")
+
+			_, err = srv.DecryptUserPassword(uri, "", []byte("secret"), nonce)
+			require.Error(t, err, "plain password")
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		pass, err := srv.DecryptUserPassword(ua.SecurityPolicyURINone, "", []byte("secret"), nonce)
+		require.NoError(t, err)
+		require.Equal(t, []byte("secret"), pass)
+	})
+}
+
+func TestUserTokenSignature(t *testing.T) {
+	srvCert, srvKey := genThumbprintTestCert(t, "server")
+	userCert, userKey := genThumbprintTestCert(t, "user")
+	otherCert, _ := genThumbprintTestCert(t, "other")
+	nonce := bytes.Repeat([]byte{0x5a}, 32)
+	uri := ua.SecurityPolicyURIBasic256Sha256
+
+	cli := &SecureChannel{cfg: &Config{SecurityPolicyURI: ua.SecurityPolicyURINone, UserKey: userKey}}
+	srv := &SecureChannel{cfg: &Config{SecurityPolicyURI: ua.SecurityPolicyURINone, LocalKey: srvKey, Certificate: srvCert}}
+
+	sig, _, err := cli.NewUserTokenSignature(uri, srvCert, nonce)
+	require.NoError(t, err)
+
+	require.NoError(t, srv.VerifyUserTokenSignature(uri, userCert, nonce, sig))
+	require.Error(t, srv.VerifyUserTokenSignature(uri, otherCert, nonce, sig), "other certificate")
+	require.Error(t, srv.VerifyUserTokenSignature(uri, userCert, bytes.Repeat([]byte{0xa5}, 32), sig), "wrong nonce")
+	require.Error(t, srv.VerifyUserTokenSignature(uri, userCert, nonce, nil), "missing signature")
+}
@@ -73,6 +73,11 @@ func (c *channelInstance) newRequestMessage(req ua.Request, reqID uint32, authTo
 		Timestamp:           c.sc.timeNow(),
 		RequestHandle:       reqID, // TODO: can I cheat like this?
 	}
+	// keep the additional header of the caller, e.g. the request for an
+	// ephemeral key in CreateSession.
+	if h := req.Header(); h != nil {
+		reqHdr.AdditionalHeader = h.AdditionalHeader
+	}
 
 	if timeout > 0 && timeout < c.sc.cfg.RequestTimeout {
 		timeout = c.sc.cfg.RequestTimeout
@@ -167,6 +172,16 @@ func (c *channelInstance) SetMaximumBodySize(chunkSize int) {
 	c.maxBodySize = uint32(maxBodySize)
 }
 
+// encrypted returns true if a chunk is encrypted. Asymmetric chunks, i.e.
+// OpenSecureChannel messages, are always encrypted except for the ECC
+// security policies which only sign them (OPC UA Part 6 v1.05 §6.7.2).
+func (c *channelInstance) encrypted(isAsymmetric bool) bool {
+	if isAsymmetric {
+		return !uapolicy.IsECC(c.sc.cfg.SecurityPolicyURI)
+	}
+	return c.sc.cfg.SecurityMode == ua.MessageSecurityModeSignAndEncrypt
+}
+
 // signAndEncrypt encrypts the message bytes stored in b and returns the
 // data signed and encrypted per the security policy information from the
 // secure channel.
@@ -200,7 +215,7 @@ func (c *channelInstance) signAndEncrypt(m *Message, b []byte) ([]byte, error) {
 	}
 
 	var encryptedLength int
-	if c.sc.cfg.SecurityMode == ua.MessageSecurityModeSignAndEncrypt || isAsymmetric {
+	if c.encrypted(isAsymmetric) {
 		plaintextBlockSize := c.algo.PlaintextBlockSize()
 		extraPadding := c.algo.RemoteSignatureLength() > 256
 		paddingBytes := 1
@@ -241,7 +256,7 @@ func (c *channelInstance) signAndEncrypt(m *Message, b []byte) ([]byte, error) {
 
 	b = append(b, signature...)
 	p := b[headerLength:]
-	if c.sc.cfg.SecurityMode == ua.MessageSecurityModeSignAndEncrypt || isAsymmetric {
+	if c.encrypted(isAsymmetric) {
 		p, err = c.algo.Encrypt(p)
 		if err != nil {
 			return nil, ua.StatusBadSecurityChecksFailed
@@ -253,7 +268,7 @@ func (c *channelInstance) signAndEncrypt(m *Message, b []byte) ([]byte, error) {
 // verifyAndDecrypt verifies and optionally decrypts an incoming chunk.
 //
 // An asymmetric OpenSecureChannel under a real security policy is always signed
-// and encrypted, even while the channel's SecurityMode still reads None during
+// and, except for the ECC policies, encrypted, even while the channel's SecurityMode still reads None during
 // the handshake (OPC UA Part 6 v1.05 §6.7.4). The SecurityMode==None case below
 // therefore returns raw only for a genuinely unsecured chunk: the explicit #None
 // policy, or any symmetric message regardless of policy. This carve-out is the
@@ -280,7 +295,7 @@ func (c *channelInstance) verifyAndDecrypt(m *MessageChunk, r []byte) ([]byte, e
 	b := make([]byte, len(r))
 	copy(b, r)
 
-	if c.sc.cfg.SecurityMode == ua.MessageSecurityModeSignAndEncrypt || isAsymmetric {
+	if c.encrypted(isAsymmetric) {
 		p, err := c.algo.Decrypt(b[headerLength:])
 		if err != nil {
 			return nil, ua.StatusBadSecurityChecksFailed
@@ -296,7 +311,7 @@ func (c *channelInstance) verifyAndDecrypt(m *MessageChunk, r []byte) ([]byte, e
 	}
 
 	var paddingLength int
-	if c.sc.cfg.SecurityMode == ua.MessageSecurityModeSignAndEncrypt || isAsymmetric {
+	if c.encrypted(isAsymmetric) {
 		paddingLength = int(messageToVerify[len(messageToVerify)-1])
 		if c.algo.SignatureLength() > 256 {
 			paddingLength <<= 8